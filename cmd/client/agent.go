@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/agent"
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/tui"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// defaultAgentTimeout locks the agent after this long without requests
+const defaultAgentTimeout = 15 * time.Minute
+
+// agentOptions holds the flags of the agent command
+type agentOptions struct {
+	socket     string
+	timeout    time.Duration
+	foreground bool
+}
+
+func parseAgentArgs(args []string) (agentOptions, error) {
+	opts := agentOptions{socket: agent.DefaultSocketPath(), timeout: defaultAgentTimeout}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--foreground", "-f":
+			opts.foreground = true
+			continue
+		case "--socket", "--timeout", "-t":
+		default:
+			return opts, fmt.Errorf("unknown option: %s", arg)
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			value = args[i]
+		}
+
+		if name == "--socket" {
+			opts.socket = value
+			continue
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return opts, fmt.Errorf("invalid timeout: %s", value)
+		}
+		opts.timeout = timeout
+	}
+
+	return opts, nil
+}
+
+func handleAgent() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showAgentHelp()
+		return
+	}
+
+	opts, err := parseAgentArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass agent help' for usage")
+		os.Exit(exitError)
+	}
+
+	session := unlockVault()
+	defer session.Close()
+
+	if !opts.foreground {
+		pid, err := agent.Start(session, opts.socket, opts.timeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+			os.Exit(exitError)
+		}
+		printAgentEnv(opts.socket, pid)
+		return
+	}
+
+	server, err := agent.Listen(session, opts.socket, opts.timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	printAgentEnv(opts.socket, os.Getpid())
+
+	if err := server.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	fmt.Fprintln(os.Stderr, tui.ColorInfo("Agent locked"))
+}
+
+// printAgentEnv prints the shell commands that point the CLI at the agent,
+// for use with eval like ssh-agent's output
+func printAgentEnv(socket string, pid int) {
+	fmt.Printf("%s=%s; export %s;\n", agent.EnvSocket, shellQuote(socket), agent.EnvSocket)
+	fmt.Printf("echo Agent pid %d;\n", pid)
+}
+
+// shellQuote quotes a value for POSIX shells when it needs it
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`;&|<>()*?[]#~!{}") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func handleLock() {
+	revoked, err := revokeCachedKey()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: failed to revoke the cached key: %v\n", err)))
+		os.Exit(exitError)
+	}
+	if revoked {
+		fmt.Fprintln(os.Stderr, tui.ColorSuccess("✓ Cached key revoked"))
+	}
+
+	client, err := agent.Dial(agent.SocketPath())
+	if err != nil {
+		if !revoked {
+			fmt.Fprintln(os.Stderr, tui.ColorInfo("No agent is running and no key is cached"))
+		}
+		return
+	}
+	defer client.Close()
+
+	if err := client.Lock(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	fmt.Fprintln(os.Stderr, tui.ColorSuccess("✓ Agent locked"))
+}
+
+// openStore returns the agent when OPENPASSWD_AGENT_SOCK points at one that
+// answers, and an unlocked session otherwise
+func openStore() vault.Store {
+	if client := dialAgent(); client != nil {
+		return client
+	}
+	return unlockVault()
+}
+
+// dialAgent connects to the agent OPENPASSWD_AGENT_SOCK points at, or
+// returns nil when it isn't set or nothing answers
+func dialAgent() *agent.Client {
+	path := os.Getenv(agent.EnvSocket)
+	if path == "" {
+		return nil
+	}
+	client, err := agent.Dial(path)
+	if err != nil {
+		return nil
+	}
+	return client
+}
+
+// unlockFromAgent unlocks a local session with the key of the agent
+// OPENPASSWD_AGENT_SOCK points at, for commands that work on the database
+// directly. It reports whether that worked.
+func unlockFromAgent(session *vault.Session) bool {
+	client := dialAgent()
+	if client == nil {
+		return false
+	}
+	defer client.Close()
+
+	key, err := client.Key()
+	if err != nil {
+		return false
+	}
+	defer crypto.WipeMemory(key)
+
+	return session.UnlockWithKey(key) == nil
+}
+
+func showAgentHelp() {
+	help := `OpenPasswd - Agent
+
+The agent keeps the vault unlocked in the background so get, show, ls, add and
+list don't ask for the passphrase or run the key derivation every time. The key is
+held in locked memory and served over a Unix socket that only your user can
+connect to.
+
+COMMANDS:
+    openpass agent [options]    Unlock the vault and start the agent
+    openpass lock               Wipe the key and stop the agent (and drop a
+                                key cached in the kernel keyring)
+
+OPTIONS:
+    --timeout, -t <duration>    Lock after this long without requests
+                                (default 15m, 0 to never lock)
+    --socket <path>             Socket path (default under $XDG_RUNTIME_DIR)
+    --foreground, -f            Stay in the foreground instead of detaching
+
+The agent prints the shell commands that set OPENPASSWD_AGENT_SOCK; commands
+only use the agent while it is set.
+
+EXAMPLES:
+    eval "$(openpass agent)"
+    openpass agent --timeout 1h
+    openpass lock
+`
+	fmt.Println(help)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/dockercred"
+	"github.com/r2unit/openpasswd/pkg/version"
+)
+
+// isDockerCredentialHelper reports whether the binary was run as
+// docker-credential-openpasswd, e.g. through a symlink
+func isDockerCredentialHelper() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == dockercred.BinaryName
+}
+
+func handleDockerCredential() {
+	if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		showDockerCredentialHelp()
+		return
+	}
+	runDockerCredential(os.Args[2])
+}
+
+// runDockerCredential runs one operation of the Docker credential helper
+// protocol. Errors go to stdout, where Docker reads them from.
+func runDockerCredential(op string) {
+	if err := dockerCredential(op); err != nil {
+		fmt.Fprintln(os.Stdout, err)
+		os.Exit(exitError)
+	}
+}
+
+func dockerCredential(op string) error {
+	switch op {
+	case dockercred.OpVersion:
+		fmt.Printf("%s (openpasswd) %s\n", dockercred.BinaryName, version.Version)
+		return nil
+
+	case dockercred.OpGet, dockercred.OpErase:
+		serverURL, err := dockercred.ReadServerURL(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		store := openCredentialStore()
+		defer store.Close()
+		items := loadItems(store)
+
+		if op == dockercred.OpErase {
+			return dockercred.Erase(store, items, serverURL)
+		}
+		creds, err := dockercred.Get(items, serverURL)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(creds)
+
+	case dockercred.OpStore:
+		creds, err := dockercred.ReadCredentials(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		store := openCredentialStore()
+		defer store.Close()
+		return dockercred.Store(store, loadItems(store), creds)
+
+	case dockercred.OpList:
+		store := openCredentialStore()
+		defer store.Close()
+		return json.NewEncoder(os.Stdout).Encode(dockercred.List(loadItems(store)))
+
+	default:
+		return errors.New("unknown credential helper operation: " + op)
+	}
+}
+
+func showDockerCredentialHelp() {
+	help := `OpenPasswd - Docker Credential Helper
+
+Keeps container registry logins in the vault as "registry" entries, which
+the list hides and 'openpass ls' only shows with --all. The helper speaks
+Docker's credential helper protocol: get, store, erase and list, with JSON
+on stdin and stdout.
+
+COMMANDS:
+    openpass docker-credential get|store|erase|list|version
+
+SETUP:
+    Docker runs docker-credential-<name> from the PATH, so link the binary
+    under that name and select it in ~/.docker/config.json:
+
+    ln -s "$(command -v openpass)" ~/.local/bin/docker-credential-openpasswd
+    { "credsStore": "openpasswd" }
+
+    In CI, unlock with OPENPASSWD_PASSPHRASE_COMMAND or a running agent
+    (OPENPASSWD_AGENT_SOCK); otherwise the passphrase is asked for on the
+    terminal.
+
+EXAMPLES:
+    echo ghcr.io | openpass docker-credential get
+    openpass docker-credential list
+    openpass ls --all
+`
+	fmt.Println(help)
+}
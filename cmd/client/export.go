@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/backup"
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/export"
+	"github.com/r2unit/openpasswd/pkg/tui"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// formatBackup is the encrypted export format, restorable with 'openpass restore'
+const formatBackup = "openpasswd-backup"
+
+// exportOptions holds the flags of the export command
+type exportOptions struct {
+	format      string
+	output      string
+	iUnderstand bool
+}
+
+func parseExportArgs(args []string) (exportOptions, error) {
+	opts := exportOptions{format: formatBackup}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--format" || arg == "-f":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a format", arg)
+			}
+			i++
+			opts.format = args[i]
+		case strings.HasPrefix(arg, "--format="):
+			opts.format = strings.TrimPrefix(arg, "--format=")
+		case arg == "--output" || arg == "-o":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a file name", arg)
+			}
+			i++
+			opts.output = args[i]
+		case strings.HasPrefix(arg, "--output="):
+			opts.output = strings.TrimPrefix(arg, "--output=")
+		case arg == "--i-understand":
+			opts.iUnderstand = true
+		default:
+			return opts, fmt.Errorf("unknown option: %s", arg)
+		}
+	}
+
+	if opts.format != formatBackup && !slices.Contains(export.Formats, opts.format) {
+		return opts, fmt.Errorf("unknown export format: %s", opts.format)
+	}
+
+	return opts, nil
+}
+
+func handleExport() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showExportHelp()
+		return
+	}
+
+	opts, err := parseExportArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass export help' for usage")
+		os.Exit(exitError)
+	}
+
+	plaintext := opts.format != formatBackup
+	if plaintext && !opts.iUnderstand {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: the %s format writes every secret unencrypted\n", opts.format)))
+		fmt.Fprintln(os.Stderr, "Pass --i-understand to export anyway, or use --format openpasswd-backup")
+		os.Exit(exitError)
+	}
+
+	session, passphrase := unlockVaultWithPassphrase()
+	defer session.Close()
+	defer passphrase.Wipe()
+
+	if plaintext {
+		if err := confirmPassphrase(session); err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+			os.Exit(exitError)
+		}
+	}
+
+	items := loadItems(session)
+	toFile := opts.output != "" && opts.output != "-"
+
+	// Render the export in memory, so a file is only created once it's
+	// complete and always ends up with private permissions
+	var buf bytes.Buffer
+	if plaintext {
+		err = export.Write(&buf, opts.format, items)
+	} else {
+		var plain string
+		if plain, err = passphrase.Get(); err == nil {
+			err = backup.Write(&buf, items, plain)
+		}
+	}
+	if err == nil {
+		if toFile {
+			err = writePrivateFile(opts.output, buf.Bytes())
+		} else {
+			_, err = os.Stdout.Write(buf.Bytes())
+		}
+	}
+	crypto.WipeMemory(buf.Bytes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error writing export: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	if toFile {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorSuccess(fmt.Sprintf("✓ Exported %d entries to %s\n", len(items), opts.output)))
+	}
+}
+
+// confirmPassphrase asks for the master passphrase again on the terminal.
+// Plaintext exports can't be confirmed through --passphrase-fd and friends so
+// a script can't dump the vault unnoticed.
+func confirmPassphrase(session *vault.Session) error {
+	if !stdinIsTerminal() {
+		return errors.New("plaintext export must be confirmed on a terminal")
+	}
+
+	fmt.Fprintln(os.Stderr, tui.ColorWarning("⚠ The export will contain every secret in plaintext"))
+	typed, err := promptPassword("Re-enter master passphrase to confirm", false)
+	if err != nil {
+		return err
+	}
+
+	if !session.CheckPassphrase(typed) {
+		return errors.New("passphrases do not match")
+	}
+	return nil
+}
+
+func handleRestore() {
+	if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		showExportHelp()
+		return
+	}
+
+	file, err := os.Open(os.Args[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	defer file.Close()
+
+	session, passphrase := unlockVaultWithPassphrase()
+	defer session.Close()
+	defer passphrase.Wipe()
+
+	// Backups of this vault use the master passphrase, so try that first
+	plain, err := passphrase.Get()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	items, err := backup.Read(file, plain)
+
+	if errors.Is(err, backup.ErrDecrypt) && stdinIsTerminal() {
+		var other string
+		other, err = promptPassword("Enter backup passphrase", false)
+		if err == nil {
+			if _, err = file.Seek(0, io.SeekStart); err == nil {
+				items, err = backup.Read(file, other)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	restored, err := restoreItems(session, items)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v (restored %d of %d entries)\n", err, restored, len(items))))
+		os.Exit(exitError)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s", tui.ColorSuccess(fmt.Sprintf("✓ Restored %d entries\n", restored)))
+}
+
+// restoreItems re-encrypts backed-up items with the vault key and adds them
+// as new entries
+func restoreItems(session *vault.Session, items []*vault.Item) (int, error) {
+	encryptor, err := session.Encryptor()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, item := range items {
+		p, err := vault.Encrypt(encryptor, item)
+		if err != nil {
+			return i, fmt.Errorf("failed to encrypt %q: %w", item.Name, err)
+		}
+		if err := session.DB.AddPassword(p); err != nil {
+			return i, fmt.Errorf("failed to save %q: %w", item.Name, err)
+		}
+	}
+	return len(items), nil
+}
+
+func showExportHelp() {
+	help := `OpenPasswd - Export and Restore
+
+COMMANDS:
+    openpass export [--format <format>] [--output <file>]   Export the vault
+    openpass restore <file>                                  Restore an encrypted backup
+
+FORMATS:
+    openpasswd-backup    Encrypted backup (default), restorable into any vault
+    json                 Plaintext JSON, same layout as 'openpass show --json'
+    csv                  Plaintext CSV (type,name,username,password,url,notes,totp,fields)
+    bitwarden-json       Plaintext Bitwarden JSON, importable into Bitwarden/Vaultwarden
+    keepass-xml          Plaintext KeePass 2.x XML, importable into KeePass/KeePassXC
+
+OPTIONS:
+    --format, -f <format>    Export format (see above)
+    --output, -o <file>      Write to a file (mode 0600) instead of stdout
+    --i-understand           Required for plaintext formats
+
+BACKUPS:
+    A backup is encrypted with your master passphrase and carries its own
+    salt and KDF parameters, so it can be restored after 'openpass init' on a
+    new machine. If the backup passphrase differs from the current master
+    passphrase, restore asks for it. Restored entries are added as new entries.
+
+PLAINTEXT EXPORTS:
+    Plaintext formats need --i-understand and the master passphrase typed a
+    second time on the terminal. Delete the file once you're done with it.
+
+EXAMPLES:
+    openpass export -o vault.backup
+    openpass restore vault.backup
+    openpass export --format bitwarden-json --i-understand -o bitwarden.json
+`
+	fmt.Println(help)
+}
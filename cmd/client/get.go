@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/tui"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// Exit codes of the scriptable commands (get, show, ls)
+const (
+	exitError     = 1
+	exitNoMatch   = 2
+	exitAmbiguous = 3
+)
+
+// lookupOptions holds the flags shared by get, show and ls
+type lookupOptions struct {
+	query     string
+	field     string
+	mode      vault.MatchMode
+	json      bool
+	noNewline bool
+	all       bool
+}
+
+// parseLookupArgs parses the arguments following a scriptable command
+func parseLookupArgs(args []string) (lookupOptions, error) {
+	var opts lookupOptions
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--field" || arg == "-f":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			opts.field = args[i]
+		case strings.HasPrefix(arg, "--field="):
+			opts.field = strings.TrimPrefix(arg, "--field=")
+		case arg == "--exact" || arg == "-e":
+			opts.mode = vault.MatchExact
+		case arg == "--fuzzy" || arg == "-z":
+			opts.mode = vault.MatchFuzzy
+		case arg == "--json":
+			opts.json = true
+		case arg == "--no-newline" || arg == "-n":
+			opts.noNewline = true
+		case arg == "--all" || arg == "-a":
+			opts.all = true
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return opts, fmt.Errorf("unknown flag: %s", arg)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) > 1 {
+		return opts, fmt.Errorf("expected one name or ID, got %d", len(positional))
+	}
+	if len(positional) == 1 {
+		opts.query = positional[0]
+	}
+
+	return opts, nil
+}
+
+// unlockVault opens and unlocks the vault for scripted use, with the cached
+// key if there is one. Prompts go to stderr so stdout only carries the
+// requested data.
+func unlockVault() *vault.Session {
+	session := openVault()
+	if unlockFromKeyCache(session) {
+		return session
+	}
+
+	unlockWithPrompt(session).Wipe()
+	return session
+}
+
+// unlockVaultWithPassphrase is unlockVault for commands that need the master
+// passphrase itself afterwards; the caller must wipe it
+func unlockVaultWithPassphrase() (*vault.Session, *crypto.SecureString) {
+	session := openVault()
+	return session, unlockWithPrompt(session)
+}
+
+// unlockWithPrompt asks for the passphrase and second factor, unlocks the
+// session and caches the key when the key cache is enabled
+func unlockWithPrompt(session *vault.Session) *crypto.SecureString {
+	passphrase, err := readMasterPassphrase("Enter master passphrase", true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error reading passphrase: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	if err := unlockSession(session, passphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	if err := verifySecondFactor(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	cacheKey(session)
+	return passphrase
+}
+
+// openVault opens the configured vault, still locked
+func openVault() *vault.Session {
+	session, err := vault.Open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !isInitialized() {
+			fmt.Fprintln(os.Stderr, "\nRun 'openpass init' to initialize the password manager")
+		}
+		os.Exit(exitError)
+	}
+	return session
+}
+
+// unlockSession unlocks the session with a passphrase read by
+// readMasterPassphrase
+func unlockSession(session *vault.Session, passphrase *crypto.SecureString) error {
+	plain, err := passphrase.Get()
+	if err != nil {
+		return err
+	}
+	return session.Unlock(plain)
+}
+
+// loadItems decrypts every entry in the vault
+func loadItems(store vault.Store) []*vault.Item {
+	items, err := store.Items()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	return items
+}
+
+// findItem resolves a query to a single entry, exiting with a distinct code
+// when nothing or more than one entry matches
+func findItem(store vault.Store, opts lookupOptions) *vault.Item {
+	item, err := store.Find(opts.query, opts.mode)
+	if err == nil {
+		return item
+	}
+
+	fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+
+	var ambiguous *vault.AmbiguousError
+	switch {
+	case errors.Is(err, vault.ErrNoMatch):
+		os.Exit(exitNoMatch)
+	case errors.As(err, &ambiguous):
+		fmt.Fprintln(os.Stderr, tui.ColorInfo("Use the entry ID or --exact to pick one"))
+		os.Exit(exitAmbiguous)
+	}
+	os.Exit(exitError)
+	return nil
+}
+
+func handleGet() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showGetHelp()
+		return
+	}
+
+	opts, err := parseLookupArgs(os.Args[2:])
+	if err == nil && opts.query == "" {
+		err = fmt.Errorf("missing entry name or ID")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass get help' for usage")
+		os.Exit(exitError)
+	}
+
+	store := openStore()
+	defer store.Close()
+
+	item := findItem(store, opts)
+
+	value, err := vault.FieldValue(store, item, opts.field)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	if opts.noNewline {
+		fmt.Print(value)
+	} else {
+		fmt.Println(value)
+	}
+}
+
+func handleShow() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showGetHelp()
+		return
+	}
+
+	opts, err := parseLookupArgs(os.Args[2:])
+	if err == nil && opts.query == "" {
+		err = fmt.Errorf("missing entry name or ID")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass get help' for usage")
+		os.Exit(exitError)
+	}
+
+	store := openStore()
+	defer store.Close()
+
+	item := findItem(store, opts)
+
+	if opts.json {
+		writeJSON(item)
+		return
+	}
+
+	fmt.Printf("ID:       %d\n", item.ID)
+	fmt.Printf("Type:     %s\n", item.Type)
+	fmt.Printf("Name:     %s\n", item.Name)
+	if item.Username != "" {
+		fmt.Printf("Username: %s\n", item.Username)
+	}
+	if item.Password != "" {
+		fmt.Printf("Password: %s\n", item.Password)
+	}
+	if item.URL != "" {
+		fmt.Printf("URL:      %s\n", item.URL)
+	}
+	keys := make([]string, 0, len(item.Fields))
+	for key := range item.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("%s: %s\n", key, item.Fields[key])
+	}
+	if item.Notes != "" {
+		fmt.Printf("Notes:    %s\n", item.Notes)
+	}
+	fmt.Printf("Created:  %s\n", item.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Updated:  %s\n", item.UpdatedAt.Format("2006-01-02 15:04:05"))
+}
+
+func handleLs() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showGetHelp()
+		return
+	}
+
+	opts, err := parseLookupArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass get help' for usage")
+		os.Exit(exitError)
+	}
+
+	store := openStore()
+	defer store.Close()
+
+	items := loadItems(store)
+	if !opts.all {
+		items = slices.DeleteFunc(items, func(item *vault.Item) bool {
+			return item.Type.Hidden()
+		})
+	}
+	items = vault.Filter(items, opts.query, opts.mode)
+	if opts.query != "" && len(items) == 0 {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: no entries match %q\n", opts.query)))
+		os.Exit(exitNoMatch)
+	}
+
+	if opts.json {
+		writeJSON(items)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tNAME\tUSERNAME\tURL")
+	for _, item := range items {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", item.ID, item.Type, item.Name, item.Username, item.URL)
+	}
+	w.Flush()
+}
+
+func writeJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error encoding JSON: %v\n", err)))
+		os.Exit(exitError)
+	}
+}
+
+func showGetHelp() {
+	help := `OpenPasswd - Scriptable Commands
+
+COMMANDS:
+    openpass get <name|id> [--field <field>]   Print a single value
+    openpass show <name|id> [--json]           Print a whole entry
+    openpass ls [query] [--json] [--all]       List entries
+
+OPTIONS:
+    --field, -f <field>      Field to print: password (default), username, url,
+                             notes, name, totp (current code) or a custom field.
+                             HOTP entries advance their counter with every code
+    --exact, -e              Only match the exact entry name
+    --fuzzy, -z              Match names (or URLs) containing the query letters in order
+    --json                   Output JSON (show, ls)
+    --no-newline, -n         Do not print a trailing newline (get)
+    --all, -a                Include registry logins of the Docker credential
+                             helper (ls)
+
+MATCHING:
+    A numeric query is tried as an entry ID first. By default an exact name
+    match wins, otherwise any name containing the query matches.
+
+EXIT CODES:
+    0    Success
+    1    Error (wrong passphrase, unknown field, ...)
+    2    No matching entry
+    3    Query matches more than one entry
+
+EXAMPLES:
+    openpass get github                        # Print the GitHub password
+    openpass get github --field username       # Print the username
+    openpass get 42 --field totp               # Print the current TOTP code
+    openpass show github --json                # Whole entry as JSON
+    openpass ls --json | jq '.[].name'         # All entry names
+`
+	fmt.Println(help)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/r2unit/openpasswd/pkg/gitcred"
+	"github.com/r2unit/openpasswd/pkg/tui"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+func handleGitCredential() {
+	if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		showGitCredentialHelp()
+		return
+	}
+
+	op := os.Args[2]
+	switch op {
+	case gitcred.OpGet, gitcred.OpStore, gitcred.OpErase:
+	default:
+		// Helpers must ignore operations they don't know, git may add more
+		return
+	}
+
+	cred, err := gitcred.Read(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	if cred.Protocol == "" || cred.Host == "" {
+		return
+	}
+	if op != gitcred.OpGet && cred.Username == "" {
+		return
+	}
+
+	store := openCredentialStore()
+	defer store.Close()
+
+	items := loadItems(store)
+
+	switch op {
+	case gitcred.OpGet:
+		err = gitCredentialGet(items, cred)
+	case gitcred.OpStore:
+		err = gitCredentialStore(store, items, cred)
+	case gitcred.OpErase:
+		err = gitCredentialErase(store, items, cred)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+}
+
+// openCredentialStore uses the agent when there is one. Otherwise stdin
+// carried git's request, so the passphrase is asked for on the terminal.
+func openCredentialStore() vault.Store {
+	if client := dialAgent(); client != nil {
+		return client
+	}
+	useTerminalInput()
+	return unlockVault()
+}
+
+// useTerminalInput points stdin at the controlling terminal so prompts work
+// in commands whose stdin carries data. Without a terminal stdin is left
+// alone and only the non-interactive passphrase sources remain.
+func useTerminalInput() {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+	if tty, err := os.OpenFile(name, os.O_RDWR, 0); err == nil {
+		os.Stdin = tty
+	}
+}
+
+// gitCredentialGet prints the best matching login, or nothing so git moves
+// on to its next helper or prompt
+func gitCredentialGet(items []*vault.Item, cred *gitcred.Credential) error {
+	matches := gitcred.Match(items, cred)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	best := matches[0]
+	answer := &gitcred.Credential{Username: best.Username, Password: best.Password}
+	if answer.Username == "" {
+		answer.Username = cred.Username
+	}
+	return answer.Write(os.Stdout)
+}
+
+// gitCredentialStore saves a login git used successfully. Logins that are
+// already stored are left alone; a new password for the same URL and
+// username updates the entry and keeps the old one in its history.
+func gitCredentialStore(store vault.Store, items []*vault.Item, cred *gitcred.Credential) error {
+	if cred.Password == "" {
+		return nil
+	}
+
+	for _, item := range gitcred.Match(items, cred) {
+		if item.Username == cred.Username && item.Password == cred.Password {
+			return nil
+		}
+	}
+
+	if exact := gitcred.Exact(items, cred); len(exact) > 0 {
+		merged, _ := vault.Merge(exact[0], &vault.Item{Password: cred.Password})
+		if err := store.Update(merged); err != nil {
+			return fmt.Errorf("failed to update %q: %w", merged.Name, err)
+		}
+		return nil
+	}
+
+	if _, err := store.Add(cred.Item()); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+	return nil
+}
+
+// gitCredentialErase deletes a login git says was rejected. Only entries
+// stored for exactly this URL and username with the rejected password are
+// removed, so a broader entry (like a website login) is never lost to a
+// failed push.
+func gitCredentialErase(store vault.Store, items []*vault.Item, cred *gitcred.Credential) error {
+	for _, item := range gitcred.Exact(items, cred) {
+		if cred.Password != "" && item.Password != cred.Password {
+			continue
+		}
+		if err := store.Delete(item.ID); err != nil {
+			return fmt.Errorf("failed to delete %q: %w", item.Name, err)
+		}
+	}
+	return nil
+}
+
+func showGitCredentialHelp() {
+	help := `OpenPasswd - Git Credential Helper
+
+Answers git's credential requests from the vault. Logins are found by
+matching the protocol, host and path git asks about against the URLs of
+login entries; the most specific URL wins.
+
+COMMANDS:
+    openpass git-credential get      Print the username and password for git
+    openpass git-credential store    Save a login git used successfully
+    openpass git-credential erase    Delete a login git says was rejected
+
+Git passes the request on stdin. The running agent is used when
+OPENPASSWD_AGENT_SOCK is set, otherwise the passphrase is asked for on the
+terminal. erase only removes entries stored for exactly that URL and
+username, never broader ones.
+
+SETUP:
+    git config --global credential.helper '!openpass git-credential'
+    git config --global credential.useHttpPath true   # match per repository
+
+EXAMPLES:
+    printf 'protocol=https\nhost=github.com\n' | openpass git-credential get
+`
+	fmt.Println(help)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/config"
+	"github.com/r2unit/openpasswd/pkg/sources"
+	"github.com/r2unit/openpasswd/pkg/tui"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// importOptions holds the flags of the import command
+type importOptions struct {
+	source      string
+	file        string
+	dryRun      bool
+	onDuplicate vault.Resolution
+	keyFile     string
+	report      string
+}
+
+func parseImportArgs(args []string) (importOptions, error) {
+	opts := importOptions{onDuplicate: vault.ResolveSkip}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--dry-run" || arg == "-n":
+			opts.dryRun = true
+		case arg == "--on-duplicate" || arg == "--key-file" || arg == "--report":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			if err := opts.set(arg, args[i]); err != nil {
+				return opts, err
+			}
+		case strings.HasPrefix(arg, "--on-duplicate="), strings.HasPrefix(arg, "--key-file="), strings.HasPrefix(arg, "--report="):
+			name, value, _ := strings.Cut(arg, "=")
+			if err := opts.set(name, value); err != nil {
+				return opts, err
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return opts, fmt.Errorf("unknown option: %s", arg)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	switch len(positional) {
+	case 0:
+	case 2:
+		opts.source, opts.file = positional[0], positional[1]
+	default:
+		return opts, fmt.Errorf("expected a source and a file, got %d arguments", len(positional))
+	}
+
+	return opts, nil
+}
+
+func (o *importOptions) set(name, value string) error {
+	switch name {
+	case "--on-duplicate":
+		r, err := vault.ParseResolution(value)
+		if err != nil {
+			return err
+		}
+		o.onDuplicate = r
+	case "--key-file":
+		o.keyFile = value
+	case "--report":
+		o.report = value
+	}
+	return nil
+}
+
+func handleImport() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showImportHelp()
+		return
+	}
+
+	opts, err := parseImportArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass import help' for usage")
+		os.Exit(exitError)
+	}
+
+	if opts.source == "" {
+		session := unlockVault()
+		defer session.Close()
+
+		err := tui.RunImportTUI(session, tui.ImportOptions{DryRun: opts.dryRun, ReportPath: opts.report})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+			os.Exit(exitError)
+		}
+		return
+	}
+
+	importFile(opts)
+}
+
+// importFile imports a file without the TUI, resolving every duplicate the
+// same way
+func importFile(opts importOptions) {
+	importer := sources.GetImporter(sources.Source(opts.source))
+	if importer == nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: unknown source: %s\n", opts.source)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass import help' for the list of sources")
+		os.Exit(exitError)
+	}
+
+	if _, err := os.Stat(opts.file); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	if opts.keyFile != "" {
+		kf, ok := importer.(sources.KeyFileImporter)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %s exports don't use key files\n", importer.GetName())))
+			os.Exit(exitError)
+		}
+		kf.SetKeyFile(opts.keyFile)
+	}
+
+	if mapper, ok := importer.(sources.ColumnMapper); ok {
+		layout, err := mapper.DetectColumns(opts.file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+			os.Exit(exitError)
+		}
+		if !layout.Known() {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError("Error: the CSV layout wasn't recognised\n"))
+			fmt.Fprintln(os.Stderr, "Run 'openpass import' without arguments to map the columns by hand")
+			os.Exit(exitError)
+		}
+	}
+
+	session := unlockVault()
+	defer session.Close()
+
+	var passphrase string
+	if sources.NeedsPassphrase(importer, opts.file) {
+		var err error
+		if passphrase, err = promptPassword("Enter export passphrase", opts.keyFile != ""); err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error reading passphrase: %v\n", err)))
+			os.Exit(exitError)
+		}
+	}
+
+	passwords, err := importer.Import(opts.file, passphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: import failed: %v\n", err)))
+		os.Exit(exitError)
+	}
+	if len(passwords) == 0 {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError("Error: no passwords found in file\n"))
+		os.Exit(exitError)
+	}
+
+	imported := make([]*vault.Item, 0, len(passwords))
+	for _, pwd := range passwords {
+		imported = append(imported, vault.FromPassword(pwd))
+	}
+
+	plan := vault.PlanImport(loadItems(session), imported)
+	plan.Skipped = sources.Skipped(importer)
+	plan.ResolveAll(opts.onDuplicate)
+
+	var report *vault.ImportReport
+	if opts.dryRun {
+		report = plan.DryRun()
+	} else {
+		report = plan.Apply(session)
+	}
+	report.Source, report.File = importer.GetName(), opts.file
+
+	// A dry run is the preview, so show the whole report
+	if opts.dryRun {
+		if err := report.Write(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+			os.Exit(exitError)
+		}
+	}
+
+	path := opts.report
+	if path == "" {
+		if path, err = config.ImportReportPath(report.Time); err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: failed to save import report: %v\n", err)))
+			os.Exit(exitError)
+		}
+	}
+	if err := report.Save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	added, updated, skipped, failed := report.Summary()
+	if opts.dryRun {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorInfo(fmt.Sprintf("Dry run: would add %d, update %d and skip %d entries\n", added, updated, skipped)))
+	} else {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorSuccess(fmt.Sprintf("✓ Imported %d new and updated %d existing entries (%d skipped)\n", added, updated, skipped)))
+	}
+	if n := len(report.Skipped); n > 0 {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorWarning(fmt.Sprintf("⚠ %d source items could not be read and were left out, see the report\n", n)))
+	}
+	fmt.Fprintf(os.Stderr, "Report: %s\n", path)
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %d entries failed, see the report\n", failed)))
+		os.Exit(exitError)
+	}
+}
+
+func showImportHelp() {
+	help := `OpenPasswd - Import
+
+COMMANDS:
+    openpass import [--dry-run]                   Import interactively, with a preview
+    openpass import <source> <file> [options]     Import a file without the TUI
+
+SOURCES:
+    protonpass    Proton Pass JSON, CSV, ZIP or PGP export
+    bitwarden     Bitwarden JSON export (also password-protected)
+    keepass       KeePass KDBX database or XML export
+    1password     1Password 1PUX or CSV export
+    browser       Chrome, Edge, Firefox, Safari or LastPass CSV export
+    pass          pass / gopass store directory
+
+OPTIONS:
+    --dry-run, -n              Preview the import and write the report only
+    --on-duplicate <choice>    What to do with entries already in the vault:
+                               skip (default), overwrite, keep-both or merge
+    --key-file <file>          KeePass key file
+    --report <file>            Write the import report here
+
+DUPLICATES:
+    An imported item is a duplicate of an existing entry with the same URL
+    host and username or, failing that, the same name. In the interactive
+    preview each duplicate can be resolved on its own. Merging fills in
+    empty fields, keeps the newer value of conflicting ones, moves a replaced
+    password to the password history and appends differing notes.
+
+REPORTS:
+    Every import writes a report of what was added, updated and skipped (no
+    secrets) to ~/.config/openpasswd/imports/ unless --report is given.
+
+EXAMPLES:
+    openpass import
+    openpass import bitwarden export.json --dry-run
+    openpass import keepass vault.kdbx --key-file vault.keyx --on-duplicate merge
+`
+	fmt.Println(help)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/config"
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/keyring"
+	"github.com/r2unit/openpasswd/pkg/tui"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// keyCacheTimeout returns how long unlocked keys stay in the kernel keyring,
+// zero when the key cache is disabled
+func keyCacheTimeout() time.Duration {
+	cfg, _ := config.LoadKeyCacheConfig()
+	return cfg.TimeoutDuration()
+}
+
+// unlockFromKeyCache unlocks the session with a key cached in the kernel
+// keyring. A cached key that doesn't fit the vault anymore is revoked.
+func unlockFromKeyCache(session *vault.Session) bool {
+	if keyCacheTimeout() <= 0 {
+		return false
+	}
+
+	name := keyring.Name(session.Config.DatabasePath)
+	key, err := keyring.Load(name)
+	if err != nil {
+		return false
+	}
+	defer crypto.WipeMemory(key)
+
+	if err := session.UnlockWithKey(key); err != nil {
+		_ = keyring.Revoke(name)
+		return false
+	}
+	return true
+}
+
+// cacheKey stores the key of an unlocked session in the kernel keyring when
+// the key cache is enabled. Without a keyring the cache is silently skipped.
+func cacheKey(session *vault.Session) {
+	timeout := keyCacheTimeout()
+	if timeout <= 0 {
+		return
+	}
+
+	encryptor, err := session.Encryptor()
+	if err != nil {
+		return
+	}
+
+	err = keyring.Store(keyring.Name(session.Config.DatabasePath), encryptor.GetKey(), timeout)
+	if err != nil && !errors.Is(err, keyring.ErrUnavailable) {
+		fmt.Fprintln(os.Stderr, tui.ColorWarning(fmt.Sprintf("⚠ Failed to cache the key: %v", err)))
+	}
+}
+
+// revokeCachedKey drops the cached key of the configured vault, reporting
+// whether there was one
+func revokeCachedKey() (bool, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return false, err
+	}
+
+	err = keyring.Revoke(keyring.Name(cfg.DatabasePath))
+	if errors.Is(err, keyring.ErrNotFound) || errors.Is(err, keyring.ErrUnavailable) {
+		return false, nil
+	}
+	return err == nil, err
+}
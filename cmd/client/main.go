@@ -1,20 +1,42 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
+	"github.com/r2unit/openpasswd/pkg/agent"
+	"github.com/r2unit/openpasswd/pkg/clipboard"
 	"github.com/r2unit/openpasswd/pkg/config"
 	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/database"
 	"github.com/r2unit/openpasswd/pkg/mfa"
+	"github.com/r2unit/openpasswd/pkg/models"
 	_ "github.com/r2unit/openpasswd/pkg/proton/pass" // Register Proton Pass provider
 	"github.com/r2unit/openpasswd/pkg/tui"
+	"github.com/r2unit/openpasswd/pkg/vault"
 	"github.com/r2unit/openpasswd/pkg/version"
 )
 
 func main() {
+	args, err := extractPassphraseFlags(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(1)
+	}
+	os.Args = args
+
+	// Docker runs the credential helper as docker-credential-openpasswd <op>
+	if isDockerCredentialHelper() {
+		if len(os.Args) < 2 {
+			showDockerCredentialHelp()
+			os.Exit(exitError)
+		}
+		runDockerCredential(os.Args[1])
+		return
+	}
+
 	if len(os.Args) < 2 {
 		showHelp()
 		return
@@ -24,6 +46,18 @@ func main() {
 
 	// Commands that don't require initialization
 	switch cmd {
+	case clipboard.ClearHelperCommand:
+		// Detached helper spawned after copying a secret; never shown in help
+		if err := clipboard.RunClearHelper(os.Args[2:]); err != nil {
+			os.Exit(1)
+		}
+		return
+	case agent.HelperCommand:
+		// Detached agent started by 'openpass agent'; never shown in help
+		if err := agent.RunHelper(os.Args[2:]); err != nil {
+			os.Exit(1)
+		}
+		return
 	case "init":
 		initializeConfig()
 		return
@@ -58,17 +92,42 @@ func main() {
 		os.Exit(1)
 
 	case "import":
-		fmt.Fprintf(os.Stderr, "%s", tui.ColorWarning("Import functionality is currently disabled\n"))
-		os.Exit(1)
+		handleImport()
 
 	case "add":
 		handleAdd()
 	case "list":
 		handleList()
+	case "get":
+		handleGet()
+	case "show":
+		handleShow()
+	case "ls":
+		handleLs()
+	case "export":
+		handleExport()
+	case "restore":
+		handleRestore()
 	case "settings":
 		handleSettings()
 	case "migrate":
 		handleMigrate()
+	case "agent":
+		handleAgent()
+	case "lock":
+		handleLock()
+	case "ssh-keygen":
+		handleSSHKeygen()
+	case "ssh-agent":
+		handleSSHAgent()
+	case "git-credential":
+		handleGitCredential()
+	case "docker-credential":
+		handleDockerCredential()
+	case "run":
+		handleRun()
+	case "inject":
+		handleInject()
 	default:
 		showHelp()
 	}
@@ -146,6 +205,16 @@ func initializeConfig() {
 		os.Exit(1)
 	}
 
+	// Save the value unlocking checks the passphrase against, so even an
+	// empty vault rejects a wrong one
+	encryptor := crypto.NewEncryptorWithVersion(setupResult.Passphrase, salt, crypto.CurrentKDFVersion)
+	err = vault.SaveKeyCheck(encryptor)
+	encryptor.Wipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error saving key check: %v\n", err)))
+		os.Exit(1)
+	}
+
 	// Encrypt and save recovery key
 	encryptedRecovery, err := crypto.EncryptRecoveryKey(setupResult.RecoveryKey, setupResult.Passphrase, salt)
 	if err != nil {
@@ -217,6 +286,20 @@ COMMANDS:
     openpasswd init              Initialize configuration and database
     openpasswd add               Add a new password entry
     openpasswd list              List and search passwords
+    openpasswd get <name|id>     Print a single value (for scripts)
+    openpasswd show <name|id>    Print a whole entry (--json for JSON)
+    openpasswd ls                List entries (--json for JSON)
+    openpasswd import            Import from another password manager
+    openpasswd export            Export an encrypted backup or plaintext file
+    openpasswd restore <file>    Restore an encrypted backup
+    openpasswd agent             Keep the vault unlocked in the background
+    openpasswd lock              Stop the agent and drop the cached key
+    openpasswd ssh-keygen <name> Create an SSH key in the vault
+    openpasswd ssh-agent         Serve the vault's SSH keys to ssh and git
+    openpasswd git-credential    Git credential helper (get, store, erase)
+    openpasswd docker-credential Docker credential helper (get, store, erase, list)
+    openpasswd run -- <command>  Run a command with secrets from env files
+    openpasswd inject            Render a template with secret references
     openpasswd settings          Manage settings (passphrase, MFA, etc.)
     openpasswd version           Show version information
     openpasswd upgrade           Upgrade to the latest version
@@ -226,11 +309,27 @@ OPTIONS:
     --help, -h                   Show this help message
     --version, -v                Show version number
 
+PASSPHRASE INPUT (for scripts, cron and CI):
+    --passphrase-fd <n>          Read the master passphrase from file descriptor n
+    --passphrase-file <path>     Read it from a file (must not be readable by others)
+    --pinentry[=<program>]       Ask through a GnuPG pinentry program
+    OPENPASSWD_PASSPHRASE_COMMAND   Shell command that prints the passphrase
+    OPENPASSWD_PINENTRY          Pinentry program to use (same as --pinentry)
+    OPENPASSWD_AGENT_SOCK        Use the running agent instead of asking (set by 'agent')
+
 EXAMPLES:
     openpasswd init                             # First-time setup
     openpasswd add                              # Add password interactively
     openpasswd add login                        # Add login password
     openpasswd list                             # List all passwords
+    openpasswd get github --field username      # Print a single field
+    openpasswd ls --json                        # List entries as JSON
+    openpasswd import bitwarden export.json -n  # Preview an import (dry run)
+    openpasswd export -o vault.backup           # Encrypted backup
+    eval "$(openpasswd agent)"                  # Unlock once for this shell
+    openpasswd ssh-keygen github                # New Ed25519 key, prints the public key
+    git config --global credential.helper '!openpasswd git-credential'
+    openpasswd run --env-file .env.tpl -- npm start  # Secrets only in the child's env
     openpasswd settings set-passphrase          # Set master passphrase
     openpasswd settings set-totp                # Enable TOTP authentication
     openpasswd settings set-yubikey             # Enable YubiKey authentication
@@ -244,7 +343,9 @@ CONFIGURATION:
     ~/.config/openpasswd/passwords.db          Encrypted password database
     ~/.config/openpasswd/salt                  Encryption salt
     ~/.config/openpasswd/totp_secret           TOTP secret (optional)
+    ~/.config/openpasswd/totp_backup_codes     Hashed TOTP backup codes (optional)
     ~/.config/openpasswd/config.toml           Color configuration
+    ~/.config/openpasswd/imports/              Import reports
     ~/.config/openpasswd/disable_version_check Flag to disable auto-update checks
     ~/.cache/openpasswd/version_check.json     Cached version check (24hr TTL)
 
@@ -253,10 +354,6 @@ For more information, visit: https://github.com/r2unit/openpasswd
 	fmt.Println(help)
 }
 
-// This function will allow importing passwords from export files of various password managers
-// Planned support: Bitwarden, 1Password, LastPass, KeePass, etc.
-// Currently disabled - implementation pending
-
 // This function will handle OAuth/API authentication with providers like:
 // - Proton Pass (via API)
 // - Bitwarden (self-hosted or cloud)
@@ -276,28 +373,19 @@ func handleAdd() {
 		return
 	}
 
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Println("\nRun 'openpass init' to initialize the password manager")
-		os.Exit(1)
-	}
-
-	db, err := database.New(cfg.DatabasePath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
-		os.Exit(1)
-	}
-	defer db.Close()
-
 	passwordType := ""
 	if len(os.Args) >= 3 {
 		passwordType = os.Args[2]
 	}
+	if passwordType == string(models.TypeSSHKey) {
+		fmt.Fprintln(os.Stderr, tui.ColorInfo("SSH keys are created with 'openpass ssh-keygen <name>'"))
+		os.Exit(1)
+	}
 
-	passphrase := ""
+	store := openStore()
+	defer store.Close()
 
-	if err := tui.RunAddTUI(db, cfg.Salt, passphrase, passwordType); err != nil {
+	if err := tui.RunAddTUI(store, passwordType); err != nil {
 		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
 		os.Exit(1)
 	}
@@ -385,6 +473,9 @@ func handleSettings() {
 	case "remove-totp":
 		handleRemoveTOTP()
 
+	case "regenerate-backup-codes":
+		handleRegenerateBackupCodes()
+
 	case "show-totp-qr":
 		handleShowTOTPQR()
 
@@ -402,77 +493,168 @@ func handleSettings() {
 }
 
 func handleList() {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Println("\nRun 'openpass init' to initialize the password manager")
-		os.Exit(1)
+	session := openVault()
+	defer session.Close()
+
+	if !unlockFromAgent(session) && !unlockFromKeyCache(session) {
+		unlockInteractive(session)
 	}
 
-	db, err := database.New(cfg.DatabasePath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+	if err := tui.RunListTUI(session); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
 		os.Exit(1)
 	}
-	defer db.Close()
+}
 
-	// Always prompt for passphrase (plaintext storage removed for security)
-	passphrase, err := promptPassword("Enter master passphrase", true)
+// unlockInteractive is unlockWithPrompt for the TUIs, showing the wrong
+// passphrase screen instead of a plain error
+func unlockInteractive(session *vault.Session) {
+	// Always ask for the passphrase (plaintext storage removed for security)
+	passphrase, err := readMasterPassphrase("Enter master passphrase", true)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error reading passphrase: %v\n", err)))
 		os.Exit(1)
 	}
 
 	// Validate passphrase before showing TUI
-	if !validatePassphrase(db, cfg.Salt, passphrase, cfg.KDFVersion) {
+	err = unlockSession(session, passphrase)
+	passphrase.Wipe()
+	if errors.Is(err, vault.ErrWrongPassphrase) {
 		if err := tui.RunWrongPassphraseTUI(); err != nil {
 			fmt.Fprintf(os.Stderr, tui.ColorError("Error: %v\n"), err)
 		}
 		os.Exit(1)
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(1)
+	}
 
-	if err := tui.RunListTUI(db, cfg.Salt, passphrase); err != nil {
+	if err := verifySecondFactor(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
 		os.Exit(1)
 	}
+
+	cacheKey(session)
 }
 
-// validatePassphrase checks if the passphrase can decrypt the database
-func validatePassphrase(db *database.DB, salt []byte, passphrase string, kdfVersion int) bool {
-	passwords, err := db.ListPasswords()
+func handleSetTOTP() {
+	username := "user"
+
+	if err := tui.RunTOTPSetupTUI(username); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(1)
+	}
+
+	fmt.Println(tui.ColorSuccess("\n✓ TOTP authentication enabled successfully!"))
+	fmt.Println(tui.ColorInfo("You will need to provide a TOTP code when accessing passwords."))
+
+	if err := generateBackupCodes(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error generating backup codes: %v\n", err)))
+		fmt.Println(tui.ColorInfo("Run 'openpass settings regenerate-backup-codes' to try again."))
+		os.Exit(1)
+	}
+}
+
+// verifySecondFactor prompts for a TOTP or backup code when TOTP is enabled.
+// Accepted TOTP time steps are recorded so a code cannot be replayed, and
+// backup codes are removed once used.
+func verifySecondFactor() error {
+	if !config.HasTOTP() {
+		return nil
+	}
+
+	code, err := mfa.GetTOTPCode()
 	if err != nil {
-		return false
+		return err
 	}
 
-	// If database is empty, any passphrase is "valid" (no way to verify)
-	if len(passwords) == 0 {
-		return true
+	remaining, err := vault.VerifySecondFactor(code)
+	if err != nil {
+		return err
 	}
 
-	// Try to decrypt the first password's name using the correct KDF version
-	encryptor := crypto.NewEncryptorWithVersion(passphrase, salt, kdfVersion)
-	for _, p := range passwords {
-		if p.Name != "" {
-			_, err := encryptor.Decrypt(p.Name)
-			// If decryption succeeds, passphrase is correct
-			return err == nil
+	if remaining >= 0 {
+		fmt.Println(tui.ColorWarning(fmt.Sprintf("⚠ Backup code used. %d backup code(s) remaining.", remaining)))
+		if remaining <= 2 {
+			fmt.Println(tui.ColorInfo("Run 'openpass settings regenerate-backup-codes' to create new ones."))
 		}
+		return nil
 	}
 
-	// If no encrypted fields found, assume passphrase is valid
-	return true
+	warnBackupCodes()
+	return nil
 }
 
-func handleSetTOTP() {
-	username := "user"
+// warnBackupCodes points to regenerate-backup-codes when no backup codes
+// are left or they were stored with the old, fast hash
+func warnBackupCodes() {
+	set, err := vault.BackupCodes()
+	if err != nil {
+		return
+	}
 
-	if err := tui.RunTOTPSetupTUI(username); err != nil {
+	switch {
+	case set.Remaining() == 0:
+		fmt.Println(tui.ColorWarning("⚠ No backup codes left: losing your authenticator would lock you out."))
+	case set.Legacy():
+		fmt.Println(tui.ColorWarning("⚠ Your backup codes are stored with an outdated hash."))
+	default:
+		return
+	}
+	fmt.Println(tui.ColorInfo("Run 'openpass settings regenerate-backup-codes' to create new ones."))
+}
+
+// generateBackupCodes creates, stores and displays a fresh set of backup codes
+func generateBackupCodes() error {
+	codes, set, err := mfa.GenerateBackupCodes(mfa.DefaultBackupCodeCount)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := mfa.EncodeBackupCodes(set)
+	if err != nil {
+		return err
+	}
+
+	if err := config.SaveBackupCodes(encoded); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println(tui.ColorWarning("Backup codes (each can be used once instead of a TOTP code):"))
+	fmt.Println()
+	for i := 0; i < len(codes); i += 2 {
+		line := "    " + codes[i]
+		if i+1 < len(codes) {
+			line += "    " + codes[i+1]
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+	fmt.Println(tui.ColorInfo("Store these somewhere safe. They will not be shown again."))
+
+	return nil
+}
+
+func handleRegenerateBackupCodes() {
+	if !config.HasTOTP() {
+		fmt.Println(tui.ColorWarning("TOTP authentication is not currently enabled"))
+		fmt.Println("Run 'openpass settings set-totp' to enable it")
+		return
+	}
+
+	if err := verifySecondFactor(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
 		os.Exit(1)
 	}
 
-	fmt.Println(tui.ColorSuccess("\n✓ TOTP authentication enabled successfully!"))
-	fmt.Println(tui.ColorInfo("You will need to provide a TOTP code when accessing passwords."))
+	if err := generateBackupCodes(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error generating backup codes: %v\n", err)))
+		os.Exit(1)
+	}
+
+	fmt.Println(tui.ColorSuccess("✓ Backup codes regenerated. Previous codes no longer work."))
 }
 
 func handleRemoveTOTP() {
@@ -489,6 +671,12 @@ func handleRemoveTOTP() {
 		return
 	}
 
+	// Removing the second factor requires the second factor
+	if err := verifySecondFactor(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(1)
+	}
+
 	if err := config.RemoveTOTP(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error removing TOTP: %v\n", err)))
 		os.Exit(1)
@@ -573,6 +761,8 @@ func showSettingsHelp() {
 COMMANDS:
     openpass settings set-totp            Enable TOTP (authenticator app)
     openpass settings remove-totp         Disable TOTP authentication
+    openpass settings regenerate-backup-codes
+                                          Replace TOTP backup codes
     openpass settings show-totp-qr        Show TOTP QR code again
     openpass settings set-yubikey         Enable YubiKey authentication
     openpass settings remove-yubikey      Disable YubiKey authentication
@@ -592,6 +782,7 @@ EXAMPLES:
     openpass settings set-totp            # Enable Google Authenticator
     openpass settings set-yubikey         # Enable YubiKey
     openpass settings show-totp-qr        # Re-display QR code
+    openpass settings regenerate-backup-codes  # New one-time backup codes
 `
 	fmt.Println(help)
 }
@@ -676,17 +867,29 @@ func handleMigrateUpgradeKDF() {
 	if len(passwords) == 0 {
 		fmt.Println(tui.ColorInfo("No passwords to migrate."))
 
-		// Just update KDF version
+		// Just update KDF version; the key check of the old key is replaced
+		// on the next unlock
 		if err := config.SaveKDFVersion(crypto.KDFVersionPBKDF2_600k); err != nil {
 			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error saving KDF version: %v\n", err)))
 			os.Exit(1)
 		}
+		if err := config.RemoveKeyCheck(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error removing key check: %v\n", err)))
+			os.Exit(1)
+		}
 
 		fmt.Println(tui.ColorSuccess("✓ KDF version updated to 600k iterations"))
 		return
 	}
 
-	passphrase, err := promptPassword("Enter master passphrase", false)
+	securePassphrase, err := readMasterPassphrase("Enter master passphrase", false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error reading passphrase: %v\n", err)))
+		os.Exit(1)
+	}
+	defer securePassphrase.Wipe()
+
+	passphrase, err := securePassphrase.Get()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error reading passphrase: %v\n", err)))
 		os.Exit(1)
@@ -761,6 +964,10 @@ func handleMigrateUpgradeKDF() {
 		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error saving KDF version: %v\n", err)))
 		os.Exit(1)
 	}
+	if err := vault.SaveKeyCheck(newEncryptor); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error saving key check: %v\n", err)))
+		os.Exit(1)
+	}
 
 	fmt.Println(tui.ColorSuccess("✓ Migration complete!"))
 	fmt.Println(tui.ColorInfo("  KDF: PBKDF2-HMAC-SHA256 with 600,000 iterations"))
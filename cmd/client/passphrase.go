@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/pinentry"
+)
+
+// passphraseCommandEnv names a shell command whose output is the master passphrase
+const passphraseCommandEnv = "OPENPASSWD_PASSPHRASE_COMMAND"
+
+// pinentryEnv selects a pinentry program, the same as --pinentry=<program>
+const pinentryEnv = "OPENPASSWD_PINENTRY"
+
+// errNoPassphraseSource is returned when there is no terminal to prompt on
+var errNoPassphraseSource = errors.New("stdin is not a terminal; provide the passphrase with --passphrase-fd, --passphrase-file, " +
+	passphraseCommandEnv + " or --pinentry")
+
+// passphraseFlags holds the global flags that choose where the master passphrase comes from
+var passphraseFlags = struct {
+	fd          int
+	file        string
+	pinentry    string
+	usePinentry bool
+}{fd: -1}
+
+// extractPassphraseFlags removes the global passphrase flags from args so the
+// commands never see them. Parsing stops at "--".
+func extractPassphraseFlags(args []string) ([]string, error) {
+	out := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+
+		switch name {
+		case "--passphrase-fd", "--passphrase-file":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("%s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+
+			if name == "--passphrase-file" {
+				passphraseFlags.file = value
+				continue
+			}
+
+			fd, err := strconv.Atoi(value)
+			if err != nil || fd < 0 {
+				return nil, fmt.Errorf("invalid file descriptor for --passphrase-fd: %s", value)
+			}
+			passphraseFlags.fd = fd
+
+		case "--pinentry":
+			passphraseFlags.usePinentry = true
+			passphraseFlags.pinentry = value
+
+		case "--":
+			return append(out, args[i:]...), nil
+
+		default:
+			out = append(out, arg)
+		}
+	}
+
+	return out, nil
+}
+
+// readMasterPassphrase reads the master passphrase from the first configured
+// source: --passphrase-fd, --passphrase-file, OPENPASSWD_PASSPHRASE_COMMAND,
+// pinentry, and finally an interactive prompt on the terminal.
+func readMasterPassphrase(message string, optional bool) (*crypto.SecureString, error) {
+	switch {
+	case passphraseFlags.fd >= 0:
+		f := os.NewFile(uintptr(passphraseFlags.fd), "passphrase-fd")
+		if f == nil {
+			return nil, fmt.Errorf("invalid file descriptor: %d", passphraseFlags.fd)
+		}
+		defer f.Close()
+		return readPassphraseLine(f)
+
+	case passphraseFlags.file != "":
+		return readPassphraseFile(passphraseFlags.file)
+
+	case os.Getenv(passphraseCommandEnv) != "":
+		return runPassphraseCommand(os.Getenv(passphraseCommandEnv))
+
+	case passphraseFlags.usePinentry || os.Getenv(pinentryEnv) != "":
+		program := passphraseFlags.pinentry
+		if program == "" {
+			program = os.Getenv(pinentryEnv)
+		}
+		return pinentry.GetPIN(program, pinentry.Request{
+			Title:       "OpenPasswd",
+			Description: "Enter the master passphrase to unlock your vault",
+			Prompt:      "Passphrase:",
+		})
+	}
+
+	if !stdinIsTerminal() {
+		return nil, errNoPassphraseSource
+	}
+
+	passphrase, err := promptPassword(message, optional)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewSecureString(passphrase)
+}
+
+// readPassphraseFile reads the first line of a file, refusing files other users can read
+func readPassphraseFile(path string) (*crypto.SecureString, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open passphrase file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat passphrase file: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("passphrase file %s is accessible by other users (mode %04o); run chmod 600 %s",
+			path, info.Mode().Perm(), path)
+	}
+
+	return readPassphraseLine(f)
+}
+
+// runPassphraseCommand runs the passphrase hook through the shell and reads its output
+func runPassphraseCommand(command string) (*crypto.SecureString, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	defer crypto.WipeMemory(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", passphraseCommandEnv, err)
+	}
+
+	passphrase, _, _ := strings.Cut(string(out), "\n")
+	return crypto.NewSecureString(strings.TrimSuffix(passphrase, "\r"))
+}
+
+// readPassphraseLine reads up to the first newline
+func readPassphraseLine(r io.Reader) (*crypto.SecureString, error) {
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	defer crypto.WipeMemory(line)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	passphrase := strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r")
+	return crypto.NewSecureString(passphrase)
+}
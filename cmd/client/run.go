@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/r2unit/openpasswd/pkg/secretref"
+	"github.com/r2unit/openpasswd/pkg/tui"
+)
+
+// runOptions holds the flags of the run command
+type runOptions struct {
+	envFiles []string
+	mask     bool
+	command  []string
+}
+
+func parseRunArgs(args []string) (runOptions, error) {
+	var opts runOptions
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--":
+			opts.command = args[i+1:]
+			i = len(args)
+			continue
+		case "--mask", "-m":
+			opts.mask = true
+			continue
+		case "--env-file", "-e":
+		default:
+			return opts, fmt.Errorf("unknown option: %s (put the command after --)", arg)
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			value = args[i]
+		}
+		opts.envFiles = append(opts.envFiles, value)
+	}
+
+	if len(opts.command) == 0 {
+		return opts, errors.New("missing command after --")
+	}
+	return opts, nil
+}
+
+func handleRun() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showRunHelp()
+		return
+	}
+
+	opts, err := parseRunArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass run help' for usage")
+		os.Exit(exitError)
+	}
+
+	// Variables of the current environment may hold references as well;
+	// the env files override them in order
+	var vars []secretref.EnvVar
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && secretref.Contains(value) {
+			vars = append(vars, secretref.EnvVar{Name: name, Value: value})
+		}
+	}
+	for _, path := range opts.envFiles {
+		fileVars, err := readEnvFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+			os.Exit(exitError)
+		}
+		vars = append(vars, fileVars...)
+	}
+
+	resolver, done := newResolver(vars)
+	env := os.Environ()
+	for _, v := range vars {
+		value, err := resolver.Render(v.Value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %s: %v\n", v.Name, err)))
+			os.Exit(exitError)
+		}
+		env = append(env, v.Name+"="+value)
+	}
+	done()
+
+	os.Exit(runWithEnv(opts.command, env, resolver.Values(), opts.mask))
+}
+
+// readEnvFile parses an env file by path
+func readEnvFile(path string) ([]secretref.EnvVar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer f.Close()
+
+	vars, err := secretref.ParseEnvFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// newResolver loads the vault only when some value holds a reference, so
+// env files without any don't ask for the passphrase. done closes the vault
+// once everything is resolved.
+func newResolver(vars []secretref.EnvVar) (resolver *secretref.Resolver, done func()) {
+	for _, v := range vars {
+		if secretref.Contains(v.Value) {
+			store := openStore()
+			return secretref.NewResolver(store, loadItems(store)), func() { store.Close() }
+		}
+	}
+	return secretref.NewResolver(nil, nil), func() {}
+}
+
+// runWithEnv runs the command with the resolved environment and returns its
+// exit code. With mask set its output goes through a Masker; otherwise it
+// inherits the terminal. Signals are passed on to the command.
+func runWithEnv(command, env, secrets []string, mask bool) int {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	var maskers []*secretref.Masker
+	if mask {
+		stdout := secretref.NewMasker(os.Stdout, secrets)
+		stderr := secretref.NewMasker(os.Stderr, secrets)
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		maskers = append(maskers, stdout, stderr)
+	}
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		return 127
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	for _, m := range maskers {
+		m.Flush()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		return exitError
+	default:
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		return exitError
+	}
+}
+
+// injectOptions holds the flags of the inject command
+type injectOptions struct {
+	input  string
+	output string
+}
+
+func parseInjectArgs(args []string) (injectOptions, error) {
+	var opts injectOptions
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--in-file", "-i", "--out-file", "-o":
+		default:
+			return opts, fmt.Errorf("unknown option: %s", arg)
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			value = args[i]
+		}
+
+		if name == "--in-file" || name == "-i" {
+			opts.input = value
+		} else {
+			opts.output = value
+		}
+	}
+
+	return opts, nil
+}
+
+func handleInject() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showRunHelp()
+		return
+	}
+
+	opts, err := parseInjectArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass inject help' for usage")
+		os.Exit(exitError)
+	}
+
+	var template []byte
+	if opts.input == "" || opts.input == "-" {
+		template, err = io.ReadAll(os.Stdin)
+		// The template came on stdin, so prompts have to use the terminal
+		useTerminalInput()
+	} else {
+		template, err = os.ReadFile(opts.input)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: failed to read template: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	resolver, done := newResolver([]secretref.EnvVar{{Value: string(template)}})
+	rendered, err := resolver.Render(string(template))
+	done()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	if opts.output == "" || opts.output == "-" {
+		fmt.Print(rendered)
+		return
+	}
+	if err := writePrivateFile(opts.output, []byte(rendered)); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	fmt.Fprintln(os.Stderr, tui.ColorSuccess(fmt.Sprintf("✓ Wrote %s", opts.output)))
+}
+
+// writePrivateFile writes data to a file only the owner can read, tightening
+// the mode of an existing file first
+func writePrivateFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to restrict permissions of %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func showRunHelp() {
+	help := `OpenPasswd - Secret References
+
+Secrets are referenced as openpasswd://<vault>/<item>/<field>. <item> is
+looked up like 'openpass get' does, <field> takes the same names as --field
+and can be left out for the password. <vault> is the folder an entry was
+imported into (its 1Password vault, Bitwarden folder or KeePass group), or
+"default" for any entry. Percent-encode "/" and spaces in names (%2F, %20).
+
+COMMANDS:
+    openpass run [options] -- <command> [args...]
+        Run a command with references in env files (and in the current
+        environment) resolved into its environment. Nothing is written to
+        disk and the values only reach that command.
+
+    openpass inject [-i <template>] [-o <file>]
+        Render a template (stdin by default) with every reference replaced.
+        References may be bare or written as {{ openpasswd://... }}.
+
+RUN OPTIONS:
+    --env-file, -e <file>       Env file with NAME=value lines (repeatable)
+    --mask, -m                  Replace resolved secrets in the command's
+                                output with "<concealed by openpasswd>"
+
+INJECT OPTIONS:
+    --in-file, -i <file>        Template to read (default stdin)
+    --out-file, -o <file>       File to write, created with mode 0600
+                                (default stdout)
+
+EXAMPLES:
+    # .env.tpl
+    GITHUB_TOKEN=openpasswd://default/GitHub/password
+    DB_PASSWORD=openpasswd://Work/Postgres%20prod/password
+
+    openpass run --env-file .env.tpl -- npm start
+    openpass run -e .env.tpl --mask -- ./deploy.sh
+    openpass inject -i config.yml.tpl -o config.yml
+`
+	fmt.Println(help)
+}
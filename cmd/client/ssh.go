@@ -0,0 +1,342 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/agent"
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/sshkey"
+	"github.com/r2unit/openpasswd/pkg/tui"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// sshKeygenOptions holds the flags of the ssh-keygen command
+type sshKeygenOptions struct {
+	name       string
+	keyType    string
+	bits       int
+	comment    string
+	importPath string
+}
+
+func parseSSHKeygenArgs(args []string) (sshKeygenOptions, error) {
+	opts := sshKeygenOptions{keyType: sshkey.TypeEd25519}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-t", "-b", "-C", "--import":
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return opts, fmt.Errorf("unknown option: %s", arg)
+			}
+			positional = append(positional, arg)
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return opts, fmt.Errorf("%s requires a value", arg)
+		}
+		i++
+		value := args[i]
+
+		switch arg {
+		case "-t":
+			opts.keyType = strings.ToLower(value)
+		case "-b":
+			bits, err := strconv.Atoi(value)
+			if err != nil || bits <= 0 {
+				return opts, fmt.Errorf("invalid key size: %s", value)
+			}
+			opts.bits = bits
+		case "-C":
+			opts.comment = value
+		case "--import":
+			opts.importPath = value
+		}
+	}
+
+	if len(positional) != 1 {
+		return opts, fmt.Errorf("expected one entry name, got %d", len(positional))
+	}
+	opts.name = positional[0]
+
+	if opts.bits != 0 && opts.keyType != sshkey.TypeRSA {
+		return opts, fmt.Errorf("-b only applies to RSA keys")
+	}
+	return opts, nil
+}
+
+func handleSSHKeygen() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showSSHHelp()
+		return
+	}
+
+	opts, err := parseSSHKeygenArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass ssh-keygen help' for usage")
+		os.Exit(exitError)
+	}
+
+	var key crypto.Signer
+	var comment, passphrase string
+	if opts.importPath != "" {
+		key, comment, passphrase, err = readSSHKeyFile(opts.importPath)
+	} else {
+		key, err = sshkey.Generate(opts.keyType, opts.bits)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	defer sshkey.Wipe(key)
+
+	if opts.comment == "" {
+		opts.comment = comment
+	}
+	if opts.comment == "" {
+		opts.comment = defaultSSHComment()
+	}
+
+	item, err := sshKeyItem(opts.name, opts.comment, passphrase, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	store := openStore()
+	defer store.Close()
+
+	if _, err := store.Add(item); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: failed to save key: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	fmt.Fprintln(os.Stderr, tui.ColorSuccess(fmt.Sprintf("✓ Saved SSH key %q (%s)", opts.name, item.Fields[models.FieldFingerprint])))
+	fmt.Println(item.Fields[models.FieldPublicKey])
+}
+
+// readSSHKeyFile reads an existing private key to import, asking for the
+// passphrase of legacy encrypted PEM keys. It returns the key, its comment
+// and the passphrase.
+func readSSHKeyFile(path string) (crypto.Signer, string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read key: %w", err)
+	}
+
+	var passphrase string
+	if strings.Contains(string(data), "ENCRYPTED") && strings.Contains(string(data), "Proc-Type:") {
+		passphrase, err = promptPassword("Enter the key's passphrase", false)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	key, comment, err := sshkey.ParsePrivateKey(data, passphrase)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return key, comment, passphrase, nil
+}
+
+// sshKeyItem builds the vault entry for a private key
+func sshKeyItem(name, comment, passphrase string, key crypto.Signer) (*vault.Item, error) {
+	private, err := sshkey.MarshalPrivateKey(key, comment)
+	if err != nil {
+		return nil, err
+	}
+	public, err := sshkey.AuthorizedKey(key.Public(), comment)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := sshkey.MarshalPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	item := &vault.Item{
+		Type: models.TypeSSHKey,
+		Name: name,
+		Fields: map[string]string{
+			models.FieldPrivateKey:  string(private),
+			models.FieldPublicKey:   public,
+			models.FieldComment:     comment,
+			models.FieldFingerprint: sshkey.Fingerprint(blob),
+		},
+	}
+	if passphrase != "" {
+		item.Fields[models.FieldPassphrase] = passphrase
+	}
+	return item, nil
+}
+
+// defaultSSHComment returns user@host like ssh-keygen does
+func defaultSSHComment() string {
+	name := "openpasswd"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		return name + "@" + host
+	}
+	return name
+}
+
+// sshAgentOptions holds the flags of the ssh-agent command
+type sshAgentOptions struct {
+	socket    string
+	noConfirm bool
+}
+
+func parseSSHAgentArgs(args []string) (sshAgentOptions, error) {
+	opts := sshAgentOptions{socket: agent.DefaultSSHSocketPath()}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--no-confirm":
+			opts.noConfirm = true
+		case "--socket":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return opts, fmt.Errorf("%s requires a value", arg)
+				}
+				i++
+				value = args[i]
+			}
+			opts.socket = value
+		default:
+			return opts, fmt.Errorf("unknown option: %s", arg)
+		}
+	}
+
+	return opts, nil
+}
+
+func handleSSHAgent() {
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showSSHHelp()
+		return
+	}
+
+	opts, err := parseSSHAgentArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		fmt.Fprintln(os.Stderr, "Run 'openpass ssh-agent help' for usage")
+		os.Exit(exitError)
+	}
+	if !opts.noConfirm && !stdinIsTerminal() {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError("Error: the SSH agent confirms every signature and needs a terminal (use --no-confirm to skip)\n"))
+		os.Exit(exitError)
+	}
+
+	identities := loadSSHIdentities()
+	if len(identities) == 0 {
+		fmt.Fprintln(os.Stderr, tui.ColorWarning("No SSH keys in the vault; create one with 'openpass ssh-keygen <name>'"))
+		os.Exit(exitError)
+	}
+
+	var confirm agent.SSHConfirmFunc
+	if !opts.noConfirm {
+		confirm = func(id *agent.SSHIdentity, purpose string) bool {
+			allowed, err := tui.RunSSHConfirmTUI(id.Name, id.Comment, id.Fingerprint(), purpose)
+			return err == nil && allowed
+		}
+	}
+
+	server, err := agent.ListenSSH(identities, opts.socket, confirm)
+	if err != nil {
+		for _, id := range identities {
+			sshkey.Wipe(id.Key)
+		}
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+
+	fmt.Printf("%s=%s; export %s;\n", agent.EnvSSHSocket, shellQuote(opts.socket), agent.EnvSSHSocket)
+	fmt.Fprintln(os.Stderr, tui.ColorInfo(fmt.Sprintf("Serving %d SSH key(s); press Ctrl+C to stop", len(identities))))
+	for _, id := range identities {
+		fmt.Fprintf(os.Stderr, "  %s  %s\n", id.Fingerprint(), id.Name)
+	}
+
+	if err := server.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s", tui.ColorError(fmt.Sprintf("Error: %v\n", err)))
+		os.Exit(exitError)
+	}
+	fmt.Fprintln(os.Stderr, tui.ColorInfo("SSH agent stopped"))
+}
+
+// loadSSHIdentities decrypts the SSH key entries of the vault. Keys that
+// can't be parsed are skipped with a warning.
+func loadSSHIdentities() []*agent.SSHIdentity {
+	store := openStore()
+	defer store.Close()
+
+	var identities []*agent.SSHIdentity
+	for _, item := range loadItems(store) {
+		if item.Type != models.TypeSSHKey {
+			continue
+		}
+
+		key, comment, err := sshkey.ParsePrivateKey([]byte(item.Fields[models.FieldPrivateKey]), item.Fields[models.FieldPassphrase])
+		if err == nil {
+			if c := item.Fields[models.FieldComment]; c != "" {
+				comment = c
+			}
+			var id *agent.SSHIdentity
+			if id, err = agent.NewSSHIdentity(item.Name, comment, key); err == nil {
+				identities = append(identities, id)
+				continue
+			}
+		}
+		fmt.Fprintln(os.Stderr, tui.ColorWarning(fmt.Sprintf("Skipping %q: %v", item.Name, err)))
+	}
+	return identities
+}
+
+func showSSHHelp() {
+	help := `OpenPasswd - SSH Keys
+
+SSH keys live in the vault as "ssh_key" entries (private key, public key,
+comment and passphrase). The built-in SSH agent serves them to ssh and git
+without writing them to disk, and asks before every signature.
+
+COMMANDS:
+    openpass ssh-keygen [options] <name>   Create a key in the vault and print
+                                           its public key
+    openpass ssh-agent [options]           Serve the vault's SSH keys
+
+SSH-KEYGEN OPTIONS:
+    -t <ed25519|rsa>            Key type (default ed25519)
+    -b <bits>                   RSA key size (default 3072)
+    -C <comment>                Key comment (default user@host)
+    --import <file>             Store an existing private key instead
+
+SSH-AGENT OPTIONS:
+    --socket <path>             Socket path (default under $XDG_RUNTIME_DIR)
+    --no-confirm                Sign without asking
+
+The agent stays in the foreground so it can ask for confirmation, and prints
+the SSH_AUTH_SOCK line to use in other shells. Passphrase-protected OpenSSH
+keys can't be imported; remove the passphrase with 'ssh-keygen -p' first.
+
+EXAMPLES:
+    openpass ssh-keygen github
+    openpass ssh-keygen -t rsa -b 4096 -C work@example.com work
+    openpass ssh-keygen --import ~/.ssh/id_ed25519 laptop
+    openpass get github --field public_key
+    openpass ssh-agent
+    export SSH_AUTH_SOCK=$XDG_RUNTIME_DIR/openpasswd/ssh-agent.sock
+`
+	fmt.Println(help)
+}
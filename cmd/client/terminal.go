@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strings"
 )
 
@@ -34,13 +35,13 @@ func promptPassword(message string, optional bool) (string, error) {
 	prompt.WriteString(": ")
 	prompt.WriteString(colorReset)
 
-	fmt.Print(prompt.String())
+	fmt.Fprint(os.Stderr, prompt.String())
 
 	password, err := readPasswordWithBullets(prompt.String())
 	if err != nil {
 		return "", err
 	}
 
-	fmt.Println() // New line after password entry
+	fmt.Fprintln(os.Stderr) // New line after password entry
 	return password, nil
 }
@@ -49,21 +49,28 @@ func readPasswordWithBullets(prompt string) (string, error) {
 			if len(password) > 0 {
 				password = password[:len(password)-1]
 				// Clear the line and reprint
-				fmt.Print("\r")
-				fmt.Print(prompt)
-				fmt.Print(colorGrey)
-				fmt.Print(strings.Repeat("•", len(password)))
-				fmt.Print(colorReset)
+				fmt.Fprint(os.Stderr, "\r")
+				fmt.Fprint(os.Stderr, prompt)
+				fmt.Fprint(os.Stderr, colorGrey)
+				fmt.Fprint(os.Stderr, strings.Repeat("•", len(password)))
+				fmt.Fprint(os.Stderr, colorReset)
 			}
 			continue
 		}
 
 		password = append(password, buf[0])
 		// Print grey bullet
-		fmt.Print(colorGrey)
-		fmt.Print("•")
-		fmt.Print(colorReset)
+		fmt.Fprint(os.Stderr, colorGrey)
+		fmt.Fprint(os.Stderr, "•")
+		fmt.Fprint(os.Stderr, colorReset)
 	}
 
 	return string(password), nil
 }
+
+// stdinIsTerminal reports whether stdin is a terminal we can prompt on
+func stdinIsTerminal() bool {
+	var state syscall.Termios
+	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, os.Stdin.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&state)), 0, 0, 0)
+	return err == 0
+}
@@ -61,13 +61,13 @@ func readPasswordWithBullets(prompt string) (string, error) {
 			if buf[0] == 8 { // Backspace on Windows
 				if len(password) > 0 {
 					password = password[:len(password)-1]
-					fmt.Print("\b \b") // Backspace, space, backspace
+					fmt.Fprint(os.Stderr, "\b \b") // Backspace, space, backspace
 				}
 				continue
 			}
 
 			password = append(password, buf[0])
-			fmt.Print(colorGrey + "•" + colorReset)
+			fmt.Fprint(os.Stderr, colorGrey+"•"+colorReset)
 		}
 
 		return string(password), nil
@@ -87,3 +87,10 @@ func readPasswordWithBullets(prompt string) (string, error) {
 
 	return strings.TrimRight(line, "\r\n"), nil
 }
+
+// stdinIsTerminal reports whether stdin is a console we can prompt on
+func stdinIsTerminal() bool {
+	var mode uint32
+	r, _, _ := procGetConsoleMode.Call(os.Stdin.Fd(), uintptr(unsafe.Pointer(&mode)))
+	return r != 0
+}
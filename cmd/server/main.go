@@ -1,9 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
+	"github.com/r2unit/openpasswd/pkg/config"
+	"github.com/r2unit/openpasswd/pkg/server"
 	"github.com/r2unit/openpasswd/pkg/version"
 )
 
@@ -31,7 +40,7 @@ func showHelp() {
 	help := `OpenPasswd Server - Sync server for OpenPasswd password manager
 
 COMMANDS:
-    openpasswd-server start              Start the sync server
+    openpasswd-server start [options]    Start the sync server
     openpasswd-server version            Show version information
     openpasswd-server help               Show this help message
 
@@ -39,43 +48,199 @@ OPTIONS:
     --help, -h                           Show this help message
     --version, -v                        Show version number
 
+START OPTIONS:
+    --config, -c <file>                  Config file (default ~/.config/openpasswd/server.toml)
+    --listen, -l <addr>                  Address to listen on (default :8443)
+    --data-dir, -d <dir>                 Where accounts and items are stored
+                                         (default ~/.config/openpasswd/server)
+    --tls <mode>                         auto, files or off (default auto)
+    --tls-cert <file>                    TLS certificate (PEM)
+    --tls-key <file>                     TLS private key (PEM)
+    --session-ttl <duration>             How long logins last (default 24h)
+    --log-format <format>                text or json (default text)
+
 DESCRIPTION:
     The OpenPasswd server provides sync capabilities for the OpenPasswd
     password manager, allowing you to sync your passwords across multiple
     devices securely.
 
-EXAMPLES:
-    openpasswd-server start              # Start the sync server
-    openpasswd-server version            # Show version info
+    The server is zero-knowledge: clients encrypt every entry before it is
+    uploaded and log in with SRP, so neither the passphrase nor the vault
+    key ever reaches it.
+
+    With --tls auto the server uses --tls-cert and --tls-key when given,
+    and otherwise a self-signed certificate kept in <data-dir>/tls; its
+    fingerprint is logged at startup. --tls off serves plain HTTP, for
+    running behind a reverse proxy that terminates TLS.
+
+    SIGINT or SIGTERM stop the server gracefully, letting requests in
+    flight finish.
 
 CONFIGURATION:
-    Server configuration will use the same config directory as the client:
-    ~/.config/openpasswd/
+    Flags override the [server] table of the config file:
+
+    [server]
+    listen = ":8443"
+    data_dir = "/var/lib/openpasswd-server"
+    tls = "files"
+    tls_cert = "/etc/openpasswd/cert.pem"
+    tls_key = "/etc/openpasswd/key.pem"
+    session_ttl = "12h"
+    registration_key = "only-people-who-know-this-can-sign-up"
 
-FUTURE FEATURES:
-    - End-to-end encrypted sync
-    - Multi-device support
-    - Conflict resolution
-    - RESTful API
-    - WebSocket support for real-time sync
+EXAMPLES:
+    openpasswd-server start                      # Self-signed TLS on :8443
+    openpasswd-server start -c /etc/openpasswd/server.toml
+    openpasswd-server start --listen 127.0.0.1:8080 --tls off --log-format json
+    openpasswd-server version                    # Show version info
 
 For more information, visit: https://github.com/r2unit/openpasswd
 `
 	fmt.Println(help)
 }
 
+// startOptions holds the flags of the start command
+type startOptions struct {
+	configPath string
+	logFormat  string
+	overrides  config.ServerConfig
+}
+
+func parseStartArgs(args []string) (startOptions, error) {
+	opts := startOptions{logFormat: "text"}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--config", "-c":
+			opts.configPath = value
+		case "--listen", "-l":
+			opts.overrides.Listen = value
+		case "--data-dir", "-d":
+			opts.overrides.DataDir = value
+		case "--tls":
+			opts.overrides.TLS = value
+		case "--tls-cert":
+			opts.overrides.TLSCert = value
+		case "--tls-key":
+			opts.overrides.TLSKey = value
+		case "--session-ttl":
+			opts.overrides.SessionTTL = value
+		case "--log-format":
+			if value != "text" && value != "json" {
+				return opts, fmt.Errorf("invalid log format: %s (use text or json)", value)
+			}
+			opts.logFormat = value
+		default:
+			return opts, fmt.Errorf("unknown option: %s", arg)
+		}
+	}
+
+	return opts, nil
+}
+
 func handleStart() {
-	fmt.Println("OpenPasswd Server - Starting...")
-	fmt.Println()
-	fmt.Println("⚠ Server functionality is currently under development")
-	fmt.Println()
-	fmt.Println("The sync server will provide:")
-	fmt.Println("  • End-to-end encrypted synchronization")
-	fmt.Println("  • Multi-device support")
-	fmt.Println("  • Conflict resolution")
-	fmt.Println("  • RESTful API for client connections")
-	fmt.Println()
-	fmt.Println("Coming soon in a future release!")
+	if len(os.Args) >= 3 && (os.Args[2] == "help" || os.Args[2] == "--help" || os.Args[2] == "-h") {
+		showHelp()
+		return
+	}
+
+	if err := start(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func start(args []string) error {
+	opts, err := parseStartArgs(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadServerConfig(opts.configPath)
+	if err != nil {
+		return err
+	}
+	cfg.Merge(opts.overrides)
+
+	sessionTTL, err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, nil)
+	if opts.logFormat == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, nil)
+	}
+	logger := slog.New(handler)
+
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	store, err := server.OpenStore(filepath.Join(cfg.DataDir, "store.json"))
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := serverTLSConfig(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	srv := server.New(store, server.Options{
+		RegistrationKey: cfg.RegistrationKey,
+		SessionTTL:      sessionTTL,
+		Logger:          logger,
+	})
+	if cfg.RegistrationKey == "" {
+		logger.Warn("registration is open to anyone; set registration_key to restrict it")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := srv.ListenAndServe(ctx, cfg.Listen, tlsConfig); err != nil {
+		return err
+	}
+	logger.Info("server stopped")
+	return nil
+}
+
+// serverTLSConfig returns the TLS configuration for the configured mode,
+// nil for plain HTTP
+func serverTLSConfig(cfg config.ServerConfig, logger *slog.Logger) (*tls.Config, error) {
+	mode := strings.ToLower(cfg.TLS)
+	if mode == config.TLSOff {
+		logger.Warn("TLS is off; only run this behind a proxy that terminates TLS")
+		return nil, nil
+	}
+
+	certFile, keyFile := cfg.TLSCert, cfg.TLSKey
+	if certFile == "" {
+		var err error
+		certFile, keyFile, err = server.SelfSignedCertificate(filepath.Join(cfg.DataDir, "tls"))
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("using a self-signed certificate", "cert", certFile)
+	}
+
+	tlsConfig, err := server.LoadTLSConfig(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("TLS certificate", "sha256", server.CertificateFingerprint(tlsConfig))
+	return tlsConfig, nil
 }
 
 func handleVersion() {
@@ -0,0 +1,133 @@
+package agent
+
+// Package agent keeps an unlocked vault in a long-lived process, in the
+// spirit of ssh-agent and gpg-agent, so that commands don't have to ask for
+// the passphrase and run the KDF every time.
+//
+// The agent holds the derived key in locked memory and serves get, list,
+// add, update and delete requests on a Unix socket in a directory only the owner can enter.
+// Commands that need the key itself, like the list TUI, get it with a key
+// request, the way gpg-agent hands its cached passphrase to gpg.
+// Every connection is checked against the peer credentials of the socket,
+// on both ends. The agent locks, wiping the key and exiting, after an idle
+// timeout or when asked to by 'openpass lock'.
+//
+// The protocol is one JSON request per line, each answered by one JSON
+// response per line.
+//
+// The package also has an SSH agent (see ListenSSH) that serves the vault's
+// SSH keys to ssh and git over the standard agent protocol, on a socket
+// protected the same way.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// EnvSocket names the environment variable holding the agent socket path
+const EnvSocket = "OPENPASSWD_AGENT_SOCK"
+
+// Operations of the agent protocol
+const (
+	OpGet    = "get"
+	OpList   = "list"
+	OpAdd    = "add"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpKey    = "key"
+	OpLock   = "lock"
+)
+
+// Error codes of the agent protocol, for errors callers tell apart
+const (
+	codeNoMatch   = "no_match"
+	codeAmbiguous = "ambiguous"
+	codeLocked    = "locked"
+	codeModified  = "modified"
+)
+
+// maxMessageSize bounds a single request or response line
+const maxMessageSize = 4 << 20
+
+// Request is a single call to the agent
+type Request struct {
+	Op    string          `json:"op"`
+	Query string          `json:"query,omitempty"`
+	Mode  vault.MatchMode `json:"mode,omitempty"`
+	Item  *vault.Item     `json:"item,omitempty"`
+	ID    int64           `json:"id,omitempty"`
+}
+
+// Response answers a Request. Items holds only IDs and names when the
+// error code is ambiguous.
+type Response struct {
+	Error string        `json:"error,omitempty"`
+	Code  string        `json:"code,omitempty"`
+	Item  *vault.Item   `json:"item,omitempty"`
+	Items []*vault.Item `json:"items,omitempty"`
+	ID    int64         `json:"id,omitempty"`
+	Key   []byte        `json:"key,omitempty"`
+}
+
+// DefaultSocketPath returns the agent socket in the user's runtime
+// directory, or in a per-user directory under the system temp dir
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "openpasswd", "agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("openpasswd-%d", os.Getuid()), "agent.sock")
+}
+
+// SocketPath returns the socket named by OPENPASSWD_AGENT_SOCK, falling
+// back to the default path
+func SocketPath() string {
+	if path := os.Getenv(EnvSocket); path != "" {
+		return path
+	}
+	return DefaultSocketPath()
+}
+
+// privateDir creates the socket directory or checks that an existing one
+// belongs to the current user and isn't open to anyone else
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check socket directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if uid, ok := fileOwner(info); ok && uid != os.Getuid() {
+		return fmt.Errorf("%s belongs to another user", dir)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%s is accessible by other users (mode %04o)", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// checkSocket checks that the socket file belongs to the current user
+func checkSocket(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s is not a socket", path)
+	}
+	if uid, ok := fileOwner(info); ok && uid != os.Getuid() {
+		return fmt.Errorf("%s belongs to another user", path)
+	}
+	return nil
+}
+
+// errPeer is returned when the other end of the socket is another user
+var errPeer = errors.New("agent socket peer belongs to another user")
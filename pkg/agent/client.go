@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/database"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// Client talks to a running agent. It implements vault.Store.
+type Client struct {
+	conn    *net.UnixConn
+	encoder *json.Encoder
+	decoder *json.Decoder
+}
+
+// Dial connects to the agent on path after checking that the socket and
+// the process behind it belong to the current user
+func Dial(path string) (*Client, error) {
+	if err := checkSocket(path); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to agent: %w", err)
+	}
+
+	uid, err := peerUID(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to check agent credentials: %w", err)
+	}
+	if uid != os.Getuid() {
+		conn.Close()
+		return nil, errPeer
+	}
+
+	return &Client{
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+		decoder: json.NewDecoder(conn),
+	}, nil
+}
+
+// call sends a request and waits for the response
+func (c *Client) call(req *Request) (*Response, error) {
+	c.conn.SetDeadline(time.Now().Add(connTimeout))
+
+	if err := c.encoder.Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request to agent: %w", err)
+	}
+
+	var resp Response
+	if err := c.decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read agent response: %w", err)
+	}
+	return &resp, nil
+}
+
+// Items returns every entry in the vault
+func (c *Client) Items() ([]*vault.Item, error) {
+	resp, err := c.call(&Request{Op: OpList})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, responseError(resp, "")
+	}
+	return resp.Items, nil
+}
+
+// Find resolves a query to a single entry. The errors match vault.Find.
+func (c *Client) Find(query string, mode vault.MatchMode) (*vault.Item, error) {
+	resp, err := c.call(&Request{Op: OpGet, Query: query, Mode: mode})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, responseError(resp, query)
+	}
+	return resp.Item, nil
+}
+
+// Add stores a new entry and returns its ID
+func (c *Client) Add(item *vault.Item) (int64, error) {
+	resp, err := c.call(&Request{Op: OpAdd, Item: item})
+	if err != nil {
+		return 0, err
+	}
+	if resp.Error != "" {
+		return 0, responseError(resp, "")
+	}
+	return resp.ID, nil
+}
+
+// Update replaces a stored entry with the same ID
+func (c *Client) Update(item *vault.Item) error {
+	resp, err := c.call(&Request{Op: OpUpdate, Item: item})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return responseError(resp, "")
+	}
+	return nil
+}
+
+// Delete removes an entry
+func (c *Client) Delete(id int64) error {
+	resp, err := c.call(&Request{Op: OpDelete, ID: id})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return responseError(resp, "")
+	}
+	return nil
+}
+
+// Key returns the vault key, for unlocking a local session with
+// vault.Session.UnlockWithKey. The caller wipes it.
+func (c *Client) Key() ([]byte, error) {
+	resp, err := c.call(&Request{Op: OpKey})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, responseError(resp, "")
+	}
+	return resp.Key, nil
+}
+
+// Lock tells the agent to wipe the key and exit
+func (c *Client) Lock() error {
+	resp, err := c.call(&Request{Op: OpLock})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return responseError(resp, "")
+	}
+	return nil
+}
+
+// Close closes the connection; the agent keeps running
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// responseError turns an error response back into the vault error it was
+// made from
+func responseError(resp *Response, query string) error {
+	switch resp.Code {
+	case codeNoMatch:
+		return fmt.Errorf("%w for %q", vault.ErrNoMatch, query)
+	case codeAmbiguous:
+		return &vault.AmbiguousError{Query: query, Matches: resp.Items}
+	case codeLocked:
+		return vault.ErrLocked
+	case codeModified:
+		return database.ErrModified
+	}
+	return errors.New(resp.Error)
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// HelperCommand is the hidden sub-command that runs the agent in the
+// background. Binaries that call Start must dispatch it to RunHelper.
+const HelperCommand = "__agent"
+
+// Start runs the agent as a detached copy of the current binary and returns
+// its process ID. The key is handed over on the helper's stdin, so it never
+// appears in arguments or the environment.
+func Start(session *vault.Session, path string, timeout time.Duration) (int, error) {
+	encryptor, err := session.Encryptor()
+	if err != nil {
+		return 0, err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	cmd := exec.Command(exe, HelperCommand, path, timeout.String())
+	detach(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start agent: %w", err)
+	}
+
+	_, err = stdin.Write(encryptor.GetKey())
+	if closeErr := stdin.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return 0, fmt.Errorf("failed to hand the key to the agent: %w", err)
+	}
+
+	// The helper reports "ok" once it listens, or the error that stopped it
+	status, err := bufio.NewReader(stdout).ReadString('\n')
+	status = strings.TrimSpace(status)
+	if status != "ok" {
+		_ = cmd.Wait()
+		if status == "" && err != nil {
+			return 0, fmt.Errorf("agent exited during startup: %w", err)
+		}
+		return 0, fmt.Errorf("%s", strings.TrimPrefix(status, "error: "))
+	}
+
+	pid := cmd.Process.Pid
+	_ = cmd.Process.Release()
+	return pid, nil
+}
+
+// RunHelper is the entry point of the detached agent process.
+// args are the socket path and the idle timeout, the key is read from stdin.
+func RunHelper(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s <socket> <timeout>", HelperCommand)
+	}
+
+	timeout, err := time.ParseDuration(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	server, err := listenWithKey(args[0], timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "error: %v\n", err)
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, "ok")
+	os.Stdout.Close()
+
+	err = server.Run()
+	if closeErr := server.session.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// listenWithKey unlocks the vault with the key on stdin and opens the socket
+func listenWithKey(path string, timeout time.Duration) (*Server, error) {
+	key, err := io.ReadAll(io.LimitReader(os.Stdin, 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	defer crypto.WipeMemory(key)
+
+	session, err := vault.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := session.UnlockWithKey(key); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	server, err := Listen(session, path, timeout)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return server, nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd
+
+package agent
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// peerUID fails where peer credentials aren't available, so the agent
+// refuses every connection rather than serving unchecked ones
+func peerUID(conn *net.UnixConn) (int, error) {
+	return -1, errors.New("peer credentials are not supported on this platform")
+}
+
+// fileOwner can't tell the owner of a file on this platform
+func fileOwner(info os.FileInfo) (int, bool) {
+	return -1, false
+}
@@ -0,0 +1,296 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/database"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// connTimeout closes connections that stay silent this long
+const connTimeout = time.Minute
+
+// Server serves an unlocked vault session on a Unix socket
+type Server struct {
+	session  *vault.Session
+	listener *net.UnixListener
+	path     string
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	idle   *time.Timer
+	done   chan struct{}
+	locked bool
+}
+
+// Listen locks the session key in memory and opens the socket. The agent
+// locks itself after timeout without requests; zero disables the timeout.
+func Listen(session *vault.Session, path string, timeout time.Duration) (*Server, error) {
+	encryptor, err := session.Encryptor()
+	if err != nil {
+		return nil, err
+	}
+	if err := encryptor.LockMemory(); err != nil {
+		return nil, err
+	}
+
+	listener, err := listenSocket(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		session:  session,
+		listener: listener,
+		path:     path,
+		timeout:  timeout,
+		done:     make(chan struct{}),
+	}
+	if timeout > 0 {
+		s.idle = time.AfterFunc(timeout, s.Lock)
+	}
+	return s, nil
+}
+
+// listenSocket opens a Unix socket only the current user can reach, in a
+// private directory. A stale socket is replaced, a live agent's never is.
+func listenSocket(path string) (*net.UnixListener, error) {
+	if err := privateDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Lstat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("an agent is already listening on %s", path)
+		}
+		if err := checkSocket(path); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	listener.SetUnlinkOnClose(true)
+
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	return listener, nil
+}
+
+// Path returns the socket path
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Run serves requests until the agent is locked, locking it on SIGINT and
+// SIGTERM as well
+func (s *Server) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		select {
+		case <-signals:
+			s.Lock()
+		case <-s.done:
+		}
+	}()
+
+	return s.Serve()
+}
+
+// Serve accepts connections until the agent is locked
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		go s.handle(conn)
+	}
+}
+
+// Lock wipes the key and stops the agent
+func (s *Server) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return
+	}
+	s.locked = true
+
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+	s.session.Lock()
+	close(s.done)
+	s.listener.Close()
+}
+
+// Done is closed once the agent is locked
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// touch restarts the idle timeout
+func (s *Server) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.idle != nil && !s.locked {
+		s.idle.Reset(s.timeout)
+	}
+}
+
+func (s *Server) handle(conn *net.UnixConn) {
+	defer conn.Close()
+
+	uid, err := peerUID(conn)
+	if err != nil || uid != os.Getuid() {
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(connTimeout))
+		if !scanner.Scan() {
+			return
+		}
+
+		var req Request
+		var resp *Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp = &Response{Error: fmt.Sprintf("invalid request: %v", err)}
+		} else {
+			s.touch()
+			resp = s.dispatch(&req)
+		}
+
+		err := encoder.Encode(resp)
+		crypto.WipeMemory(resp.Key)
+		if err != nil {
+			return
+		}
+		if req.Op == OpLock {
+			s.Lock()
+			return
+		}
+	}
+}
+
+func (s *Server) dispatch(req *Request) *Response {
+	// The CLI, the TUI and imports write the database directly, so serve
+	// every request from the file as it is now
+	if req.Op != OpLock {
+		if err := s.session.Reload(); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	switch req.Op {
+	case OpGet:
+		item, err := s.session.Find(req.Query, req.Mode)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return &Response{Item: item}
+
+	case OpList:
+		items, err := s.session.Items()
+		if err != nil {
+			return errorResponse(err)
+		}
+		return &Response{Items: items}
+
+	case OpAdd:
+		if req.Item == nil {
+			return &Response{Error: "missing item"}
+		}
+		id, err := s.session.Add(req.Item)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return &Response{ID: id}
+
+	case OpUpdate:
+		if req.Item == nil {
+			return &Response{Error: "missing item"}
+		}
+		if err := s.session.Update(req.Item); err != nil {
+			return errorResponse(err)
+		}
+		return &Response{}
+
+	case OpDelete:
+		if err := s.session.Delete(req.ID); err != nil {
+			return errorResponse(err)
+		}
+		return &Response{}
+
+	case OpKey:
+		key, err := s.session.Key()
+		if err != nil {
+			return errorResponse(err)
+		}
+		return &Response{Key: key}
+
+	case OpLock:
+		return &Response{}
+
+	default:
+		return &Response{Error: fmt.Sprintf("unknown operation: %s", req.Op)}
+	}
+}
+
+// errorResponse encodes an error, keeping what callers need to tell the
+// lookup errors apart. Ambiguous matches only carry IDs and names.
+func errorResponse(err error) *Response {
+	resp := &Response{Error: err.Error()}
+
+	var ambiguous *vault.AmbiguousError
+	switch {
+	case errors.Is(err, vault.ErrNoMatch):
+		resp.Code = codeNoMatch
+	case errors.As(err, &ambiguous):
+		resp.Code = codeAmbiguous
+		for _, m := range ambiguous.Matches {
+			resp.Items = append(resp.Items, &vault.Item{ID: m.ID, Type: m.Type, Name: m.Name})
+		}
+	case errors.Is(err, vault.ErrLocked):
+		resp.Code = codeLocked
+	case errors.Is(err, database.ErrModified):
+		resp.Code = codeModified
+	}
+	return resp
+}
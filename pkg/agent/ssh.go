@@ -0,0 +1,278 @@
+package agent
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/r2unit/openpasswd/pkg/sshkey"
+)
+
+// EnvSSHSocket names the environment variable SSH clients read the agent
+// socket from
+const EnvSSHSocket = "SSH_AUTH_SOCK"
+
+// Messages of the SSH agent protocol (draft-miller-ssh-agent) that the
+// agent answers; everything else gets SSH_AGENT_FAILURE
+const (
+	sshAgentFailure          = 5
+	sshAgentRequestIDs       = 11
+	sshAgentIdentitiesAnswer = 12
+	sshAgentSignRequest      = 13
+	sshAgentSignResponse     = 14
+)
+
+// maxSSHMessageSize bounds a single SSH agent message
+const maxSSHMessageSize = 256 << 10
+
+// SSHIdentity is a key served by the SSH agent
+type SSHIdentity struct {
+	Name    string // Vault entry the key came from
+	Comment string
+	Key     crypto.Signer
+
+	blob []byte
+}
+
+// Fingerprint returns the SHA256 fingerprint of the public key
+func (id *SSHIdentity) Fingerprint() string {
+	return sshkey.Fingerprint(id.blob)
+}
+
+// SSHConfirmFunc decides whether a sign request may use a key. purpose
+// describes what the client is signing, as far as it can be told.
+type SSHConfirmFunc func(id *SSHIdentity, purpose string) bool
+
+// SSHServer serves vault SSH keys over the SSH agent protocol
+type SSHServer struct {
+	listener   *net.UnixListener
+	path       string
+	identities []*SSHIdentity
+	confirm    SSHConfirmFunc
+
+	confirmMu sync.Mutex // One confirmation prompt at a time
+	mu        sync.Mutex
+	done      chan struct{}
+	closed    bool
+}
+
+// NewSSHIdentity prepares a private key for the SSH agent
+func NewSSHIdentity(name, comment string, key crypto.Signer) (*SSHIdentity, error) {
+	blob, err := sshkey.MarshalPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &SSHIdentity{Name: name, Comment: comment, Key: key, blob: blob}, nil
+}
+
+// DefaultSSHSocketPath returns the SSH agent socket, next to the vault
+// agent's
+func DefaultSSHSocketPath() string {
+	return filepath.Join(filepath.Dir(DefaultSocketPath()), "ssh-agent.sock")
+}
+
+// ListenSSH opens the SSH agent socket. Every sign request is passed to
+// confirm first; a nil confirm allows all of them.
+func ListenSSH(identities []*SSHIdentity, path string, confirm SSHConfirmFunc) (*SSHServer, error) {
+	listener, err := listenSocket(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SSHServer{
+		listener:   listener,
+		path:       path,
+		identities: identities,
+		confirm:    confirm,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Path returns the socket path
+func (s *SSHServer) Path() string {
+	return s.path
+}
+
+// Run serves requests until the agent is closed, closing it on SIGINT and
+// SIGTERM as well
+func (s *SSHServer) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		select {
+		case <-signals:
+			s.Close()
+		case <-s.done:
+		}
+	}()
+
+	return s.Serve()
+}
+
+// Serve accepts connections until the agent is closed
+func (s *SSHServer) Serve() error {
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close wipes the keys and stops the agent
+func (s *SSHServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	close(s.done)
+	s.listener.Close()
+
+	// Wait for a sign request in progress before wiping its key
+	s.confirmMu.Lock()
+	defer s.confirmMu.Unlock()
+	for _, id := range s.identities {
+		sshkey.Wipe(id.Key)
+	}
+}
+
+// handle serves one client. SSH keeps its connection for the whole session,
+// so there is no idle deadline here.
+func (s *SSHServer) handle(conn *net.UnixConn) {
+	defer conn.Close()
+
+	uid, err := peerUID(conn)
+	if err != nil || uid != os.Getuid() {
+		return
+	}
+
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size == 0 || size > maxSSHMessageSize {
+			return
+		}
+
+		msg := make([]byte, size)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+
+		reply := s.dispatch(msg)
+		out := binary.BigEndian.AppendUint32(nil, uint32(len(reply)))
+		if _, err := conn.Write(append(out, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *SSHServer) dispatch(msg []byte) []byte {
+	failure := []byte{sshAgentFailure}
+
+	switch msg[0] {
+	case sshAgentRequestIDs:
+		var b sshkey.Builder
+		b.Byte(sshAgentIdentitiesAnswer).Uint32(uint32(len(s.identities)))
+		for _, id := range s.identities {
+			b.String(id.blob).String([]byte(id.Comment))
+		}
+		return b.Bytes()
+
+	case sshAgentSignRequest:
+		r := sshkey.NewReader(msg[1:])
+		blob := r.String()
+		data := r.String()
+		flags := r.Uint32()
+		if r.Err() != nil {
+			return failure
+		}
+
+		id := s.identity(blob)
+		if id == nil {
+			return failure
+		}
+
+		s.confirmMu.Lock()
+		defer s.confirmMu.Unlock()
+		select {
+		case <-s.done:
+			return failure
+		default:
+		}
+		if s.confirm != nil && !s.confirm(id, signPurpose(data)) {
+			return failure
+		}
+
+		sig, err := sshkey.Sign(id.Key, data, flags)
+		if err != nil {
+			return failure
+		}
+		var b sshkey.Builder
+		b.Byte(sshAgentSignResponse).String(sig)
+		return b.Bytes()
+
+	default:
+		// Adding, removing and locking keys is done in the vault, not here
+		return failure
+	}
+}
+
+// identity returns the identity with the wire-encoded public key blob
+func (s *SSHServer) identity(blob []byte) *SSHIdentity {
+	for _, id := range s.identities {
+		if bytes.Equal(id.blob, blob) {
+			return id
+		}
+	}
+	return nil
+}
+
+// signPurpose describes the data of a sign request for the confirmation
+// prompt: an SSH login (RFC 4252 section 7) or an SSHSIG signature as made
+// by 'ssh-keygen -Y sign' and git
+func signPurpose(data []byte) string {
+	if rest, ok := bytes.CutPrefix(data, []byte("SSHSIG")); ok {
+		namespace := string(sshkey.NewReader(rest).String())
+		if namespace == "" {
+			return "sign data"
+		}
+		return fmt.Sprintf("sign data (namespace %q)", namespace)
+	}
+
+	r := sshkey.NewReader(data)
+	r.String() // session identifier
+	msgType := r.Byte()
+	user := string(r.String())
+	r.String() // service
+	method := string(r.String())
+	if r.Err() == nil && msgType == 50 && method == "publickey" {
+		return fmt.Sprintf("log in as %q", user)
+	}
+	return "sign data"
+}
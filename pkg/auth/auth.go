@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
@@ -18,9 +19,9 @@ type Token struct {
 }
 
 type Session struct {
-	Token      string
-	Passphrase string
-	ExpiresAt  time.Time
+	Token     string
+	Username  string
+	ExpiresAt time.Time
 }
 
 func GenerateToken() (string, error) {
@@ -31,6 +32,14 @@ func GenerateToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
+// TokenFromKey derives a bearer token from a key both sides of a login
+// agreed on, so the token itself never crosses the wire
+func TokenFromKey(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("openpasswd session token"))
+	return base64.URLEncoding.EncodeToString(mac.Sum(nil))
+}
+
 func HashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
 	return base64.URLEncoding.EncodeToString(hash[:])
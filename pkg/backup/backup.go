@@ -0,0 +1,185 @@
+package backup
+
+// Package backup writes and reads encrypted, self-contained vault backups.
+//
+// A backup is a JSON document with a plaintext header describing how to
+// derive the key (KDF, parameters, salt) and an AES-256-GCM ciphertext of
+// the decrypted items. The header is bound to the ciphertext as additional
+// authenticated data, so it can't be tampered with (e.g. to lower the KDF
+// cost) without the restore failing. Because the salt and parameters travel
+// with the file, a backup can be restored into any vault, including a
+// freshly initialized one with a different salt or passphrase.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+const (
+	// Format identifies openpasswd backup files
+	Format = "openpasswd-backup"
+
+	// Version is the current backup file version
+	Version = 1
+
+	kdfPBKDF2    = "pbkdf2-sha256"
+	cipherAESGCM = "aes-256-gcm"
+
+	defaultIterations = 600000
+	minIterations     = 100000
+	saltSize          = 32
+)
+
+// ErrDecrypt is returned when the backup can't be decrypted, usually
+// because the passphrase is wrong
+var ErrDecrypt = errors.New("failed to decrypt backup (wrong passphrase or corrupted file)")
+
+// Header is the unencrypted part of a backup
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	KDF       KDF       `json:"kdf"`
+	Cipher    string    `json:"cipher"`
+	Nonce     []byte    `json:"nonce"`
+}
+
+// KDF describes the key derivation used for a backup
+type KDF struct {
+	Algorithm  string `json:"algorithm"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+}
+
+type file struct {
+	Header
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type payload struct {
+	Items []*vault.Item `json:"items"`
+}
+
+// Write encrypts items with a key derived from passphrase and writes the backup to w
+func Write(w io.Writer, items []*vault.Item, passphrase string) error {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	header := Header{
+		Format:    Format,
+		Version:   Version,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		KDF: KDF{
+			Algorithm:  kdfPBKDF2,
+			Iterations: defaultIterations,
+			Salt:       salt,
+		},
+		Cipher: cipherAESGCM,
+	}
+
+	plaintext, err := json.Marshal(payload{Items: items})
+	if err != nil {
+		return fmt.Errorf("failed to encode items: %w", err)
+	}
+	defer crypto.WipeMemory(plaintext)
+
+	aead, err := newAEAD(passphrase, header.KDF)
+	if err != nil {
+		return err
+	}
+
+	header.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(header.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	aad, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	out := file{
+		Header:     header,
+		Ciphertext: aead.Seal(nil, header.Nonce, plaintext, aad),
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// Read decrypts a backup written by Write
+func Read(r io.Reader, passphrase string) ([]*vault.Item, error) {
+	var in file
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("failed to parse backup: %w", err)
+	}
+
+	if in.Format != Format {
+		return nil, fmt.Errorf("not an %s file", Format)
+	}
+	if in.Version != Version {
+		return nil, fmt.Errorf("unsupported backup version %d", in.Version)
+	}
+	if in.Cipher != cipherAESGCM {
+		return nil, fmt.Errorf("unsupported backup cipher %q", in.Cipher)
+	}
+
+	aead, err := newAEAD(passphrase, in.KDF)
+	if err != nil {
+		return nil, err
+	}
+	if len(in.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid backup nonce")
+	}
+
+	aad, err := json.Marshal(in.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, in.Nonce, in.Ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	defer crypto.WipeMemory(plaintext)
+
+	var p payload
+	if err := json.Unmarshal(plaintext, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode items: %w", err)
+	}
+
+	return p.Items, nil
+}
+
+func newAEAD(passphrase string, kdf KDF) (cipher.AEAD, error) {
+	if kdf.Algorithm != kdfPBKDF2 {
+		return nil, fmt.Errorf("unsupported backup KDF %q", kdf.Algorithm)
+	}
+	if kdf.Iterations < minIterations {
+		return nil, fmt.Errorf("backup KDF iteration count %d is below the minimum of %d", kdf.Iterations, minIterations)
+	}
+	if len(kdf.Salt) < 16 {
+		return nil, errors.New("backup salt is too short")
+	}
+
+	key := crypto.DeriveKey(passphrase, kdf.Salt, crypto.KDFParams{Iterations: kdf.Iterations})
+	defer crypto.WipeMemory(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
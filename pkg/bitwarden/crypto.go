@@ -0,0 +1,151 @@
+package bitwarden
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+)
+
+// encTypeAESCBC256HMAC is the EncString type of password-protected exports:
+// "2.<iv>|<ciphertext>|<mac>", each part base64-encoded
+const encTypeAESCBC256HMAC = "2"
+
+// ErrWrongPassword is returned when the export password doesn't match
+var ErrWrongPassword = errors.New("wrong password for encrypted Bitwarden export")
+
+// symmetricKey is a stretched Bitwarden key: AES-256-CBC plus HMAC-SHA256
+type symmetricKey struct {
+	enc []byte
+	mac []byte
+}
+
+func (k *symmetricKey) wipe() {
+	crypto.WipeMemory(k.enc)
+	crypto.WipeMemory(k.mac)
+}
+
+// deriveExportKey turns the export password into the key of a
+// password-protected export, like Bitwarden's PIN/export key derivation
+func deriveExportKey(password string, e *EncryptedExport) (*symmetricKey, error) {
+	var master []byte
+
+	switch e.KDFType {
+	case KDFPBKDF2:
+		if e.KDFIterations == 0 {
+			return nil, errors.New("missing PBKDF2 iteration count")
+		}
+		// The salt is used as its UTF-8 text, not base64-decoded
+		master = crypto.DeriveKey(password, []byte(e.Salt), crypto.KDFParams{Iterations: int(e.KDFIterations)})
+
+	case KDFArgon2id:
+		if e.KDFIterations == 0 || e.KDFMemory == 0 || e.KDFParallelism == 0 {
+			return nil, errors.New("missing Argon2id parameters")
+		}
+		salt := sha256.Sum256([]byte(e.Salt))
+		master = crypto.Argon2idKey([]byte(password), salt[:], crypto.Argon2Params{
+			Time:        e.KDFIterations,
+			Memory:      e.KDFMemory * 1024,
+			Parallelism: e.KDFParallelism,
+			KeyLen:      32,
+		})
+
+	default:
+		return nil, fmt.Errorf("unsupported KDF type %d", e.KDFType)
+	}
+	defer crypto.WipeMemory(master)
+
+	// Stretch the 32-byte master key into separate encryption and MAC keys
+	encKey, err := hkdf.Expand(sha256.New, master, "enc", 32)
+	if err != nil {
+		return nil, err
+	}
+	macKey, err := hkdf.Expand(sha256.New, master, "mac", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &symmetricKey{enc: encKey, mac: macKey}, nil
+}
+
+// decryptString decrypts a type 2 EncString
+func decryptString(key *symmetricKey, encString string) ([]byte, error) {
+	encType, rest, ok := strings.Cut(encString, ".")
+	if !ok || encType != encTypeAESCBC256HMAC {
+		return nil, fmt.Errorf("unsupported encryption type %q", encType)
+	}
+
+	parts := strings.Split(rest, "|")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed encrypted string")
+	}
+
+	var raw [3][]byte
+	for i, part := range parts {
+		decoded, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return nil, fmt.Errorf("malformed encrypted string: %w", err)
+		}
+		raw[i] = decoded
+	}
+	iv, ciphertext, mac := raw[0], raw[1], raw[2]
+
+	h := hmac.New(sha256.New, key.mac)
+	h.Write(iv)
+	h.Write(ciphertext)
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return nil, ErrWrongPassword
+	}
+
+	block, err := aes.NewCipher(key.enc)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("malformed ciphertext")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// Remove PKCS#7 padding
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range plaintext[len(plaintext)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("invalid padding")
+		}
+	}
+
+	return plaintext[:len(plaintext)-pad], nil
+}
+
+// decryptExport decrypts the inner document of a password-protected export
+func decryptExport(e *EncryptedExport, password string) ([]byte, error) {
+	key, err := deriveExportKey(password, e)
+	if err != nil {
+		return nil, err
+	}
+	defer key.wipe()
+
+	// The validation string is a random value encrypted with the same key;
+	// checking it first gives a clear error for a wrong password
+	if e.EncKeyValidation != "" {
+		validation, err := decryptString(key, e.EncKeyValidation)
+		if err != nil {
+			return nil, err
+		}
+		crypto.WipeMemory(validation)
+	}
+
+	return decryptString(key, e.Data)
+}
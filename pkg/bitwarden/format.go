@@ -0,0 +1,153 @@
+package bitwarden
+
+// Package bitwarden describes the Bitwarden JSON export format.
+//
+// Bitwarden exports either a plain document ("encrypted": false) holding
+// folders and items, or the same document encrypted with the account key
+// or an export password. See https://bitwarden.com/help/export-your-data/
+
+// Item types
+const (
+	TypeLogin      = 1
+	TypeSecureNote = 2
+	TypeCard       = 3
+	TypeIdentity   = 4
+	TypeSSHKey     = 5
+)
+
+// Custom field types
+const (
+	FieldText    = 0
+	FieldHidden  = 1
+	FieldBoolean = 2
+	FieldLinked  = 3
+)
+
+// KDF types of password-protected exports
+const (
+	KDFPBKDF2   = 0
+	KDFArgon2id = 1
+)
+
+// Export is the top-level document of a JSON export
+type Export struct {
+	Encrypted   bool     `json:"encrypted"`
+	Folders     []Folder `json:"folders"`
+	Collections []Folder `json:"collections,omitempty"` // Organization exports
+	Items       []Item   `json:"items"`
+}
+
+// EncryptedExport is a password-protected JSON export. Data holds an
+// encrypted Export; account-restricted exports instead encrypt every value
+// with the account key and can't be read outside Bitwarden.
+type EncryptedExport struct {
+	Encrypted         bool   `json:"encrypted"`
+	PasswordProtected bool   `json:"passwordProtected"`
+	Salt              string `json:"salt"`
+	KDFType           int    `json:"kdfType"`
+	KDFIterations     uint32 `json:"kdfIterations"`
+	KDFMemory         uint32 `json:"kdfMemory"`      // MiB, Argon2id only
+	KDFParallelism    uint8  `json:"kdfParallelism"` // Argon2id only
+	EncKeyValidation  string `json:"encKeyValidation_DO_NOT_EDIT"`
+	Data              string `json:"data"`
+}
+
+type Folder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Item struct {
+	ID             string      `json:"id,omitempty"`
+	OrganizationID *string     `json:"organizationId"`
+	FolderID       *string     `json:"folderId"`
+	Type           int         `json:"type"`
+	Reprompt       int         `json:"reprompt"`
+	Name           string      `json:"name"`
+	Notes          *string     `json:"notes"`
+	Favorite       bool        `json:"favorite"`
+	Fields         []Field     `json:"fields,omitempty"`
+	Login          *Login      `json:"login,omitempty"`
+	SecureNote     *SecureNote `json:"secureNote,omitempty"`
+	Card           *Card       `json:"card,omitempty"`
+	Identity       *Identity   `json:"identity,omitempty"`
+	SSHKey         *SSHKey     `json:"sshKey,omitempty"`
+	CollectionIDs  []string    `json:"collectionIds"`
+	RevisionDate   string      `json:"revisionDate,omitempty"`
+	CreationDate   string      `json:"creationDate,omitempty"`
+}
+
+type Field struct {
+	Name     string  `json:"name"`
+	Value    *string `json:"value"`
+	Type     int     `json:"type"`
+	LinkedID *int    `json:"linkedId"`
+}
+
+type Login struct {
+	URIs     []URI   `json:"uris,omitempty"`
+	Username *string `json:"username"`
+	Password *string `json:"password"`
+	TOTP     *string `json:"totp"`
+}
+
+type URI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+type SecureNote struct {
+	Type int `json:"type"`
+}
+
+type Card struct {
+	CardholderName *string `json:"cardholderName"`
+	Brand          *string `json:"brand"`
+	Number         *string `json:"number"`
+	ExpMonth       *string `json:"expMonth"`
+	ExpYear        *string `json:"expYear"`
+	Code           *string `json:"code"`
+}
+
+type Identity struct {
+	Title          *string `json:"title"`
+	FirstName      *string `json:"firstName"`
+	MiddleName     *string `json:"middleName"`
+	LastName       *string `json:"lastName"`
+	Address1       *string `json:"address1"`
+	Address2       *string `json:"address2"`
+	Address3       *string `json:"address3"`
+	City           *string `json:"city"`
+	State          *string `json:"state"`
+	PostalCode     *string `json:"postalCode"`
+	Country        *string `json:"country"`
+	Company        *string `json:"company"`
+	Email          *string `json:"email"`
+	Phone          *string `json:"phone"`
+	SSN            *string `json:"ssn"`
+	Username       *string `json:"username"`
+	PassportNumber *string `json:"passportNumber"`
+	LicenseNumber  *string `json:"licenseNumber"`
+}
+
+type SSHKey struct {
+	PrivateKey     *string `json:"privateKey"`
+	PublicKey      *string `json:"publicKey"`
+	KeyFingerprint *string `json:"keyFingerprint"`
+}
+
+// String returns a pointer to s, or nil for an empty string (Bitwarden uses null)
+func String(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Value dereferences a nullable string
+func Value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
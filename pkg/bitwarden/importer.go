@@ -0,0 +1,276 @@
+package bitwarden
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/importutil"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// Importer handles imports from Bitwarden JSON exports, both plain and
+// password-protected ("encrypted JSON" with a file password)
+type Importer struct{}
+
+func (b *Importer) GetName() string {
+	return "Bitwarden"
+}
+
+func (b *Importer) GetDescription() string {
+	return "Import passwords from Bitwarden (JSON or password-protected JSON export)"
+}
+
+func (b *Importer) SupportsFormat(format string) bool {
+	return strings.ToLower(format) == ".json"
+}
+
+// NeedsPassphrase reports whether the export is password-protected
+func (b *Importer) NeedsPassphrase(filePath string) bool {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return false
+	}
+
+	var header EncryptedExport
+	if err := json.Unmarshal(data, &header); err != nil {
+		return false
+	}
+	return header.Encrypted && header.PasswordProtected
+}
+
+func (b *Importer) Import(filePath string, passphrase string) ([]*models.Password, error) {
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext != ".json" {
+		return nil, fmt.Errorf("unsupported file format: %s", ext)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON file: %w", err)
+	}
+
+	return b.parseJSON(data, passphrase)
+}
+
+func (b *Importer) parseJSON(data []byte, passphrase string) ([]*models.Password, error) {
+	var header EncryptedExport
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	if header.Encrypted {
+		if !header.PasswordProtected {
+			return nil, fmt.Errorf("account-restricted encrypted exports can only be imported into Bitwarden; export again with the \"Password protected\" option")
+		}
+		if passphrase == "" {
+			return nil, fmt.Errorf("passphrase required for encrypted export")
+		}
+
+		decrypted, err := decryptExport(&header, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		defer crypto.WipeMemory(decrypted)
+		data = decrypted
+	}
+
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	folders := make(map[string]string, len(export.Folders)+len(export.Collections))
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
+	for _, c := range export.Collections {
+		folders[c.ID] = c.Name
+	}
+
+	var passwords []*models.Password
+	for _, item := range export.Items {
+		passwords = append(passwords, b.convertItem(item, folders))
+	}
+
+	return passwords, nil
+}
+
+func (b *Importer) convertItem(item Item, folders map[string]string) *models.Password {
+	pwd := &models.Password{
+		Name:   item.Name,
+		Notes:  Value(item.Notes),
+		Fields: make(map[string]string),
+	}
+
+	set := func(key string, value *string) {
+		if v := Value(value); v != "" {
+			pwd.Fields[key] = v
+		}
+	}
+
+	switch item.Type {
+	case TypeLogin:
+		pwd.Type = models.TypeLogin
+		if item.Login != nil {
+			pwd.Username = Value(item.Login.Username)
+			pwd.Password = Value(item.Login.Password)
+			set(models.FieldTOTP, item.Login.TOTP)
+
+			// The first URI becomes the URL; further ones are kept as url_2, url_3, ...
+			n := 1
+			for _, uri := range item.Login.URIs {
+				if uri.URI == "" {
+					continue
+				}
+				if n == 1 {
+					pwd.URL = uri.URI
+				} else {
+					pwd.Fields["url_"+strconv.Itoa(n)] = uri.URI
+				}
+				n++
+			}
+		}
+
+	case TypeSecureNote:
+		pwd.Type = models.TypeNote
+
+	case TypeCard:
+		pwd.Type = models.TypeCard
+		if c := item.Card; c != nil {
+			set("cardholder_name", c.CardholderName)
+			set("number", c.Number)
+			set("cvv", c.Code)
+			set("brand", c.Brand)
+			if expiry := formatExpiry(Value(c.ExpMonth), Value(c.ExpYear)); expiry != "" {
+				pwd.Fields["expiration_date"] = expiry
+			}
+		}
+
+	case TypeIdentity:
+		pwd.Type = models.TypeIdentity
+		if id := item.Identity; id != nil {
+			pwd.Username = Value(id.Username)
+			if name := importutil.JoinNonEmpty(" ", Value(id.Title), Value(id.FirstName), Value(id.MiddleName), Value(id.LastName)); name != "" {
+				pwd.Fields["full_name"] = name
+			}
+			set("email", id.Email)
+			set("phone_number", id.Phone)
+			set("company", id.Company)
+			set("ssn", id.SSN)
+			set("passport_number", id.PassportNumber)
+			set("license_number", id.LicenseNumber)
+
+			street := importutil.JoinNonEmpty(", ", Value(id.Address1), Value(id.Address2), Value(id.Address3))
+			city := importutil.JoinNonEmpty(" ", Value(id.PostalCode), Value(id.City))
+			if address := importutil.JoinNonEmpty(", ", street, city, Value(id.State), Value(id.Country)); address != "" {
+				pwd.Fields["address"] = address
+			}
+		}
+
+	case TypeSSHKey:
+		pwd.Type = models.TypeSSHKey
+		if k := item.SSHKey; k != nil {
+			set(models.FieldPrivateKey, k.PrivateKey)
+			set(models.FieldPublicKey, k.PublicKey)
+			set(models.FieldFingerprint, k.KeyFingerprint)
+		}
+
+	default:
+		pwd.Type = models.TypeOther
+	}
+
+	for _, field := range item.Fields {
+		key := importutil.UniqueKey(pwd.Fields, field.Name)
+		switch field.Type {
+		case FieldLinked:
+			// Linked fields are aliases of a built-in field (e.g. "Email" for
+			// the username), so copy the value they point at
+			if field.LinkedID != nil {
+				if v := linkedValue(item, *field.LinkedID); v != "" {
+					pwd.Fields[key] = v
+				}
+			}
+		case FieldBoolean:
+			value := strings.ToLower(Value(field.Value))
+			if value != "true" {
+				value = "false"
+			}
+			pwd.Fields[key] = value
+		default:
+			if v := Value(field.Value); v != "" {
+				pwd.Fields[key] = v
+			}
+		}
+	}
+
+	if item.FolderID != nil {
+		if name := folders[*item.FolderID]; name != "" {
+			pwd.Fields[models.FieldFolder] = name
+		}
+	} else if len(item.CollectionIDs) > 0 {
+		if name := folders[item.CollectionIDs[0]]; name != "" {
+			pwd.Fields[models.FieldFolder] = name
+		}
+	}
+
+	pwd.CreatedAt = parseTime(item.CreationDate)
+	pwd.UpdatedAt = parseTime(item.RevisionDate)
+
+	return pwd
+}
+
+// linkedValue resolves the target of a linked custom field
+func linkedValue(item Item, linkedID int) string {
+	if l := item.Login; l != nil {
+		switch linkedID {
+		case 100:
+			return Value(l.Username)
+		case 101:
+			return Value(l.Password)
+		}
+	}
+	if c := item.Card; c != nil {
+		switch linkedID {
+		case 300:
+			return Value(c.CardholderName)
+		case 301:
+			return Value(c.ExpMonth)
+		case 302:
+			return Value(c.ExpYear)
+		case 303:
+			return Value(c.Code)
+		case 304:
+			return Value(c.Brand)
+		case 305:
+			return Value(c.Number)
+		}
+	}
+	return ""
+}
+
+// formatExpiry formats a card expiry as MM/YYYY
+func formatExpiry(month, year string) string {
+	if month == "" && year == "" {
+		return ""
+	}
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	if len(year) == 2 {
+		year = "20" + year
+	}
+	return month + "/" + year
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,116 @@
+package bitwarden
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// exportPassword is the file password of the encrypted fixtures
+const exportPassword = "export password"
+
+func importFixture(t *testing.T, name, passphrase string) map[string]*models.Password {
+	t.Helper()
+
+	passwords, err := (&Importer{}).Import(filepath.Join("testdata", name), passphrase)
+	if err != nil {
+		t.Fatalf("Import(%s): %v", name, err)
+	}
+
+	byName := make(map[string]*models.Password, len(passwords))
+	for _, p := range passwords {
+		byName[p.Name] = p
+	}
+	if len(byName) != 4 {
+		t.Fatalf("got %d items, want 4", len(byName))
+	}
+	return byName
+}
+
+func checkFields(t *testing.T, p *models.Password, want map[string]string) {
+	t.Helper()
+	if !reflect.DeepEqual(p.Fields, want) {
+		t.Errorf("%s fields = %v, want %v", p.Name, p.Fields, want)
+	}
+}
+
+func checkExport(t *testing.T, items map[string]*models.Password) {
+	login := items["GitHub"]
+	if login.Type != models.TypeLogin || login.Username != "alice@example.com" ||
+		login.Password != "correct horse battery staple" || login.URL != "https://github.com/login" ||
+		login.Notes != "Work account" {
+		t.Errorf("login = %+v", login)
+	}
+	if want := time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC); !login.CreatedAt.Equal(want) {
+		t.Errorf("login created = %v, want %v", login.CreatedAt, want)
+	}
+	checkFields(t, login, map[string]string{
+		models.FieldTOTP:   "otpauth://totp/GitHub:alice?secret=JBSWY3DPEHPK3PXP&issuer=GitHub",
+		models.FieldFolder: "Work",
+		"url_2":            "https://gist.github.com",
+		"Recovery code":    "abcd-efgh-ijkl",
+		"Admin":            "true",
+		"Email":            "alice@example.com",
+		"Email (2)":        "second@example.com",
+	})
+
+	card := items["Visa"]
+	if card.Type != models.TypeCard {
+		t.Errorf("card type = %s", card.Type)
+	}
+	checkFields(t, card, map[string]string{
+		"cardholder_name": "Alice Example",
+		"number":          "4111111111111111",
+		"cvv":             "123",
+		"brand":           "Visa",
+		"expiration_date": "07/2029",
+		"Security code":   "123",
+	})
+
+	identity := items["Passport"]
+	if identity.Type != models.TypeIdentity || identity.Username != "alice" {
+		t.Errorf("identity = %+v", identity)
+	}
+	checkFields(t, identity, map[string]string{
+		"full_name":       "Dr Alice Example",
+		"email":           "alice@example.com",
+		"phone_number":    "+1 555 0100",
+		"company":         "Acme",
+		"ssn":             "123-45-6789",
+		"passport_number": "X1234567",
+		"license_number":  "D7654321",
+		"address":         "1 Main Street, Apt 2, 62701 Springfield, IL, US",
+	})
+
+	note := items["Wifi"]
+	if note.Type != models.TypeNote || note.Notes != "SSID: office\nKey: hunter2" {
+		t.Errorf("note = %+v", note)
+	}
+	checkFields(t, note, map[string]string{models.FieldFolder: "Work"})
+}
+
+func TestImportPlain(t *testing.T) {
+	checkExport(t, importFixture(t, "export.json", ""))
+}
+
+func TestImportEncrypted(t *testing.T) {
+	for _, name := range []string{"export_pbkdf2.json", "export_argon2.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", name)
+			if !(&Importer{}).NeedsPassphrase(path) {
+				t.Fatal("NeedsPassphrase = false")
+			}
+
+			checkExport(t, importFixture(t, name, exportPassword))
+
+			_, err := (&Importer{}).Import(path, "not the password")
+			if !errors.Is(err, ErrWrongPassword) {
+				t.Errorf("wrong password: err = %v, want ErrWrongPassword", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/importutil"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// ErrUnknownLayout is returned by Import when the CSV header isn't a known
+// layout and no column mapping was set
+var ErrUnknownLayout = errors.New("unrecognised CSV layout; map the columns first")
+
+// lastPassNoteURL is the URL LastPass gives secure notes
+const lastPassNoteURL = "http://sn"
+
+// Importer handles CSV password exports from browsers (Chrome, Edge,
+// Firefox, Safari) and LastPass, and other CSV files with a user-supplied
+// column mapping
+type Importer struct {
+	mapping map[string]int
+}
+
+func (b *Importer) GetName() string {
+	return "Browser CSV"
+}
+
+func (b *Importer) GetDescription() string {
+	return "Import passwords from Chrome, Edge, Firefox, Safari or LastPass CSV exports"
+}
+
+func (b *Importer) SupportsFormat(format string) bool {
+	return strings.ToLower(format) == ".csv"
+}
+
+// DetectColumns reads the header and first row of the file and detects its layout
+func (b *Importer) DetectColumns(filePath string) (*Layout, error) {
+	records, err := readCSV(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := detectLayout(records[0])
+	if len(records) > 1 {
+		layout.Sample = records[1]
+	}
+	return layout, nil
+}
+
+// SetColumnMapping sets the field to column index mapping used by the next
+// Import instead of the detected one
+func (b *Importer) SetColumnMapping(mapping map[string]int) {
+	b.mapping = mapping
+}
+
+func (b *Importer) Import(filePath string, passphrase string) ([]*models.Password, error) {
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext != ".csv" {
+		return nil, fmt.Errorf("unsupported file format: %s", ext)
+	}
+
+	records, err := readCSV(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("CSV file is empty or has no data rows")
+	}
+
+	layout := detectLayout(records[0])
+	if b.mapping != nil {
+		layout.Mapping = b.mapping
+	} else if !layout.Known() {
+		return nil, ErrUnknownLayout
+	}
+
+	for field, idx := range layout.Mapping {
+		if idx < 0 || idx >= len(layout.Columns) {
+			return nil, fmt.Errorf("column %d for %s is out of range", idx, field)
+		}
+	}
+
+	var passwords []*models.Password
+	for _, record := range records[1:] {
+		passwords = append(passwords, convertRecord(layout, record))
+	}
+
+	return passwords, nil
+}
+
+// readCSV reads all rows of the file, skipping blank ones. A UTF-8 byte
+// order mark (written by Excel and some Windows exports) is stripped.
+func readCSV(filePath string) ([][]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV file: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	all, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	var records [][]string
+	for _, record := range all {
+		if strings.TrimSpace(strings.Join(record, "")) != "" {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+	return records, nil
+}
+
+func convertRecord(layout *Layout, record []string) *models.Password {
+	pwd := &models.Password{
+		Type:   models.TypeLogin,
+		Fields: make(map[string]string),
+	}
+
+	normalized := make([]string, len(layout.Columns))
+	for i, col := range layout.Columns {
+		normalized[i] = normalizeHeader(col)
+	}
+
+	cell := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	used := make(map[int]bool)
+	values := make(map[string]string)
+	for _, field := range Fields {
+		idx, ok := layout.Mapping[field]
+		if !ok {
+			continue
+		}
+		used[idx] = true
+		values[field] = cell(idx)
+
+		// Some exports repeat a column; use the first duplicate with a value
+		if values[field] == "" {
+			for i := range layout.Columns {
+				if !used[i] && normalized[i] == normalized[idx] && cell(i) != "" {
+					values[field] = cell(i)
+					used[i] = true
+					break
+				}
+			}
+		}
+	}
+
+	pwd.Name = values[FieldName]
+	pwd.URL = values[FieldURL]
+	pwd.Username = values[FieldUsername]
+	pwd.Password = values[FieldPassword]
+	pwd.Notes = values[FieldNotes]
+
+	if totp := values[FieldTOTP]; totp != "" {
+		pwd.Fields[models.FieldTOTP] = totp
+	}
+	if folder := values[FieldFolder]; folder != "" {
+		if layout.Name == "LastPass" {
+			// LastPass separates nested folders with backslashes
+			folder = strings.ReplaceAll(folder, `\`, "/")
+		}
+		pwd.Fields[models.FieldFolder] = folder
+	}
+
+	// Remaining columns are kept as custom fields; repeated URL columns
+	// become url_2, url_3, ...
+	ignored := ignoredColumns(layout.Name)
+	urlIndex := 2
+	for i, col := range layout.Columns {
+		value := cell(i)
+		if used[i] || ignored[normalized[i]] || value == "" {
+			continue
+		}
+
+		if idx, ok := layout.Mapping[FieldURL]; ok && normalized[i] == normalized[idx] {
+			if value != pwd.URL {
+				pwd.Fields["url_"+strconv.Itoa(urlIndex)] = value
+				urlIndex++
+			}
+			continue
+		}
+
+		duplicate := false
+		for field, idx := range layout.Mapping {
+			if normalized[i] == normalized[idx] && value == values[field] {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			pwd.Fields[importutil.UniqueKey(pwd.Fields, strings.TrimSpace(col))] = value
+		}
+	}
+
+	if layout.Name == "Firefox" {
+		pwd.CreatedAt = firefoxTime(layout, record, "timecreated")
+		pwd.UpdatedAt = firefoxTime(layout, record, "timepasswordchanged")
+	}
+
+	switch {
+	case layout.Name == "LastPass" && pwd.URL == lastPassNoteURL:
+		pwd.Type = models.TypeNote
+		pwd.URL = ""
+	case pwd.Username == "" && pwd.Password == "" && pwd.URL == "" && pwd.Notes != "":
+		pwd.Type = models.TypeNote
+	}
+
+	// Firefox and some Chrome exports have no names, so fall back to the site
+	if pwd.Name == "" {
+		pwd.Name = siteName(pwd.URL)
+	}
+	if pwd.Name == "" {
+		pwd.Name = pwd.Username
+	}
+
+	return pwd
+}
+
+// firefoxTime parses a Firefox timestamp column (milliseconds since the epoch)
+func firefoxTime(layout *Layout, record []string, column string) time.Time {
+	for i, col := range layout.Columns {
+		if normalizeHeader(col) != column || i >= len(record) {
+			continue
+		}
+		if ms, err := strconv.ParseInt(strings.TrimSpace(record[i]), 10, 64); err == nil && ms > 0 {
+			return time.UnixMilli(ms).UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// siteName returns the host name of a URL without a leading "www."
+func siteName(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return rawURL
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
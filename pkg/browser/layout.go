@@ -0,0 +1,174 @@
+package browser
+
+import "strings"
+
+// Fields columns can be mapped to
+const (
+	FieldName     = "name"
+	FieldURL      = "url"
+	FieldUsername = "username"
+	FieldPassword = "password"
+	FieldNotes    = "notes"
+	FieldTOTP     = "totp"
+	FieldFolder   = "folder"
+)
+
+// Fields lists the mappable fields in display order
+var Fields = []string{FieldName, FieldURL, FieldUsername, FieldPassword, FieldNotes, FieldTOTP, FieldFolder}
+
+// Layout describes the columns of a CSV file
+type Layout struct {
+	// Name is the detected source (e.g. "Chrome"), or empty if the layout
+	// wasn't recognised
+	Name string
+
+	// Columns is the header row and Sample the first data row
+	Columns []string
+	Sample  []string
+
+	// Mapping maps fields to column indices; unmapped fields are absent
+	Mapping map[string]int
+}
+
+// Known reports whether the layout was recognised
+func (l *Layout) Known() bool {
+	return l.Name != ""
+}
+
+// knownLayout is a header layout written by a browser or password manager
+type knownLayout struct {
+	name string
+
+	// required headers identify the layout; all must be present
+	required []string
+
+	// columns maps lower-cased headers to fields
+	columns map[string]string
+
+	// ignored columns carry bookkeeping data that isn't worth keeping
+	ignored []string
+}
+
+// knownLayouts are tried in order, so more specific layouts come first
+var knownLayouts = []knownLayout{
+	{
+		// url,username,password,httpRealm,formActionOrigin,guid,timeCreated,timeLastUsed,timePasswordChanged
+		name:     "Firefox",
+		required: []string{"url", "username", "password", "guid"},
+		columns:  map[string]string{"url": FieldURL, "username": FieldUsername, "password": FieldPassword},
+		ignored:  []string{"httprealm", "formactionorigin", "guid", "timecreated", "timelastused", "timepasswordchanged"},
+	},
+	{
+		// url,username,password,totp,extra,name,grouping,fav
+		name:     "LastPass",
+		required: []string{"url", "username", "password", "extra", "name", "grouping"},
+		columns: map[string]string{
+			"url": FieldURL, "username": FieldUsername, "password": FieldPassword, "totp": FieldTOTP,
+			"extra": FieldNotes, "name": FieldName, "grouping": FieldFolder,
+		},
+		ignored: []string{"fav"},
+	},
+	{
+		// Title,URL,Username,Password,Notes,OTPAuth
+		name:     "Safari",
+		required: []string{"title", "url", "username", "password"},
+		columns: map[string]string{
+			"title": FieldName, "url": FieldURL, "username": FieldUsername, "password": FieldPassword,
+			"notes": FieldNotes, "otpauth": FieldTOTP,
+		},
+	},
+	{
+		// name,url,username,password,note (Edge uses the same layout)
+		name:     "Chrome",
+		required: []string{"name", "url", "username", "password"},
+		columns: map[string]string{
+			"name": FieldName, "url": FieldURL, "username": FieldUsername, "password": FieldPassword,
+			"note": FieldNotes,
+		},
+	},
+}
+
+// detectLayout matches the header against the known layouts, or guesses a
+// mapping from the column names if none matches
+func detectLayout(header []string) *Layout {
+	normalized := make([]string, len(header))
+	present := make(map[string]bool, len(header))
+	for i, col := range header {
+		normalized[i] = normalizeHeader(col)
+		present[normalized[i]] = true
+	}
+
+	layout := &Layout{Columns: header, Mapping: make(map[string]int)}
+
+	for _, known := range knownLayouts {
+		if !hasAll(present, known.required) {
+			continue
+		}
+		layout.Name = known.name
+		for i, col := range normalized {
+			field, ok := known.columns[col]
+			if _, taken := layout.Mapping[field]; ok && !taken {
+				layout.Mapping[field] = i
+			}
+		}
+		return layout
+	}
+
+	for i, col := range normalized {
+		if field := guessField(col); field != "" {
+			if _, taken := layout.Mapping[field]; !taken {
+				layout.Mapping[field] = i
+			}
+		}
+	}
+	return layout
+}
+
+// ignoredColumns returns the bookkeeping columns of a known layout
+func ignoredColumns(name string) map[string]bool {
+	ignored := make(map[string]bool)
+	for _, known := range knownLayouts {
+		if known.name == name {
+			for _, col := range known.ignored {
+				ignored[col] = true
+			}
+		}
+	}
+	return ignored
+}
+
+// guessField guesses the field of a column from its name
+func guessField(col string) string {
+	switch {
+	case strings.Contains(col, "otp") || strings.Contains(col, "2fa") || strings.Contains(col, "authenticator"):
+		return FieldTOTP
+	case strings.Contains(col, "pass") || strings.Contains(col, "secret") || col == "pwd":
+		return FieldPassword
+	case strings.Contains(col, "url") || strings.HasSuffix(col, "uri") || strings.Contains(col, "site") ||
+		strings.Contains(col, "web") || strings.Contains(col, "link") || strings.Contains(col, "host") ||
+		strings.Contains(col, "domain"):
+		return FieldURL
+	case strings.Contains(col, "user") || strings.Contains(col, "login") || strings.Contains(col, "email"):
+		return FieldUsername
+	case strings.Contains(col, "note") || strings.Contains(col, "comment") || col == "extra":
+		return FieldNotes
+	case strings.Contains(col, "folder") || strings.Contains(col, "group") || strings.Contains(col, "categor"):
+		return FieldFolder
+	case strings.Contains(col, "name") || strings.Contains(col, "title") || col == "account":
+		return FieldName
+	}
+	return ""
+}
+
+func normalizeHeader(col string) string {
+	return strings.ToLower(strings.TrimSpace(col))
+}
+
+func hasAll(present map[string]bool, cols []string) bool {
+	for _, col := range cols {
+		if !present[col] {
+			return false
+		}
+	}
+	return true
+}
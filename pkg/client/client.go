@@ -2,46 +2,91 @@ package client
 
 // This package provides HTTP client functionality for syncing with OpenPasswd server
 //
+// All encryption happens here: entries are sealed with a key derived from
+// the passphrase before they are uploaded, and the server only ever stores
+// and returns the encrypted blobs (see Keys).
+//
 // Planned features:
-// - RESTful API client with automatic retry logic
-// - Secure token-based authentication
-// - Background sync with conflict detection
+// - Automatic retry logic
+// - Background sync
 // - Offline mode with queue for pending changes
-// - Delta sync to minimize bandwidth usage
 //
-// Usage example (future):
-//   client := client.New("https://sync.openpasswd.com", token)
-//   passwords, err := client.ListPasswords()
-//   err = client.SyncPassword(password)
+// Usage example:
+//   err = client.Register("https://sync.example.com", username, passphrase, registrationKey)
+//   c, expiresAt, err := client.Login("https://sync.example.com", username, passphrase)
+//   items, revision, err := c.Changes(0)
+//   err = c.Put(client.NewItem(password))
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/r2unit/openpasswd/pkg/auth"
+	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/srp"
 )
 
+// ErrConflict is returned by Put and Delete when another device changed the
+// item first. The item is updated to the server's version.
+var ErrConflict = errors.New("item was changed by another device")
+
+// ErrUnsupportedKDF is returned when a server asks for a KDF version that
+// is unknown or weaker than sync accounts may use
+var ErrUnsupportedKDF = errors.New("unsupported KDF version")
+
 type Client struct {
 	baseURL string
 	token   string
+	keys    *Keys
 	client  *http.Client
 }
 
-func New(baseURL, token string) *Client {
+// Item is a decrypted entry with its sync metadata. Version is 0 for items
+// that were never uploaded.
+type Item struct {
+	ID       string
+	Version  int64
+	Deleted  bool
+	Password *models.Password
+}
+
+// blob is an item as the server stores it
+type blob struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+	Data    []byte `json:"data,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+func New(baseURL, token string, keys *Keys) *Client {
 	return &Client{
 		baseURL: baseURL,
 		token:   token,
+		keys:    keys,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
+// NewItem wraps an entry for its first upload, under a new random ID
+func NewItem(p *models.Password) *Item {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &Item{ID: hex.EncodeToString(id), Password: p}
+}
+
 func (c *Client) doRequest(method, path string, body interface{}) (*http.Response, error) {
 	var reqBody io.Reader
 	if body != nil {
@@ -63,40 +108,117 @@ func (c *Client) doRequest(method, path string, body interface{}) (*http.Respons
 	return c.client.Do(req)
 }
 
-func Login(serverURL, passphrase, masterKey string) (string, time.Time, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
+// postJSON sends an unauthenticated request and decodes the reply into out
+func postJSON(client *http.Client, url string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
 
-	reqBody := map[string]string{
-		"passphrase": passphrase,
-		"master_key": masterKey,
+	resp, err := client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	data, err := json.Marshal(reqBody)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s", bytes.TrimSpace(body))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Register creates an account on the server. The KDF salt and version are
+// stored there so other devices derive the same keys; the auth key is only
+// sent as an SRP verifier.
+func Register(serverURL, username, passphrase, registrationKey string) error {
+	kdfSalt, err := crypto.GenerateSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	srpSalt, err := srp.NewSalt()
 	if err != nil {
-		return "", time.Time{}, err
+		return fmt.Errorf("failed to generate salt: %w", err)
 	}
 
-	resp, err := client.Post(serverURL+"/api/auth/login", "application/json", bytes.NewBuffer(data))
+	kdfVersion := crypto.KDFVersionArgon2id
+	keys := DeriveKeys(passphrase, kdfSalt, kdfVersion)
+	defer keys.Wipe()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	err = postJSON(client, serverURL+"/api/auth/register", map[string]interface{}{
+		"username":         username,
+		"registration_key": registrationKey,
+		"kdf_version":      kdfVersion,
+		"kdf_salt":         kdfSalt,
+		"srp_salt":         srpSalt,
+		"verifier":         srp.NewVerifier(username, keys.AuthKey(), srpSalt),
+	}, nil)
 	if err != nil {
-		return "", time.Time{}, err
+		return fmt.Errorf("registration failed: %w", err)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", time.Time{}, fmt.Errorf("login failed: %s", string(body))
+// Login runs an SRP-6a login: neither the passphrase nor the auth key is
+// sent, and the server has to prove it holds the account's verifier before
+// the client trusts it. The returned client holds the session token, which
+// both sides derive from the shared key, and the encryption key.
+func Login(serverURL, username, passphrase string) (*Client, time.Time, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	exchange, err := srp.NewClient(username)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("login failed: %w", err)
 	}
 
-	var result struct {
-		Token     string    `json:"token"`
-		ExpiresAt time.Time `json:"expires_at"`
+	var start struct {
+		LoginID    string `json:"login_id"`
+		KDFVersion int    `json:"kdf_version"`
+		KDFSalt    []byte `json:"kdf_salt"`
+		SRPSalt    []byte `json:"srp_salt"`
+		B          []byte `json:"b"`
+	}
+	err = postJSON(client, serverURL+"/api/auth/login/start", map[string]interface{}{
+		"username": username,
+		"a":        exchange.PublicKey(),
+	}, &start)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("login failed: %w", err)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", time.Time{}, err
+	// A fake server could ask for a cheap KDF to brute-force M1 offline
+	if !crypto.ValidSyncKDFVersion(start.KDFVersion) {
+		return nil, time.Time{}, fmt.Errorf("login failed: %w: %d", ErrUnsupportedKDF, start.KDFVersion)
+	}
+
+	keys := DeriveKeys(passphrase, start.KDFSalt, start.KDFVersion)
+	m1, err := exchange.Proof(keys.AuthKey(), start.SRPSalt, start.B)
+	if err != nil {
+		keys.Wipe()
+		return nil, time.Time{}, fmt.Errorf("login failed: %w", err)
+	}
+
+	var finish struct {
+		M2        []byte    `json:"m2"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = postJSON(client, serverURL+"/api/auth/login/finish", map[string]interface{}{
+		"login_id": start.LoginID,
+		"m1":       m1,
+	}, &finish)
+	if err == nil {
+		err = exchange.Verify(finish.M2)
+	}
+	if err != nil {
+		keys.Wipe()
+		return nil, time.Time{}, fmt.Errorf("login failed: %w", err)
 	}
 
-	return result.Token, result.ExpiresAt, nil
+	return New(serverURL, auth.TokenFromKey(exchange.Key()), keys), finish.ExpiresAt, nil
 }
 
 func (c *Client) Logout() error {
@@ -110,104 +232,113 @@ func (c *Client) Logout() error {
 		return fmt.Errorf("logout failed with status: %d", resp.StatusCode)
 	}
 
-	return auth.DeleteClientToken()
+	c.keys.Wipe()
+	if err := auth.DeleteClientToken(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
 }
 
-func (c *Client) ListPasswords() ([]*models.Password, error) {
-	resp, err := c.doRequest("GET", "/api/passwords", nil)
+// Changes returns the items changed after revision since, decrypted, and
+// the revision to pass next time. Pass 0 to get everything.
+func (c *Client) Changes(since int64) ([]*Item, int64, error) {
+	resp, err := c.doRequest("GET", "/api/items?since="+strconv.FormatInt(since, 10), nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status: %d", resp.StatusCode)
+		return nil, 0, fmt.Errorf("request failed with status: %d", resp.StatusCode)
 	}
 
-	var passwords []*models.Password
-	if err := json.NewDecoder(resp.Body).Decode(&passwords); err != nil {
-		return nil, err
-	}
-
-	return passwords, nil
-}
-
-func (c *Client) GetPassword(id int64) (*models.Password, error) {
-	resp, err := c.doRequest("GET", fmt.Sprintf("/api/passwords/%d", id), nil)
-	if err != nil {
-		return nil, err
+	var result struct {
+		Revision int64   `json:"revision"`
+		Items    []*blob `json:"items"`
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status: %d", resp.StatusCode)
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, err
 	}
 
-	var password models.Password
-	if err := json.NewDecoder(resp.Body).Decode(&password); err != nil {
-		return nil, err
+	items := make([]*Item, 0, len(result.Items))
+	for _, b := range result.Items {
+		item, err := c.open(b)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, item)
 	}
 
-	return &password, nil
+	return items, result.Revision, nil
 }
 
-func (c *Client) AddPassword(p *models.Password) error {
-	resp, err := c.doRequest("POST", "/api/passwords", p)
+// Put uploads an item and updates its version
+func (c *Client) Put(item *Item) error {
+	data, err := c.keys.Seal(item.ID, item.Password)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("request failed with status: %d", resp.StatusCode)
+	resp, err := c.doRequest("PUT", "/api/items/"+url.PathEscape(item.ID), map[string]interface{}{
+		"base_version": item.Version,
+		"data":         data,
+	})
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	return json.NewDecoder(resp.Body).Decode(p)
+	return c.update(item, resp)
 }
 
-func (c *Client) UpdatePassword(p *models.Password) error {
-	resp, err := c.doRequest("PUT", fmt.Sprintf("/api/passwords/%d", p.ID), p)
+// Delete removes an item on the server, leaving a tombstone for other devices
+func (c *Client) Delete(item *Item) error {
+	path := fmt.Sprintf("/api/items/%s?base_version=%d", url.PathEscape(item.ID), item.Version)
+	resp, err := c.doRequest("DELETE", path, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	return c.update(item, resp)
+}
+
+// update applies the blob the server answered a change with
+func (c *Client) update(item *Item, resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
 		return fmt.Errorf("request failed with status: %d", resp.StatusCode)
 	}
 
-	return nil
-}
+	var b blob
+	if err := json.NewDecoder(resp.Body).Decode(&b); err != nil {
+		return err
+	}
+	if b.ID != item.ID {
+		return fmt.Errorf("server returned item %q for %q", b.ID, item.ID)
+	}
 
-func (c *Client) DeletePassword(id int64) error {
-	resp, err := c.doRequest("DELETE", fmt.Sprintf("/api/passwords/%d", id), nil)
+	current, err := c.open(&b)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	*item = *current
 
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("request failed with status: %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusConflict {
+		return ErrConflict
 	}
-
 	return nil
 }
 
-func (c *Client) SearchPasswords(query string) ([]*models.Password, error) {
-	resp, err := c.doRequest("GET", "/api/passwords/search?q="+query, nil)
-	if err != nil {
-		return nil, err
+func (c *Client) open(b *blob) (*Item, error) {
+	item := &Item{ID: b.ID, Version: b.Version, Deleted: b.Deleted}
+	if b.Deleted {
+		return item, nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status: %d", resp.StatusCode)
-	}
-
-	var passwords []*models.Password
-	if err := json.NewDecoder(resp.Body).Decode(&passwords); err != nil {
+	p, err := c.keys.Open(b.ID, b.Data)
+	if err != nil {
 		return nil, err
 	}
-
-	return passwords, nil
+	item.Password = p
+	return item, nil
 }
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// Labels for deriving the two sync keys from the passphrase key
+const (
+	encryptionKeyLabel = "openpasswd sync encryption key"
+	authKeyLabel       = "openpasswd sync auth key"
+)
+
+// Keys are the keys a device derives from the passphrase. Neither leaves the
+// device: the auth key is the SRP password, so the server only ever stores a
+// verifier of it, and the encryption key is a separate one-way derivation.
+type Keys struct {
+	encryption []byte
+	auth       []byte
+}
+
+// DeriveKeys derives the sync keys with the account's KDF parameters
+func DeriveKeys(passphrase string, salt []byte, kdfVersion int) *Keys {
+	master := crypto.DeriveKey(passphrase, salt, crypto.GetKDFParams(kdfVersion))
+	defer crypto.WipeMemory(master)
+
+	return &Keys{
+		encryption: deriveSubkey(master, encryptionKeyLabel),
+		auth:       deriveSubkey(master, authKeyLabel),
+	}
+}
+
+func deriveSubkey(master []byte, label string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// AuthKey returns the SRP password logins prove knowledge of
+func (k *Keys) AuthKey() []byte {
+	return k.auth
+}
+
+// Wipe zeroes the keys; they can't be used afterwards
+func (k *Keys) Wipe() {
+	crypto.WipeMemory(k.encryption)
+	crypto.WipeMemory(k.auth)
+}
+
+func (k *Keys) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.encryption)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts an entry for upload. The item ID is authenticated along with
+// it, so the server can't swap the blobs of two items.
+func (k *Keys) Seal(id string, p *models.Password) ([]byte, error) {
+	plaintext, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode item: %w", err)
+	}
+	defer crypto.WipeMemory(plaintext)
+
+	gcm, err := k.aead()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt item: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to encrypt item: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, []byte(id)), nil
+}
+
+// Open decrypts a downloaded blob
+func (k *Keys) Open(id string, data []byte) (*models.Password, error) {
+	gcm, err := k.aead()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt item: %w", err)
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("failed to decrypt item: ciphertext too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt item %s: %w", id, err)
+	}
+	defer crypto.WipeMemory(plaintext)
+
+	var p models.Password
+	if err := json.Unmarshal(plaintext, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode item %s: %w", id, err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,146 @@
+package clipboard
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ClearHelperCommand is the hidden sub-command that runs the detached clear
+// helper. Binaries that call CopyWithTimeout must dispatch it to RunClearHelper.
+const ClearHelperCommand = "__clipboard-clear"
+
+// CopyWithTimeout writes text to the clipboard and clears it after the given
+// delay if the clipboard still holds the same value. For external clipboard
+// utilities the clear is performed by a detached helper process, so it still
+// happens after the calling program has exited. A zero delay disables clearing.
+func CopyWithTimeout(text string, after time.Duration) error {
+	b, err := Current()
+	if err != nil {
+		return err
+	}
+
+	if err := b.Write(text); err != nil {
+		return err
+	}
+
+	if after <= 0 || text == "" {
+		return nil
+	}
+
+	return scheduleClear(b, digest(text), after)
+}
+
+func scheduleClear(b Backend, sum string, after time.Duration) error {
+	// External clipboards outlive this process, so a detached helper clears them
+	var terminal *os.File
+	if osc, ok := b.(*OSC52Backend); ok {
+		terminal = osc.terminal
+	}
+
+	if _, ok := b.(*execBackend); !ok && terminal == nil {
+		// In-process backends only live as long as this process
+		time.AfterFunc(after, func() {
+			_ = clearIfUnchanged(b, sum)
+		})
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable for clipboard helper: %w", err)
+	}
+
+	cmd := exec.Command(exe, ClearHelperCommand, b.Name(), after.String())
+	detach(cmd)
+
+	if terminal != nil {
+		// The detached helper has no controlling terminal, so it inherits ours
+		if !passTerminal(cmd, terminal) {
+			time.AfterFunc(after, func() {
+				_ = clearIfUnchanged(b, sum)
+			})
+			return nil
+		}
+	}
+
+	// The digest goes over stdin so it never shows up in the process list
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start clipboard helper: %w", err)
+	}
+
+	if _, err := fmt.Fprintln(stdin, sum); err != nil {
+		return err
+	}
+	if err := stdin.Close(); err != nil {
+		return err
+	}
+
+	return cmd.Process.Release()
+}
+
+// RunClearHelper is the entry point of the detached helper process.
+// args are the backend name and the delay, the expected digest is read from stdin.
+func RunClearHelper(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s <backend> <delay>", ClearHelperCommand)
+	}
+
+	after, err := time.ParseDuration(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid delay: %w", err)
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read clipboard digest: %w", err)
+	}
+	sum := strings.TrimSpace(line)
+
+	var b Backend
+	if args[0] == osc52Name {
+		b = newOSC52BackendFile(os.NewFile(helperTerminalFd, "tty"))
+	} else if b, err = ByName(args[0]); err != nil {
+		return err
+	}
+
+	time.Sleep(after)
+	return clearIfUnchanged(b, sum)
+}
+
+// clearIfUnchanged clears the clipboard only if it still holds the copied value
+func clearIfUnchanged(b Backend, sum string) error {
+	current, err := b.Read()
+	if errors.Is(err, ErrReadUnsupported) {
+		// Write-only backends (OSC 52) cannot be checked, so clear unconditionally
+		// rather than leave the secret behind
+		return b.Clear()
+	}
+	if err != nil {
+		// Without a way to check, leave whatever the user copied since alone
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(digest(current)), []byte(sum)) != 1 {
+		return nil
+	}
+
+	return b.Clear()
+}
+
+func digest(text string) string {
+	h := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(h[:])
+}
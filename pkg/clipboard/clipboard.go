@@ -0,0 +1,121 @@
+package clipboard
+
+// Package clipboard provides system clipboard access for the TUIs.
+//
+// Platform access goes through a Backend so that tools can be swapped
+// (xclip, wl-copy, pbcopy, OSC 52, ...) and tests can run headless with the
+// in-memory backend. CopyWithTimeout clears the clipboard again after a
+// delay, but only if it still holds the value that was copied.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Backend reads and writes a clipboard
+type Backend interface {
+	// Name identifies the backend (e.g. "xclip", "pbcopy", "memory")
+	Name() string
+
+	// Write replaces the clipboard contents
+	Write(text string) error
+
+	// Read returns the current clipboard contents
+	Read() (string, error)
+
+	// Clear empties the clipboard
+	Clear() error
+}
+
+var (
+	// ErrUnavailable is returned when no clipboard backend can be used
+	ErrUnavailable = errors.New("no clipboard utility found (install xclip, xsel, or wl-clipboard, or set backend = \"osc52\" under [clipboard])")
+
+	// ErrReadUnsupported is returned by backends that can only write
+	ErrReadUnsupported = errors.New("clipboard backend cannot read the clipboard")
+)
+
+var (
+	backendMu       sync.Mutex
+	backendOverride Backend
+)
+
+// SetBackend overrides backend detection. Passing nil restores detection.
+func SetBackend(b Backend) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	backendOverride = b
+}
+
+// UseBackend selects a backend by name. "" and "auto" restore detection.
+func UseBackend(name string) error {
+	if name == "" || name == "auto" {
+		SetBackend(nil)
+		return nil
+	}
+
+	b, err := ByName(name)
+	if err != nil {
+		return err
+	}
+	SetBackend(b)
+	return nil
+}
+
+// Current returns the backend in use: the override if set, otherwise the
+// best backend detected for this platform
+func Current() (Backend, error) {
+	backendMu.Lock()
+	b := backendOverride
+	backendMu.Unlock()
+
+	if b != nil {
+		return b, nil
+	}
+	return Detect()
+}
+
+// Copy writes text to the clipboard without scheduling a clear
+func Copy(text string) error {
+	b, err := Current()
+	if err != nil {
+		return err
+	}
+	return b.Write(text)
+}
+
+// Clear empties the clipboard
+func Clear() error {
+	b, err := Current()
+	if err != nil {
+		return err
+	}
+	return b.Clear()
+}
+
+// ByName returns the backend with the given name
+func ByName(name string) (Backend, error) {
+	backendMu.Lock()
+	b := backendOverride
+	backendMu.Unlock()
+
+	if b != nil && b.Name() == name {
+		return b, nil
+	}
+
+	if name == osc52Name {
+		return NewOSC52Backend()
+	}
+
+	for _, candidate := range execBackends() {
+		if candidate.Name() == name {
+			if !candidate.available() {
+				return nil, fmt.Errorf("clipboard backend %q is not installed", name)
+			}
+			return candidate, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown clipboard backend: %s", name)
+}
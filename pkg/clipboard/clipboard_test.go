@@ -0,0 +1,120 @@
+package clipboard
+
+import (
+	"testing"
+	"time"
+)
+
+// memoryClipboard installs an in-memory backend for the duration of the test
+func memoryClipboard(t *testing.T) *MemoryBackend {
+	t.Helper()
+	b := NewMemoryBackend()
+	SetBackend(b)
+	t.Cleanup(func() { SetBackend(nil) })
+	return b
+}
+
+// waitFor polls the clipboard until it holds want or the deadline passes
+func waitFor(t *testing.T, b Backend, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got, err := b.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("clipboard = %q, want %q", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCopyWithTimeoutClears(t *testing.T) {
+	b := memoryClipboard(t)
+
+	if err := CopyWithTimeout("s3cret", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Read(); got != "s3cret" {
+		t.Fatalf("clipboard = %q right after copying", got)
+	}
+	waitFor(t, b, "")
+}
+
+func TestCopyWithTimeoutKeepsNewerValue(t *testing.T) {
+	b := memoryClipboard(t)
+
+	if err := CopyWithTimeout("s3cret", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write("copied since"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got, _ := b.Read(); got != "copied since" {
+		t.Errorf("clipboard = %q, want the value copied since", got)
+	}
+}
+
+func TestCopyWithoutTimeout(t *testing.T) {
+	b := memoryClipboard(t)
+
+	if err := CopyWithTimeout("s3cret", 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got, _ := b.Read(); got != "s3cret" {
+		t.Errorf("clipboard = %q, want it left alone", got)
+	}
+}
+
+// writeOnlyBackend cannot read back, like OSC 52
+type writeOnlyBackend struct {
+	MemoryBackend
+}
+
+func (b *writeOnlyBackend) Read() (string, error) {
+	return "", ErrReadUnsupported
+}
+
+func TestClearIfUnchanged(t *testing.T) {
+	sum := digest("s3cret")
+
+	tests := []struct {
+		name    string
+		backend Backend
+		current string
+		want    string
+	}{
+		{"unchanged", NewMemoryBackend(), "s3cret", ""},
+		{"replaced", NewMemoryBackend(), "copied since", "copied since"},
+		{"write only", &writeOnlyBackend{}, "copied since", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.backend.Write(tt.current); err != nil {
+				t.Fatal(err)
+			}
+			if err := clearIfUnchanged(tt.backend, sum); err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			switch b := tt.backend.(type) {
+			case *writeOnlyBackend:
+				got, _ = b.MemoryBackend.Read()
+			default:
+				got, _ = b.Read()
+			}
+			if got != tt.want {
+				t.Errorf("clipboard = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
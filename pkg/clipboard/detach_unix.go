@@ -0,0 +1,23 @@
+//go:build !windows
+
+package clipboard
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the helper in its own session so it survives the terminal closing
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// helperTerminalFd is where the clear helper finds the inherited terminal
+const helperTerminalFd = 3
+
+// passTerminal hands the terminal to the helper as its first extra file
+func passTerminal(cmd *exec.Cmd, tty *os.File) bool {
+	cmd.ExtraFiles = []*os.File{tty}
+	return true
+}
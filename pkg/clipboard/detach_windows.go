@@ -0,0 +1,27 @@
+//go:build windows
+
+package clipboard
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const (
+	detachedProcess       = 0x00000008
+	createNewProcessGroup = 0x00000200
+)
+
+// detach starts the helper without a console so it survives the terminal closing
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess | createNewProcessGroup}
+}
+
+// helperTerminalFd is unused on Windows, which cannot pass extra files
+const helperTerminalFd = ^uintptr(0)
+
+// passTerminal reports false because Windows does not support ExtraFiles
+func passTerminal(cmd *exec.Cmd, tty *os.File) bool {
+	return false
+}
@@ -0,0 +1,134 @@
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// execBackend drives an external clipboard utility
+type execBackend struct {
+	name     string
+	copyCmd  []string
+	pasteCmd []string
+	clearCmd []string // Optional; falls back to writing an empty string
+}
+
+func (b *execBackend) Name() string {
+	return b.name
+}
+
+func (b *execBackend) available() bool {
+	_, err := exec.LookPath(b.copyCmd[0])
+	return err == nil
+}
+
+func (b *execBackend) Write(text string) error {
+	cmd := exec.Command(b.copyCmd[0], b.copyCmd[1:]...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if _, err := stdin.Write([]byte(text)); err != nil {
+		return err
+	}
+
+	if err := stdin.Close(); err != nil {
+		return err
+	}
+
+	return cmd.Wait()
+}
+
+func (b *execBackend) Read() (string, error) {
+	if len(b.pasteCmd) == 0 {
+		return "", fmt.Errorf("%s cannot read the clipboard", b.name)
+	}
+
+	out, err := exec.Command(b.pasteCmd[0], b.pasteCmd[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func (b *execBackend) Clear() error {
+	if len(b.clearCmd) == 0 {
+		return b.Write("")
+	}
+	return exec.Command(b.clearCmd[0], b.clearCmd[1:]...).Run()
+}
+
+// execBackends lists the clipboard utilities for this platform in order of preference
+func execBackends() []*execBackend {
+	switch runtime.GOOS {
+	case "darwin":
+		return []*execBackend{
+			{name: "pbcopy", copyCmd: []string{"pbcopy"}, pasteCmd: []string{"pbpaste"}},
+		}
+	case "windows":
+		return []*execBackend{
+			{
+				name:     "clip",
+				copyCmd:  []string{"clip"},
+				pasteCmd: []string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+			},
+		}
+	default:
+		wayland := &execBackend{
+			name:     "wl-copy",
+			copyCmd:  []string{"wl-copy"},
+			pasteCmd: []string{"wl-paste", "--no-newline"},
+			clearCmd: []string{"wl-copy", "--clear"},
+		}
+		xclip := &execBackend{
+			name:     "xclip",
+			copyCmd:  []string{"xclip", "-selection", "clipboard"},
+			pasteCmd: []string{"xclip", "-selection", "clipboard", "-o"},
+		}
+		xsel := &execBackend{
+			name:     "xsel",
+			copyCmd:  []string{"xsel", "--clipboard", "--input"},
+			pasteCmd: []string{"xsel", "--clipboard", "--output"},
+			clearCmd: []string{"xsel", "--clipboard", "--clear"},
+		}
+
+		// Prefer native Wayland tools in a Wayland session
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			return []*execBackend{wayland, xclip, xsel}
+		}
+		return []*execBackend{xclip, xsel, wayland}
+	}
+}
+
+// Detect returns the best clipboard backend for this session: OSC 52 over
+// SSH, otherwise the first available clipboard utility for this platform
+func Detect() (Backend, error) {
+	if inSSHSession() {
+		if b, err := NewOSC52Backend(); err == nil {
+			return b, nil
+		}
+	}
+
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd", "darwin", "windows":
+	default:
+		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+
+	for _, b := range execBackends() {
+		if b.available() {
+			return b, nil
+		}
+	}
+
+	return nil, ErrUnavailable
+}
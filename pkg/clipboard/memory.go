@@ -0,0 +1,35 @@
+package clipboard
+
+import "sync"
+
+// MemoryBackend is an in-process clipboard for headless use and tests
+type MemoryBackend struct {
+	mu   sync.Mutex
+	text string
+}
+
+// NewMemoryBackend creates an empty in-memory clipboard
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{}
+}
+
+func (b *MemoryBackend) Name() string {
+	return "memory"
+}
+
+func (b *MemoryBackend) Write(text string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.text = text
+	return nil
+}
+
+func (b *MemoryBackend) Read() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.text, nil
+}
+
+func (b *MemoryBackend) Clear() error {
+	return b.Write("")
+}
@@ -0,0 +1,122 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	osc52Name = "osc52"
+
+	// Most terminals cap the OSC 52 payload; xterm's default is just under 100000
+	osc52MaxPayload = 99992
+
+	// GNU screen truncates DCS strings, so the sequence is sent in small chunks
+	screenChunkSize = 76
+)
+
+// OSC52Backend writes the clipboard through the terminal using the OSC 52
+// escape sequence, which works over SSH where no clipboard utility is
+// installed. Terminals do not let the contents be read back.
+type OSC52Backend struct {
+	mu       sync.Mutex
+	out      io.Writer
+	terminal *os.File
+}
+
+var (
+	osc52Mu     sync.Mutex
+	osc52Shared *OSC52Backend
+)
+
+// NewOSC52Backend returns an OSC 52 backend on the controlling terminal.
+// The terminal is opened once and shared for the life of the process.
+func NewOSC52Backend() (*OSC52Backend, error) {
+	osc52Mu.Lock()
+	defer osc52Mu.Unlock()
+
+	if osc52Shared != nil {
+		return osc52Shared, nil
+	}
+
+	tty, err := openTerminal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open terminal for OSC 52: %w", err)
+	}
+
+	osc52Shared = &OSC52Backend{out: tty, terminal: tty}
+	return osc52Shared, nil
+}
+
+// newOSC52BackendFile uses an already open terminal, e.g. one inherited by the clear helper
+func newOSC52BackendFile(tty *os.File) *OSC52Backend {
+	return &OSC52Backend{out: tty, terminal: tty}
+}
+
+func (b *OSC52Backend) Name() string {
+	return osc52Name
+}
+
+func (b *OSC52Backend) Write(text string) error {
+	encoded := base64.StdEncoding.EncodeToString([]byte(text))
+	if len(encoded) > osc52MaxPayload {
+		return fmt.Errorf("value too large for OSC 52 (%d bytes encoded)", len(encoded))
+	}
+
+	seq := wrapPassthrough("\x1b]52;c;" + encoded + "\x07")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := io.WriteString(b.out, seq)
+	return err
+}
+
+func (b *OSC52Backend) Read() (string, error) {
+	return "", ErrReadUnsupported
+}
+
+func (b *OSC52Backend) Clear() error {
+	return b.Write("")
+}
+
+// wrapPassthrough wraps an escape sequence so tmux or screen forwards it to
+// the outer terminal instead of swallowing it
+func wrapPassthrough(seq string) string {
+	if os.Getenv("TMUX") != "" {
+		// tmux needs every ESC doubled inside its DCS passthrough
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+
+	if strings.HasPrefix(os.Getenv("TERM"), "screen") || os.Getenv("STY") != "" {
+		var sb strings.Builder
+		for len(seq) > 0 {
+			n := min(screenChunkSize, len(seq))
+			sb.WriteString("\x1bP")
+			sb.WriteString(seq[:n])
+			sb.WriteString("\x1b\\")
+			seq = seq[n:]
+		}
+		return sb.String()
+	}
+
+	return seq
+}
+
+// openTerminal opens the terminal directly so OSC output bypasses redirected stdout
+func openTerminal() (*os.File, error) {
+	if runtime.GOOS == "windows" {
+		return os.OpenFile("CONOUT$", os.O_WRONLY, 0)
+	}
+	return os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+}
+
+// inSSHSession reports whether we are running in an interactive SSH session,
+// where clipboard utilities (if any) would copy on the remote machine
+func inSSHSession() bool {
+	return os.Getenv("SSH_TTY") != ""
+}
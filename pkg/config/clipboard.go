@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/toml"
+)
+
+// DefaultClipboardClearAfter is how long copied secrets stay on the clipboard
+const DefaultClipboardClearAfter = 30 * time.Second
+
+type ClipboardConfig struct {
+	Backend    string `toml:"backend"`     // auto, osc52, xclip, xsel, wl-copy, pbcopy or clip
+	ClearAfter string `toml:"clear_after"` // Seconds or Go duration; "0" disables clearing
+}
+
+type clipboardConfigFile struct {
+	Clipboard ClipboardConfig `toml:"clipboard"`
+}
+
+// LoadClipboardConfig loads the [clipboard] table from config.toml
+func LoadClipboardConfig() (ClipboardConfig, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return ClipboardConfig{}, nil
+	}
+
+	configPath := filepath.Join(configDir, "config.toml")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return ClipboardConfig{}, nil
+	}
+
+	var cfg clipboardConfigFile
+	if _, err := toml.DecodeFile(configPath, &cfg); err != nil {
+		return ClipboardConfig{}, nil
+	}
+
+	return cfg.Clipboard, nil
+}
+
+// ClearAfterDuration parses clear_after, falling back to the default when unset or invalid
+func (c ClipboardConfig) ClearAfterDuration() time.Duration {
+	value := strings.TrimSpace(strings.ToLower(c.ClearAfter))
+
+	switch value {
+	case "":
+		return DefaultClipboardClearAfter
+	case "0", "off", "never", "false":
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return DefaultClipboardClearAfter
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d
+	}
+
+	return DefaultClipboardClearAfter
+}
@@ -173,6 +173,27 @@ down_alt = "j"
 
 # Select / confirm
 select = "enter"
+
+# Edit / delete the selected entry (in the details view; use :edit or
+# :delete from the list)
+edit = "e"
+delete = "d"
+
+[clipboard]
+# Clipboard backend: "auto", "osc52", "xclip", "xsel", "wl-copy", "pbcopy" or "clip".
+# "auto" uses OSC 52 (copy through the terminal) in SSH sessions, otherwise the
+# first installed clipboard utility. OSC 52 also works inside tmux and screen.
+backend = "auto"
+
+# Clear copied secrets from the clipboard after this many seconds
+# (only if the clipboard still holds the copied value). "0" disables clearing.
+clear_after = "30"
+
+[key_cache]
+# Keep the unlocked vault key in the Linux kernel session keyring for this
+# long (seconds or e.g. "15m"), so repeated commands skip the passphrase and
+# key derivation. 'openpass lock' drops it early. "0" disables the cache.
+timeout = "0"
 `
 
 	return os.WriteFile(configPath, []byte(defaultConfig), 0600)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/r2unit/openpasswd/pkg/toml"
 )
@@ -18,6 +19,8 @@ type Keybindings struct {
 	Down    string `toml:"down"`
 	DownAlt string `toml:"down_alt"`
 	Select  string `toml:"select"`
+	Edit    string `toml:"edit"`
+	Delete  string `toml:"delete"`
 }
 
 type Config struct {
@@ -59,6 +62,8 @@ func DefaultKeybindings() Keybindings {
 		Down:    "down",
 		DownAlt: "j",
 		Select:  "enter",
+		Edit:    "e",
+		Delete:  "d",
 	}
 }
 
@@ -108,6 +113,12 @@ func LoadKeybindings() (Keybindings, error) {
 	if cfg.Keybindings.Select != "" {
 		kb.Select = cfg.Keybindings.Select
 	}
+	if cfg.Keybindings.Edit != "" {
+		kb.Edit = cfg.Keybindings.Edit
+	}
+	if cfg.Keybindings.Delete != "" {
+		kb.Delete = cfg.Keybindings.Delete
+	}
 
 	return kb, nil
 }
@@ -201,6 +212,50 @@ func LoadKDFVersion() (int, error) {
 	return version, nil
 }
 
+// SaveKeyCheck saves the value encrypted with the vault key that unlocking
+// is checked against
+func SaveKeyCheck(encrypted string) error {
+	configDir, err := EnsureConfigDir()
+	if err != nil {
+		return err
+	}
+
+	checkPath := filepath.Join(configDir, "key_check")
+	return os.WriteFile(checkPath, []byte(encrypted), 0600)
+}
+
+// LoadKeyCheck loads the key check value; vaults from before it existed
+// don't have one
+func LoadKeyCheck() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	checkPath := filepath.Join(configDir, "key_check")
+	data, err := os.ReadFile(checkPath)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// RemoveKeyCheck removes the key check value, for when the vault key changed
+// without the new key at hand; the next unlock writes a new one
+func RemoveKeyCheck() error {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(configDir, "key_check"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // REMOVED: Plaintext passphrase storage functions for security
 // Previously: HasPassphrase(), SavePassphrase(), LoadPassphrase(), RemovePassphrase()
 // These functions stored the master passphrase in plaintext, which defeats the purpose
@@ -254,15 +309,78 @@ func RemoveTOTP() error {
 		return err
 	}
 
-	totpPath := filepath.Join(configDir, "totp_secret")
-	err = os.Remove(totpPath)
-	if err != nil && !os.IsNotExist(err) {
-		return err
+	for _, name := range []string{"totp_secret", "totp_last_step", "totp_backup_codes"} {
+		err = os.Remove(filepath.Join(configDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// SaveTOTPLastStep records the last accepted TOTP time step (replay protection)
+func SaveTOTPLastStep(step int64) error {
+	configDir, err := EnsureConfigDir()
+	if err != nil {
+		return err
+	}
+
+	stepPath := filepath.Join(configDir, "totp_last_step")
+	return os.WriteFile(stepPath, []byte(fmt.Sprintf("%d", step)), 0600)
+}
+
+// LoadTOTPLastStep loads the last accepted TOTP time step (0 if none)
+func LoadTOTPLastStep() (int64, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return 0, err
+	}
+
+	stepPath := filepath.Join(configDir, "totp_last_step")
+	data, err := os.ReadFile(stepPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var step int64
+	if _, err := fmt.Sscanf(string(data), "%d", &step); err != nil {
+		return 0, fmt.Errorf("invalid TOTP step file: %w", err)
+	}
+
+	return step, nil
+}
+
+// SaveBackupCodes saves the hashed TOTP backup codes
+func SaveBackupCodes(encoded string) error {
+	configDir, err := EnsureConfigDir()
+	if err != nil {
+		return err
+	}
+
+	codesPath := filepath.Join(configDir, "totp_backup_codes")
+	return os.WriteFile(codesPath, []byte(encoded), 0600)
+}
+
+// LoadBackupCodes loads the hashed TOTP backup codes
+func LoadBackupCodes() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	codesPath := filepath.Join(configDir, "totp_backup_codes")
+	data, err := os.ReadFile(codesPath)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
 func HasYubiKey() bool {
 	configDir, err := GetConfigDir()
 	if err != nil {
@@ -418,3 +536,27 @@ func EnableVersionCheck() error {
 
 	return nil
 }
+
+// ImportReportPath returns a new file name for an import report in the
+// imports directory, creating the directory if needed
+func ImportReportPath(t time.Time) (string, error) {
+	configDir, err := EnsureConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(configDir, "imports")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	// Imports in the same second get a numbered suffix
+	base := filepath.Join(dir, "import-"+t.Format("20060102-150405"))
+	path := base + ".txt"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, nil
+		}
+		path = fmt.Sprintf("%s-%d.txt", base, i)
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/toml"
+)
+
+type KeyCacheConfig struct {
+	Timeout string `toml:"timeout"` // Seconds or Go duration; "0" disables the cache
+}
+
+type keyCacheConfigFile struct {
+	KeyCache KeyCacheConfig `toml:"key_cache"`
+}
+
+// LoadKeyCacheConfig loads the [key_cache] table from config.toml
+func LoadKeyCacheConfig() (KeyCacheConfig, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return KeyCacheConfig{}, nil
+	}
+
+	configPath := filepath.Join(configDir, "config.toml")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return KeyCacheConfig{}, nil
+	}
+
+	var cfg keyCacheConfigFile
+	if _, err := toml.DecodeFile(configPath, &cfg); err != nil {
+		return KeyCacheConfig{}, nil
+	}
+
+	return cfg.KeyCache, nil
+}
+
+// TimeoutDuration parses timeout; unset, invalid or "0" disables the cache
+func (c KeyCacheConfig) TimeoutDuration() time.Duration {
+	value := strings.TrimSpace(strings.ToLower(c.Timeout))
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d
+	}
+
+	return 0
+}
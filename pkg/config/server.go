@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/toml"
+)
+
+// TLS modes of the sync server
+const (
+	TLSAuto  = "auto"  // cert and key files if set, otherwise a self-signed certificate
+	TLSFiles = "files" // cert and key files, required
+	TLSOff   = "off"   // plain HTTP, for running behind a TLS-terminating proxy
+)
+
+// ServerConfig configures openpasswd-server
+type ServerConfig struct {
+	Listen          string `toml:"listen"`
+	DataDir         string `toml:"data_dir"`
+	TLS             string `toml:"tls"`
+	TLSCert         string `toml:"tls_cert"`
+	TLSKey          string `toml:"tls_key"`
+	SessionTTL      string `toml:"session_ttl"`      // Go duration
+	RegistrationKey string `toml:"registration_key"` // empty allows anyone to register
+}
+
+type serverConfigFile struct {
+	Server ServerConfig `toml:"server"`
+}
+
+// DefaultServerConfig returns the settings used for anything the config
+// file and flags leave unset
+func DefaultServerConfig() ServerConfig {
+	dataDir := "openpasswd-server"
+	if configDir, err := GetConfigDir(); err == nil {
+		dataDir = filepath.Join(configDir, "server")
+	}
+
+	return ServerConfig{
+		Listen:     ":8443",
+		DataDir:    dataDir,
+		TLS:        TLSAuto,
+		SessionTTL: "24h",
+	}
+}
+
+// DefaultServerConfigPath returns server.toml in the config directory
+func DefaultServerConfigPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "server.toml"), nil
+}
+
+// LoadServerConfig loads the [server] table of a config file over the
+// defaults. An empty path uses server.toml in the config directory if it
+// exists; a path that was asked for has to exist.
+func LoadServerConfig(path string) (ServerConfig, error) {
+	cfg := DefaultServerConfig()
+
+	if path == "" {
+		defaultPath, err := DefaultServerConfigPath()
+		if err != nil {
+			return cfg, nil
+		}
+		if _, err := os.Stat(defaultPath); os.IsNotExist(err) {
+			return cfg, nil
+		}
+		path = defaultPath
+	}
+
+	var file serverConfigFile
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return cfg, fmt.Errorf("failed to read server config: %w", err)
+	}
+
+	cfg.Merge(file.Server)
+	return cfg, nil
+}
+
+// Merge overrides the settings that are set in other
+func (c *ServerConfig) Merge(other ServerConfig) {
+	set := func(dst *string, value string) {
+		if value != "" {
+			*dst = value
+		}
+	}
+	set(&c.Listen, other.Listen)
+	set(&c.DataDir, other.DataDir)
+	set(&c.TLS, other.TLS)
+	set(&c.TLSCert, other.TLSCert)
+	set(&c.TLSKey, other.TLSKey)
+	set(&c.SessionTTL, other.SessionTTL)
+	set(&c.RegistrationKey, other.RegistrationKey)
+}
+
+// Validate checks the settings and returns the parsed session TTL
+func (c ServerConfig) Validate() (time.Duration, error) {
+	if c.Listen == "" {
+		return 0, fmt.Errorf("listen address is not set")
+	}
+	if c.DataDir == "" {
+		return 0, fmt.Errorf("data directory is not set")
+	}
+
+	switch strings.ToLower(c.TLS) {
+	case TLSAuto:
+		if (c.TLSCert == "") != (c.TLSKey == "") {
+			return 0, fmt.Errorf("tls_cert and tls_key have to be set together")
+		}
+	case TLSFiles:
+		if c.TLSCert == "" || c.TLSKey == "" {
+			return 0, fmt.Errorf("tls = %q needs tls_cert and tls_key", TLSFiles)
+		}
+	case TLSOff:
+	default:
+		return 0, fmt.Errorf("invalid tls mode %q (use %s, %s or %s)", c.TLS, TLSAuto, TLSFiles, TLSOff)
+	}
+
+	ttl, err := time.ParseDuration(c.SessionTTL)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid session TTL: %s", c.SessionTTL)
+	}
+	return ttl, nil
+}
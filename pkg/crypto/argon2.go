@@ -2,9 +2,10 @@ package crypto
 
 import (
 	"encoding/binary"
+	"math/bits"
 )
 
-// Argon2id implementation (hybrid of Argon2i and Argon2d)
+// Argon2 implementation (Argon2d and Argon2id)
 // Based on RFC 9106: https://www.rfc-editor.org/rfc/rfc9106.html
 
 const (
@@ -15,10 +16,17 @@ const (
 	argon2BlockSize = 1024
 	argon2QWords    = argon2BlockSize / 8 // 128 uint64 words
 
-	// Sync points for Argon2id
+	// Number of slices per pass
 	argon2SyncPoints = 4
 )
 
+// Argon2 variants (the y parameter of RFC 9106)
+const (
+	argon2d  = 0
+	argon2i  = 1
+	argon2id = 2
+)
+
 // Argon2Params holds Argon2 parameters
 type Argon2Params struct {
 	Time        uint32 // Number of iterations
@@ -39,112 +47,110 @@ func DefaultArgon2Params() Argon2Params {
 
 // Argon2idKey derives a key using Argon2id
 func Argon2idKey(password, salt []byte, params Argon2Params) []byte {
-	// Calculate memory blocks
-	memoryBlocks := params.Memory / 4 // Each block is 1 KiB, memory is in KiB
-	if memoryBlocks < 8*uint32(params.Parallelism) {
-		memoryBlocks = 8 * uint32(params.Parallelism)
-	}
-
-	segmentLength := memoryBlocks / (uint32(params.Parallelism) * argon2SyncPoints)
-	laneLength := segmentLength * argon2SyncPoints
+	return argon2Key(argon2id, password, salt, nil, nil, params)
+}
 
-	// Initialize memory
-	memory := make([]block, memoryBlocks)
+// Argon2dKey derives a key using Argon2d (used by KeePass KDBX 4 files)
+func Argon2dKey(password, salt []byte, params Argon2Params) []byte {
+	return argon2Key(argon2d, password, salt, nil, nil, params)
+}
 
-	// Initial hash H0
-	h0 := argon2InitialHash(password, salt, params, memoryBlocks)
+// Argon2KeyWithSecret derives a key with the optional secret (K) and
+// associated data (X) inputs of RFC 9106. Only Argon2d and Argon2id are
+// supported; id selects Argon2id.
+func Argon2KeyWithSecret(id bool, password, salt, secret, data []byte, params Argon2Params) []byte {
+	mode := argon2d
+	if id {
+		mode = argon2id
+	}
+	return argon2Key(mode, password, salt, secret, data, params)
+}
 
-	// Fill first two blocks of each lane
-	for lane := uint8(0); lane < params.Parallelism; lane++ {
-		// H' = H0 || LE32(0) || LE32(lane)
-		var buf [72]byte // 64 (H0) + 4 + 4
-		copy(buf[:64], h0)
-		binary.LittleEndian.PutUint32(buf[64:], 0)
-		binary.LittleEndian.PutUint32(buf[68:], uint32(lane))
+func argon2Key(mode int, password, salt, secret, data []byte, params Argon2Params) []byte {
+	if params.Time < 1 {
+		params.Time = 1
+	}
+	if params.Parallelism < 1 {
+		params.Parallelism = 1
+	}
+	lanes := uint32(params.Parallelism)
 
-		laneOffset := uint32(lane) * laneLength
+	h0 := argon2InitialHash(mode, password, salt, secret, data, params)
 
-		// First block: H(H' || LE32(0))
-		b0 := argon2Blake2bLong(buf[:], 1024)
-		copy(memory[laneOffset][:], bytesToBlock(b0))
+	// m' = 4 * p * floor(m / 4p), at least 8 blocks per lane
+	memoryBlocks := params.Memory / (argon2SyncPoints * lanes) * (argon2SyncPoints * lanes)
+	if memoryBlocks < 2*argon2SyncPoints*lanes {
+		memoryBlocks = 2 * argon2SyncPoints * lanes
+	}
+	laneLength := memoryBlocks / lanes
+	segmentLength := laneLength / argon2SyncPoints
 
-		// Second block: H(H' || LE32(1))
-		binary.LittleEndian.PutUint32(buf[64:], 1)
-		b1 := argon2Blake2bLong(buf[:], 1024)
-		copy(memory[laneOffset+1][:], bytesToBlock(b1))
+	memory := make([]block, memoryBlocks)
+	defer func() {
+		for i := range memory {
+			memory[i] = block{}
+		}
+	}()
+
+	// Fill first two blocks of each lane: H'(H0 || LE32(i) || LE32(lane))
+	var buf [72]byte
+	copy(buf[:64], h0)
+	var raw [argon2BlockSize]byte
+	for lane := uint32(0); lane < lanes; lane++ {
+		binary.LittleEndian.PutUint32(buf[68:], lane)
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(buf[64:], i)
+			argon2Blake2bLong(raw[:], buf[:])
+			bytesToBlock(&memory[lane*laneLength+i], raw[:])
+		}
 	}
 
 	// Fill remaining blocks
 	for pass := uint32(0); pass < params.Time; pass++ {
 		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
-			for lane := uint8(0); lane < params.Parallelism; lane++ {
-				argon2FillSegment(memory, pass, lane, slice, params, laneLength, segmentLength)
+			for lane := uint32(0); lane < lanes; lane++ {
+				argon2FillSegment(memory, mode, pass, slice, lane, params.Time, memoryBlocks, lanes, laneLength, segmentLength)
 			}
 		}
 	}
 
-	// Final block: XOR all last blocks of all lanes
-	finalBlock := memory[(laneLength - 1)]
-	for lane := uint8(1); lane < params.Parallelism; lane++ {
-		laneOffset := uint32(lane) * laneLength
-		lastBlock := memory[laneOffset+laneLength-1]
-		for i := range finalBlock {
-			finalBlock[i] ^= lastBlock[i]
+	// Final block: XOR of the last block of every lane
+	final := memory[laneLength-1]
+	for lane := uint32(1); lane < lanes; lane++ {
+		last := &memory[lane*laneLength+laneLength-1]
+		for i := range final {
+			final[i] ^= last[i]
 		}
 	}
 
-	// Generate output key
-	return argon2Blake2bLong(blockToBytes(finalBlock), int(params.KeyLen))
+	blockToBytes(raw[:], &final)
+	key := make([]byte, params.KeyLen)
+	argon2Blake2bLong(key, raw[:])
+	WipeMemory(raw[:])
+	return key
 }
 
 // argon2InitialHash computes H0 = H(p, τ, m, t, v, y, |P|, P, |S|, S, |K|, K, |X|, X)
-func argon2InitialHash(password, salt []byte, params Argon2Params, memoryBlocks uint32) []byte {
+func argon2InitialHash(mode int, password, salt, secret, data []byte, params Argon2Params) []byte {
 	h, _ := NewBlake2b(64)
 
-	// Encode parameters
 	var buf [4]byte
+	writeUint32 := func(v uint32) {
+		binary.LittleEndian.PutUint32(buf[:], v)
+		h.Write(buf[:])
+	}
 
-	// Parallelism
-	binary.LittleEndian.PutUint32(buf[:], uint32(params.Parallelism))
-	h.Write(buf[:])
-
-	// Tag length (key length)
-	binary.LittleEndian.PutUint32(buf[:], params.KeyLen)
-	h.Write(buf[:])
-
-	// Memory size in KiB
-	binary.LittleEndian.PutUint32(buf[:], params.Memory)
-	h.Write(buf[:])
-
-	// Iterations
-	binary.LittleEndian.PutUint32(buf[:], params.Time)
-	h.Write(buf[:])
-
-	// Version
-	binary.LittleEndian.PutUint32(buf[:], argon2Version)
-	h.Write(buf[:])
-
-	// Type (2 = Argon2id)
-	binary.LittleEndian.PutUint32(buf[:], 2)
-	h.Write(buf[:])
-
-	// Password length and password
-	binary.LittleEndian.PutUint32(buf[:], uint32(len(password)))
-	h.Write(buf[:])
-	h.Write(password)
-
-	// Salt length and salt
-	binary.LittleEndian.PutUint32(buf[:], uint32(len(salt)))
-	h.Write(buf[:])
-	h.Write(salt)
-
-	// Secret (empty)
-	binary.LittleEndian.PutUint32(buf[:], 0)
-	h.Write(buf[:])
+	writeUint32(uint32(params.Parallelism))
+	writeUint32(params.KeyLen)
+	writeUint32(params.Memory)
+	writeUint32(params.Time)
+	writeUint32(argon2Version)
+	writeUint32(uint32(mode))
 
-	// Associated data (empty)
-	binary.LittleEndian.PutUint32(buf[:], 0)
-	h.Write(buf[:])
+	for _, input := range [][]byte{password, salt, secret, data} {
+		writeUint32(uint32(len(input)))
+		h.Write(input)
+	}
 
 	return h.Sum(nil)
 }
@@ -152,212 +158,195 @@ func argon2InitialHash(password, salt []byte, params Argon2Params, memoryBlocks
 // block represents a 1024-byte Argon2 block
 type block [argon2QWords]uint64
 
-// bytesToBlock converts bytes to a block
-func bytesToBlock(b []byte) []uint64 {
-	result := make([]uint64, argon2QWords)
-	for i := 0; i < argon2QWords && i*8 < len(b); i++ {
-		result[i] = binary.LittleEndian.Uint64(b[i*8:])
+// bytesToBlock decodes 1024 little-endian bytes into a block
+func bytesToBlock(dst *block, b []byte) {
+	for i := range dst {
+		dst[i] = binary.LittleEndian.Uint64(b[i*8:])
 	}
-	return result
 }
 
-// blockToBytes converts a block to bytes
-func blockToBytes(b block) []byte {
-	result := make([]byte, argon2BlockSize)
+// blockToBytes encodes a block as 1024 little-endian bytes
+func blockToBytes(dst []byte, b *block) {
 	for i, v := range b {
-		binary.LittleEndian.PutUint64(result[i*8:], v)
+		binary.LittleEndian.PutUint64(dst[i*8:], v)
 	}
-	return result
 }
 
-// argon2FillSegment fills a segment of the memory
-func argon2FillSegment(memory []block, pass uint32, lane uint8, slice uint32, params Argon2Params, laneLength, segmentLength uint32) {
-	var dataIndependentAddressing bool
-
-	// Argon2id uses data-independent addressing for first half of first pass
-	if pass == 0 && slice < argon2SyncPoints/2 {
-		dataIndependentAddressing = true
+// argon2FillSegment fills one segment (a quarter of a lane) for a pass
+func argon2FillSegment(memory []block, mode int, pass, slice, lane, passes, memoryBlocks, lanes, laneLength, segmentLength uint32) {
+	// Argon2id uses data-independent addressing for the first half of the first pass
+	independent := mode == argon2i || (mode == argon2id && pass == 0 && slice < argon2SyncPoints/2)
+
+	var addresses, input, zero block
+	if independent {
+		input[0] = uint64(pass)
+		input[1] = uint64(lane)
+		input[2] = uint64(slice)
+		input[3] = uint64(memoryBlocks)
+		input[4] = uint64(passes)
+		input[5] = uint64(mode)
 	}
 
-	startIndex := slice*segmentLength + 2
+	index := uint32(0)
 	if pass == 0 && slice == 0 {
-		startIndex = 2 // Skip first two blocks
+		index = 2 // The first two blocks are already filled
+		if independent {
+			argon2NextAddresses(&addresses, &input, &zero)
+		}
 	}
 
-	laneOffset := uint32(lane) * laneLength
-
-	for i := startIndex; i < (slice+1)*segmentLength; i++ {
-		prevIndex := i - 1
-		if i == 0 {
-			prevIndex = laneLength - 1
+	offset := lane*laneLength + slice*segmentLength + index
+	for ; index < segmentLength; index, offset = index+1, offset+1 {
+		prev := offset - 1
+		if index == 0 && slice == 0 {
+			prev += laneLength // Wrap to the last block of the lane
 		}
 
-		// Get reference block index
-		var refLane, refIndex uint32
-		if dataIndependentAddressing {
-			refLane, refIndex = argon2IndexAlpha(pass, lane, slice, i, laneLength, segmentLength, params.Parallelism, 0, 0)
+		var pseudoRand uint64
+		if independent {
+			if index%argon2QWords == 0 {
+				argon2NextAddresses(&addresses, &input, &zero)
+			}
+			pseudoRand = addresses[index%argon2QWords]
 		} else {
-			pseudo := memory[laneOffset+prevIndex][0]
-			refLane, refIndex = argon2IndexAlpha(pass, lane, slice, i, laneLength, segmentLength, params.Parallelism, pseudo, pseudo>>32)
+			pseudoRand = memory[prev][0]
 		}
 
-		// Compute new block
-		refBlock := memory[refLane+refIndex]
-		prevBlock := memory[laneOffset+prevIndex]
+		ref := argon2IndexAlpha(pseudoRand, pass, slice, lane, index, lanes, laneLength, segmentLength)
 
-		var newBlock block
-		argon2ComputeBlock(&newBlock, &prevBlock, &refBlock)
-
-		// Store with XOR for passes > 0
-		if pass == 0 {
-			memory[laneOffset+i] = newBlock
-		} else {
-			for j := range newBlock {
-				memory[laneOffset+i][j] ^= newBlock[j]
-			}
-		}
+		// Version 1.3 XORs into the existing block; on the first pass it is zero
+		argon2ComputeBlock(&memory[offset], &memory[prev], &memory[ref], true)
 	}
 }
 
-// argon2IndexAlpha computes reference block indices
-func argon2IndexAlpha(pass uint32, lane uint8, slice, index, laneLength, segmentLength uint32, lanes uint8, pseudoRand1, pseudoRand2 uint64) (uint32, uint32) {
-	var refLane uint32
+// argon2NextAddresses generates the next block of pseudo-random reference
+// positions for data-independent addressing
+func argon2NextAddresses(addresses, input, zero *block) {
+	input[6]++
+	argon2ComputeBlock(addresses, zero, input, false)
+	argon2ComputeBlock(addresses, zero, addresses, false)
+}
 
+// argon2IndexAlpha maps a pseudo-random value to the absolute index of the reference block
+func argon2IndexAlpha(pseudoRand uint64, pass, slice, lane, index, lanes, laneLength, segmentLength uint32) uint32 {
+	refLane := uint32(pseudoRand>>32) % lanes
 	if pass == 0 && slice == 0 {
-		refLane = uint32(lane)
-	} else {
-		refLane = uint32(pseudoRand2 % uint64(lanes))
+		refLane = lane
 	}
 
-	// Calculate reference area size
-	var areaSize uint32
+	// Size of the reference area and where it starts
+	var area, start uint32
 	if pass == 0 {
-		if slice == 0 {
-			areaSize = index - 1
-		} else {
-			areaSize = slice*segmentLength + index - 1
+		area = slice * segmentLength
+		if slice == 0 || refLane == lane {
+			area += index
 		}
 	} else {
-		areaSize = laneLength - segmentLength + index - 1
+		area = laneLength - segmentLength
+		if refLane == lane {
+			area += index
+		}
+		start = ((slice + 1) % argon2SyncPoints) * segmentLength
 	}
-
-	if areaSize == 0 {
-		areaSize = 1
+	if index == 0 || refLane == lane {
+		area--
 	}
 
-	// Map pseudoRand1 to reference index
-	x := pseudoRand1
+	x := pseudoRand & 0xFFFFFFFF
 	x = (x * x) >> 32
-	x = (uint64(areaSize) * x) >> 32
-	refIndex := areaSize - 1 - uint32(x)
+	x = (uint64(area) * x) >> 32
+	relative := uint64(area) - 1 - x
 
-	if pass != 0 && slice != argon2SyncPoints-1 {
-		refIndex += (slice + 1) * segmentLength
-		if refIndex >= laneLength {
-			refIndex -= laneLength
-		}
-	}
-
-	return refLane * laneLength, refIndex
+	return refLane*laneLength + uint32((uint64(start)+relative)%uint64(laneLength))
 }
 
-// argon2ComputeBlock computes G(X, Y) -> Z
-func argon2ComputeBlock(out, in1, in2 *block) {
-	var r, z block
-
-	// R = X XOR Y
+// argon2ComputeBlock computes G(X, Y) into out, XORing with its previous
+// contents when xor is set
+func argon2ComputeBlock(out, x, y *block, xor bool) {
+	var r block
 	for i := range r {
-		r[i] = in1[i] ^ in2[i]
+		r[i] = x[i] ^ y[i]
 	}
-	z = r
+	z := r
 
-	// Apply Blake2b permutation
-	argon2BlakeMix(&z, &r)
-
-	// Z = Z XOR R
-	for i := range out {
-		out[i] = z[i] ^ in1[i] ^ in2[i]
+	// Apply the permutation P to each row (8 x 16 words)...
+	for i := 0; i < argon2QWords; i += 16 {
+		argon2Round(&z, i, i+1, i+2, i+3, i+4, i+5, i+6, i+7, i+8, i+9, i+10, i+11, i+12, i+13, i+14, i+15)
 	}
-}
-
-// argon2BlakeMix applies the Blake2b-based mixing function
-func argon2BlakeMix(out, in *block) {
-	// Row-wise mixing
-	for i := 0; i < 8; i++ {
-		argon2BlakeMixRow(out, in, i*16)
+	// ...then to each column (pairs of words across the rows)
+	for i := 0; i < 16; i += 2 {
+		argon2Round(&z, i, i+1, i+16, i+17, i+32, i+33, i+48, i+49, i+64, i+65, i+80, i+81, i+96, i+97, i+112, i+113)
 	}
 
-	// Column-wise mixing
-	var tmp block
-	for i := 0; i < 8; i++ {
-		for j := 0; j < 16; j++ {
-			tmp[i*16+j] = out[j*8+i]
+	if xor {
+		for i := range out {
+			out[i] ^= r[i] ^ z[i]
+		}
+	} else {
+		for i := range out {
+			out[i] = r[i] ^ z[i]
 		}
-	}
-	for i := 0; i < 8; i++ {
-		argon2BlakeMixRow(out, &tmp, i*16)
 	}
 }
 
-// argon2BlakeMixRow mixes a row using Blake2b round
-func argon2BlakeMixRow(out, in *block, offset int) {
-	v := in[offset : offset+16]
-
-	// Blake2b round with permutation
-	argon2G(&v[0], &v[1], &v[2], &v[3], &v[4], &v[5], &v[6], &v[7])
-	argon2G(&v[8], &v[9], &v[10], &v[11], &v[12], &v[13], &v[14], &v[15])
-
-	copy(out[offset:offset+16], v)
+// argon2Round applies the BlaMka round to 16 words of a block
+func argon2Round(b *block, i0, i1, i2, i3, i4, i5, i6, i7, i8, i9, i10, i11, i12, i13, i14, i15 int) {
+	argon2G(&b[i0], &b[i4], &b[i8], &b[i12])
+	argon2G(&b[i1], &b[i5], &b[i9], &b[i13])
+	argon2G(&b[i2], &b[i6], &b[i10], &b[i14])
+	argon2G(&b[i3], &b[i7], &b[i11], &b[i15])
+
+	argon2G(&b[i0], &b[i5], &b[i10], &b[i15])
+	argon2G(&b[i1], &b[i6], &b[i11], &b[i12])
+	argon2G(&b[i2], &b[i7], &b[i8], &b[i13])
+	argon2G(&b[i3], &b[i4], &b[i9], &b[i14])
 }
 
-// argon2G is the Blake2b G mixing function for Argon2
-func argon2G(a, b, c, d, e, f, g, h *uint64) {
+// argon2G is the Blake2b G function with the BlaMka multiplication
+func argon2G(a, b, c, d *uint64) {
 	*a = *a + *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
-	*d = rotr64(*d^*a, 32)
+	*d = bits.RotateLeft64(*d^*a, -32)
 	*c = *c + *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
-	*b = rotr64(*b^*c, 24)
+	*b = bits.RotateLeft64(*b^*c, -24)
 	*a = *a + *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
-	*d = rotr64(*d^*a, 16)
+	*d = bits.RotateLeft64(*d^*a, -16)
 	*c = *c + *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
-	*b = rotr64(*b^*c, 63)
+	*b = bits.RotateLeft64(*b^*c, -63)
 }
 
-// argon2Blake2bLong generates variable-length output using Blake2b
-func argon2Blake2bLong(input []byte, outLen int) []byte {
-	out := make([]byte, outLen)
+// argon2Blake2bLong is the variable-length hash H' of RFC 9106, filling out
+func argon2Blake2bLong(out, input []byte) {
+	outLen := len(out)
+
+	var prefix [4]byte
+	binary.LittleEndian.PutUint32(prefix[:], uint32(outLen))
 
 	if outLen <= 64 {
-		// Short output: single Blake2b hash
 		h, _ := NewBlake2b(outLen)
+		h.Write(prefix[:])
 		h.Write(input)
 		copy(out, h.Sum(nil))
-		return out
+		return
 	}
 
-	// Long output: iterate Blake2b
-	var buf [4]byte
-	binary.LittleEndian.PutUint32(buf[:], uint32(outLen))
-
+	// V1 = H^64(LE32(T) || X), then V(i+1) = H^64(Vi); the first 32 bytes of
+	// each Vi are output, and the final V(r+1) is as long as what remains
 	h, _ := NewBlake2b(64)
-	h.Write(buf[:])
+	h.Write(prefix[:])
 	h.Write(input)
 	v := h.Sum(nil)
 
-	copy(out[:32], v)
+	copy(out, v[:32])
 	pos := 32
-
-	for pos < outLen {
+	for outLen-pos > 64 {
 		h, _ = NewBlake2b(64)
 		h.Write(v)
 		v = h.Sum(nil)
-
-		toCopy := 64
-		if outLen-pos < 64 {
-			toCopy = outLen - pos
-		}
-		copy(out[pos:], v[:toCopy])
-		pos += toCopy
+		copy(out[pos:], v[:32])
+		pos += 32
 	}
 
-	return out
+	h, _ = NewBlake2b(outLen - pos)
+	h.Write(v)
+	copy(out[pos:], h.Sum(nil))
 }
@@ -0,0 +1,48 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 9106 section 5 test vectors
+func TestArgon2RFC9106(t *testing.T) {
+	password := bytes.Repeat([]byte{0x01}, 32)
+	salt := bytes.Repeat([]byte{0x02}, 16)
+	secret := bytes.Repeat([]byte{0x03}, 8)
+	data := bytes.Repeat([]byte{0x04}, 12)
+	params := Argon2Params{Time: 3, Memory: 32, Parallelism: 4, KeyLen: 32}
+
+	tests := []struct {
+		name string
+		id   bool
+		want string
+	}{
+		{"Argon2d", false, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{"Argon2id", true, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hex.EncodeToString(Argon2KeyWithSecret(tt.id, password, salt, secret, data, params))
+			if got != tt.want {
+				t.Errorf("tag = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Without secret and associated data the exported helpers must agree with
+// Argon2KeyWithSecret
+func TestArgon2Helpers(t *testing.T) {
+	params := Argon2Params{Time: 1, Memory: 64, Parallelism: 2, KeyLen: 32}
+	password, salt := []byte("password"), []byte("somesaltsomesalt")
+
+	if !bytes.Equal(Argon2idKey(password, salt, params), Argon2KeyWithSecret(true, password, salt, nil, nil, params)) {
+		t.Error("Argon2idKey differs from Argon2KeyWithSecret")
+	}
+	if !bytes.Equal(Argon2dKey(password, salt, params), Argon2KeyWithSecret(false, password, salt, nil, nil, params)) {
+		t.Error("Argon2dKey differs from Argon2KeyWithSecret")
+	}
+}
@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// ChaCha20 and Salsa20 stream ciphers
+// Based on RFC 8439 (ChaCha20) and https://cr.yp.to/snuffle/spec.pdf (Salsa20)
+
+// ChaCha20 key and nonce sizes (RFC 8439 variant with a 96-bit nonce)
+const (
+	ChaCha20KeySize   = 32
+	ChaCha20NonceSize = 12
+	Salsa20NonceSize  = 8
+)
+
+// sigma is the "expand 32-byte k" constant shared by both ciphers
+var sigma = [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+
+// streamCipher XORs data with a keystream generated 64 bytes at a time
+type streamCipher struct {
+	state     [16]uint32
+	keystream [64]byte
+	offset    int // Bytes of keystream already used
+	block     func(out *[64]byte, state *[16]uint32)
+	next      func(state *[16]uint32)
+}
+
+// NewChaCha20 returns a ChaCha20 stream with a 32-byte key, 12-byte nonce and
+// the block counter starting at 0
+func NewChaCha20(key, nonce []byte) (cipher.Stream, error) {
+	return newChaCha20(key, nonce, 0)
+}
+
+func newChaCha20(key, nonce []byte, counter uint32) (*streamCipher, error) {
+	if len(key) != ChaCha20KeySize {
+		return nil, errors.New("chacha20: invalid key size")
+	}
+	if len(nonce) != ChaCha20NonceSize {
+		return nil, errors.New("chacha20: invalid nonce size")
+	}
+
+	s := &streamCipher{offset: 64, block: chachaBlock, next: chachaNext}
+	copy(s.state[0:4], sigma[:])
+	for i := 0; i < 8; i++ {
+		s.state[4+i] = binary.LittleEndian.Uint32(key[i*4:])
+	}
+	s.state[12] = counter
+	for i := 0; i < 3; i++ {
+		s.state[13+i] = binary.LittleEndian.Uint32(nonce[i*4:])
+	}
+	return s, nil
+}
+
+// NewSalsa20 returns a Salsa20/20 stream with a 32-byte key and 8-byte nonce
+func NewSalsa20(key, nonce []byte) (cipher.Stream, error) {
+	if len(key) != ChaCha20KeySize {
+		return nil, errors.New("salsa20: invalid key size")
+	}
+	if len(nonce) != Salsa20NonceSize {
+		return nil, errors.New("salsa20: invalid nonce size")
+	}
+
+	s := &streamCipher{offset: 64, block: salsaBlock, next: salsaNext}
+	s.state[0], s.state[5], s.state[10], s.state[15] = sigma[0], sigma[1], sigma[2], sigma[3]
+	for i := 0; i < 4; i++ {
+		s.state[1+i] = binary.LittleEndian.Uint32(key[i*4:])
+		s.state[11+i] = binary.LittleEndian.Uint32(key[16+i*4:])
+	}
+	s.state[6] = binary.LittleEndian.Uint32(nonce[0:])
+	s.state[7] = binary.LittleEndian.Uint32(nonce[4:])
+	// Words 8 and 9 hold the 64-bit block counter, starting at 0
+	return s, nil
+}
+
+// XORKeyStream implements cipher.Stream
+func (s *streamCipher) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("crypto: output smaller than input")
+	}
+	for i := range src {
+		if s.offset == 64 {
+			s.block(&s.keystream, &s.state)
+			s.next(&s.state)
+			s.offset = 0
+		}
+		dst[i] = src[i] ^ s.keystream[s.offset]
+		s.offset++
+	}
+}
+
+func chachaNext(state *[16]uint32) {
+	state[12]++
+}
+
+func salsaNext(state *[16]uint32) {
+	state[8]++
+	if state[8] == 0 {
+		state[9]++
+	}
+}
+
+// chachaBlock computes one 64-byte ChaCha20 keystream block
+func chachaBlock(out *[64]byte, state *[16]uint32) {
+	x := *state
+	for i := 0; i < 10; i++ {
+		// Column rounds
+		chachaQuarter(&x, 0, 4, 8, 12)
+		chachaQuarter(&x, 1, 5, 9, 13)
+		chachaQuarter(&x, 2, 6, 10, 14)
+		chachaQuarter(&x, 3, 7, 11, 15)
+		// Diagonal rounds
+		chachaQuarter(&x, 0, 5, 10, 15)
+		chachaQuarter(&x, 1, 6, 11, 12)
+		chachaQuarter(&x, 2, 7, 8, 13)
+		chachaQuarter(&x, 3, 4, 9, 14)
+	}
+	for i := range x {
+		binary.LittleEndian.PutUint32(out[i*4:], x[i]+state[i])
+	}
+}
+
+func chachaQuarter(x *[16]uint32, a, b, c, d int) {
+	x[a] += x[b]
+	x[d] = bits.RotateLeft32(x[d]^x[a], 16)
+	x[c] += x[d]
+	x[b] = bits.RotateLeft32(x[b]^x[c], 12)
+	x[a] += x[b]
+	x[d] = bits.RotateLeft32(x[d]^x[a], 8)
+	x[c] += x[d]
+	x[b] = bits.RotateLeft32(x[b]^x[c], 7)
+}
+
+// salsaBlock computes one 64-byte Salsa20/20 keystream block
+func salsaBlock(out *[64]byte, state *[16]uint32) {
+	x := *state
+	for i := 0; i < 10; i++ {
+		// Column rounds
+		salsaQuarter(&x, 0, 4, 8, 12)
+		salsaQuarter(&x, 5, 9, 13, 1)
+		salsaQuarter(&x, 10, 14, 2, 6)
+		salsaQuarter(&x, 15, 3, 7, 11)
+		// Row rounds
+		salsaQuarter(&x, 0, 1, 2, 3)
+		salsaQuarter(&x, 5, 6, 7, 4)
+		salsaQuarter(&x, 10, 11, 8, 9)
+		salsaQuarter(&x, 15, 12, 13, 14)
+	}
+	for i := range x {
+		binary.LittleEndian.PutUint32(out[i*4:], x[i]+state[i])
+	}
+}
+
+func salsaQuarter(x *[16]uint32, a, b, c, d int) {
+	x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+	x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+	x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+	x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+}
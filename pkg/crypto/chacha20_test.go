@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// rfcKey is the key 00 01 02 ... 1f of the RFC 8439 examples
+func rfcKey() []byte {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+// RFC 8439 section 2.3.2: the block function
+func TestChaCha20Block(t *testing.T) {
+	s, err := newChaCha20(rfcKey(), mustHex(t, "000000090000004a00000000"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keystream := make([]byte, 64)
+	s.XORKeyStream(keystream, keystream)
+
+	want := "10f1e7e4d13b5915500fdd1fa32071c4c7d1f4c733c068030422aa9ac3d46c4e" +
+		"d2826446079faa0914c2d705d98b02a2b5129cd1de164eb9cbd083e8a2503c4e"
+	if got := hex.EncodeToString(keystream); got != want {
+		t.Errorf("keystream = %s, want %s", got, want)
+	}
+}
+
+// RFC 8439 section 2.4.2: encrypting more than one block
+func TestChaCha20Encrypt(t *testing.T) {
+	plaintext := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	want := "6e2e359a2568f98041ba0728dd0d6981e97e7aec1d4360c20a27afccfd9fae0b" +
+		"f91b65c5524733ab8f593dabcd62b3571639d624e65152ab8f530c359f0861d8" +
+		"07ca0dbf500d6a6156a38e088a22b65e52bc514d16ccf806818ce91ab7793736" +
+		"5af90bbf74a35be6b40b8eedf2785e42874d"
+
+	s, err := newChaCha20(rfcKey(), mustHex(t, "000000000000004a00000000"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Uneven writes must continue the keystream where the last one stopped
+	ciphertext := make([]byte, len(plaintext))
+	for _, r := range [][2]int{{0, 7}, {7, 64}, {64, 65}, {65, len(plaintext)}} {
+		s.XORKeyStream(ciphertext[r[0]:r[1]], plaintext[r[0]:r[1]])
+	}
+	if got := hex.EncodeToString(ciphertext); got != want {
+		t.Errorf("ciphertext = %s, want %s", got, want)
+	}
+}
+
+// ECRYPT Salsa20/20 test vectors, 256-bit key set 1 vector 0: key 80 00 ...,
+// zero nonce
+func TestSalsa20(t *testing.T) {
+	key := make([]byte, 32)
+	key[0] = 0x80
+
+	s, err := NewSalsa20(key, make([]byte, Salsa20NonceSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystream := make([]byte, 512)
+	s.XORKeyStream(keystream, keystream)
+
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, "e3be8fdd8beca2e3ea8ef9475b29a6e7003951e1097a5c38d23b7a5fad9f6844" +
+			"b22c97559e2723c7cbbd3fe4fc8d9a0744652a83e72a9c461876af4d7ef1a117"},
+		{448, "696afcfd0cddcc83c7e77f11a649d79acdc3354e9635ff137e929933a0bd6f53" +
+			"77efa105a3a4266b7c0d089d08f1e855cc32b15b93784a36e56a76cc64bc8477"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(keystream[tt.offset : tt.offset+64]); got != tt.want {
+			t.Errorf("stream[%d:%d] = %s, want %s", tt.offset, tt.offset+64, got, tt.want)
+		}
+	}
+}
+
+func TestStreamCipherSizes(t *testing.T) {
+	if _, err := NewChaCha20(make([]byte, 16), make([]byte, ChaCha20NonceSize)); err == nil {
+		t.Error("ChaCha20 accepted a 16-byte key")
+	}
+	if _, err := NewChaCha20(make([]byte, ChaCha20KeySize), make([]byte, 8)); err == nil {
+		t.Error("ChaCha20 accepted an 8-byte nonce")
+	}
+	if _, err := NewSalsa20(make([]byte, ChaCha20KeySize), make([]byte, 12)); err == nil {
+		t.Error("Salsa20 accepted a 12-byte nonce")
+	}
+
+	// Encrypting twice with the same key and nonce gives the plaintext back
+	plaintext := bytes.Repeat([]byte("openpasswd"), 20)
+	data := bytes.Clone(plaintext)
+	for i := 0; i < 2; i++ {
+		s, err := NewChaCha20(rfcKey(), make([]byte, ChaCha20NonceSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.XORKeyStream(data, data)
+	}
+	if !bytes.Equal(data, plaintext) {
+		t.Error("ChaCha20 round trip changed the data")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"hash"
 	"io"
 )
@@ -21,7 +22,8 @@ const (
 )
 
 type Encryptor struct {
-	key []byte
+	key    []byte
+	locked bool
 }
 
 // GetKey returns the encryption key (for HMAC derivation)
@@ -29,6 +31,28 @@ func (e *Encryptor) GetKey() []byte {
 	return e.key
 }
 
+// LockMemory keeps the key out of swap for long-lived processes like the agent
+func (e *Encryptor) LockMemory() error {
+	if e.locked {
+		return nil
+	}
+	if err := mlock(e.key); err != nil {
+		return fmt.Errorf("failed to lock key in memory: %w", err)
+	}
+	e.locked = true
+	return nil
+}
+
+// Wipe zeroes the key; the encryptor can't be used afterwards
+func (e *Encryptor) Wipe() {
+	WipeMemory(e.key)
+	if e.locked {
+		_ = munlock(e.key)
+		e.locked = false
+	}
+	e.key = nil
+}
+
 func pbkdf2Key(password, salt []byte, iterations, keyLen int, h func() hash.Hash) []byte {
 	prf := hmac.New(h, password)
 	hashLen := prf.Size()
@@ -64,7 +88,9 @@ func pbkdf2Key(password, salt []byte, iterations, keyLen int, h func() hash.Hash
 	return dk[:keyLen]
 }
 
-// NewEncryptor creates an encryptor using the current KDF version (600k iterations)
+// NewEncryptor creates an encryptor using the current KDF version (600k iterations).
+// Vaults record the KDF version they were created with; open them with
+// NewEncryptorWithVersion or a vault.Session.
 func NewEncryptor(passphrase string, salt []byte) *Encryptor {
 	key := pbkdf2Key([]byte(passphrase), salt, iterationsCurrent, keySize, sha256.New)
 	return &Encryptor{key: key}
@@ -72,18 +98,28 @@ func NewEncryptor(passphrase string, salt []byte) *Encryptor {
 
 // NewEncryptorWithVersion creates an encryptor with a specific KDF version
 func NewEncryptorWithVersion(passphrase string, salt []byte, version int) *Encryptor {
-	params := GetKDFParams(version)
+	return &Encryptor{key: DeriveKey(passphrase, salt, GetKDFParams(version))}
+}
+
+// NewEncryptorFromKey creates an encryptor from an already derived 32-byte key
+func NewEncryptorFromKey(key []byte) (*Encryptor, error) {
+	if len(key) != keySize {
+		return nil, errors.New("invalid key size")
+	}
+	k := make([]byte, keySize)
+	copy(k, key)
+	return &Encryptor{key: k}, nil
+}
 
-	var key []byte
-	if version == KDFVersionArgon2id && params.Argon2 != nil {
+// DeriveKey derives a 32-byte encryption key from a passphrase with the given KDF parameters
+func DeriveKey(passphrase string, salt []byte, params KDFParams) []byte {
+	if params.Argon2 != nil {
 		// Use Argon2id
-		key = Argon2idKey([]byte(passphrase), salt, *params.Argon2)
-	} else {
-		// Use PBKDF2
-		key = pbkdf2Key([]byte(passphrase), salt, params.Iterations, keySize, sha256.New)
+		return Argon2idKey([]byte(passphrase), salt, *params.Argon2)
 	}
 
-	return &Encryptor{key: key}
+	// Use PBKDF2
+	return pbkdf2Key([]byte(passphrase), salt, params.Iterations, keySize, sha256.New)
 }
 
 // NewEncryptorArgon2id creates an encryptor using Argon2id
@@ -0,0 +1,113 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+type eax struct {
+	block  cipher.Block
+	k1, k2 [16]byte // CMAC subkeys
+}
+
+// NewEAX returns EAX with a 16-byte nonce and 128-bit tag
+func NewEAX(block cipher.Block) (cipher.AEAD, error) {
+	if block.BlockSize() != aeadBlockSize {
+		return nil, errors.New("eax: block size must be 128 bits")
+	}
+
+	e := &eax{block: block}
+	var l [16]byte
+	block.Encrypt(l[:], l[:])
+	e.k1 = double(l)
+	e.k2 = double(e.k1)
+	return e, nil
+}
+
+func (e *eax) NonceSize() int { return aeadBlockSize }
+func (e *eax) Overhead() int  { return aeadTagSize }
+
+// omac computes OMAC^t(data), i.e. CMAC over [t]_16 || data
+func (e *eax) omac(t byte, data []byte) [16]byte {
+	var mac [16]byte
+	mac[15] = t
+
+	// With no data the tweak block is the last, complete block
+	if len(data) == 0 {
+		xorBlock(&mac, e.k1)
+		e.block.Encrypt(mac[:], mac[:])
+		return mac
+	}
+	e.block.Encrypt(mac[:], mac[:])
+
+	for len(data) > 16 {
+		for j := range mac {
+			mac[j] ^= data[j]
+		}
+		e.block.Encrypt(mac[:], mac[:])
+		data = data[16:]
+	}
+
+	// Last block: complete blocks use K1, padded ones K2
+	if len(data) == 16 {
+		for j := range mac {
+			mac[j] ^= data[j] ^ e.k1[j]
+		}
+	} else {
+		var last [16]byte
+		copy(last[:], data)
+		last[len(data)] = 0x80
+		for j := range mac {
+			mac[j] ^= last[j] ^ e.k2[j]
+		}
+	}
+	e.block.Encrypt(mac[:], mac[:])
+	return mac
+}
+
+func (e *eax) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != aeadBlockSize {
+		panic("eax: incorrect nonce length")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+aeadTagSize)
+	n := e.omac(0, nonce)
+	h := e.omac(1, additionalData)
+
+	cipher.NewCTR(e.block, n[:]).XORKeyStream(out[:len(plaintext)], plaintext)
+	c := e.omac(2, out[:len(plaintext)])
+
+	for j := 0; j < aeadTagSize; j++ {
+		out[len(plaintext)+j] = n[j] ^ c[j] ^ h[j]
+	}
+	return ret
+}
+
+func (e *eax) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != aeadBlockSize {
+		panic("eax: incorrect nonce length")
+	}
+	if len(ciphertext) < aeadTagSize {
+		return nil, errOpen
+	}
+
+	expected := ciphertext[len(ciphertext)-aeadTagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-aeadTagSize]
+
+	n := e.omac(0, nonce)
+	h := e.omac(1, additionalData)
+	c := e.omac(2, ciphertext)
+
+	var tag [16]byte
+	for j := range tag {
+		tag[j] = n[j] ^ c[j] ^ h[j]
+	}
+	if subtle.ConstantTimeCompare(tag[:], expected) != 1 {
+		return nil, errOpen
+	}
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	cipher.NewCTR(e.block, n[:]).XORKeyStream(out, ciphertext)
+	return ret, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+
+package crypto
+
+import "errors"
+
+func mlock(b []byte) error {
+	return errors.New("memory locking is not supported on this platform")
+}
+
+func munlock(b []byte) error {
+	return nil
+}
@@ -0,0 +1,248 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// OCB and EAX authenticated encryption modes for 128-bit block ciphers
+// Based on RFC 7253 (OCB) and Bellare, Rogaway and Wagner's EAX paper.
+// Both are used by OpenPGP's AEAD packets; the standard library only has GCM.
+
+const (
+	aeadBlockSize = 16
+	aeadTagSize   = 16
+)
+
+var errOpen = errors.New("crypto: message authentication failed")
+
+type ocb struct {
+	block     cipher.Block
+	nonceSize int
+	lStar     [16]byte
+	lDollar   [16]byte
+	l         [][16]byte // L_0, L_1, ... computed on demand
+}
+
+// NewOCB returns OCB3 with a 128-bit tag and the given nonce size (1 to 15
+// bytes; OpenPGP uses 15)
+func NewOCB(block cipher.Block, nonceSize int) (cipher.AEAD, error) {
+	if block.BlockSize() != aeadBlockSize {
+		return nil, errors.New("ocb: block size must be 128 bits")
+	}
+	if nonceSize < 1 || nonceSize > 15 {
+		return nil, errors.New("ocb: invalid nonce size")
+	}
+
+	o := &ocb{block: block, nonceSize: nonceSize}
+	block.Encrypt(o.lStar[:], o.lStar[:])
+	o.lDollar = double(o.lStar)
+	o.l = append(o.l, double(o.lDollar))
+	return o, nil
+}
+
+func (o *ocb) NonceSize() int { return o.nonceSize }
+func (o *ocb) Overhead() int  { return aeadTagSize }
+
+// lAt returns L_i, extending the table as needed
+func (o *ocb) lAt(i int) [16]byte {
+	for len(o.l) <= i {
+		o.l = append(o.l, double(o.l[len(o.l)-1]))
+	}
+	return o.l[i]
+}
+
+// initialOffset computes Offset_0 from the nonce
+func (o *ocb) initialOffset(nonce []byte) [16]byte {
+	var n [16]byte
+	// 7 bits of tag length (128 mod 128 = 0), zero padding, a 1 bit, then the nonce
+	copy(n[16-len(nonce):], nonce)
+	n[15-len(nonce)] |= 1
+
+	bottom := int(n[15] & 0x3f)
+	n[15] &= 0xc0
+
+	var ktop [16]byte
+	o.block.Encrypt(ktop[:], n[:])
+
+	var stretch [24]byte
+	copy(stretch[:], ktop[:])
+	for i := 0; i < 8; i++ {
+		stretch[16+i] = ktop[i] ^ ktop[i+1]
+	}
+
+	// Offset_0 is bits bottom..bottom+127 of Stretch
+	var offset [16]byte
+	shift, bit := bottom/8, uint(bottom%8)
+	for i := 0; i < 16; i++ {
+		offset[i] = stretch[i+shift] << bit
+		if bit != 0 {
+			offset[i] |= stretch[i+shift+1] >> (8 - bit)
+		}
+	}
+	return offset
+}
+
+// hash computes HASH(K, A) over the associated data
+func (o *ocb) hash(ad []byte) [16]byte {
+	var sum, offset, tmp [16]byte
+	i := 1
+	for ; len(ad) >= 16; i++ {
+		xorBlock(&offset, o.lAt(ntz(i)))
+		for j := range tmp {
+			tmp[j] = ad[j] ^ offset[j]
+		}
+		o.block.Encrypt(tmp[:], tmp[:])
+		xorBlock(&sum, tmp)
+		ad = ad[16:]
+	}
+	if len(ad) > 0 {
+		xorBlock(&offset, o.lStar)
+		tmp = [16]byte{}
+		copy(tmp[:], ad)
+		tmp[len(ad)] = 0x80
+		xorBlock(&tmp, offset)
+		o.block.Encrypt(tmp[:], tmp[:])
+		xorBlock(&sum, tmp)
+	}
+	return sum
+}
+
+func (o *ocb) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != o.nonceSize {
+		panic("ocb: incorrect nonce length")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+aeadTagSize)
+	offset := o.initialOffset(nonce)
+	var checksum, tmp [16]byte
+
+	i := 1
+	for ; len(plaintext) >= 16; i++ {
+		xorBlock(&offset, o.lAt(ntz(i)))
+		for j := range tmp {
+			checksum[j] ^= plaintext[j]
+			tmp[j] = plaintext[j] ^ offset[j]
+		}
+		o.block.Encrypt(tmp[:], tmp[:])
+		for j := range tmp {
+			out[j] = tmp[j] ^ offset[j]
+		}
+		plaintext, out = plaintext[16:], out[16:]
+	}
+	if len(plaintext) > 0 {
+		xorBlock(&offset, o.lStar)
+		var pad [16]byte
+		o.block.Encrypt(pad[:], offset[:])
+		for j := range plaintext {
+			checksum[j] ^= plaintext[j]
+			out[j] = plaintext[j] ^ pad[j]
+		}
+		checksum[len(plaintext)] ^= 0x80
+		out = out[len(plaintext):]
+	}
+
+	tag := o.tag(checksum, offset, additionalData)
+	copy(out, tag[:])
+	return ret
+}
+
+func (o *ocb) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != o.nonceSize {
+		panic("ocb: incorrect nonce length")
+	}
+	if len(ciphertext) < aeadTagSize {
+		return nil, errOpen
+	}
+
+	expected := ciphertext[len(ciphertext)-aeadTagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-aeadTagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	plain := out
+	offset := o.initialOffset(nonce)
+	var checksum, tmp [16]byte
+
+	i := 1
+	for ; len(ciphertext) >= 16; i++ {
+		xorBlock(&offset, o.lAt(ntz(i)))
+		for j := range tmp {
+			tmp[j] = ciphertext[j] ^ offset[j]
+		}
+		o.block.Decrypt(tmp[:], tmp[:])
+		for j := range tmp {
+			out[j] = tmp[j] ^ offset[j]
+			checksum[j] ^= out[j]
+		}
+		ciphertext, out = ciphertext[16:], out[16:]
+	}
+	if len(ciphertext) > 0 {
+		xorBlock(&offset, o.lStar)
+		var pad [16]byte
+		o.block.Encrypt(pad[:], offset[:])
+		for j := range ciphertext {
+			out[j] = ciphertext[j] ^ pad[j]
+			checksum[j] ^= out[j]
+		}
+		checksum[len(ciphertext)] ^= 0x80
+	}
+
+	tag := o.tag(checksum, offset, additionalData)
+	if subtle.ConstantTimeCompare(tag[:], expected) != 1 {
+		WipeMemory(plain)
+		return nil, errOpen
+	}
+	return ret, nil
+}
+
+// tag computes ENCIPHER(K, Checksum xor Offset xor L_$) xor HASH(K, A)
+func (o *ocb) tag(checksum, offset [16]byte, ad []byte) [16]byte {
+	var t [16]byte
+	for j := range t {
+		t[j] = checksum[j] ^ offset[j] ^ o.lDollar[j]
+	}
+	o.block.Encrypt(t[:], t[:])
+	xorBlock(&t, o.hash(ad))
+	return t
+}
+
+// double multiplies by x in GF(2^128)
+func double(b [16]byte) [16]byte {
+	var out [16]byte
+	carry := b[0] >> 7
+	for i := 0; i < 15; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[15] = b[15]<<1 ^ carry*0x87
+	return out
+}
+
+// ntz returns the number of trailing zero bits of i
+func ntz(i int) int {
+	n := 0
+	for i&1 == 0 {
+		i >>= 1
+		n++
+	}
+	return n
+}
+
+func xorBlock(dst *[16]byte, src [16]byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the
+// new part
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
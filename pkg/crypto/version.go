@@ -8,8 +8,24 @@ const (
 
 	// CurrentKDFVersion is the default version for new encryptions
 	CurrentKDFVersion = KDFVersionPBKDF2_600k
+
+	// MinSyncKDFVersion is the weakest KDF sync accounts may use. The server
+	// tells the client which version to use before either side has proven
+	// anything, so the client must not accept a cheaper one.
+	MinSyncKDFVersion = KDFVersionArgon2id
 )
 
+// KnownKDFVersion reports whether GetKDFParams has parameters for a version,
+// rather than falling back to the current ones
+func KnownKDFVersion(version int) bool {
+	return version >= KDFVersionPBKDF2_100k && version <= KDFVersionArgon2id
+}
+
+// ValidSyncKDFVersion reports whether a sync account may use a KDF version
+func ValidSyncKDFVersion(version int) bool {
+	return KnownKDFVersion(version) && version >= MinSyncKDFVersion
+}
+
 // KDFParams holds parameters for key derivation
 type KDFParams struct {
 	Version    int
@@ -3,7 +3,9 @@ package database
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -11,11 +13,23 @@ import (
 	"github.com/r2unit/openpasswd/pkg/models"
 )
 
+// ErrModified is returned for a change to a database file another process
+// wrote since it was loaded; Reload and try again
+var ErrModified = errors.New("database was changed by another process")
+
 type DB struct {
-	path      string
-	passwords map[int64]*models.Password
-	nextID    int64
-	mu        sync.RWMutex
+	path       string
+	passwords  map[int64]*models.Password
+	nextID     int64
+	generation int64 // counts saves, to notice writes by other processes
+	mu         sync.RWMutex
+}
+
+// storeFile is the layout of the database file
+type storeFile struct {
+	NextID     int64                      `json:"next_id"`
+	Generation int64                      `json:"generation,omitempty"`
+	Passwords  map[int64]*models.Password `json:"passwords"`
 }
 
 func New(dbPath string) (*DB, error) {
@@ -33,41 +47,108 @@ func New(dbPath string) (*DB, error) {
 }
 
 func (db *DB) load() error {
-	data, err := os.ReadFile(db.path)
+	store, err := readStore(db.path)
 	if err != nil {
 		return err
 	}
 
-	var store struct {
-		NextID    int64                      `json:"next_id"`
-		Passwords map[int64]*models.Password `json:"passwords"`
+	db.passwords = store.Passwords
+	if db.passwords == nil {
+		db.passwords = make(map[int64]*models.Password)
+	}
+	db.nextID = store.NextID
+	db.generation = store.Generation
+
+	return nil
+}
+
+func readStore(path string) (*storeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 
+	var store storeFile
 	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// Reload reads the file again, picking up changes other processes made
+// since it was loaded. Long-running users such as the agent call it before
+// serving a request.
+func (db *DB) Reload() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to reload database: %w", err)
+	}
+	return nil
+}
+
+// write applies a change and saves it while holding the file lock. The
+// change is refused with ErrModified if another process saved the file
+// since this one loaded it, as saving would silently drop that process's
+// entries.
+func (db *DB) write(change func() error) error {
+	unlock, err := lockFile(db.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock database: %w", err)
+	}
+	defer unlock()
+
+	var generation int64
+	store, err := readStore(db.path)
+	switch {
+	case err == nil:
+		generation = store.Generation
+	case !errors.Is(err, os.ErrNotExist):
 		return err
 	}
+	if generation != db.generation {
+		return ErrModified
+	}
 
-	db.passwords = store.Passwords
-	db.nextID = store.NextID
+	if err := change(); err != nil {
+		return err
+	}
 
+	db.generation++
+	if err := db.save(); err != nil {
+		db.generation--
+		return err
+	}
 	return nil
 }
 
+// save writes the file through a temporary file, so other processes never
+// read half of it
 func (db *DB) save() error {
-	store := struct {
-		NextID    int64                      `json:"next_id"`
-		Passwords map[int64]*models.Password `json:"passwords"`
-	}{
-		NextID:    db.nextID,
-		Passwords: db.passwords,
+	data, err := json.MarshalIndent(storeFile{
+		NextID:     db.nextID,
+		Generation: db.generation,
+		Passwords:  db.passwords,
+	}, "", "  ")
+	if err != nil {
+		return err
 	}
 
-	data, err := json.MarshalIndent(store, "", "  ")
+	tmp, err := os.CreateTemp(filepath.Dir(db.path), ".passwords-*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
 
-	return os.WriteFile(db.path, data, 0600)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), db.path)
 }
 
 func (db *DB) Close() error {
@@ -78,15 +159,16 @@ func (db *DB) AddPassword(p *models.Password) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	now := time.Now()
-	p.ID = db.nextID
-	p.CreatedAt = now
-	p.UpdatedAt = now
-
-	db.passwords[p.ID] = p
-	db.nextID++
+	return db.write(func() error {
+		now := time.Now()
+		p.ID = db.nextID
+		p.CreatedAt = now
+		p.UpdatedAt = now
 
-	return db.save()
+		db.passwords[p.ID] = p
+		db.nextID++
+		return nil
+	})
 }
 
 func (db *DB) GetPassword(id int64) (*models.Password, error) {
@@ -117,26 +199,29 @@ func (db *DB) UpdatePassword(p *models.Password) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if _, ok := db.passwords[p.ID]; !ok {
-		return errors.New("password not found")
-	}
-
-	p.UpdatedAt = time.Now()
-	db.passwords[p.ID] = p
+	return db.write(func() error {
+		if _, ok := db.passwords[p.ID]; !ok {
+			return errors.New("password not found")
+		}
 
-	return db.save()
+		p.UpdatedAt = time.Now()
+		db.passwords[p.ID] = p
+		return nil
+	})
 }
 
 func (db *DB) DeletePassword(id int64) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if _, ok := db.passwords[id]; !ok {
-		return errors.New("password not found")
-	}
+	return db.write(func() error {
+		if _, ok := db.passwords[id]; !ok {
+			return errors.New("password not found")
+		}
 
-	delete(db.passwords, id)
-	return db.save()
+		delete(db.passwords, id)
+		return nil
+	})
 }
 
 func (db *DB) SearchPasswords(search string) ([]*models.Password, error) {
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// Two handles on one file stand in for two processes, e.g. the agent and a
+// direct 'openpass import'
+func TestConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.json")
+
+	agent, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.AddPassword(&models.Password{Name: "imported"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stale handle must not overwrite the other writer's entry
+	if err := agent.AddPassword(&models.Password{Name: "stale"}); !errors.Is(err, ErrModified) {
+		t.Fatalf("stale write: err = %v, want ErrModified", err)
+	}
+
+	if err := agent.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.AddPassword(&models.Password{Name: "fresh"}); err != nil {
+		t.Fatalf("write after reload: %v", err)
+	}
+
+	reopened, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwords, _ := reopened.ListPasswords()
+	names := map[string]bool{}
+	for _, p := range passwords {
+		names[p.Name] = true
+	}
+	if len(passwords) != 2 || !names["imported"] || !names["fresh"] {
+		t.Errorf("stored entries = %v, want imported and fresh", names)
+	}
+}
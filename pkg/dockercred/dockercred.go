@@ -0,0 +1,149 @@
+// Package dockercred implements the Docker credential helper protocol (see
+// github.com/docker/docker-credential-helpers) on top of the vault. Registry
+// logins are kept as "registry" entries, apart from the normal logins.
+package dockercred
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// BinaryName is the name Docker runs the helper as when config.json has
+// "credsStore": "openpasswd"
+const BinaryName = "docker-credential-openpasswd"
+
+// Operations of the protocol
+const (
+	OpGet     = "get"
+	OpStore   = "store"
+	OpErase   = "erase"
+	OpList    = "list"
+	OpVersion = "version"
+)
+
+// maxInput bounds what Docker sends on stdin
+const maxInput = 1 << 20
+
+// ErrNotFound is the message Docker recognizes as "no credentials stored"
+var ErrNotFound = errors.New("credentials not found in native keychain")
+
+// Credentials is the JSON object exchanged with Docker
+type Credentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// ReadServerURL reads the server URL sent by get and erase
+func ReadServerURL(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxInput))
+	if err != nil {
+		return "", fmt.Errorf("failed to read server URL: %w", err)
+	}
+	serverURL := strings.TrimSpace(string(data))
+	if serverURL == "" {
+		return "", errors.New("no credentials server URL")
+	}
+	return serverURL, nil
+}
+
+// ReadCredentials reads the JSON object sent by store
+func ReadCredentials(r io.Reader) (*Credentials, error) {
+	var creds Credentials
+	if err := json.NewDecoder(io.LimitReader(r, maxInput)).Decode(&creds); err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	if strings.TrimSpace(creds.ServerURL) == "" {
+		return nil, errors.New("no credentials server URL")
+	}
+	if creds.Username == "" {
+		return nil, errors.New("no credentials username")
+	}
+	return &creds, nil
+}
+
+// Get returns the credentials stored for a registry
+func Get(items []*vault.Item, serverURL string) (*Credentials, error) {
+	item := find(items, serverURL)
+	if item == nil {
+		return nil, ErrNotFound
+	}
+	return &Credentials{ServerURL: serverURL, Username: item.Username, Secret: item.Password}, nil
+}
+
+// Store saves the credentials of a registry, replacing the ones stored for
+// it before
+func Store(store vault.Store, items []*vault.Item, creds *Credentials) error {
+	if item := find(items, creds.ServerURL); item != nil {
+		if item.Username == creds.Username && item.Password == creds.Secret {
+			return nil
+		}
+		updated := *item
+		updated.Username = creds.Username
+		updated.Password = creds.Secret
+		return store.Update(&updated)
+	}
+
+	_, err := store.Add(&vault.Item{
+		Type:     models.TypeRegistry,
+		Name:     registryKey(creds.ServerURL),
+		Username: creds.Username,
+		Password: creds.Secret,
+		URL:      creds.ServerURL,
+		Fields:   make(map[string]string),
+	})
+	return err
+}
+
+// Erase deletes the credentials of a registry
+func Erase(store vault.Store, items []*vault.Item, serverURL string) error {
+	item := find(items, serverURL)
+	if item == nil {
+		return ErrNotFound
+	}
+	return store.Delete(item.ID)
+}
+
+// List maps the server URL of every stored registry to its username
+func List(items []*vault.Item) map[string]string {
+	out := make(map[string]string)
+	for _, item := range items {
+		if item.Type == models.TypeRegistry && item.URL != "" {
+			out[item.URL] = item.Username
+		}
+	}
+	return out
+}
+
+// find returns the registry entry for a server URL
+func find(items []*vault.Item, serverURL string) *vault.Item {
+	key := registryKey(serverURL)
+	for _, item := range items {
+		if item.Type == models.TypeRegistry && registryKey(item.URL) == key {
+			return item
+		}
+	}
+	return nil
+}
+
+// registryKey normalizes a server URL, so "https://ghcr.io/" and "ghcr.io"
+// are the same registry while index.docker.io/v1 keeps its path
+func registryKey(serverURL string) string {
+	raw := strings.TrimSpace(serverURL)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(strings.TrimRight(serverURL, "/"))
+	}
+	return strings.ToLower(u.Host) + strings.TrimRight(u.Path, "/")
+}
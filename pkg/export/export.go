@@ -0,0 +1,351 @@
+package export
+
+// Package export writes decrypted vault items in plaintext formats that
+// other password managers (or scripts) can read.
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/bitwarden"
+	"github.com/r2unit/openpasswd/pkg/keepass"
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
+	"github.com/r2unit/openpasswd/pkg/version"
+)
+
+// Supported plaintext formats
+const (
+	FormatJSON          = "json"
+	FormatCSV           = "csv"
+	FormatBitwardenJSON = "bitwarden-json"
+	FormatKeePassXML    = "keepass-xml"
+)
+
+// Formats lists the plaintext formats in the order shown in help output
+var Formats = []string{FormatJSON, FormatCSV, FormatBitwardenJSON, FormatKeePassXML}
+
+// Write writes items to w in the given plaintext format
+func Write(w io.Writer, format string, items []*vault.Item) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, items)
+	case FormatCSV:
+		return writeCSV(w, items)
+	case FormatBitwardenJSON:
+		return writeBitwarden(w, items)
+	case FormatKeePassXML:
+		return writeKeePass(w, items)
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+func writeJSON(w io.Writer, items []*vault.Item) error {
+	doc := struct {
+		Version    int           `json:"version"`
+		ExportedAt time.Time     `json:"exported_at"`
+		Items      []*vault.Item `json:"items"`
+	}{
+		Version:    1,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Items:      items,
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func writeCSV(w io.Writer, items []*vault.Item) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"type", "name", "username", "password", "url", "notes", "totp", "fields"}); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// Custom fields other than the TOTP secret go into one JSON column
+		var fields string
+		extra := customFields(item)
+		if len(extra) > 0 {
+			data, err := json.Marshal(extra)
+			if err != nil {
+				return err
+			}
+			fields = string(data)
+		}
+
+		record := []string{
+			string(item.Type),
+			item.Name,
+			item.Username,
+			item.Password,
+			item.URL,
+			item.Notes,
+			item.Fields[models.FieldTOTP],
+			fields,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeBitwarden(w io.Writer, items []*vault.Item) error {
+	doc := bitwarden.Export{
+		Folders: []bitwarden.Folder{},
+		Items:   make([]bitwarden.Item, 0, len(items)),
+	}
+
+	for _, item := range items {
+		doc.Items = append(doc.Items, toBitwarden(item))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func toBitwarden(item *vault.Item) bitwarden.Item {
+	out := bitwarden.Item{
+		Name:          item.Name,
+		Notes:         bitwarden.String(item.Notes),
+		CollectionIDs: []string{},
+		CreationDate:  formatBitwardenTime(item.CreatedAt),
+		RevisionDate:  formatBitwardenTime(item.UpdatedAt),
+	}
+
+	// Fields that map onto the item type are consumed; the rest become custom fields
+	used := map[string]bool{models.FieldTOTP: true}
+	take := func(keys ...string) *string {
+		for _, key := range keys {
+			if val, ok := item.Fields[key]; ok {
+				used[key] = true
+				return bitwarden.String(val)
+			}
+		}
+		return nil
+	}
+
+	switch item.Type {
+	case models.TypeCard:
+		out.Type = bitwarden.TypeCard
+		month, year := splitExpiry(bitwarden.Value(take("expiry", "expiration_date")))
+		out.Card = &bitwarden.Card{
+			CardholderName: take("cardholder", "cardholder_name"),
+			Number:         take("number"),
+			ExpMonth:       bitwarden.String(month),
+			ExpYear:        bitwarden.String(year),
+			Code:           take("cvv"),
+		}
+
+	case models.TypeIdentity:
+		out.Type = bitwarden.TypeIdentity
+		first, last := splitName(bitwarden.Value(take("full_name")))
+		out.Identity = &bitwarden.Identity{
+			FirstName: bitwarden.String(first),
+			LastName:  bitwarden.String(last),
+			Email:     take("email"),
+			Phone:     take("phone", "phone_number"),
+			Address1:  take("address"),
+		}
+
+	case models.TypeNote:
+		out.Type = bitwarden.TypeSecureNote
+		out.SecureNote = &bitwarden.SecureNote{}
+
+	case models.TypeSSHKey:
+		out.Type = bitwarden.TypeSSHKey
+		out.SSHKey = &bitwarden.SSHKey{
+			PrivateKey:     take(models.FieldPrivateKey),
+			PublicKey:      take(models.FieldPublicKey),
+			KeyFingerprint: take(models.FieldFingerprint),
+		}
+
+	default:
+		out.Type = bitwarden.TypeLogin
+		out.Login = &bitwarden.Login{
+			Username: bitwarden.String(item.Username),
+			Password: bitwarden.String(item.Password),
+			TOTP:     bitwarden.String(item.Fields[models.FieldTOTP]),
+		}
+		if item.URL != "" {
+			out.Login.URIs = []bitwarden.URI{{URI: item.URL}}
+		}
+		if item.Password == "" {
+			out.Login.Password = take("value")
+		}
+	}
+
+	// Card, identity, note and SSH key items have no login, so keep their credentials as hidden fields
+	if out.Login == nil {
+		if item.Username != "" {
+			out.Fields = append(out.Fields, bitwarden.Field{Name: "username", Value: bitwarden.String(item.Username), Type: bitwarden.FieldText})
+		}
+		if item.Password != "" {
+			out.Fields = append(out.Fields, bitwarden.Field{Name: "password", Value: bitwarden.String(item.Password), Type: bitwarden.FieldHidden})
+		}
+		if item.URL != "" {
+			out.Fields = append(out.Fields, bitwarden.Field{Name: "url", Value: bitwarden.String(item.URL), Type: bitwarden.FieldText})
+		}
+		if totp := item.Fields[models.FieldTOTP]; totp != "" {
+			out.Fields = append(out.Fields, bitwarden.Field{Name: "totp", Value: bitwarden.String(totp), Type: bitwarden.FieldHidden})
+		}
+	}
+
+	for _, key := range sortedKeys(item.Fields) {
+		if used[key] {
+			continue
+		}
+		fieldType := bitwarden.FieldText
+		if isSensitive(key) {
+			fieldType = bitwarden.FieldHidden
+		}
+		out.Fields = append(out.Fields, bitwarden.Field{Name: key, Value: bitwarden.String(item.Fields[key]), Type: fieldType})
+	}
+
+	return out
+}
+
+func writeKeePass(w io.Writer, items []*vault.Item) error {
+	now := keepass.FormatTime(time.Now())
+	root := keepass.Group{
+		UUID:  keepass.NewUUID(),
+		Name:  "openpasswd",
+		Times: keepass.Times{CreationTime: now, LastModificationTime: now},
+	}
+
+	for _, item := range items {
+		root.Entries = append(root.Entries, toKeePass(item))
+	}
+
+	doc := keepass.File{
+		Meta: keepass.Meta{
+			Generator:    "openpasswd " + version.Version,
+			DatabaseName: "openpasswd",
+		},
+		Root: keepass.Root{Groups: []keepass.Group{root}},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func toKeePass(item *vault.Item) keepass.Entry {
+	entry := keepass.Entry{
+		UUID: keepass.NewUUID(),
+		Times: keepass.Times{
+			CreationTime:         keepass.FormatTime(item.CreatedAt),
+			LastModificationTime: keepass.FormatTime(item.UpdatedAt),
+		},
+	}
+
+	add := func(key, value string, protect bool) {
+		entry.Strings = append(entry.Strings, keepass.String{
+			Key:   key,
+			Value: keepass.Value{Content: value, ProtectInMemory: protect},
+		})
+	}
+
+	password := item.Password
+	if password == "" {
+		password = item.Fields["value"]
+	}
+
+	add(keepass.KeyTitle, item.Name, false)
+	add(keepass.KeyUserName, item.Username, false)
+	add(keepass.KeyPassword, password, true)
+	add(keepass.KeyURL, item.URL, false)
+	add(keepass.KeyNotes, item.Notes, false)
+	if totp := item.Fields[models.FieldTOTP]; totp != "" {
+		add(keepass.KeyOTP, totp, true)
+	}
+
+	for _, key := range sortedKeys(item.Fields) {
+		if key == models.FieldTOTP || (key == "value" && item.Password == "") {
+			continue
+		}
+		add(key, item.Fields[key], isSensitive(key))
+	}
+
+	if item.Type != "" && item.Type != models.TypeLogin {
+		entry.Tags = string(item.Type)
+	}
+
+	return entry
+}
+
+// customFields returns the item's custom fields without the TOTP secret
+func customFields(item *vault.Item) map[string]string {
+	extra := make(map[string]string)
+	for key, val := range item.Fields {
+		if key != models.FieldTOTP {
+			extra[key] = val
+		}
+	}
+	return extra
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isSensitive reports whether a custom field should be hidden or protected
+func isSensitive(key string) bool {
+	switch strings.ToLower(key) {
+	case "cvv", "number", "pin", "value", "secret", "password", "totp", models.FieldTOTP:
+		return true
+	}
+	return false
+}
+
+// splitExpiry splits "MM/YY" or "MM/YYYY" into month and year
+func splitExpiry(expiry string) (string, string) {
+	month, year, ok := strings.Cut(strings.TrimSpace(expiry), "/")
+	if !ok {
+		return "", strings.TrimSpace(expiry)
+	}
+	year = strings.TrimSpace(year)
+	if len(year) == 2 {
+		year = "20" + year
+	}
+	return strings.TrimSpace(month), year
+}
+
+// splitName splits a full name at the last space into first and last name
+func splitName(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, " "); i > 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+func formatBitwardenTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
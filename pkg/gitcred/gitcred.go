@@ -0,0 +1,256 @@
+// Package gitcred implements git's credential helper protocol (see
+// gitcredentials(7) and git-credential(1)) on top of the vault's login
+// entries. Credentials are found by matching the protocol, host and path
+// git asks about against the URLs of stored entries; URLs stored without a
+// scheme are taken to be https.
+package gitcred
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// Operations git runs helpers with
+const (
+	OpGet   = "get"
+	OpStore = "store"
+	OpErase = "erase"
+)
+
+// maxInput bounds the attributes git sends on stdin
+const maxInput = 1 << 20
+
+// Credential holds the attributes of a helper request or answer
+type Credential struct {
+	Protocol string
+	Host     string // Including the port, if any
+	Path     string // Only sent when credential.useHttpPath is set
+	Username string
+	Password string
+}
+
+// Read parses key=value lines up to a blank line or the end of the input.
+// A url attribute is split into its parts; attributes the helper doesn't
+// use are ignored.
+func Read(r io.Reader) (*Credential, error) {
+	c := &Credential{}
+	scanner := bufio.NewScanner(io.LimitReader(r, maxInput))
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid credential line: %q", line)
+		}
+
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "url":
+			if err := c.setURL(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read credential: %w", err)
+	}
+
+	return c, nil
+}
+
+// setURL fills in the attributes a url attribute stands for
+func (c *Credential) setURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid credential url: %w", err)
+	}
+
+	c.Protocol = u.Scheme
+	c.Host = u.Host
+	c.Path = strings.TrimPrefix(u.Path, "/")
+	if u.User != nil {
+		c.Username = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			c.Password = password
+		}
+	}
+	return nil
+}
+
+// Write sends the username and password back to git
+func (c *Credential) Write(w io.Writer) error {
+	for _, attr := range [][2]string{{"username", c.Username}, {"password", c.Password}} {
+		if attr[1] == "" {
+			continue
+		}
+		if strings.ContainsAny(attr[1], "\n\x00") {
+			return fmt.Errorf("%s contains a newline or NUL byte", attr[0])
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", attr[0], attr[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// URL returns the credential's location as stored in new entries
+func (c *Credential) URL() string {
+	u := c.Protocol + "://" + c.Host
+	if path := strings.Trim(c.Path, "/"); path != "" {
+		u += "/" + path
+	}
+	return u
+}
+
+// Item returns a new login entry for the credential
+func (c *Credential) Item() *vault.Item {
+	name := c.Host
+	if path := trimPath(c.Path); path != "" {
+		name += "/" + path
+	}
+
+	return &vault.Item{
+		Type:     models.TypeLogin,
+		Name:     name,
+		Username: c.Username,
+		Password: c.Password,
+		URL:      c.URL(),
+		Fields:   make(map[string]string),
+	}
+}
+
+// Match returns the login entries whose URL covers the credential, best
+// match first: the longest matching path, then an explicit scheme. Entries
+// with another username are skipped when git names one.
+func Match(items []*vault.Item, c *Credential) []*vault.Item {
+	type scored struct {
+		item  *vault.Item
+		score int
+	}
+
+	var matches []scored
+	for _, item := range items {
+		if score, ok := matchScore(item, c); ok {
+			matches = append(matches, scored{item, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	out := make([]*vault.Item, len(matches))
+	for i, m := range matches {
+		out[i] = m.item
+	}
+	return out
+}
+
+// Exact returns the login entries stored for exactly this protocol, host,
+// path and username, the ones store updates and erase removes
+func Exact(items []*vault.Item, c *Credential) []*vault.Item {
+	var out []*vault.Item
+	for _, item := range Match(items, c) {
+		scheme, _, path, _ := parseEntryURL(item.URL)
+		if item.Username == c.Username && strings.EqualFold(scheme, c.Protocol) && path == trimPath(c.Path) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// matchScore reports whether an entry covers the credential and how closely
+func matchScore(item *vault.Item, c *Credential) (int, bool) {
+	if item.Type != models.TypeLogin || item.URL == "" || c.Host == "" {
+		return 0, false
+	}
+	if c.Username != "" && item.Username != c.Username {
+		return 0, false
+	}
+
+	scheme, host, path, ok := parseEntryURL(item.URL)
+	if !ok {
+		return 0, false
+	}
+
+	// Entries saved without a scheme, like most website logins, only match
+	// https so their password is never sent over plain http
+	score := 0
+	if scheme == "" {
+		scheme = "https"
+	} else {
+		score++
+	}
+	if !strings.EqualFold(scheme, c.Protocol) {
+		return 0, false
+	}
+
+	if host != normalizeHost(c.Protocol, c.Host) {
+		return 0, false
+	}
+
+	// Without useHttpPath git sends no path, and any entry for the host fits
+	want := trimPath(c.Path)
+	if path != "" && want != "" {
+		if want != path && !strings.HasPrefix(want, path+"/") {
+			return 0, false
+		}
+		score += 2 * (strings.Count(path, "/") + 1)
+	}
+
+	return score, true
+}
+
+// parseEntryURL splits a stored URL into its scheme, normalized host and
+// path. The scheme is empty for URLs without one (e.g. "github.com/org").
+func parseEntryURL(raw string) (scheme, host, path string, ok bool) {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, "://") {
+		scheme, _, _ = strings.Cut(raw, "://")
+	} else {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", "", "", false
+	}
+	return strings.ToLower(scheme), normalizeHost(u.Scheme, u.Host), trimPath(u.Path), true
+}
+
+// normalizeHost lowercases a host and drops the protocol's default port
+func normalizeHost(protocol, host string) string {
+	host = strings.ToLower(host)
+	switch strings.ToLower(protocol) {
+	case "https":
+		host = strings.TrimSuffix(host, ":443")
+	case "http":
+		host = strings.TrimSuffix(host, ":80")
+	}
+	return host
+}
+
+// trimPath drops the slashes around a repository path and a ".git" suffix
+func trimPath(path string) string {
+	return strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+}
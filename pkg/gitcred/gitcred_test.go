@@ -0,0 +1,67 @@
+package gitcred
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+func login(name, url, username string) *vault.Item {
+	return &vault.Item{Type: models.TypeLogin, Name: name, URL: url, Username: username, Password: "pw-" + name}
+}
+
+func TestMatch(t *testing.T) {
+	items := []*vault.Item{
+		login("bare", "github.com", "alice"),
+		login("https", "https://github.com", "alice"),
+		login("org", "https://github.com/acme", "alice"),
+		login("plain", "http://intranet.example", "bob"),
+		login("bare-intranet", "intranet.example", "bob"),
+		login("port", "https://git.example:443/", "carol"),
+	}
+
+	tests := []struct {
+		name string
+		cred Credential
+		want []string
+	}{
+		{"https host", Credential{Protocol: "https", Host: "github.com"}, []string{"https", "org", "bare"}},
+		{"longest path first", Credential{Protocol: "https", Host: "github.com", Path: "acme/repo.git"}, []string{"org", "https", "bare"}},
+		{"other path", Credential{Protocol: "https", Host: "github.com", Path: "other/repo"}, []string{"https", "bare"}},
+		{"username", Credential{Protocol: "https", Host: "github.com", Username: "bob"}, nil},
+
+		// Entries without a scheme never answer for http
+		{"http host", Credential{Protocol: "http", Host: "github.com"}, nil},
+		{"http entry", Credential{Protocol: "http", Host: "intranet.example"}, []string{"plain"}},
+		{"https intranet", Credential{Protocol: "https", Host: "intranet.example"}, []string{"bare-intranet"}},
+
+		{"default port", Credential{Protocol: "https", Host: "git.example"}, []string{"port"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, item := range Match(items, &tt.cred) {
+				got = append(got, item.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadURL(t *testing.T) {
+	c, err := Read(strings.NewReader("url=https://alice@github.com/acme/repo.git\npassword=s3cret\n\nignored=1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Protocol != "https" || c.Host != "github.com" || c.Path != "acme/repo.git" || c.Username != "alice" || c.Password != "s3cret" {
+		t.Errorf("credential = %+v", c)
+	}
+	if got := c.URL(); got != "https://github.com/acme/repo.git" {
+		t.Errorf("URL = %q", got)
+	}
+}
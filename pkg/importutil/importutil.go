@@ -0,0 +1,38 @@
+package importutil
+
+// Package importutil holds the helpers the importers share when they map
+// entries of other password managers onto models.Password.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UniqueKey returns name, or name with a numeric suffix if it's already a
+// key of fields, so custom fields with the same name don't overwrite each
+// other. An empty name becomes "field".
+func UniqueKey(fields map[string]string, name string) string {
+	if name == "" {
+		name = "field"
+	}
+	if _, taken := fields[name]; !taken {
+		return name
+	}
+	for i := 2; ; i++ {
+		key := fmt.Sprintf("%s (%d)", name, i)
+		if _, taken := fields[key]; !taken {
+			return key
+		}
+	}
+}
+
+// JoinNonEmpty trims the values and joins the ones that aren't empty
+func JoinNonEmpty(sep string, values ...string) string {
+	var parts []string
+	for _, v := range values {
+		if s := strings.TrimSpace(v); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,23 @@
+package importutil
+
+import "testing"
+
+func TestUniqueKey(t *testing.T) {
+	fields := map[string]string{"Email": "a", "Email (2)": "b", "field": "c"}
+
+	for name, want := range map[string]string{
+		"Phone": "Phone",
+		"Email": "Email (3)",
+		"":      "field (2)",
+	} {
+		if got := UniqueKey(fields, name); got != want {
+			t.Errorf("UniqueKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestJoinNonEmpty(t *testing.T) {
+	if got := JoinNonEmpty(", ", " 1 Main Street ", "", "  ", "Springfield"); got != "1 Main Street, Springfield" {
+		t.Errorf("JoinNonEmpty = %q", got)
+	}
+}
@@ -0,0 +1,199 @@
+package keepass
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/importutil"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// maxAttachmentSize is the largest attachment copied into a custom field
+const maxAttachmentSize = 1 << 20
+
+// emptyUUID is the RecycleBinUUID of databases without a recycle bin
+const emptyUUID = "AAAAAAAAAAAAAAAAAAAAAA=="
+
+// Importer handles imports from KeePass 2.x databases (KDBX 3.1 and 4) and
+// KeePass XML exports
+type Importer struct {
+	// KeyFile is the key file protecting the database, if any
+	KeyFile string
+}
+
+func (k *Importer) GetName() string {
+	return "KeePass"
+}
+
+func (k *Importer) GetDescription() string {
+	return "Import passwords from KeePass or KeePassXC (.kdbx database or XML export)"
+}
+
+func (k *Importer) SupportsFormat(format string) bool {
+	format = strings.ToLower(format)
+	return format == ".kdbx" || format == ".xml"
+}
+
+// NeedsPassphrase reports whether the file is an encrypted database
+func (k *Importer) NeedsPassphrase(filePath string) bool {
+	return strings.ToLower(filepath.Ext(filePath)) == ".kdbx"
+}
+
+// SetKeyFile sets the key file used to open the next database
+func (k *Importer) SetKeyFile(path string) {
+	k.KeyFile = path
+}
+
+func (k *Importer) Import(filePath string, passphrase string) ([]*models.Password, error) {
+	var db *Database
+	var err error
+
+	switch ext := strings.ToLower(filepath.Ext(filePath)); ext {
+	case ".kdbx":
+		if db, err = OpenFile(filePath, passphrase, k.KeyFile); err != nil {
+			return nil, err
+		}
+	case ".xml":
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read XML file: %w", err)
+		}
+		file, err := decodeXML(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		if db, err = newXMLDatabase(file); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file format: %s", ext)
+	}
+
+	var passwords []*models.Password
+	for _, root := range db.Root.Groups {
+		// The root group is named after the database, so it isn't part of folder paths
+		k.convertGroup(db, root, "", &passwords)
+	}
+
+	return passwords, nil
+}
+
+func (k *Importer) convertGroup(db *Database, group Group, folder string, passwords *[]*models.Password) {
+	if recycleBin := db.Meta.RecycleBinUUID; recycleBin != "" && recycleBin != emptyUUID && group.UUID == recycleBin {
+		return
+	}
+
+	for _, entry := range group.Entries {
+		*passwords = append(*passwords, k.convertEntry(db, entry, folder))
+	}
+
+	for _, sub := range group.Groups {
+		path := sub.Name
+		if folder != "" {
+			path = folder + "/" + sub.Name
+		}
+		k.convertGroup(db, sub, path, passwords)
+	}
+}
+
+func (k *Importer) convertEntry(db *Database, entry Entry, folder string) *models.Password {
+	pwd := &models.Password{
+		Name:      entry.Get(KeyTitle),
+		Username:  entry.Get(KeyUserName),
+		Password:  entry.Get(KeyPassword),
+		URL:       entry.Get(KeyURL),
+		Notes:     entry.Get(KeyNotes),
+		Fields:    make(map[string]string),
+		CreatedAt: ParseTime(entry.Times.CreationTime),
+		UpdatedAt: ParseTime(entry.Times.LastModificationTime),
+	}
+
+	if folder != "" {
+		pwd.Fields[models.FieldFolder] = folder
+	}
+
+	for _, s := range entry.Strings {
+		value := s.Value.Content
+		switch s.Key {
+		case KeyTitle, KeyUserName, KeyPassword, KeyURL, KeyNotes:
+			continue
+		case KeyOTP:
+			pwd.Fields[models.FieldTOTP] = value
+			continue
+		case "TimeOtp-Secret-Base32", "TOTP Seed":
+			// KeePass 2.47+ and legacy KeePassXC TOTP secrets; "otp" wins if both exist
+			if _, ok := pwd.Fields[models.FieldTOTP]; !ok && value != "" {
+				pwd.Fields[models.FieldTOTP] = value
+			}
+			continue
+		}
+		if value != "" {
+			pwd.Fields[importutil.UniqueKey(pwd.Fields, s.Key)] = value
+		}
+	}
+
+	if history := passwordHistory(entry); history != "" {
+		pwd.Fields["password_history"] = history
+	}
+
+	// Attachments are kept base64-encoded under "attachment:<file name>"
+	for _, ref := range entry.Binaries {
+		content, ok := db.Binaries[ref.Value.Ref]
+		if !ok || len(content) > maxAttachmentSize {
+			continue
+		}
+		pwd.Fields[importutil.UniqueKey(pwd.Fields, "attachment:"+ref.Key)] = base64.StdEncoding.EncodeToString(content)
+	}
+
+	pwd.Type = entryType(entry, pwd)
+
+	return pwd
+}
+
+// entryType picks the entry type. Tags written by 'openpass export' carry
+// the original type; otherwise entries with only notes become notes.
+func entryType(entry Entry, pwd *models.Password) models.PasswordType {
+	for _, tag := range strings.FieldsFunc(entry.Tags, func(r rune) bool { return r == ',' || r == ';' }) {
+		switch t := models.PasswordType(strings.TrimSpace(tag)); t {
+		case models.TypeCard, models.TypeNote, models.TypeIdentity, models.TypeOther, models.TypePassword, models.TypeSSHKey:
+			return t
+		}
+	}
+
+	if pwd.Username == "" && pwd.Password == "" && pwd.URL == "" && pwd.Notes != "" {
+		return models.TypeNote
+	}
+	return models.TypeLogin
+}
+
+// passwordHistory lists previous passwords from the entry history, newest
+// first, one "<date>  <password>" line each
+func passwordHistory(entry Entry) string {
+	if entry.History == nil {
+		return ""
+	}
+
+	current := entry.Get(KeyPassword)
+	seen := map[string]bool{current: true}
+
+	var lines []string
+	for i := len(entry.History.Entries) - 1; i >= 0; i-- {
+		old := entry.History.Entries[i]
+		password := old.Get(KeyPassword)
+		if password == "" || seen[password] {
+			continue
+		}
+		seen[password] = true
+
+		date := "unknown"
+		if t := ParseTime(old.Times.LastModificationTime); !t.IsZero() {
+			date = t.Format("2006-01-02 15:04")
+		}
+		lines = append(lines, date+"  "+password)
+	}
+
+	return strings.Join(lines, "\n")
+}
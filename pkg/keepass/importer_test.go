@@ -0,0 +1,169 @@
+package keepass
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// The fixtures hold the same entries in each format and open with this
+// password; fixture.keyx is the key file of the last one. They were written
+// with golang.org/x/crypto's AES, Salsa20, ChaCha20 and Argon2id, and this
+// repo's Argon2d.
+const testPassword = "kdbx fixture"
+
+var fixtures = []struct {
+	name    string
+	keyFile string
+}{
+	{"kdbx3-aes-salsa20.kdbx", ""},                // KDBX 3.1, AES-KDF, AES-256, Salsa20 inner stream, gzip
+	{"kdbx4-argon2d-aes.kdbx", ""},                // KDBX 4, Argon2d, AES-256, ChaCha20 inner stream, gzip
+	{"kdbx4-argon2id-chacha20.kdbx", ""},          // KDBX 4, Argon2id, ChaCha20, ChaCha20 inner stream
+	{"kdbx4-aeskdf-keyfile.kdbx", "fixture.keyx"}, // KDBX 4, AES-KDF, password and key file
+}
+
+func importFixture(t *testing.T, name, password, keyFile string) ([]*models.Password, error) {
+	t.Helper()
+
+	importer := &Importer{}
+	if keyFile != "" {
+		importer.SetKeyFile(filepath.Join("testdata", keyFile))
+	}
+	return importer.Import(filepath.Join("testdata", name), password)
+}
+
+func TestImportKDBX(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.name, func(t *testing.T) {
+			passwords, err := importFixture(t, f.name, testPassword, f.keyFile)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+
+			// The recycle bin is left out
+			if len(passwords) != 2 {
+				t.Fatalf("got %d entries, want 2", len(passwords))
+			}
+			login, note := passwords[0], passwords[1]
+
+			if login.Type != models.TypeLogin || login.Name != "GitHub" || login.Username != "alice" ||
+				login.Password != "correct horse battery staple" || login.URL != "https://github.com/login" ||
+				login.Notes != "Work account" {
+				t.Errorf("login = %+v", login)
+			}
+			if want := time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC); !login.CreatedAt.Equal(want) {
+				t.Errorf("created = %v, want %v", login.CreatedAt, want)
+			}
+			if want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC); !login.UpdatedAt.Equal(want) {
+				t.Errorf("updated = %v, want %v", login.UpdatedAt, want)
+			}
+
+			// Protected values, including the ones in the history, decrypt
+			// in document order
+			wantFields := map[string]string{
+				models.FieldTOTP: "otpauth://totp/GitHub:alice?secret=JBSWY3DPEHPK3PXP&issuer=GitHub",
+				"Recovery code":  "abcd-efgh-ijkl",
+				"password_history": "2023-09-01 08:00  second password\n" +
+					"2023-05-01 08:00  first password",
+			}
+			if !reflect.DeepEqual(login.Fields, wantFields) {
+				t.Errorf("login fields = %v, want %v", login.Fields, wantFields)
+			}
+
+			if note.Type != models.TypeNote || note.Name != "Wifi" || note.Notes != "SSID: office" {
+				t.Errorf("note = %+v", note)
+			}
+			wantFields = map[string]string{
+				models.FieldFolder:     "Work",
+				"attachment:hello.txt": base64.StdEncoding.EncodeToString([]byte("hello from an attachment\n")),
+			}
+			if !reflect.DeepEqual(note.Fields, wantFields) {
+				t.Errorf("note fields = %v, want %v", note.Fields, wantFields)
+			}
+		})
+	}
+}
+
+func TestImportKDBXWrongCredentials(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.name, func(t *testing.T) {
+			_, err := importFixture(t, f.name, "wrong password", f.keyFile)
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("wrong password: err = %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+
+	// The right password alone doesn't open a database that needs a key file
+	_, err := importFixture(t, "kdbx4-aeskdf-keyfile.kdbx", testPassword, "")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("missing key file: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestOpenCorrupted(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "kdbx4-argon2d-aes.kdbx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(data[:8], testPassword, nil); !errors.Is(err, ErrNotKDBX) {
+		t.Errorf("truncated signature: err = %v, want ErrNotKDBX", err)
+	}
+
+	// A flipped payload byte fails the block HMAC, not the password check
+	data[len(data)-60] ^= 0x01
+	if _, err := Open(data, testPassword, nil); err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("modified payload: err = %v", err)
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	raw := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name    string
+		content string
+		want    []byte
+	}{
+		{"raw", string(raw), raw},
+		{"hex", "3031323334353637383961626364656630313233343536373839616263646566", raw},
+		{"xml v1", `<KeyFile><Meta><Version>1.00</Version></Meta><Key><Data>` +
+			base64.StdEncoding.EncodeToString(raw) + `</Data></Key></KeyFile>`, raw},
+		{"other", "any other file", sha256Sum([]byte("any other file"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadKeyFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("key = %x, want %x", got, tt.want)
+			}
+		})
+	}
+
+	// A version 2.0 key file with a wrong checksum is rejected
+	path := filepath.Join(dir, "bad.keyx")
+	bad := `<KeyFile><Meta><Version>2.0</Version></Meta><Key><Data Hash="00000000">` +
+		"30313233343536373839616263646566 30313233343536373839616263646566</Data></Key></KeyFile>"
+	if err := os.WriteFile(path, []byte(bad), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKeyFile(path); err == nil {
+		t.Error("bad checksum: no error")
+	}
+}
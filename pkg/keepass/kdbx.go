@@ -0,0 +1,731 @@
+package keepass
+
+// kdbx.go decrypts KDBX 3.1 and KDBX 4.x database files.
+//
+// A KDBX file is an unencrypted header (cipher, KDF parameters, seeds)
+// followed by the encrypted, optionally gzipped XML document. KDBX 3.1
+// splits the payload into SHA-256 hashed blocks after decryption; KDBX 4
+// authenticates the header and every block with HMAC-SHA256 before
+// decryption and adds an inner header with the attachments. Values marked
+// Protected="True" in the XML are additionally XORed with a Salsa20 or
+// ChaCha20 keystream in document order.
+// See https://keepass.info/help/kb/kdbx_4.html for the format.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+)
+
+const (
+	signature1 = 0x9AA2D903
+	signature2 = 0xB54BFB67 // KeePass 2.x (KeePass 1.x .kdb files use 0xB54BFB65)
+)
+
+// Outer header field IDs
+const (
+	fieldEndOfHeader        = 0
+	fieldCipherID           = 2
+	fieldCompressionFlags   = 3
+	fieldMasterSeed         = 4
+	fieldTransformSeed      = 5 // KDBX 3.1
+	fieldTransformRounds    = 6 // KDBX 3.1
+	fieldEncryptionIV       = 7
+	fieldProtectedStreamKey = 8 // KDBX 3.1
+	fieldStreamStartBytes   = 9 // KDBX 3.1
+	fieldInnerRandomStream  = 10
+	fieldKDFParameters      = 11 // KDBX 4
+)
+
+// Inner header field IDs (KDBX 4)
+const (
+	innerEndOfHeader    = 0
+	innerRandomStreamID = 1
+	innerRandomStreamKy = 2
+	innerBinary         = 3
+)
+
+// Inner random stream algorithms protecting values in the XML
+const (
+	streamNone     = 0
+	streamSalsa20  = 2
+	streamChaCha20 = 3
+)
+
+var (
+	cipherAES256   = mustUUID("31c1f2e6bf714350be5805216afc5aff")
+	cipherChaCha20 = mustUUID("d6038a2b8b6f4cb5a524339a31dbb59a")
+	cipherTwofish  = mustUUID("ad68f29f576f4bb9a36ad47af965346c")
+
+	kdfAES      = mustUUID("c9d9f39a628a4460bf740d08c18a4fea")
+	kdfAES3     = mustUUID("7c02bb8279a74ac0927d114a00648238") // KeePassXC's ID for KDBX 3.1 style AES-KDF
+	kdfArgon2d  = mustUUID("ef636ddf8c29444b91f7a9a403e30a0c")
+	kdfArgon2id = mustUUID("9e298b1956db4773b23dfc3ec6f0a1e6")
+
+	// Fixed Salsa20 nonce of the KDBX 3.1 inner stream
+	salsaNonce = []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}
+)
+
+var (
+	// ErrNotKDBX is returned for files that aren't KeePass 2.x databases
+	ErrNotKDBX = errors.New("not a KeePass 2.x (.kdbx) database")
+
+	// ErrInvalidCredentials is returned when the password or key file is wrong
+	ErrInvalidCredentials = errors.New("wrong password or key file")
+)
+
+// Database is a decrypted KDBX database
+type Database struct {
+	File
+
+	// Binaries holds attachment contents by the Ref of a BinaryRef
+	Binaries map[string][]byte
+}
+
+// header is the parsed outer header
+type header struct {
+	major      uint16
+	cipherID   []byte
+	compressed bool
+	masterSeed []byte
+	iv         []byte
+	streamID   uint32
+	streamKey  []byte
+	startBytes []byte
+	kdf        variantDict
+	aesSeed    []byte // KDBX 3.1 transform seed
+	aesRounds  uint64 // KDBX 3.1 transform rounds
+	length     int    // Size of the header in bytes
+}
+
+// OpenFile reads and decrypts a KDBX file. keyFile may be empty.
+func OpenFile(path, password, keyFile string) (*Database, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+
+	var keyData []byte
+	if keyFile != "" {
+		if keyData, err = loadKeyFile(keyFile); err != nil {
+			return nil, err
+		}
+		defer crypto.WipeMemory(keyData)
+	}
+
+	return Open(data, password, keyData)
+}
+
+// Open decrypts a KDBX database. keyData is the key derived from a key file
+// (see loadKeyFile), or nil when the database has no key file.
+func Open(data []byte, password string, keyData []byte) (*Database, error) {
+	h, err := readHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	composite := compositeKey(password, keyData)
+	defer crypto.WipeMemory(composite)
+
+	transformed, err := h.transformKey(composite)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.WipeMemory(transformed)
+
+	if h.major >= 4 {
+		return openKDBX4(data, h, transformed)
+	}
+	return openKDBX3(data, h, transformed)
+}
+
+func openKDBX3(data []byte, h *header, transformed []byte) (*Database, error) {
+	masterKey := sha256Sum(h.masterSeed, transformed)
+	defer crypto.WipeMemory(masterKey)
+
+	plaintext, err := decryptPayload(h, masterKey, data[h.length:])
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.WipeMemory(plaintext)
+
+	// The first bytes of the payload repeat a header field, so a mismatch
+	// means the key is wrong
+	if len(plaintext) < len(h.startBytes) || !bytes.Equal(plaintext[:len(h.startBytes)], h.startBytes) {
+		return nil, ErrInvalidCredentials
+	}
+
+	payload, err := readHashedBlocks(plaintext[len(h.startBytes):])
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.WipeMemory(payload)
+
+	if h.compressed {
+		if payload, err = gunzip(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	stream, err := newInnerStream(h.streamID, h.streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := decodeXML(payload, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	return newXMLDatabase(file)
+}
+
+// newXMLDatabase wraps a document whose attachments live in the Meta
+// section (KDBX 3.1 and KeePass XML exports)
+func newXMLDatabase(file *File) (*Database, error) {
+	db := &Database{File: *file, Binaries: make(map[string][]byte)}
+	if file.Meta.Binaries == nil {
+		return db, nil
+	}
+
+	for _, b := range file.Meta.Binaries.Binaries {
+		content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b.Content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid attachment %s: %w", b.ID, err)
+		}
+		if b.Compressed {
+			if content, err = gunzip(content); err != nil {
+				return nil, err
+			}
+		}
+		db.Binaries[b.ID] = content
+	}
+
+	return db, nil
+}
+
+func openKDBX4(data []byte, h *header, transformed []byte) (*Database, error) {
+	rest := data[h.length:]
+	if len(rest) < 64 {
+		return nil, errors.New("database is truncated")
+	}
+
+	headerHash := sha256.Sum256(data[:h.length])
+	if !bytes.Equal(headerHash[:], rest[:32]) {
+		return nil, errors.New("database header is corrupted")
+	}
+
+	hmacBase := sha512Sum(h.masterSeed, transformed, []byte{0x01})
+	defer crypto.WipeMemory(hmacBase)
+
+	// A valid header HMAC proves the key is right
+	mac := hmac.New(sha256.New, blockHMACKey(^uint64(0), hmacBase))
+	mac.Write(data[:h.length])
+	if !hmac.Equal(mac.Sum(nil), rest[32:64]) {
+		return nil, ErrInvalidCredentials
+	}
+
+	ciphertext, err := readHMACBlocks(rest[64:], hmacBase)
+	if err != nil {
+		return nil, err
+	}
+
+	masterKey := sha256Sum(h.masterSeed, transformed)
+	defer crypto.WipeMemory(masterKey)
+
+	payload, err := decryptPayload(h, masterKey, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.WipeMemory(payload)
+
+	if h.compressed {
+		if payload, err = gunzip(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	db := &Database{Binaries: make(map[string][]byte)}
+
+	// Inner header: protected stream parameters and attachments
+	var streamID uint32
+	var streamKey []byte
+	for {
+		if len(payload) < 5 {
+			return nil, errors.New("inner header is truncated")
+		}
+		id := payload[0]
+		size := int(binary.LittleEndian.Uint32(payload[1:5]))
+		if size < 0 || len(payload) < 5+size {
+			return nil, errors.New("inner header is truncated")
+		}
+		value := payload[5 : 5+size]
+		payload = payload[5+size:]
+
+		if id == innerEndOfHeader {
+			break
+		}
+
+		switch id {
+		case innerRandomStreamID:
+			if len(value) != 4 {
+				return nil, errors.New("invalid inner stream ID")
+			}
+			streamID = binary.LittleEndian.Uint32(value)
+		case innerRandomStreamKy:
+			streamKey = value
+		case innerBinary:
+			if len(value) < 1 {
+				return nil, errors.New("invalid attachment in inner header")
+			}
+			// The first byte holds flags (0x01 = protect in memory)
+			db.Binaries[strconv.Itoa(len(db.Binaries))] = bytes.Clone(value[1:])
+		}
+	}
+
+	stream, err := newInnerStream(streamID, streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := decodeXML(payload, stream)
+	if err != nil {
+		return nil, err
+	}
+	db.File = *file
+
+	return db, nil
+}
+
+// readHeader parses the signature, version and outer header fields
+func readHeader(data []byte) (*header, error) {
+	if len(data) < 12 ||
+		binary.LittleEndian.Uint32(data[0:4]) != signature1 ||
+		binary.LittleEndian.Uint32(data[4:8]) != signature2 {
+		return nil, ErrNotKDBX
+	}
+
+	h := &header{major: binary.LittleEndian.Uint16(data[10:12])}
+	if h.major != 3 && h.major != 4 {
+		return nil, fmt.Errorf("unsupported KDBX version %d", h.major)
+	}
+
+	pos := 12
+	for {
+		// KDBX 3.1 uses 16-bit field sizes, KDBX 4 32-bit ones
+		sizeLen := 2
+		if h.major >= 4 {
+			sizeLen = 4
+		}
+		if len(data) < pos+1+sizeLen {
+			return nil, errors.New("database header is truncated")
+		}
+
+		id := data[pos]
+		var size int
+		if sizeLen == 2 {
+			size = int(binary.LittleEndian.Uint16(data[pos+1:]))
+		} else {
+			size = int(binary.LittleEndian.Uint32(data[pos+1:]))
+		}
+		pos += 1 + sizeLen
+		if size < 0 || len(data) < pos+size {
+			return nil, errors.New("database header is truncated")
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		switch id {
+		case fieldEndOfHeader:
+			h.length = pos
+			return h, h.validate()
+		case fieldCipherID:
+			h.cipherID = value
+		case fieldCompressionFlags:
+			if len(value) != 4 {
+				return nil, errors.New("invalid compression flags")
+			}
+			h.compressed = binary.LittleEndian.Uint32(value) == 1
+		case fieldMasterSeed:
+			h.masterSeed = value
+		case fieldTransformSeed:
+			h.aesSeed = value
+		case fieldTransformRounds:
+			if len(value) != 8 {
+				return nil, errors.New("invalid transform rounds")
+			}
+			h.aesRounds = binary.LittleEndian.Uint64(value)
+		case fieldEncryptionIV:
+			h.iv = value
+		case fieldProtectedStreamKey:
+			h.streamKey = value
+		case fieldStreamStartBytes:
+			h.startBytes = value
+		case fieldInnerRandomStream:
+			if len(value) != 4 {
+				return nil, errors.New("invalid inner stream ID")
+			}
+			h.streamID = binary.LittleEndian.Uint32(value)
+		case fieldKDFParameters:
+			dict, err := readVariantDict(value)
+			if err != nil {
+				return nil, err
+			}
+			h.kdf = dict
+		}
+	}
+}
+
+func (h *header) validate() error {
+	if len(h.masterSeed) != 32 {
+		return errors.New("invalid master seed")
+	}
+	if h.major < 4 {
+		if len(h.aesSeed) != 32 {
+			return errors.New("invalid transform seed")
+		}
+		if len(h.startBytes) == 0 {
+			return errors.New("missing stream start bytes")
+		}
+	} else if h.kdf == nil {
+		return errors.New("missing KDF parameters")
+	}
+	return nil
+}
+
+// transformKey runs the composite key through the database's KDF
+func (h *header) transformKey(composite []byte) ([]byte, error) {
+	if h.major < 4 {
+		return aesKDF(composite, h.aesSeed, h.aesRounds)
+	}
+
+	uuid, ok := h.kdf.bytes("$UUID")
+	if !ok {
+		return nil, errors.New("missing KDF identifier")
+	}
+
+	switch {
+	case bytes.Equal(uuid, kdfAES), bytes.Equal(uuid, kdfAES3):
+		seed, _ := h.kdf.bytes("S")
+		rounds, _ := h.kdf.uint("R")
+		return aesKDF(composite, seed, rounds)
+
+	case bytes.Equal(uuid, kdfArgon2d), bytes.Equal(uuid, kdfArgon2id):
+		salt, _ := h.kdf.bytes("S")
+		parallelism, _ := h.kdf.uint("P")
+		memory, _ := h.kdf.uint("M")
+		iterations, _ := h.kdf.uint("I")
+		version, _ := h.kdf.uint("V")
+		secret, _ := h.kdf.bytes("K")
+		data, _ := h.kdf.bytes("A")
+
+		if version != 0x13 {
+			return nil, fmt.Errorf("unsupported Argon2 version 0x%x", version)
+		}
+		if parallelism == 0 || parallelism > 255 || iterations == 0 || iterations > 1<<32-1 || memory/1024 > 1<<32-1 {
+			return nil, errors.New("invalid Argon2 parameters")
+		}
+
+		params := crypto.Argon2Params{
+			Time:        uint32(iterations),
+			Memory:      uint32(memory / 1024), // Stored in bytes
+			Parallelism: uint8(parallelism),
+			KeyLen:      32,
+		}
+		isID := bytes.Equal(uuid, kdfArgon2id)
+		switch {
+		case len(secret) > 0 || len(data) > 0:
+			return crypto.Argon2KeyWithSecret(isID, composite, salt, secret, data, params), nil
+		case isID:
+			return crypto.Argon2idKey(composite, salt, params), nil
+		default:
+			return crypto.Argon2dKey(composite, salt, params), nil
+		}
+
+	default:
+		return nil, errors.New("unsupported key derivation function")
+	}
+}
+
+// aesKDF encrypts the key rounds times with AES-256-ECB, then hashes it
+func aesKDF(composite, seed []byte, rounds uint64) ([]byte, error) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AES-KDF seed: %w", err)
+	}
+
+	key := bytes.Clone(composite)
+	defer crypto.WipeMemory(key)
+	for i := uint64(0); i < rounds; i++ {
+		block.Encrypt(key[0:16], key[0:16])
+		block.Encrypt(key[16:32], key[16:32])
+	}
+
+	sum := sha256.Sum256(key)
+	return sum[:], nil
+}
+
+// compositeKey combines the password and key file into the raw database key
+func compositeKey(password string, keyData []byte) []byte {
+	h := sha256.New()
+	// A database protected only by a key file has no password component
+	if password != "" || keyData == nil {
+		pw := sha256.Sum256([]byte(password))
+		h.Write(pw[:])
+	}
+	if keyData != nil {
+		h.Write(keyData)
+	}
+	return h.Sum(nil)
+}
+
+// decryptPayload decrypts the database body with the outer cipher
+func decryptPayload(h *header, key, ciphertext []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(h.cipherID, cipherAES256):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(h.iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+			return nil, errors.New("database payload is corrupted")
+		}
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, h.iv).CryptBlocks(plaintext, ciphertext)
+		return unpad(plaintext)
+
+	case bytes.Equal(h.cipherID, cipherChaCha20):
+		stream, err := crypto.NewChaCha20(key, h.iv)
+		if err != nil {
+			return nil, err
+		}
+		plaintext := make([]byte, len(ciphertext))
+		stream.XORKeyStream(plaintext, ciphertext)
+		return plaintext, nil
+
+	case bytes.Equal(h.cipherID, cipherTwofish):
+		return nil, errors.New("Twofish-encrypted databases are not supported; change the encryption to AES or ChaCha20 in KeePass")
+
+	default:
+		return nil, errors.New("unsupported database cipher")
+	}
+}
+
+// unpad strips PKCS#7 padding; bad padding means the key was wrong
+func unpad(data []byte) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > aes.BlockSize || n > len(data) {
+		return nil, ErrInvalidCredentials
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, ErrInvalidCredentials
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+// readHashedBlocks reassembles the KDBX 3.1 hashed block stream
+func readHashedBlocks(data []byte) ([]byte, error) {
+	var out []byte
+	for {
+		if len(data) < 40 {
+			return nil, errors.New("database payload is truncated")
+		}
+		hash := data[4:36]
+		size := int(binary.LittleEndian.Uint32(data[36:40]))
+		data = data[40:]
+
+		if size == 0 {
+			return out, nil
+		}
+		if size < 0 || len(data) < size {
+			return nil, errors.New("database payload is truncated")
+		}
+
+		sum := sha256.Sum256(data[:size])
+		if !bytes.Equal(sum[:], hash) {
+			return nil, errors.New("database payload is corrupted")
+		}
+		out = append(out, data[:size]...)
+		data = data[size:]
+	}
+}
+
+// readHMACBlocks verifies and reassembles the KDBX 4 HMAC block stream
+func readHMACBlocks(data, hmacBase []byte) ([]byte, error) {
+	var out []byte
+	for index := uint64(0); ; index++ {
+		if len(data) < 36 {
+			return nil, errors.New("database payload is truncated")
+		}
+		blockMAC := data[:32]
+		sizeBytes := data[32:36]
+		size := int(binary.LittleEndian.Uint32(sizeBytes))
+		data = data[36:]
+		if size < 0 || len(data) < size {
+			return nil, errors.New("database payload is truncated")
+		}
+
+		var indexBytes [8]byte
+		binary.LittleEndian.PutUint64(indexBytes[:], index)
+
+		mac := hmac.New(sha256.New, blockHMACKey(index, hmacBase))
+		mac.Write(indexBytes[:])
+		mac.Write(sizeBytes)
+		mac.Write(data[:size])
+		if !hmac.Equal(mac.Sum(nil), blockMAC) {
+			return nil, fmt.Errorf("database payload is corrupted (block %d)", index)
+		}
+
+		if size == 0 {
+			return out, nil
+		}
+		out = append(out, data[:size]...)
+		data = data[size:]
+	}
+}
+
+// blockHMACKey derives the HMAC key of a KDBX 4 block; the header uses index 2^64-1
+func blockHMACKey(index uint64, hmacBase []byte) []byte {
+	var indexBytes [8]byte
+	binary.LittleEndian.PutUint64(indexBytes[:], index)
+	return sha512Sum(indexBytes[:], hmacBase)
+}
+
+// newInnerStream creates the keystream that protects values in the XML
+func newInnerStream(id uint32, key []byte) (cipher.Stream, error) {
+	switch id {
+	case streamNone:
+		return nil, nil
+	case streamSalsa20:
+		k := sha256.Sum256(key)
+		return crypto.NewSalsa20(k[:], salsaNonce)
+	case streamChaCha20:
+		k := sha512.Sum512(key)
+		return crypto.NewChaCha20(k[:32], k[32:44])
+	default:
+		return nil, fmt.Errorf("unsupported inner stream cipher %d", id)
+	}
+}
+
+// decodeXML parses the database XML, decrypting protected values in
+// document order as it goes
+func decodeXML(data []byte, stream cipher.Stream) (*File, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+
+	protected := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse database XML: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			protected = false
+			if t.Name.Local == "Value" {
+				attrs := t.Attr[:0:0]
+				for _, a := range t.Attr {
+					if a.Name.Local == "Protected" {
+						protected = strings.EqualFold(a.Value, "true")
+						continue
+					}
+					attrs = append(attrs, a)
+				}
+				t.Attr = attrs
+			}
+			tok = t
+
+		case xml.CharData:
+			if protected {
+				raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(t)))
+				if err != nil {
+					return nil, fmt.Errorf("invalid protected value: %w", err)
+				}
+				if stream != nil {
+					stream.XORKeyStream(raw, raw)
+				}
+				tok = xml.CharData(raw)
+			}
+
+		case xml.EndElement:
+			protected = false
+
+		case xml.ProcInst, xml.Directive, xml.Comment:
+			continue
+		}
+
+		if err := enc.EncodeToken(tok); err != nil {
+			return nil, fmt.Errorf("failed to parse database XML: %w", err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	var file File
+	if err := xml.Unmarshal(buf.Bytes(), &file); err != nil {
+		return nil, fmt.Errorf("failed to parse database XML: %w", err)
+	}
+	return &file, nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress database: %w", err)
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress database: %w", err)
+	}
+	return out, nil
+}
+
+func sha256Sum(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+func sha512Sum(parts ...[]byte) []byte {
+	h := sha512.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+func mustUUID(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 16 {
+		panic("keepass: invalid UUID " + s)
+	}
+	return b
+}
@@ -0,0 +1,85 @@
+package keepass
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keyFileXML is the XML key file format written by KeePass 2.x
+type keyFileXML struct {
+	XMLName xml.Name `xml:"KeyFile"`
+	Meta    struct {
+		Version string `xml:"Version"`
+	} `xml:"Meta"`
+	Key struct {
+		Data struct {
+			Hash    string `xml:"Hash,attr"`
+			Content string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"Key"`
+}
+
+// loadKeyFile reads a key file and returns the 32-byte key it contributes
+// to the composite key. KeePass accepts XML key files (versions 1.0 and
+// 2.0), raw 32-byte files, 64-character hex files, and any other file,
+// which is hashed with SHA-256.
+func loadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	if bytes.Contains(data, []byte("<KeyFile")) {
+		var kf keyFileXML
+		if err := xml.Unmarshal(data, &kf); err == nil {
+			return parseXMLKey(&kf)
+		}
+	}
+
+	switch len(data) {
+	case 32:
+		return data, nil
+	case 64:
+		if key, err := hex.DecodeString(string(data)); err == nil {
+			return key, nil
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+func parseXMLKey(kf *keyFileXML) ([]byte, error) {
+	content := strings.Join(strings.Fields(kf.Key.Data.Content), "")
+
+	if strings.HasPrefix(kf.Meta.Version, "2.") {
+		key, err := hex.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file data: %w", err)
+		}
+
+		// Version 2.0 stores the first 4 bytes of the SHA-256 as a checksum
+		if kf.Key.Data.Hash != "" {
+			expected, err := hex.DecodeString(kf.Key.Data.Hash)
+			sum := sha256.Sum256(key)
+			if err != nil || len(expected) != 4 || binary.BigEndian.Uint32(expected) != binary.BigEndian.Uint32(sum[:4]) {
+				return nil, errors.New("key file checksum mismatch")
+			}
+		}
+		return key, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file data: %w", err)
+	}
+	return key, nil
+}
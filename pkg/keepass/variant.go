@@ -0,0 +1,97 @@
+package keepass
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Value types of a KDBX 4 VariantDictionary
+const (
+	variantEnd       = 0x00
+	variantUInt32    = 0x04
+	variantUInt64    = 0x05
+	variantBool      = 0x08
+	variantInt32     = 0x0C
+	variantInt64     = 0x0D
+	variantString    = 0x18
+	variantByteArray = 0x42
+)
+
+// variantDict is a KDBX 4 VariantDictionary (used for the KDF parameters)
+type variantDict map[string]variant
+
+type variant struct {
+	kind  byte
+	value []byte
+}
+
+// readVariantDict parses a serialized VariantDictionary:
+// a uint16 version, then (type, int32 name length, name, int32 value length, value) entries
+func readVariantDict(data []byte) (variantDict, error) {
+	if len(data) < 2 {
+		return nil, errors.New("invalid KDF parameters")
+	}
+	if data[1] != 0x01 {
+		return nil, errors.New("unsupported KDF parameters version")
+	}
+	data = data[2:]
+
+	dict := make(variantDict)
+	for {
+		if len(data) < 1 {
+			return nil, errors.New("KDF parameters are truncated")
+		}
+		kind := data[0]
+		if kind == variantEnd {
+			return dict, nil
+		}
+
+		name, rest, ok := readSized(data[1:])
+		if !ok {
+			return nil, errors.New("KDF parameters are truncated")
+		}
+		value, rest, ok := readSized(rest)
+		if !ok {
+			return nil, errors.New("KDF parameters are truncated")
+		}
+
+		dict[string(name)] = variant{kind: kind, value: value}
+		data = rest
+	}
+}
+
+// readSized reads an int32 length-prefixed byte string
+func readSized(data []byte) ([]byte, []byte, bool) {
+	if len(data) < 4 {
+		return nil, nil, false
+	}
+	n := int(binary.LittleEndian.Uint32(data))
+	if n < 0 || len(data) < 4+n {
+		return nil, nil, false
+	}
+	return data[4 : 4+n], data[4+n:], true
+}
+
+// bytes returns a byte array value
+func (d variantDict) bytes(name string) ([]byte, bool) {
+	v, ok := d[name]
+	if !ok || v.kind != variantByteArray {
+		return nil, false
+	}
+	return v.value, true
+}
+
+// uint returns an unsigned integer value of either width
+func (d variantDict) uint(name string) (uint64, bool) {
+	v, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case v.kind == variantUInt32 && len(v.value) == 4:
+		return uint64(binary.LittleEndian.Uint32(v.value)), true
+	case v.kind == variantUInt64 && len(v.value) == 8:
+		return binary.LittleEndian.Uint64(v.value), true
+	}
+	return 0, false
+}
@@ -0,0 +1,152 @@
+package keepass
+
+// Package keepass reads and writes KeePass 2.x databases.
+//
+// xml.go holds the XML document found inside KDBX files, which KeePass
+// also imports and exports directly as "KeePass XML (2.x)".
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"time"
+)
+
+// Standard entry string keys
+const (
+	KeyTitle    = "Title"
+	KeyUserName = "UserName"
+	KeyPassword = "Password"
+	KeyURL      = "URL"
+	KeyNotes    = "Notes"
+	KeyOTP      = "otp" // KeePassXC's otpauth:// URI field
+)
+
+// TimeFormat is the timestamp layout of KeePass XML
+const TimeFormat = "2006-01-02T15:04:05Z"
+
+// File is the root element of a KeePass XML document
+type File struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    Meta     `xml:"Meta"`
+	Root    Root     `xml:"Root"`
+}
+
+type Meta struct {
+	Generator      string    `xml:"Generator"`
+	HeaderHash     string    `xml:"HeaderHash,omitempty"`
+	DatabaseName   string    `xml:"DatabaseName"`
+	Binaries       *Binaries `xml:"Binaries,omitempty"`
+	RecycleBinUUID string    `xml:"RecycleBinUUID,omitempty"`
+}
+
+type Binaries struct {
+	Binaries []Binary `xml:"Binary"`
+}
+
+// Binary is an attachment stored in the Meta section (KDBX 3.1)
+type Binary struct {
+	ID         string `xml:"ID,attr"`
+	Compressed bool   `xml:"Compressed,attr,omitempty"`
+	Content    string `xml:",chardata"`
+}
+
+type Root struct {
+	Groups []Group `xml:"Group"`
+}
+
+type Group struct {
+	UUID    string  `xml:"UUID"`
+	Name    string  `xml:"Name"`
+	Notes   string  `xml:"Notes,omitempty"`
+	Times   Times   `xml:"Times"`
+	Entries []Entry `xml:"Entry"`
+	Groups  []Group `xml:"Group"`
+}
+
+type Entry struct {
+	UUID     string      `xml:"UUID"`
+	Times    Times       `xml:"Times"`
+	Strings  []String    `xml:"String"`
+	Binaries []BinaryRef `xml:"Binary,omitempty"`
+	Tags     string      `xml:"Tags,omitempty"`
+	History  *History    `xml:"History,omitempty"`
+}
+
+type History struct {
+	Entries []Entry `xml:"Entry"`
+}
+
+type Times struct {
+	CreationTime         string `xml:"CreationTime,omitempty"`
+	LastModificationTime string `xml:"LastModificationTime,omitempty"`
+	LastAccessTime       string `xml:"LastAccessTime,omitempty"`
+}
+
+// String is a key/value pair of an entry
+type String struct {
+	Key   string `xml:"Key"`
+	Value Value  `xml:"Value"`
+}
+
+type Value struct {
+	Protected       bool   `xml:"Protected,attr,omitempty"`
+	ProtectInMemory bool   `xml:"ProtectInMemory,attr,omitempty"`
+	Content         string `xml:",chardata"`
+}
+
+// BinaryRef attaches a binary from the Meta section or inner header to an entry
+type BinaryRef struct {
+	Key   string `xml:"Key"`
+	Value struct {
+		Ref string `xml:"Ref,attr"`
+	} `xml:"Value"`
+}
+
+// Get returns the value of a string field
+func (e *Entry) Get(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return s.Value.Content
+		}
+	}
+	return ""
+}
+
+// NewUUID returns a random base64-encoded entry or group UUID
+func NewUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// FormatTime formats a timestamp for KeePass XML
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(TimeFormat)
+}
+
+// ParseTime parses a KeePass XML timestamp. KDBX 4 stores base64-encoded
+// seconds since year 1 instead of text.
+func ParseTime(s string) time.Time {
+	if t, err := time.Parse(TimeFormat, s); err == nil {
+		return t
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(raw) != 8 {
+		return time.Time{}
+	}
+	var secs int64
+	for i := 7; i >= 0; i-- {
+		secs = secs<<8 | int64(raw[i])
+	}
+	// Seconds between 0001-01-01 and the Unix epoch
+	const epochOffset = 62135596800
+	return time.Unix(secs-epochOffset, 0).UTC()
+}
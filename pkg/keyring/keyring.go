@@ -0,0 +1,28 @@
+package keyring
+
+// Package keyring caches the derived vault key in the Linux kernel keyring,
+// a lighter alternative to the agent. The key lives in the login session's
+// keyring, readable only by processes in that session, and expires on its
+// own after a timeout; Revoke drops it early. Elsewhere, or when the kernel
+// doesn't offer keyrings (e.g. in some containers), every call returns
+// ErrUnavailable and callers derive the key as usual.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+var (
+	// ErrUnavailable is returned when there's no session keyring to use
+	ErrUnavailable = errors.New("kernel keyring is not available")
+	// ErrNotFound is returned when no key is cached, or it expired
+	ErrNotFound = errors.New("no cached key")
+)
+
+// Name returns the key description for the vault at dbPath, so keys of
+// different vaults don't collide
+func Name(dbPath string) string {
+	sum := sha256.Sum256([]byte(dbPath))
+	return "openpasswd:" + hex.EncodeToString(sum[:8])
+}
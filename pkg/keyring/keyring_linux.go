@@ -0,0 +1,113 @@
+//go:build linux
+
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// possessorOnly lets only processes possessing the key (those in the
+// session) view, read, write, search, link and revoke it
+const possessorOnly = 0x3f000000
+
+// sessionKeyring returns the session keyring without creating one; a
+// process without a session keyring would otherwise get a private one that
+// nothing else can see
+func sessionKeyring() (int, error) {
+	id, err := unix.KeyctlGetKeyringID(unix.KEY_SPEC_SESSION_KEYRING, false)
+	if err != nil {
+		return 0, ErrUnavailable
+	}
+	return id, nil
+}
+
+// Store caches the key under name for the given time, replacing an earlier one
+func Store(name string, key []byte, timeout time.Duration) error {
+	ring, err := sessionKeyring()
+	if err != nil {
+		return err
+	}
+
+	id, err := unix.AddKey("user", name, key, ring)
+	if err != nil {
+		if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) {
+			return ErrUnavailable
+		}
+		return fmt.Errorf("failed to add key to keyring: %w", err)
+	}
+
+	if err := unix.KeyctlSetperm(id, possessorOnly); err != nil {
+		revoke(id)
+		return fmt.Errorf("failed to restrict cached key: %w", err)
+	}
+
+	seconds := int((timeout + time.Second - 1) / time.Second)
+	if _, err := unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, id, seconds, 0, 0); err != nil {
+		revoke(id)
+		return fmt.Errorf("failed to set key timeout: %w", err)
+	}
+	return nil
+}
+
+// Load returns the key cached under name
+func Load(name string) ([]byte, error) {
+	id, err := find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cached keys are 32 bytes; the buffer leaves room to notice anything else
+	buf := make([]byte, 64)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil {
+		return nil, keyError(err)
+	}
+	if n > len(buf) {
+		return nil, fmt.Errorf("cached key has unexpected size %d", n)
+	}
+	return buf[:n], nil
+}
+
+// Revoke destroys the key cached under name
+func Revoke(name string) error {
+	id, err := find(name)
+	if err != nil {
+		return err
+	}
+	return revoke(id)
+}
+
+func find(name string) (int, error) {
+	ring, err := sessionKeyring()
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := unix.KeyctlSearch(ring, "user", name, 0)
+	if err != nil {
+		return 0, keyError(err)
+	}
+	return id, nil
+}
+
+func revoke(id int) error {
+	if _, err := unix.KeyctlInt(unix.KEYCTL_REVOKE, id, 0, 0, 0); err != nil {
+		return keyError(err)
+	}
+	return nil
+}
+
+// keyError maps the errors of missing, expired and revoked keys to ErrNotFound
+func keyError(err error) error {
+	switch {
+	case errors.Is(err, unix.ENOKEY), errors.Is(err, unix.EKEYEXPIRED), errors.Is(err, unix.EKEYREVOKED):
+		return ErrNotFound
+	case errors.Is(err, unix.ENOSYS):
+		return ErrUnavailable
+	}
+	return fmt.Errorf("keyring: %w", err)
+}
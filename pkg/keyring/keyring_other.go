@@ -0,0 +1,20 @@
+//go:build !linux
+
+package keyring
+
+import "time"
+
+// Store is unavailable outside Linux
+func Store(name string, key []byte, timeout time.Duration) error {
+	return ErrUnavailable
+}
+
+// Load is unavailable outside Linux
+func Load(name string) ([]byte, error) {
+	return nil, ErrUnavailable
+}
+
+// Revoke is unavailable outside Linux
+func Revoke(name string) error {
+	return ErrUnavailable
+}
@@ -0,0 +1,210 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+)
+
+// Backup codes are one-time fallbacks for the master TOTP factor. Only an
+// Argon2id hash of each code, with its own salt, is stored, and a code is
+// removed once used.
+
+const (
+	DefaultBackupCodeCount = 10
+
+	backupCodeLength   = 10                                // 50 bits of entropy
+	backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789" // no 0/o, 1/l/i
+
+	// backupCodeVersion is the hash new codes are stored with: 1 is a salted
+	// SHA-256, 2 is Argon2id with backupCodeParams
+	backupCodeVersion = 2
+)
+
+// backupCodeParams make each guess cost about as much as a login would,
+// while checking a full set of codes stays well under a second
+var backupCodeParams = crypto.Argon2Params{
+	Time:        2,
+	Memory:      19 * 1024, // 19 MiB
+	Parallelism: 1,
+	KeyLen:      32,
+}
+
+// BackupCode is the stored (hashed) form of a single backup code. Codes
+// without a version were stored before Argon2id and use version 1.
+type BackupCode struct {
+	Version int    `json:"version,omitempty"`
+	Salt    string `json:"salt"`
+	Hash    string `json:"hash"`
+}
+
+// BackupCodeSet is the persisted collection of unused backup codes
+type BackupCodeSet struct {
+	Codes     []BackupCode `json:"codes"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// GenerateBackupCodes creates n new backup codes. The plaintext codes are
+// returned for display only; the set holds their hashes for storage.
+func GenerateBackupCodes(n int) ([]string, *BackupCodeSet, error) {
+	if n <= 0 {
+		n = DefaultBackupCodeCount
+	}
+
+	plain := make([]string, 0, n)
+	set := &BackupCodeSet{CreatedAt: time.Now()}
+
+	for i := 0; i < n; i++ {
+		code, err := randomBackupCode()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, nil, err
+		}
+
+		plain = append(plain, formatBackupCode(code))
+		set.Codes = append(set.Codes, BackupCode{
+			Version: backupCodeVersion,
+			Salt:    base64.StdEncoding.EncodeToString(salt),
+			Hash:    hashBackupCode(backupCodeVersion, salt, code),
+		})
+	}
+
+	return plain, set, nil
+}
+
+// Consume checks a backup code and, if it matches, removes it from the set.
+// The caller must persist the set afterwards.
+func (s *BackupCodeSet) Consume(code string) bool {
+	code = normalizeBackupCode(code)
+	if len(code) != backupCodeLength {
+		return false
+	}
+
+	match := -1
+	for i, bc := range s.Codes {
+		salt, err := base64.StdEncoding.DecodeString(bc.Salt)
+		if err != nil {
+			continue
+		}
+		// Check every entry so timing doesn't reveal the matching position
+		if subtle.ConstantTimeCompare([]byte(hashBackupCode(bc.version(), salt, code)), []byte(bc.Hash)) == 1 {
+			match = i
+		}
+	}
+
+	if match < 0 {
+		return false
+	}
+
+	s.Codes = append(s.Codes[:match], s.Codes[match+1:]...)
+	return true
+}
+
+// Remaining returns the number of unused backup codes
+func (s *BackupCodeSet) Remaining() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.Codes)
+}
+
+// Legacy reports whether any unused code is stored with an older hash. Those
+// can't be rehashed without the code, so the set has to be regenerated.
+func (s *BackupCodeSet) Legacy() bool {
+	if s == nil {
+		return false
+	}
+	for _, bc := range s.Codes {
+		if bc.version() < backupCodeVersion {
+			return true
+		}
+	}
+	return false
+}
+
+func (bc BackupCode) version() int {
+	if bc.Version == 0 {
+		return 1
+	}
+	return bc.Version
+}
+
+// EncodeBackupCodes serialises a backup code set for storage
+func EncodeBackupCodes(s *BackupCodeSet) (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DecodeBackupCodes parses a stored backup code set
+func DecodeBackupCodes(encoded string) (*BackupCodeSet, error) {
+	var s BackupCodeSet
+	if err := json.Unmarshal([]byte(encoded), &s); err != nil {
+		return nil, fmt.Errorf("invalid backup codes: %w", err)
+	}
+	return &s, nil
+}
+
+// LooksLikeBackupCode reports whether input has the shape of a backup code
+// rather than a numeric TOTP code
+func LooksLikeBackupCode(input string) bool {
+	return len(normalizeBackupCode(input)) == backupCodeLength
+}
+
+func randomBackupCode() (string, error) {
+	buf := make([]byte, backupCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	// Rejection sampling keeps the distribution uniform over the alphabet
+	limit := byte(256 - 256%len(backupCodeAlphabet))
+	out := make([]byte, 0, backupCodeLength)
+	for len(out) < backupCodeLength {
+		for _, b := range buf {
+			if b < limit && len(out) < backupCodeLength {
+				out = append(out, backupCodeAlphabet[int(b)%len(backupCodeAlphabet)])
+			}
+		}
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+	}
+
+	return string(out), nil
+}
+
+// hashBackupCode hashes a normalized code with the given hash version
+func hashBackupCode(version int, salt []byte, code string) string {
+	if version < 2 {
+		h := sha256.New()
+		h.Write([]byte("openpasswd-backup-code-v1"))
+		h.Write(salt)
+		h.Write([]byte(code))
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+	return base64.StdEncoding.EncodeToString(crypto.Argon2idKey([]byte(code), salt, backupCodeParams))
+}
+
+// formatBackupCode splits a code into two halves for readability (abcde-fghjk)
+func formatBackupCode(code string) string {
+	return code[:backupCodeLength/2] + "-" + code[backupCodeLength/2:]
+}
+
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,63 @@
+package mfa
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestBackupCodes(t *testing.T) {
+	codes, set, err := GenerateBackupCodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 3 || set.Remaining() != 3 || set.Legacy() {
+		t.Fatalf("got %d codes, %d stored, legacy %v", len(codes), set.Remaining(), set.Legacy())
+	}
+	for _, bc := range set.Codes {
+		if bc.Version != backupCodeVersion || strings.Contains(bc.Hash, codes[0]) {
+			t.Errorf("stored code = %+v", bc)
+		}
+	}
+
+	// Codes survive storage and are accepted once, in any case and spacing
+	encoded, err := EncodeBackupCodes(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set, err = DecodeBackupCodes(encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if set.Consume("aaaaa-aaaaa") {
+		t.Error("accepted a code that wasn't generated")
+	}
+	if !set.Consume(" " + strings.ToUpper(codes[1]) + " ") {
+		t.Fatal("rejected a generated code")
+	}
+	if set.Consume(codes[1]) {
+		t.Error("accepted a code twice")
+	}
+	if set.Remaining() != 2 {
+		t.Errorf("remaining = %d, want 2", set.Remaining())
+	}
+}
+
+// Codes stored as a salted SHA-256 still work until they are regenerated
+func TestLegacyBackupCodes(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	set := &BackupCodeSet{Codes: []BackupCode{{
+		Salt: base64.StdEncoding.EncodeToString(salt),
+		Hash: hashBackupCode(1, salt, "abcdefghjk"),
+	}}}
+
+	if !set.Legacy() {
+		t.Error("a set without versions isn't legacy")
+	}
+	if !set.Consume("abcde-fghjk") {
+		t.Fatal("rejected a legacy code")
+	}
+	if set.Legacy() || set.Remaining() != 0 {
+		t.Errorf("after use: legacy %v, remaining %d", set.Legacy(), set.Remaining())
+	}
+}
@@ -0,0 +1,270 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// HOTP (RFC 4226) and TOTP (RFC 6238) generation with configurable
+// algorithm, digits, period and validation skew, plus Steam Guard codes.
+
+// Algorithm is the HMAC hash function used to derive one-time passwords
+type Algorithm string
+
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+// Encoding selects how the truncated HMAC value is rendered as a code
+type Encoding string
+
+const (
+	EncodingDecimal Encoding = "decimal" // RFC 4226 decimal digits
+	EncodingSteam   Encoding = "steam"   // Steam Guard 5-character alphabet
+)
+
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+	DefaultSkew   = 1
+
+	minDigits     = 6
+	maxDigits     = 10
+	steamDigits   = 5
+	steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+)
+
+// OTPOptions configures one-time password generation and validation
+type OTPOptions struct {
+	Algorithm Algorithm
+	Digits    int      // Code length (ignored for Steam, which is always 5)
+	Period    int      // TOTP time step in seconds
+	Skew      int      // Time steps accepted either side of the current one
+	Encoding  Encoding // Decimal (default) or Steam
+}
+
+// DefaultOTPOptions returns the parameters used by most authenticator apps
+func DefaultOTPOptions() OTPOptions {
+	return OTPOptions{
+		Algorithm: AlgorithmSHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+		Skew:      DefaultSkew,
+		Encoding:  EncodingDecimal,
+	}
+}
+
+// SteamOTPOptions returns the parameters used by Steam Guard
+func SteamOTPOptions() OTPOptions {
+	opts := DefaultOTPOptions()
+	opts.Digits = steamDigits
+	opts.Encoding = EncodingSteam
+	return opts
+}
+
+// normalize fills in defaults for zero-valued options
+func (o OTPOptions) normalize() OTPOptions {
+	if o.Algorithm == "" {
+		o.Algorithm = AlgorithmSHA1
+	}
+	if o.Encoding == "" {
+		o.Encoding = EncodingDecimal
+	}
+	if o.Digits == 0 {
+		o.Digits = DefaultDigits
+	}
+	if o.Encoding == EncodingSteam {
+		o.Digits = steamDigits
+	}
+	if o.Period <= 0 {
+		o.Period = DefaultPeriod
+	}
+	if o.Skew < 0 {
+		o.Skew = 0
+	}
+	return o
+}
+
+func (o OTPOptions) validate() error {
+	if o.Encoding != EncodingDecimal && o.Encoding != EncodingSteam {
+		return fmt.Errorf("unsupported OTP encoding: %s", o.Encoding)
+	}
+	if o.Encoding == EncodingDecimal && (o.Digits < minDigits || o.Digits > maxDigits) {
+		return fmt.Errorf("unsupported OTP digits: %d (must be %d-%d)", o.Digits, minDigits, maxDigits)
+	}
+	return nil
+}
+
+// ParseAlgorithm parses an algorithm name as found in otpauth URIs
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch strings.ToUpper(strings.ReplaceAll(name, "-", "")) {
+	case "", "SHA1":
+		return AlgorithmSHA1, nil
+	case "SHA256":
+		return AlgorithmSHA256, nil
+	case "SHA512":
+		return AlgorithmSHA512, nil
+	default:
+		return "", fmt.Errorf("unsupported OTP algorithm: %s", name)
+	}
+}
+
+func (a Algorithm) hashFunc() (func() hash.Hash, error) {
+	switch a {
+	case AlgorithmSHA1, "":
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTP algorithm: %s", a)
+	}
+}
+
+// DecodeSecret decodes a base32 shared secret, tolerating lowercase,
+// spaces, dashes and missing padding as typed by users
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(secret)
+	secret = strings.NewReplacer(" ", "", "-", "", "=", "").Replace(secret)
+	if secret == "" {
+		return nil, fmt.Errorf("empty OTP secret")
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTP secret: %w", err)
+	}
+	return key, nil
+}
+
+// HOTP computes an RFC 4226 one-time password from a raw key and counter
+func HOTP(key []byte, counter uint64, opts OTPOptions) (string, error) {
+	opts = opts.normalize()
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+
+	newHash, err := opts.Algorithm.hashFunc()
+	if err != nil {
+		return "", err
+	}
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], counter)
+
+	h := hmac.New(newHash, key)
+	h.Write(buf[:])
+	sum := h.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0F
+	truncated := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7FFFFFFF
+
+	if opts.Encoding == EncodingSteam {
+		code := make([]byte, steamDigits)
+		for i := range code {
+			code[i] = steamAlphabet[truncated%uint32(len(steamAlphabet))]
+			truncated /= uint32(len(steamAlphabet))
+		}
+		return string(code), nil
+	}
+
+	mod := uint64(1)
+	for i := 0; i < opts.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", opts.Digits, uint64(truncated)%mod), nil
+}
+
+// GenerateHOTP computes an HOTP code from a base32 secret
+func GenerateHOTP(secret string, counter uint64, opts OTPOptions) (string, error) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return HOTP(key, counter, opts)
+}
+
+// TimeStep returns the RFC 6238 counter for t with the given period
+func TimeStep(t time.Time, period int) int64 {
+	if period <= 0 {
+		period = DefaultPeriod
+	}
+	return t.Unix() / int64(period)
+}
+
+// GenerateTOTP computes an RFC 6238 code from a base32 secret at time t
+func GenerateTOTP(secret string, t time.Time, opts OTPOptions) (string, error) {
+	opts = opts.normalize()
+	return GenerateHOTP(secret, uint64(TimeStep(t, opts.Period)), opts)
+}
+
+// ValidateTOTPAt checks a code against every time step within opts.Skew of t.
+// It returns the matched time step so callers can reject replays.
+func ValidateTOTPAt(secret, code string, t time.Time, opts OTPOptions) (int64, bool) {
+	opts = opts.normalize()
+
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := TimeStep(t, opts.Period)
+	for delta := -int64(opts.Skew); delta <= int64(opts.Skew); delta++ {
+		step := current + delta
+		if step < 0 {
+			continue
+		}
+		expected, err := HOTP(key, uint64(step), opts)
+		if err != nil {
+			return 0, false
+		}
+		if codesEqual(expected, code) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// ValidateHOTP checks a code against counter..counter+lookAhead. On success
+// it returns the counter value that must be persisted for the next check.
+func ValidateHOTP(secret, code string, counter uint64, lookAhead int, opts OTPOptions) (uint64, bool) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return counter, false
+	}
+	if lookAhead < 0 {
+		lookAhead = 0
+	}
+
+	for i := 0; i <= lookAhead; i++ {
+		expected, err := HOTP(key, counter+uint64(i), opts)
+		if err != nil {
+			return counter, false
+		}
+		if codesEqual(expected, code) {
+			return counter + uint64(i) + 1, true
+		}
+	}
+
+	return counter, false
+}
+
+// codesEqual compares codes in constant time, ignoring case and spaces
+func codesEqual(expected, code string) bool {
+	code = strings.ToUpper(strings.ReplaceAll(code, " ", ""))
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1
+}
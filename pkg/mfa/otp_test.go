@@ -0,0 +1,118 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 4226 appendix D
+func TestHOTPRFC4226(t *testing.T) {
+	key := []byte("12345678901234567890")
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, code := range want {
+		got, err := HOTP(key, uint64(counter), DefaultOTPOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != code {
+			t.Errorf("counter %d: got %s, want %s", counter, got, code)
+		}
+	}
+}
+
+// RFC 6238 appendix B; each algorithm has its own seed length
+func TestTOTPRFC6238(t *testing.T) {
+	seeds := map[Algorithm]string{
+		AlgorithmSHA1:   "12345678901234567890",
+		AlgorithmSHA256: "12345678901234567890123456789012",
+		AlgorithmSHA512: strings.Repeat("1234567890", 6) + "1234",
+	}
+
+	tests := []struct {
+		time                 int64
+		sha1, sha256, sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+
+	for _, tt := range tests {
+		for algo, want := range map[Algorithm]string{
+			AlgorithmSHA1:   tt.sha1,
+			AlgorithmSHA256: tt.sha256,
+			AlgorithmSHA512: tt.sha512,
+		} {
+			secret := base32.StdEncoding.EncodeToString([]byte(seeds[algo]))
+			opts := DefaultOTPOptions()
+			opts.Algorithm = algo
+			opts.Digits = 8
+
+			got, err := GenerateTOTP(secret, time.Unix(tt.time, 0), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s at %d: got %s, want %s", algo, tt.time, got, want)
+			}
+		}
+	}
+}
+
+func TestSteamGuard(t *testing.T) {
+	// The RFC 4226 key, rendered in the Steam alphabet
+	key, err := ParseOTPAuthURI("steam://GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for at, want := range map[int64]string{
+		59:         "PV9M4",
+		1111111109: "PY4YB",
+		1234567890: "VHHQY",
+	} {
+		got, err := key.Code(time.Unix(at, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("at %d: got %s, want %s", at, got, want)
+		}
+	}
+}
+
+func TestNextHOTP(t *testing.T) {
+	key, err := ParseOTPAuthURI("otpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := key.NextHOTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "969429" {
+		t.Errorf("code = %s, want 969429", code)
+	}
+
+	// The advanced counter must survive a round trip through the URI
+	saved, err := ParseOTPAuthURI(key.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Counter != 4 {
+		t.Errorf("saved counter = %d, want 4", saved.Counter)
+	}
+	if code, _ := saved.Code(time.Now()); code != "338314" {
+		t.Errorf("next code = %s, want 338314", code)
+	}
+}
@@ -0,0 +1,185 @@
+package mfa
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OTPTypeTOTP = "totp"
+	OTPTypeHOTP = "hotp"
+)
+
+// OTPKey is a one-time password credential as carried by otpauth:// URIs
+//
+// HOTP keys are counter based: callers advance the counter with NextHOTP and
+// persist it by storing the result of URL() back where the URI came from.
+type OTPKey struct {
+	Type        string // OTPTypeTOTP or OTPTypeHOTP
+	Secret      string // Base32 shared secret
+	Issuer      string
+	AccountName string
+	Counter     uint64 // Next HOTP counter value
+	Options     OTPOptions
+}
+
+// ParseOTPAuthURI parses otpauth://totp/..., otpauth://hotp/... and the
+// steam://SECRET shorthand used by several authenticator exports
+func ParseOTPAuthURI(uri string) (*OTPKey, error) {
+	uri = strings.TrimSpace(uri)
+
+	if strings.HasPrefix(strings.ToLower(uri), "steam://") {
+		return &OTPKey{
+			Type:    OTPTypeTOTP,
+			Secret:  uri[len("steam://"):],
+			Issuer:  "Steam",
+			Options: SteamOTPOptions(),
+		}, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otpauth URI: %w", err)
+	}
+	if u.Scheme != "otpauth" {
+		return nil, fmt.Errorf("invalid otpauth URI scheme: %s", u.Scheme)
+	}
+
+	key := &OTPKey{
+		Type:    strings.ToLower(u.Host),
+		Options: DefaultOTPOptions(),
+	}
+	if key.Type != OTPTypeTOTP && key.Type != OTPTypeHOTP {
+		return nil, fmt.Errorf("unsupported OTP type: %s", u.Host)
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Issuer = strings.TrimSpace(issuer)
+		key.AccountName = strings.TrimSpace(account)
+	} else {
+		key.AccountName = label
+	}
+
+	q := u.Query()
+	key.Secret = q.Get("secret")
+	if key.Secret == "" {
+		return nil, fmt.Errorf("otpauth URI is missing a secret")
+	}
+	if _, err := DecodeSecret(key.Secret); err != nil {
+		return nil, err
+	}
+
+	if issuer := q.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+
+	if algo := q.Get("algorithm"); algo != "" {
+		a, err := ParseAlgorithm(algo)
+		if err != nil {
+			return nil, err
+		}
+		key.Options.Algorithm = a
+	}
+
+	if digits := q.Get("digits"); digits != "" {
+		d, err := strconv.Atoi(digits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTP digits: %s", digits)
+		}
+		key.Options.Digits = d
+	}
+
+	if period := q.Get("period"); period != "" {
+		p, err := strconv.Atoi(period)
+		if err != nil || p <= 0 {
+			return nil, fmt.Errorf("invalid OTP period: %s", period)
+		}
+		key.Options.Period = p
+	}
+
+	if counter := q.Get("counter"); counter != "" {
+		c, err := strconv.ParseUint(counter, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HOTP counter: %s", counter)
+		}
+		key.Counter = c
+	}
+
+	// KeePassXC and Aegis mark Steam secrets with encoder=steam
+	if strings.EqualFold(q.Get("encoder"), "steam") {
+		key.Options.Encoding = EncodingSteam
+		key.Options.Digits = steamDigits
+	}
+
+	if err := key.Options.normalize().validate(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// URL serialises the key back into an otpauth:// URI
+func (k *OTPKey) URL() string {
+	opts := k.Options.normalize()
+
+	v := url.Values{}
+	v.Set("secret", k.Secret)
+	if k.Issuer != "" {
+		v.Set("issuer", k.Issuer)
+	}
+	v.Set("algorithm", string(opts.Algorithm))
+	v.Set("digits", strconv.Itoa(opts.Digits))
+
+	otpType := k.Type
+	if otpType == "" {
+		otpType = OTPTypeTOTP
+	}
+	if otpType == OTPTypeHOTP {
+		v.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		v.Set("period", strconv.Itoa(opts.Period))
+	}
+	if opts.Encoding == EncodingSteam {
+		v.Set("encoder", "steam")
+	}
+
+	label := k.AccountName
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.AccountName
+	}
+
+	return fmt.Sprintf("otpauth://%s/%s?%s", otpType, url.PathEscape(label), v.Encode())
+}
+
+// Code returns the current code: the code at time t for TOTP keys, or the
+// code for the stored counter (without advancing it) for HOTP keys
+func (k *OTPKey) Code(t time.Time) (string, error) {
+	if k.Type == OTPTypeHOTP {
+		return GenerateHOTP(k.Secret, k.Counter, k.Options)
+	}
+	return GenerateTOTP(k.Secret, t, k.Options)
+}
+
+// NextHOTP returns the code for the stored counter and advances the counter.
+// The caller must persist URL() afterwards so the counter is never reused.
+func (k *OTPKey) NextHOTP() (string, error) {
+	if k.Type != OTPTypeHOTP {
+		return "", fmt.Errorf("not an HOTP key")
+	}
+	code, err := GenerateHOTP(k.Secret, k.Counter, k.Options)
+	if err != nil {
+		return "", err
+	}
+	k.Counter++
+	return code, nil
+}
+
+// Remaining returns how long the current TOTP code stays valid
+func (k *OTPKey) Remaining(t time.Time) time.Duration {
+	period := int64(k.Options.normalize().Period)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
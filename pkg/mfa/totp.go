@@ -1,15 +1,12 @@
 package mfa
 
 import (
-	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha1"
 	"encoding/base32"
 	"encoding/base64"
-	"encoding/binary"
 	"fmt"
 	"net/url"
-	"strings"
+	"os"
 	"time"
 )
 
@@ -46,41 +43,30 @@ func (k *TOTPKey) URL() string {
 	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
 }
 
+// ValidateTOTP checks a code for the master TOTP secret using the default
+// parameters (SHA-1, 6 digits, 30 s) and a skew of one time step
 func ValidateTOTP(secret string, code string) bool {
-	expectedCode := generateTOTP(secret, time.Now().Unix()/30)
-	return code == expectedCode
+	_, ok := ValidateTOTPAt(secret, code, time.Now(), DefaultOTPOptions())
+	return ok
 }
 
-func generateTOTP(secret string, counter int64) string {
-	secret = strings.ToUpper(secret)
-	secret = strings.ReplaceAll(secret, " ", "")
-
-	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
-	if err != nil {
-		return ""
+// ValidateTOTPAfter validates a master TOTP code like ValidateTOTP but only
+// accepts time steps after lastStep, so an accepted code cannot be replayed.
+// It returns the accepted step, which the caller must persist.
+func ValidateTOTPAfter(secret string, code string, lastStep int64) (int64, bool) {
+	step, ok := ValidateTOTPAt(secret, code, time.Now(), DefaultOTPOptions())
+	if !ok || step <= lastStep {
+		return 0, false
 	}
-
-	buf := make([]byte, 8)
-	binary.BigEndian.PutUint64(buf, uint64(counter))
-
-	h := hmac.New(sha1.New, key)
-	h.Write(buf)
-	hash := h.Sum(nil)
-
-	offset := hash[len(hash)-1] & 0x0F
-	truncated := binary.BigEndian.Uint32(hash[offset:offset+4]) & 0x7FFFFFFF
-	code := truncated % 1000000
-
-	return fmt.Sprintf("%06d", code)
+	return step, true
 }
 
 func GenerateQRCodeASCII(key *TOTPKey) (string, error) {
 	return "", nil
 }
 
-
 func GetTOTPCode() (string, error) {
-	fmt.Print("Enter 6-digit TOTP code: ")
+	fmt.Fprint(os.Stderr, "Enter 6-digit TOTP code (or a backup code): ")
 	var code string
 	fmt.Scanln(&code)
 	return code, nil
@@ -11,8 +11,16 @@ const (
 	TypeIdentity PasswordType = "identity"
 	TypeOther    PasswordType = "other"
 	TypePassword PasswordType = "password"
+	TypeSSHKey   PasswordType = "ssh_key"
+	TypeRegistry PasswordType = "registry"
 )
 
+// Hidden reports whether entries of this type are left out of the normal
+// entry lists. Registry logins are only used by the Docker credential helper.
+func (t PasswordType) Hidden() bool {
+	return t == TypeRegistry
+}
+
 type Password struct {
 	ID        int64
 	Type      PasswordType
@@ -25,3 +33,20 @@ type Password struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// FieldTOTP is the custom field holding an otpauth:// URI or base32 TOTP secret
+const FieldTOTP = "totp_uri"
+
+// FieldFolder is the custom field holding the folder (or group, vault) an
+// imported entry belonged to in its source password manager
+const FieldFolder = "folder"
+
+// Custom fields of SSH key entries. The private key is stored in the OpenSSH
+// format; the passphrase only applies to keys imported with one.
+const (
+	FieldPrivateKey  = "private_key"
+	FieldPublicKey   = "public_key"
+	FieldComment     = "comment"
+	FieldPassphrase  = "passphrase"
+	FieldFingerprint = "fingerprint"
+)
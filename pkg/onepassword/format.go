@@ -0,0 +1,146 @@
+package onepassword
+
+// format.go describes the 1Password Unencrypted Export (1PUX) format.
+//
+// A .1pux file is a ZIP archive holding export.attributes, export.data
+// (the JSON below) and a files/ directory with attachments. Items are
+// grouped by account and vault; an item's fields live partly in fixed
+// places (loginFields, notesPlain, password) and partly in free-form
+// sections whose field values are tagged by type.
+// See https://support.1password.com/1pux-format/ for the format.
+
+import "encoding/json"
+
+// Category UUIDs of 1Password items
+const (
+	CategoryLogin          = "001"
+	CategoryCreditCard     = "002"
+	CategorySecureNote     = "003"
+	CategoryIdentity       = "004"
+	CategoryPassword       = "005"
+	CategoryDocument       = "006"
+	CategorySoftware       = "100"
+	CategoryBankAccount    = "101"
+	CategoryDatabase       = "102"
+	CategoryDriverLicense  = "103"
+	CategoryOutdoorLicense = "104"
+	CategoryMembership     = "105"
+	CategoryPassport       = "106"
+	CategoryRewardProgram  = "107"
+	CategorySSN            = "108"
+	CategoryRouter         = "109"
+	CategoryServer         = "110"
+	CategoryEmailAccount   = "111"
+	CategoryAPICredential  = "112"
+	CategoryMedicalRecord  = "113"
+	CategorySSHKey         = "114"
+)
+
+// Export is the root of export.data
+type Export struct {
+	Accounts []Account `json:"accounts"`
+}
+
+type Account struct {
+	Attrs struct {
+		AccountName string `json:"accountName"`
+		Name        string `json:"name"`
+		Email       string `json:"email"`
+	} `json:"attrs"`
+	Vaults []Vault `json:"vaults"`
+}
+
+type Vault struct {
+	Attrs struct {
+		UUID string `json:"uuid"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"attrs"`
+	Items []Item `json:"items"`
+}
+
+type Item struct {
+	UUID         string   `json:"uuid"`
+	CreatedAt    int64    `json:"createdAt"`
+	UpdatedAt    int64    `json:"updatedAt"`
+	State        string   `json:"state"`
+	CategoryUUID string   `json:"categoryUuid"`
+	Details      Details  `json:"details"`
+	Overview     Overview `json:"overview"`
+}
+
+type Details struct {
+	LoginFields     []LoginField      `json:"loginFields"`
+	NotesPlain      string            `json:"notesPlain"`
+	Sections        []Section         `json:"sections"`
+	PasswordHistory []PasswordHistory `json:"passwordHistory"`
+	Password        string            `json:"password,omitempty"`
+}
+
+// LoginField is a field of the web form a login was saved from
+type LoginField struct {
+	Value       string `json:"value"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	FieldType   string `json:"fieldType"`
+	Designation string `json:"designation"` // "username", "password" or empty
+}
+
+type Section struct {
+	Title  string         `json:"title"`
+	Name   string         `json:"name"`
+	Fields []SectionField `json:"fields"`
+}
+
+type SectionField struct {
+	Title string     `json:"title"`
+	ID    string     `json:"id"`
+	Value FieldValue `json:"value"`
+}
+
+// FieldValue holds a single typed value, e.g. {"concealed": "..."},
+// {"totp": "otpauth://..."} or {"monthYear": 202512}
+type FieldValue map[string]json.RawMessage
+
+type PasswordHistory struct {
+	Value string `json:"value"`
+	Time  int64  `json:"time"`
+}
+
+type Overview struct {
+	Title string   `json:"title"`
+	URL   string   `json:"url"`
+	URLs  []URL    `json:"urls"`
+	Tags  []string `json:"tags"`
+}
+
+type URL struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// Address is the value of an "address" field
+type Address struct {
+	Street  string `json:"street"`
+	City    string `json:"city"`
+	Country string `json:"country"`
+	Zip     string `json:"zip"`
+	State   string `json:"state"`
+}
+
+// Email is the value of an "email" field in newer exports; older ones
+// store the address as a plain string
+type Email struct {
+	Address  string `json:"email_address"`
+	Provider string `json:"provider"`
+}
+
+// SSHKey is the value of an "sshKey" field
+type SSHKey struct {
+	PrivateKey string `json:"privateKey"`
+	Metadata   struct {
+		PublicKey   string `json:"publicKey"`
+		Fingerprint string `json:"fingerprint"`
+		KeyType     string `json:"keyType"`
+	} `json:"metadata"`
+}
@@ -0,0 +1,454 @@
+package onepassword
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/importutil"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// Importer handles imports from 1Password 1PUX archives and CSV exports
+type Importer struct{}
+
+func (o *Importer) GetName() string {
+	return "1Password"
+}
+
+func (o *Importer) GetDescription() string {
+	return "Import passwords from 1Password (.1pux archive or CSV export)"
+}
+
+func (o *Importer) SupportsFormat(format string) bool {
+	format = strings.ToLower(format)
+	return format == ".1pux" || format == ".csv"
+}
+
+func (o *Importer) Import(filePath string, passphrase string) ([]*models.Password, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+
+	switch ext {
+	case ".1pux":
+		return o.importFromZip(filePath)
+	case ".csv":
+		return o.importFromCSV(filePath)
+	default:
+		return nil, fmt.Errorf("unsupported file format: %s", ext)
+	}
+}
+
+func (o *Importer) importFromZip(filePath string) ([]*models.Password, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open 1PUX file: %w", err)
+	}
+	defer r.Close()
+
+	// Look for export.data inside the archive
+	for _, f := range r.File {
+		if f.Name != "export.data" && !strings.HasSuffix(f.Name, "/export.data") {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file in 1PUX: %w", err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file in 1PUX: %w", err)
+		}
+
+		return o.parseJSON(data)
+	}
+
+	return nil, fmt.Errorf("no export.data found in 1PUX file")
+}
+
+func (o *Importer) parseJSON(data []byte) ([]*models.Password, error) {
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var passwords []*models.Password
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				passwords = append(passwords, o.convertItem(item, vault.Attrs.Name))
+			}
+		}
+	}
+
+	return passwords, nil
+}
+
+func (o *Importer) convertItem(item Item, vaultName string) *models.Password {
+	pwd := &models.Password{
+		Type:      itemType(item.CategoryUUID),
+		Name:      item.Overview.Title,
+		Notes:     item.Details.NotesPlain,
+		Fields:    make(map[string]string),
+		CreatedAt: unixTime(item.CreatedAt),
+		UpdatedAt: unixTime(item.UpdatedAt),
+	}
+
+	if vaultName != "" {
+		pwd.Fields[models.FieldFolder] = vaultName
+	}
+
+	// Fields of the web form a login was saved from
+	for _, field := range item.Details.LoginFields {
+		switch {
+		case field.Designation == "username" && pwd.Username == "":
+			pwd.Username = field.Value
+		case field.Designation == "password" && pwd.Password == "":
+			pwd.Password = field.Value
+		case field.Value != "" && !ignoredLoginField(field.FieldType):
+			name := field.Name
+			if name == "" {
+				name = field.ID
+			}
+			pwd.Fields[importutil.UniqueKey(pwd.Fields, name)] = field.Value
+		}
+	}
+
+	if item.Details.Password != "" && pwd.Password == "" {
+		pwd.Password = item.Details.Password
+	}
+
+	// The first URL becomes the URL; further ones are kept as url_2, url_3, ...
+	pwd.URL = item.Overview.URL
+	n := 2
+	for _, u := range item.Overview.URLs {
+		if u.URL == "" || u.URL == pwd.URL {
+			continue
+		}
+		if pwd.URL == "" {
+			pwd.URL = u.URL
+			continue
+		}
+		pwd.Fields["url_"+strconv.Itoa(n)] = u.URL
+		n++
+	}
+
+	var nameParts []string
+	for _, section := range item.Details.Sections {
+		for _, field := range section.Fields {
+			kind, _ := field.Value.kind()
+
+			switch kind {
+			case "totp":
+				if _, ok := pwd.Fields[models.FieldTOTP]; !ok {
+					if v := field.Value.text(); v != "" {
+						pwd.Fields[models.FieldTOTP] = v
+					}
+					continue
+				}
+			case "sshKey":
+				var key SSHKey
+				if err := json.Unmarshal(field.Value["sshKey"], &key); err == nil {
+					setField(pwd, models.FieldPrivateKey, key.PrivateKey)
+					setField(pwd, models.FieldPublicKey, key.Metadata.PublicKey)
+					setField(pwd, models.FieldFingerprint, key.Metadata.Fingerprint)
+					setField(pwd, "key_type", key.Metadata.KeyType)
+				}
+				continue
+			case "file":
+				// Attachments live in files/ in the archive and aren't imported
+				continue
+			}
+
+			value := field.Value.text()
+			if value == "" {
+				continue
+			}
+
+			// Well-known fields map onto the built-in ones
+			switch {
+			case pwd.Type == models.TypeIdentity && (field.ID == "firstname" || field.ID == "initial" || field.ID == "lastname"):
+				nameParts = append(nameParts, value)
+				continue
+			case field.ID == "username" && pwd.Username == "":
+				pwd.Username = value
+				continue
+			case (field.ID == "password" || field.ID == "credential") && pwd.Password == "":
+				pwd.Password = value
+				continue
+			case (field.ID == "url" || field.ID == "hostname" || field.ID == "website") && pwd.URL == "":
+				pwd.URL = value
+				continue
+			}
+
+			key := knownFieldKey(pwd.Type, field.ID)
+			if key == "" {
+				key = field.Title
+			}
+			if key == "" {
+				key = field.ID
+			}
+			pwd.Fields[importutil.UniqueKey(pwd.Fields, key)] = value
+		}
+	}
+
+	if len(nameParts) > 0 {
+		pwd.Fields["full_name"] = strings.Join(nameParts, " ")
+	}
+
+	if history := passwordHistory(item.Details.PasswordHistory, pwd.Password); history != "" {
+		pwd.Fields["password_history"] = history
+	}
+
+	return pwd
+}
+
+// itemType maps a 1Password category onto an item type
+func itemType(category string) models.PasswordType {
+	switch category {
+	case CategoryLogin, CategoryAPICredential:
+		return models.TypeLogin
+	case CategoryPassword:
+		return models.TypePassword
+	case CategoryCreditCard:
+		return models.TypeCard
+	case CategoryIdentity:
+		return models.TypeIdentity
+	case CategorySecureNote:
+		return models.TypeNote
+	case CategorySSHKey:
+		return models.TypeSSHKey
+	default:
+		return models.TypeOther
+	}
+}
+
+// knownFieldKey returns the field key used for well-known 1Password field IDs
+func knownFieldKey(t models.PasswordType, id string) string {
+	switch t {
+	case models.TypeCard:
+		switch id {
+		case "cardholder":
+			return "cardholder_name"
+		case "ccnum":
+			return "number"
+		case "cvv":
+			return "cvv"
+		case "expiry":
+			return "expiration_date"
+		case "type":
+			return "brand"
+		case "pin":
+			return "pin"
+		}
+	case models.TypeIdentity:
+		switch id {
+		case "email":
+			return "email"
+		case "defphone":
+			return "phone_number"
+		case "address":
+			return "address"
+		case "company":
+			return "company"
+		}
+	}
+	return ""
+}
+
+// ignoredLoginField reports whether a web form field type carries no
+// useful value (checkboxes, radio buttons, submit buttons, images)
+func ignoredLoginField(fieldType string) bool {
+	switch fieldType {
+	case "C", "R", "B", "I":
+		return true
+	}
+	return false
+}
+
+// kind returns the type tag of the value and its raw JSON
+func (v FieldValue) kind() (string, json.RawMessage) {
+	for k, raw := range v {
+		return k, raw
+	}
+	return "", nil
+}
+
+// text formats the value as a string
+func (v FieldValue) text() string {
+	kind, raw := v.kind()
+	if raw == nil {
+		return ""
+	}
+
+	switch kind {
+	case "date":
+		var ts int64
+		if err := json.Unmarshal(raw, &ts); err == nil && ts != 0 {
+			return time.Unix(ts, 0).UTC().Format("2006-01-02")
+		}
+		return ""
+
+	case "monthYear":
+		// YYYYMM, e.g. 202512
+		var my int
+		if err := json.Unmarshal(raw, &my); err == nil && my != 0 {
+			return fmt.Sprintf("%02d/%04d", my%100, my/100)
+		}
+		return ""
+
+	case "address":
+		var a Address
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return ""
+		}
+		return importutil.JoinNonEmpty(", ", a.Street, importutil.JoinNonEmpty(" ", a.Zip, a.City), a.State, a.Country)
+
+	case "email":
+		var e Email
+		if err := json.Unmarshal(raw, &e); err == nil {
+			return e.Address
+		}
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// passwordHistory lists previous passwords, newest first, one
+// "<date>  <password>" line each
+func passwordHistory(history []PasswordHistory, current string) string {
+	entries := append([]PasswordHistory(nil), history...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time > entries[j].Time })
+
+	seen := map[string]bool{current: true}
+	var lines []string
+	for _, h := range entries {
+		if h.Value == "" || seen[h.Value] {
+			continue
+		}
+		seen[h.Value] = true
+
+		date := "unknown"
+		if h.Time != 0 {
+			date = time.Unix(h.Time, 0).UTC().Format("2006-01-02 15:04")
+		}
+		lines = append(lines, date+"  "+h.Value)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// csvColumns maps lower-cased 1Password CSV headers (1Password 7 and 8) to
+// the built-in fields
+var csvColumns = map[string]string{
+	"title":             "name",
+	"name":              "name",
+	"url":               "url",
+	"urls":              "url",
+	"website":           "url",
+	"login_uri":         "url",
+	"username":          "username",
+	"login_username":    "username",
+	"password":          "password",
+	"login_password":    "password",
+	"otpauth":           "totp",
+	"one-time password": "totp",
+	"notes":             "notes",
+	"notesplain":        "notes",
+	"favorite":          "",
+	"archived":          "",
+}
+
+func (o *Importer) importFromCSV(filePath string) ([]*models.Password, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	if len(records) < 2 {
+		return nil, fmt.Errorf("CSV file is empty or has no data rows")
+	}
+
+	header := records[0]
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	var passwords []*models.Password
+	for _, record := range records[1:] {
+		pwd := &models.Password{
+			Type:   models.TypeLogin,
+			Fields: make(map[string]string),
+		}
+
+		for i, col := range header {
+			if i >= len(record) || record[i] == "" {
+				continue
+			}
+			value := record[i]
+
+			target, known := csvColumns[strings.ToLower(strings.TrimSpace(col))]
+			switch {
+			case !known:
+				pwd.Fields[importutil.UniqueKey(pwd.Fields, strings.TrimSpace(col))] = value
+			case target == "name" && pwd.Name == "":
+				pwd.Name = value
+			case target == "url" && pwd.URL == "":
+				pwd.URL = value
+			case target == "username" && pwd.Username == "":
+				pwd.Username = value
+			case target == "password" && pwd.Password == "":
+				pwd.Password = value
+			case target == "totp":
+				pwd.Fields[models.FieldTOTP] = value
+			case target == "notes" && pwd.Notes == "":
+				pwd.Notes = value
+			}
+		}
+
+		if pwd.Username == "" && pwd.Password == "" && pwd.URL == "" && pwd.Notes != "" {
+			pwd.Type = models.TypeNote
+		}
+
+		passwords = append(passwords, pwd)
+	}
+
+	return passwords, nil
+}
+
+func setField(pwd *models.Password, key, value string) {
+	if value != "" {
+		pwd.Fields[key] = value
+	}
+}
+
+func unixTime(ts int64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0).UTC()
+}
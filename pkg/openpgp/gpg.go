@@ -0,0 +1,67 @@
+package openpgp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GPG decrypts messages with the gpg binary, which can also use the
+// secret keys and agent of the user's keyring
+type GPG struct {
+	// Path is the gpg binary; empty means "gpg" from PATH
+	Path string
+}
+
+// Decrypt runs gpg with the message on stdin and reads the plaintext from
+// stdout. The passphrase is passed through a pipe rather than the command
+// line, where other users could read it.
+func (g *GPG) Decrypt(data []byte, passphrase string) ([]byte, error) {
+	path := g.Path
+	if path == "" {
+		path = "gpg"
+	}
+	if _, err := exec.LookPath(path); err != nil {
+		return nil, errors.New("gpg is not installed")
+	}
+
+	args := []string{"--decrypt", "--batch", "--quiet", "--no-tty"}
+
+	var extra []*os.File
+	if passphrase != "" {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pipe: %w", err)
+		}
+		defer r.Close()
+
+		go func() {
+			w.Write([]byte(passphrase))
+			w.Close()
+		}()
+
+		// ExtraFiles start at file descriptor 3
+		args = append(args, "--pinentry-mode", "loopback", "--passphrase-fd", "3")
+		extra = append(extra, r)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.ExtraFiles = extra
+
+	if err := cmd.Run(); err != nil {
+		// gpg prefixes its messages with "gpg:" already
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w", msg, err)
+		}
+		return nil, fmt.Errorf("gpg: %w", err)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,507 @@
+package openpgp
+
+// native.go decrypts password-encrypted (symmetric) OpenPGP messages
+// in-process: one or more SKESK packets carrying the session key, followed
+// by SEIPD v1 (CFB with an MDC), SEIPD v2 (RFC 9580 AEAD) or GnuPG's
+// AEAD Encrypted Data packet. Everything stays in memory.
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	pcrypto "github.com/r2unit/openpasswd/pkg/crypto"
+)
+
+// Symmetric cipher and AEAD algorithm IDs
+const (
+	cipherAES128 = 7
+	cipherAES192 = 8
+	cipherAES256 = 9
+
+	aeadEAX = 1
+	aeadOCB = 2
+	aeadGCM = 3
+)
+
+var (
+	// ErrWrongPassphrase is returned when no session key could be
+	// decrypted with the passphrase
+	ErrWrongPassphrase = errors.New("openpgp: wrong passphrase")
+
+	// ErrPublicKey is returned for messages encrypted to a public key,
+	// which need a backend with access to the secret key
+	ErrPublicKey = errors.New("openpgp: message is encrypted to a public key")
+)
+
+// Native decrypts password-encrypted messages without external programs
+type Native struct {
+	// Fallback decrypts messages encrypted to a public key; nil rejects them
+	Fallback Decrypter
+}
+
+// sessionKey is a decrypted message key and its cipher
+type sessionKey struct {
+	cipher byte
+	key    []byte
+}
+
+// Decrypt decrypts a password-encrypted message, handing messages encrypted
+// to a public key to the fallback
+func (n *Native) Decrypt(data []byte, passphrase string) ([]byte, error) {
+	raw, err := dearmor(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var skesks [][]byte
+	publicKey := false
+
+	for rest := raw; len(rest) > 0; {
+		tag, body, next, err := readPacket(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = next
+
+		switch tag {
+		case tagSKESK:
+			skesks = append(skesks, body)
+		case tagPKESK:
+			publicKey = true
+		case tagMarker, tagPadding:
+			continue
+		case tagSEIPD, tagAEADEncrypted:
+			if len(skesks) == 0 {
+				if publicKey && n.Fallback != nil {
+					return n.Fallback.Decrypt(data, passphrase)
+				}
+				if publicKey {
+					return nil, ErrPublicKey
+				}
+				return nil, errors.New("openpgp: message has no session key")
+			}
+			return decryptWithPassphrase(skesks, tag, body, passphrase)
+		case tagSymEncrypted:
+			return nil, errors.New("openpgp: message uses legacy encryption without integrity protection")
+		default:
+			return nil, fmt.Errorf("openpgp: not an encrypted message (packet %d)", tag)
+		}
+	}
+
+	return nil, errors.New("openpgp: message has no encrypted data")
+}
+
+// decryptWithPassphrase tries each SKESK until one yields a session key that
+// decrypts the data
+func decryptWithPassphrase(skesks [][]byte, tag int, body []byte, passphrase string) ([]byte, error) {
+	pass := []byte(passphrase)
+	defer pcrypto.WipeMemory(pass)
+
+	lastErr := ErrWrongPassphrase
+	for _, skesk := range skesks {
+		sk, err := decryptSKESK(skesk, pass)
+		if err != nil {
+			if !errors.Is(err, ErrWrongPassphrase) {
+				lastErr = err
+			}
+			continue
+		}
+
+		var plaintext []byte
+		if tag == tagSEIPD {
+			plaintext, err = decryptSEIPD(body, sk)
+		} else {
+			plaintext, err = decryptAEADPacket(body, sk)
+		}
+		pcrypto.WipeMemory(sk.key)
+
+		if errors.Is(err, ErrWrongPassphrase) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		defer pcrypto.WipeMemory(plaintext)
+		literal, err := readLiteral(plaintext, 0)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Clone(literal), nil
+	}
+
+	return nil, lastErr
+}
+
+// decryptSKESK derives the session key from a Symmetric-Key Encrypted
+// Session Key packet (versions 4, 5 and 6)
+func decryptSKESK(body, passphrase []byte) (*sessionKey, error) {
+	if len(body) < 2 {
+		return nil, errTruncated
+	}
+
+	switch version := body[0]; version {
+	case 4:
+		algo := body[1]
+		keySize, err := cipherKeySize(algo)
+		if err != nil {
+			return nil, err
+		}
+		s, esk, err := parseS2K(body[2:])
+		if err != nil {
+			return nil, err
+		}
+		kek := s.deriveKey(passphrase, keySize)
+
+		// Without an encrypted session key the S2K output is the session key
+		if len(esk) == 0 {
+			return &sessionKey{cipher: algo, key: kek}, nil
+		}
+		defer pcrypto.WipeMemory(kek)
+
+		block, err := newBlockCipher(algo, kek)
+		if err != nil {
+			return nil, err
+		}
+		decrypted := make([]byte, len(esk))
+		cipher.NewCFBDecrypter(block, make([]byte, block.BlockSize())).XORKeyStream(decrypted, esk)
+
+		// First byte is the cipher of the session key
+		sessionAlgo := decrypted[0]
+		size, err := cipherKeySize(sessionAlgo)
+		if err != nil || len(decrypted) != 1+size {
+			pcrypto.WipeMemory(decrypted)
+			return nil, ErrWrongPassphrase
+		}
+		return &sessionKey{cipher: sessionAlgo, key: decrypted[1:]}, nil
+
+	case 5, 6:
+		return decryptAEADSKESK(version, body, passphrase)
+
+	default:
+		return nil, fmt.Errorf("openpgp: unsupported SKESK version %d", version)
+	}
+}
+
+// decryptAEADSKESK handles v5 (GnuPG) and v6 (RFC 9580) SKESK packets,
+// whose session key is AEAD-encrypted
+func decryptAEADSKESK(version byte, body, passphrase []byte) (*sessionKey, error) {
+	data := body[1:]
+	if version == 6 {
+		// A count of the following fields, for skipping unknown S2K types
+		if len(data) < 1 {
+			return nil, errTruncated
+		}
+		data = data[1:]
+	}
+	if len(data) < 2 {
+		return nil, errTruncated
+	}
+	algo, aeadAlgo := data[0], data[1]
+	data = data[2:]
+
+	if version == 6 {
+		// S2K length prefix
+		if len(data) < 1 {
+			return nil, errTruncated
+		}
+		data = data[1:]
+	}
+
+	keySize, err := cipherKeySize(algo)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize, err := aeadNonceSize(aeadAlgo)
+	if err != nil {
+		return nil, err
+	}
+
+	s, data, err := parseS2K(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < nonceSize+16 {
+		return nil, errTruncated
+	}
+	iv, esk := data[:nonceSize], data[nonceSize:]
+
+	ad := []byte{0xc0 | tagSKESK, version, algo, aeadAlgo}
+
+	kek := s.deriveKey(passphrase, keySize)
+	defer pcrypto.WipeMemory(kek)
+	if version == 6 {
+		derived, err := hkdf.Key(sha256.New, kek, nil, string(ad), keySize)
+		if err != nil {
+			return nil, err
+		}
+		defer pcrypto.WipeMemory(derived)
+		kek = derived
+	}
+
+	aead, err := newAEAD(algo, aeadAlgo, kek)
+	if err != nil {
+		return nil, err
+	}
+	key, err := aead.Open(nil, iv, esk, ad)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return &sessionKey{cipher: algo, key: key}, nil
+}
+
+// decryptSEIPD decrypts a Symmetrically Encrypted Integrity Protected Data packet
+func decryptSEIPD(body []byte, sk *sessionKey) ([]byte, error) {
+	if len(body) < 1 {
+		return nil, errTruncated
+	}
+
+	switch body[0] {
+	case 1:
+		return decryptSEIPDv1(body[1:], sk)
+	case 2:
+		return decryptSEIPDv2(body, sk)
+	default:
+		return nil, fmt.Errorf("openpgp: unsupported SEIPD version %d", body[0])
+	}
+}
+
+// decryptSEIPDv1 decrypts CFB data that starts with a random prefix and
+// ends with a SHA-1 Modification Detection Code packet
+func decryptSEIPDv1(ciphertext []byte, sk *sessionKey) ([]byte, error) {
+	block, err := newBlockCipher(sk.cipher, sk.key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	if len(ciphertext) < bs+2+22 {
+		return nil, errTruncated
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCFBDecrypter(block, make([]byte, bs)).XORKeyStream(plaintext, ciphertext)
+
+	// The last two prefix bytes repeat, which catches a wrong key early
+	if plaintext[bs-2] != plaintext[bs] || plaintext[bs-1] != plaintext[bs+1] {
+		pcrypto.WipeMemory(plaintext)
+		return nil, ErrWrongPassphrase
+	}
+
+	mdc := len(plaintext) - 22
+	sum := sha1.Sum(plaintext[:mdc+2])
+	if plaintext[mdc] != 0xc0|tagMDC || plaintext[mdc+1] != sha1.Size ||
+		subtle.ConstantTimeCompare(sum[:], plaintext[mdc+2:]) != 1 {
+		pcrypto.WipeMemory(plaintext)
+		return nil, errors.New("openpgp: message was modified (MDC mismatch)")
+	}
+
+	return plaintext[bs+2 : mdc], nil
+}
+
+// decryptSEIPDv2 decrypts RFC 9580 chunked AEAD data. The message key and
+// nonce prefix are derived from the session key and a per-message salt.
+func decryptSEIPDv2(body []byte, sk *sessionKey) ([]byte, error) {
+	if len(body) < 4+32 {
+		return nil, errTruncated
+	}
+	header := []byte{0xc0 | tagSEIPD, body[0], body[1], body[2], body[3]}
+	algo, aeadAlgo, chunkByte := body[1], body[2], body[3]
+	salt := body[4:36]
+
+	if algo != sk.cipher {
+		return nil, errors.New("openpgp: cipher of session key and data differ")
+	}
+	keySize, err := cipherKeySize(algo)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize, err := aeadNonceSize(aeadAlgo)
+	if err != nil {
+		return nil, err
+	}
+
+	derived, err := hkdf.Key(sha256.New, sk.key, salt, string(header), keySize+nonceSize-8)
+	if err != nil {
+		return nil, err
+	}
+	defer pcrypto.WipeMemory(derived)
+
+	aead, err := newAEAD(algo, aeadAlgo, derived[:keySize])
+	if err != nil {
+		return nil, err
+	}
+
+	// Each nonce is the derived prefix followed by the chunk index
+	iv := derived[keySize:]
+	nonce := func(index uint64) []byte {
+		n := make([]byte, nonceSize)
+		copy(n, iv)
+		binary.BigEndian.PutUint64(n[nonceSize-8:], index)
+		return n
+	}
+
+	return openChunks(aead, body[36:], chunkByte, nonce, func(index uint64) []byte {
+		return header
+	}, header)
+}
+
+// decryptAEADPacket decrypts GnuPG's AEAD Encrypted Data packet (tag 20),
+// which uses the session key directly and XORs the chunk index into the IV
+func decryptAEADPacket(body []byte, sk *sessionKey) ([]byte, error) {
+	if len(body) < 4 {
+		return nil, errTruncated
+	}
+	if body[0] != 1 {
+		return nil, fmt.Errorf("openpgp: unsupported AEAD packet version %d", body[0])
+	}
+	header := []byte{0xc0 | tagAEADEncrypted, body[0], body[1], body[2], body[3]}
+	algo, aeadAlgo, chunkByte := body[1], body[2], body[3]
+
+	if algo != sk.cipher {
+		return nil, errors.New("openpgp: cipher of session key and data differ")
+	}
+	nonceSize, err := aeadNonceSize(aeadAlgo)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 4+nonceSize {
+		return nil, errTruncated
+	}
+	iv := body[4 : 4+nonceSize]
+
+	aead, err := newAEAD(algo, aeadAlgo, sk.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := func(index uint64) []byte {
+		n := bytes.Clone(iv)
+		for i := 0; i < 8; i++ {
+			n[nonceSize-1-i] ^= byte(index >> (8 * i))
+		}
+		return n
+	}
+
+	// The associated data of each chunk ends with its index
+	ad := func(index uint64) []byte {
+		return binary.BigEndian.AppendUint64(bytes.Clone(header), index)
+	}
+
+	return openChunks(aead, body[4+nonceSize:], chunkByte, nonce, ad, nil)
+}
+
+// openChunks decrypts chunked AEAD data followed by a final tag over the
+// total length. finalAD is the associated data prefix of the final tag; nil
+// means the per-chunk associated data of the final index.
+func openChunks(aead cipher.AEAD, data []byte, chunkByte byte, nonce, ad func(uint64) []byte, finalAD []byte) ([]byte, error) {
+	if chunkByte > 16 {
+		return nil, errors.New("openpgp: invalid AEAD chunk size")
+	}
+	chunkSize := 1 << (chunkByte + 6)
+	tagSize := aead.Overhead()
+
+	if len(data) < tagSize {
+		return nil, errTruncated
+	}
+	finalTag := data[len(data)-tagSize:]
+	data = data[:len(data)-tagSize]
+
+	var plaintext []byte
+	var index uint64
+	for len(data) > 0 {
+		n := chunkSize + tagSize
+		if n > len(data) {
+			n = len(data)
+		}
+		if n <= tagSize {
+			return nil, errTruncated
+		}
+
+		chunk, err := aead.Open(nil, nonce(index), data[:n], ad(index))
+		if err != nil {
+			pcrypto.WipeMemory(plaintext)
+			if index == 0 {
+				// A wrong session key fails on the first chunk
+				return nil, ErrWrongPassphrase
+			}
+			return nil, errors.New("openpgp: message was modified (AEAD chunk)")
+		}
+		plaintext = append(plaintext, chunk...)
+		pcrypto.WipeMemory(chunk)
+		data = data[n:]
+		index++
+	}
+
+	if finalAD == nil {
+		finalAD = ad(index)
+	}
+	finalAD = binary.BigEndian.AppendUint64(bytes.Clone(finalAD), uint64(len(plaintext)))
+	if _, err := aead.Open(nil, nonce(index), finalTag, finalAD); err != nil {
+		pcrypto.WipeMemory(plaintext)
+		if index == 0 {
+			return nil, ErrWrongPassphrase
+		}
+		return nil, errors.New("openpgp: message was truncated or modified (final AEAD tag)")
+	}
+
+	return plaintext, nil
+}
+
+func cipherKeySize(algo byte) (int, error) {
+	switch algo {
+	case cipherAES128:
+		return 16, nil
+	case cipherAES192:
+		return 24, nil
+	case cipherAES256:
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("openpgp: unsupported cipher algorithm %d (only AES is supported)", algo)
+	}
+}
+
+func newBlockCipher(algo byte, key []byte) (cipher.Block, error) {
+	if _, err := cipherKeySize(algo); err != nil {
+		return nil, err
+	}
+	return aes.NewCipher(key)
+}
+
+func aeadNonceSize(algo byte) (int, error) {
+	switch algo {
+	case aeadEAX:
+		return 16, nil
+	case aeadOCB:
+		return 15, nil
+	case aeadGCM:
+		return 12, nil
+	default:
+		return 0, fmt.Errorf("openpgp: unsupported AEAD algorithm %d", algo)
+	}
+}
+
+func newAEAD(algo, aeadAlgo byte, key []byte) (cipher.AEAD, error) {
+	block, err := newBlockCipher(algo, key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch aeadAlgo {
+	case aeadEAX:
+		return pcrypto.NewEAX(block)
+	case aeadOCB:
+		return pcrypto.NewOCB(block, 15)
+	case aeadGCM:
+		return cipher.NewGCM(block)
+	default:
+		return nil, fmt.Errorf("openpgp: unsupported AEAD algorithm %d", aeadAlgo)
+	}
+}
@@ -0,0 +1,78 @@
+package openpgp
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The fixtures encrypt testdata/message.txt with this passphrase. The CFB
+// ones come from gpg 2.2 (AES-256 compressed, AES-128 armored without
+// compression); the AEAD ones are GnuPG-style v5 SKESK and AEAD Encrypted
+// Data packets in 64-byte chunks.
+const testPassphrase = "correct horse"
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+var fixtures = []string{"aes256.gpg", "aes128.asc", "aead-ocb.gpg", "aead-eax.gpg"}
+
+func TestNativeDecrypt(t *testing.T) {
+	want := readFixture(t, "message.txt")
+
+	for _, name := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			got, err := (&Native{}).Decrypt(readFixture(t, name), testPassphrase)
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Decrypt = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestNativeWrongPassphrase(t *testing.T) {
+	for _, name := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			_, err := (&Native{}).Decrypt(readFixture(t, name), "wrong horse")
+			if !errors.Is(err, ErrWrongPassphrase) {
+				t.Errorf("err = %v, want ErrWrongPassphrase", err)
+			}
+		})
+	}
+}
+
+func TestNativeModified(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int // from the end, inside the encrypted data
+		want   string
+	}{
+		{"aes256.gpg", 60, "MDC mismatch"},
+		{"aead-ocb.gpg", 60, "modified"},
+		{"aead-eax.gpg", 60, "modified"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := readFixture(t, tt.name)
+			data[len(data)-tt.offset] ^= 0x01
+
+			_, err := (&Native{}).Decrypt(data, testPassphrase)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package openpgp
+
+// Package openpgp decrypts OpenPGP messages for importers (Proton Pass
+// encrypted exports, password-store files). The actual decryption is done
+// by a pluggable backend so importers don't depend on how it's done.
+
+// Decrypter decrypts OpenPGP messages
+type Decrypter interface {
+	// Decrypt decrypts a binary or ASCII-armored OpenPGP message.
+	// passphrase unlocks symmetrically encrypted messages; it may be empty
+	// for messages encrypted to a key the backend can unlock by itself.
+	Decrypt(data []byte, passphrase string) ([]byte, error)
+}
+
+// Default returns the backend importers use unless configured otherwise:
+// password-encrypted messages are decrypted natively, anything encrypted to
+// a public key goes to gpg
+func Default() Decrypter {
+	return &Native{Fallback: &GPG{}}
+}
@@ -0,0 +1,267 @@
+package openpgp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Packet tags (RFC 9580, section 5)
+const (
+	tagPKESK         = 1
+	tagSignature     = 2
+	tagSKESK         = 3
+	tagOnePassSig    = 4
+	tagCompressed    = 8
+	tagSymEncrypted  = 9 // Legacy, unauthenticated
+	tagMarker        = 10
+	tagLiteral       = 11
+	tagSEIPD         = 18
+	tagMDC           = 19
+	tagAEADEncrypted = 20 // GnuPG's pre-RFC 9580 AEAD packet
+	tagPadding       = 21
+)
+
+// maxNestingDepth limits compressed packets inside compressed packets
+const maxNestingDepth = 8
+
+var errTruncated = errors.New("openpgp: message is truncated")
+
+// readPacket reads one packet, returning its tag, body and the remaining data
+func readPacket(data []byte) (tag int, body, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errTruncated
+	}
+	hdr := data[0]
+	if hdr&0x80 == 0 {
+		return 0, nil, nil, errors.New("openpgp: invalid packet header")
+	}
+
+	if hdr&0x40 == 0 {
+		// Legacy format: 4-bit tag and a length type
+		tag = int(hdr>>2) & 0x0f
+		data = data[1:]
+		var n int
+		switch hdr & 3 {
+		case 0:
+			n, data = int(data[0]), data[1:]
+		case 1:
+			if len(data) < 2 {
+				return 0, nil, nil, errTruncated
+			}
+			n, data = int(data[0])<<8|int(data[1]), data[2:]
+		case 2:
+			if len(data) < 4 {
+				return 0, nil, nil, errTruncated
+			}
+			n, data = int(uint32(data[0])<<24|uint32(data[1])<<16|uint32(data[2])<<8|uint32(data[3])), data[4:]
+		case 3:
+			// Indeterminate length: the packet extends to the end
+			return tag, data, nil, nil
+		}
+		if n < 0 || n > len(data) {
+			return 0, nil, nil, errTruncated
+		}
+		return tag, data[:n], data[n:], nil
+	}
+
+	// OpenPGP format: 6-bit tag, possibly split into partial body chunks
+	tag = int(hdr & 0x3f)
+	data = data[1:]
+	for {
+		n, partial, remaining, err := readLength(data)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if n > len(remaining) {
+			return 0, nil, nil, errTruncated
+		}
+		if !partial && body == nil {
+			return tag, remaining[:n], remaining[n:], nil
+		}
+		body = append(body, remaining[:n]...)
+		data = remaining[n:]
+		if !partial {
+			return tag, body, data, nil
+		}
+	}
+}
+
+// readLength reads a new-format body length
+func readLength(data []byte) (n int, partial bool, rest []byte, err error) {
+	if len(data) < 1 {
+		return 0, false, nil, errTruncated
+	}
+	switch o := int(data[0]); {
+	case o < 192:
+		return o, false, data[1:], nil
+	case o < 224:
+		if len(data) < 2 {
+			return 0, false, nil, errTruncated
+		}
+		return (o-192)<<8 + int(data[1]) + 192, false, data[2:], nil
+	case o < 255:
+		return 1 << (o & 0x1f), true, data[1:], nil
+	default:
+		if len(data) < 5 {
+			return 0, false, nil, errTruncated
+		}
+		n := int(uint32(data[1])<<24 | uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4]))
+		if n < 0 {
+			return 0, false, nil, errTruncated
+		}
+		return n, false, data[5:], nil
+	}
+}
+
+// readLiteral extracts the contents of the literal data packet in a
+// decrypted message, decompressing and skipping signatures on the way
+func readLiteral(data []byte, depth int) ([]byte, error) {
+	if depth > maxNestingDepth {
+		return nil, errors.New("openpgp: message is nested too deeply")
+	}
+
+	for len(data) > 0 {
+		tag, body, rest, err := readPacket(data)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+
+		switch tag {
+		case tagLiteral:
+			// Format, file name length, file name and a 4-byte date precede the data
+			if len(body) < 2 || len(body) < 2+int(body[1])+4 {
+				return nil, errTruncated
+			}
+			return body[2+int(body[1])+4:], nil
+
+		case tagCompressed:
+			inner, err := decompress(body)
+			if err != nil {
+				return nil, err
+			}
+			return readLiteral(inner, depth+1)
+
+		case tagOnePassSig, tagSignature, tagMarker, tagPadding:
+			// Signatures aren't verified: the message is only password-encrypted
+			continue
+
+		default:
+			return nil, fmt.Errorf("openpgp: unexpected packet %d in message", tag)
+		}
+	}
+
+	return nil, errors.New("openpgp: message has no literal data")
+}
+
+func decompress(body []byte) ([]byte, error) {
+	if len(body) < 1 {
+		return nil, errTruncated
+	}
+
+	var r io.Reader
+	switch algo := body[0]; algo {
+	case 0:
+		return body[1:], nil
+	case 1:
+		r = flate.NewReader(bytes.NewReader(body[1:]))
+	case 2:
+		zr, err := zlib.NewReader(bytes.NewReader(body[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("openpgp: failed to decompress: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case 3:
+		r = bzip2.NewReader(bytes.NewReader(body[1:]))
+	default:
+		return nil, fmt.Errorf("openpgp: unsupported compression algorithm %d", algo)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: failed to decompress: %w", err)
+	}
+	return out, nil
+}
+
+// dearmor decodes an ASCII-armored message; binary input is returned as is
+func dearmor(data []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("-----BEGIN PGP ")) {
+		return data, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 64*1024), len(trimmed)+1)
+
+	// Skip the BEGIN line and armor headers, which end at a blank line
+	scanner.Scan()
+	inHeaders := true
+
+	var b64 strings.Builder
+	var checksum string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case inHeaders:
+			if line == "" {
+				inHeaders = false
+			} else if !strings.Contains(line, ": ") {
+				// No headers at all: this is already base64
+				inHeaders = false
+				b64.WriteString(line)
+			}
+		case strings.HasPrefix(line, "-----END PGP "):
+			return decodeArmorBody(b64.String(), checksum)
+		case strings.HasPrefix(line, "=") && len(line) == 5:
+			checksum = line[1:]
+		default:
+			b64.WriteString(line)
+		}
+	}
+
+	return nil, errors.New("openpgp: armored message has no END line")
+}
+
+func decodeArmorBody(b64, checksum string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: invalid armor: %w", err)
+	}
+
+	// The CRC-24 checksum is optional since RFC 9580
+	if checksum != "" {
+		sum, err := base64.StdEncoding.DecodeString(checksum)
+		if err != nil || len(sum) != 3 {
+			return nil, errors.New("openpgp: invalid armor checksum")
+		}
+		crc := crc24(data)
+		if byte(crc>>16) != sum[0] || byte(crc>>8) != sum[1] || byte(crc) != sum[2] {
+			return nil, errors.New("openpgp: armor checksum mismatch")
+		}
+	}
+	return data, nil
+}
+
+func crc24(data []byte) uint32 {
+	crc := uint32(0xb704ce)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864cfb
+			}
+		}
+	}
+	return crc & 0xffffff
+}
@@ -0,0 +1,154 @@
+package openpgp
+
+import (
+	"crypto"
+	_ "crypto/md5" // Registers the hashes for crypto.Hash.New
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+
+	pcrypto "github.com/r2unit/openpasswd/pkg/crypto"
+)
+
+// String-to-key specifier types (RFC 9580, section 3.7)
+const (
+	s2kSimple   = 0
+	s2kSalted   = 1
+	s2kIterated = 3
+	s2kArgon2   = 4
+)
+
+// s2k is a parsed string-to-key specifier
+type s2k struct {
+	mode   byte
+	hash   crypto.Hash
+	salt   []byte
+	count  int // Iterated: number of bytes hashed
+	time   uint8
+	lanes  uint8
+	memExp uint8 // Argon2: memory is 2^memExp KiB
+}
+
+// parseS2K reads an S2K specifier and returns the bytes after it
+func parseS2K(data []byte) (*s2k, []byte, error) {
+	if len(data) < 1 {
+		return nil, nil, errTruncated
+	}
+	s := &s2k{mode: data[0]}
+
+	switch s.mode {
+	case s2kSimple, s2kSalted, s2kIterated:
+		if len(data) < 2 {
+			return nil, nil, errTruncated
+		}
+		h, err := hashFor(data[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		s.hash = h
+		data = data[2:]
+
+		if s.mode == s2kSimple {
+			return s, data, nil
+		}
+		if len(data) < 8 {
+			return nil, nil, errTruncated
+		}
+		s.salt, data = data[:8], data[8:]
+
+		if s.mode == s2kIterated {
+			if len(data) < 1 {
+				return nil, nil, errTruncated
+			}
+			c := int(data[0])
+			s.count = (16 + c&15) << (c>>4 + 6)
+			data = data[1:]
+		}
+		return s, data, nil
+
+	case s2kArgon2:
+		// 16-byte salt, passes, parallelism and the memory exponent
+		if len(data) < 20 {
+			return nil, nil, errTruncated
+		}
+		s.salt = data[1:17]
+		s.time, s.lanes, s.memExp = data[17], data[18], data[19]
+		if s.time == 0 || s.lanes == 0 || s.memExp > 31 || uint32(1)<<s.memExp < 8*uint32(s.lanes) {
+			return nil, nil, errors.New("openpgp: invalid Argon2 parameters")
+		}
+		return s, data[20:], nil
+
+	default:
+		return nil, nil, fmt.Errorf("openpgp: unsupported S2K type %d", s.mode)
+	}
+}
+
+// deriveKey turns the passphrase into a key of the given size
+func (s *s2k) deriveKey(passphrase []byte, size int) []byte {
+	if s.mode == s2kArgon2 {
+		return pcrypto.Argon2idKey(passphrase, s.salt, pcrypto.Argon2Params{
+			Time:        uint32(s.time),
+			Memory:      uint32(1) << s.memExp,
+			Parallelism: s.lanes,
+			KeyLen:      uint32(size),
+		})
+	}
+
+	// Keys longer than the hash use more hash contexts, each preloaded with
+	// one more zero byte than the last
+	key := make([]byte, 0, size)
+	for i := 0; len(key) < size; i++ {
+		h := s.hash.New()
+		h.Write(make([]byte, i))
+		s.hashInput(h, passphrase)
+		key = h.Sum(key)
+	}
+	return key[:size]
+}
+
+func (s *s2k) hashInput(h hash.Hash, passphrase []byte) {
+	switch s.mode {
+	case s2kSimple:
+		h.Write(passphrase)
+	case s2kSalted:
+		h.Write(s.salt)
+		h.Write(passphrase)
+	case s2kIterated:
+		// Hash salt||passphrase repeatedly until count bytes have been
+		// hashed, but always at least once completely
+		input := append(append([]byte{}, s.salt...), passphrase...)
+		defer pcrypto.WipeMemory(input)
+
+		count := s.count
+		if count < len(input) {
+			count = len(input)
+		}
+		for count > len(input) {
+			h.Write(input)
+			count -= len(input)
+		}
+		h.Write(input[:count])
+	}
+}
+
+func hashFor(id byte) (crypto.Hash, error) {
+	switch id {
+	case 1:
+		return crypto.MD5, nil
+	case 2:
+		return crypto.SHA1, nil
+	case 8:
+		return crypto.SHA256, nil
+	case 9:
+		return crypto.SHA384, nil
+	case 10:
+		return crypto.SHA512, nil
+	case 11:
+		return crypto.SHA224, nil
+	default:
+		return 0, fmt.Errorf("openpgp: unsupported hash algorithm %d", id)
+	}
+}
@@ -0,0 +1,227 @@
+package passwordstore
+
+// Package passwordstore imports password-store (pass) and gopass trees.
+//
+// Every secret is a GPG-encrypted file under the store directory, e.g.
+// ~/.password-store/work/github.com.gpg. By convention the first line of a
+// file is the password and further lines are "key: value" pairs (login:,
+// url:, ...) or free text; an otpauth:// line holds the TOTP secret, as
+// written by the pass-otp extension. gopass may separate the password from
+// a YAML body with a "---" line.
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/importutil"
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/openpgp"
+)
+
+// Importer handles imports from a password-store directory
+type Importer struct {
+	// Decrypter decrypts the store's files; nil uses openpgp.Default()
+	Decrypter openpgp.Decrypter
+
+	skipped []string
+}
+
+func (p *Importer) GetName() string {
+	return "pass / gopass"
+}
+
+func (p *Importer) GetDescription() string {
+	return "Import a password-store directory such as ~/.password-store (uses your GPG key)"
+}
+
+// SupportsFormat accepts a store directory (no extension) or a single .gpg file
+func (p *Importer) SupportsFormat(format string) bool {
+	format = strings.ToLower(format)
+	return format == "" || format == ".gpg"
+}
+
+// NeedsPassphrase is always false: files are encrypted to the user's GPG
+// key, which gpg-agent unlocks
+func (p *Importer) NeedsPassphrase(filePath string) bool {
+	return false
+}
+
+// Import decrypts every .gpg file under the directory. The passphrase, if
+// given, is used to unlock the GPG key instead of gpg-agent's prompt. Files
+// that can't be read or decrypted, e.g. ones encrypted to another key, are
+// left out and listed by Skipped; the import fails only if none could be.
+func (p *Importer) Import(filePath string, passphrase string) ([]*models.Password, error) {
+	p.skipped = nil
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open password store: %w", err)
+	}
+
+	if !info.IsDir() {
+		pwd, err := p.importFile(filePath, filepath.Base(filePath), passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.Password{pwd}, nil
+	}
+
+	var passwords []*models.Password
+	err = filepath.WalkDir(filePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip .git, .extensions and other hidden directories
+		if d.IsDir() {
+			if path != filePath && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".gpg") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(filePath, path)
+		if err != nil {
+			return err
+		}
+
+		pwd, err := p.importFile(path, filepath.ToSlash(rel), passphrase)
+		if err != nil {
+			p.skipped = append(p.skipped, err.Error())
+			return nil
+		}
+		passwords = append(passwords, pwd)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passwords) == 0 && len(p.skipped) > 0 {
+		return nil, fmt.Errorf("no secret in the store could be imported: %s", p.skipped[0])
+	}
+	return passwords, nil
+}
+
+// Skipped lists the files the last Import left out and why
+func (p *Importer) Skipped() []string {
+	return p.skipped
+}
+
+// importFile decrypts one secret; rel is its slash-separated path in the store
+func (p *Importer) importFile(path, rel, passphrase string) (*models.Password, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rel, err)
+	}
+
+	decrypter := p.Decrypter
+	if decrypter == nil {
+		decrypter = openpgp.Default()
+	}
+
+	plaintext, err := decrypter.Decrypt(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", rel, err)
+	}
+	defer crypto.WipeMemory(plaintext)
+
+	pwd := parseSecret(plaintext)
+
+	// The path becomes the folder and the file name the entry name
+	rel = strings.TrimSuffix(rel, ".gpg")
+	pwd.Name = rel
+	if i := strings.LastIndex(rel, "/"); i >= 0 {
+		pwd.Name = rel[i+1:]
+		pwd.Fields[models.FieldFolder] = rel[:i]
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		pwd.CreatedAt = info.ModTime()
+		pwd.UpdatedAt = info.ModTime()
+	}
+
+	return pwd, nil
+}
+
+// parseSecret parses the decrypted contents of a secret
+func parseSecret(data []byte) *models.Password {
+	pwd := &models.Password{
+		Type:   models.TypeLogin,
+		Fields: make(map[string]string),
+	}
+
+	lines := strings.Split(string(bytes.TrimRight(data, "\r\n")), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+
+	pwd.Password = lines[0]
+
+	var notes []string
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "---" && len(notes) == 0:
+			// gopass separator between the password and its YAML body
+			continue
+		case strings.HasPrefix(trimmed, "otpauth://"):
+			if _, ok := pwd.Fields[models.FieldTOTP]; !ok {
+				pwd.Fields[models.FieldTOTP] = trimmed
+				continue
+			}
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") || strings.HasPrefix(value, "//") {
+			// Free text (URLs without a key contain a colon too)
+			notes = append(notes, line)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "login", "username", "user":
+			if pwd.Username == "" {
+				pwd.Username = value
+				continue
+			}
+		case "email":
+			if pwd.Username == "" {
+				pwd.Username = value
+			}
+		case "url", "website", "site", "link":
+			if pwd.URL == "" {
+				pwd.URL = value
+				continue
+			}
+		case "otp", "totp":
+			if _, ok := pwd.Fields[models.FieldTOTP]; !ok && value != "" {
+				pwd.Fields[models.FieldTOTP] = value
+				continue
+			}
+		}
+
+		if value != "" {
+			pwd.Fields[importutil.UniqueKey(pwd.Fields, key)] = value
+		}
+	}
+
+	pwd.Notes = strings.TrimSpace(strings.Join(notes, "\n"))
+
+	if pwd.Password == "" && pwd.Username == "" && pwd.URL == "" && pwd.Notes != "" {
+		pwd.Type = models.TypeNote
+	}
+
+	return pwd
+}
@@ -0,0 +1,90 @@
+package passwordstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// plainDecrypter "decrypts" files holding plain text and fails on the rest,
+// like a store with secrets encrypted to someone else's key
+type plainDecrypter struct{}
+
+func (plainDecrypter) Decrypt(data []byte, passphrase string) ([]byte, error) {
+	if s, ok := strings.CutPrefix(string(data), "plain:"); ok {
+		return []byte(s), nil
+	}
+	return nil, errors.New("no secret key")
+}
+
+func writeStore(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImportSkipsUndecryptable(t *testing.T) {
+	dir := writeStore(t, map[string]string{
+		"work/github.com.gpg": "plain:s3cret\nlogin: alice\nurl: https://github.com\n",
+		"shared/team.gpg":     "encrypted to another key",
+		".git/config":         "not a secret",
+	})
+
+	importer := &Importer{Decrypter: plainDecrypter{}}
+	passwords, err := importer.Import(dir, "")
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if len(passwords) != 1 {
+		t.Fatalf("got %d entries, want 1", len(passwords))
+	}
+	pwd := passwords[0]
+	if pwd.Name != "github.com" || pwd.Password != "s3cret" || pwd.Username != "alice" ||
+		pwd.URL != "https://github.com" || pwd.Fields[models.FieldFolder] != "work" {
+		t.Errorf("entry = %+v", pwd)
+	}
+
+	skipped := importer.Skipped()
+	if len(skipped) != 1 || !strings.Contains(skipped[0], "shared/team.gpg") {
+		t.Errorf("skipped = %q, want shared/team.gpg", skipped)
+	}
+}
+
+func TestImportNothingDecrypts(t *testing.T) {
+	dir := writeStore(t, map[string]string{
+		"a.gpg": "encrypted to another key",
+		"b.gpg": "encrypted to another key",
+	})
+
+	importer := &Importer{Decrypter: plainDecrypter{}}
+	if _, err := importer.Import(dir, ""); err == nil {
+		t.Error("Import succeeded without a single readable secret")
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+	pwd := parseSecret([]byte("pw\n---\nusername: bob\nemail: bob@example.com\notpauth://totp/x?secret=ABC\nfree text\n"))
+
+	if pwd.Password != "pw" || pwd.Username != "bob" || pwd.Fields["email"] != "bob@example.com" ||
+		pwd.Fields[models.FieldTOTP] != "otpauth://totp/x?secret=ABC" || pwd.Notes != "free text" {
+		t.Errorf("parsed = %+v", pwd)
+	}
+
+	if note := parseSecret([]byte("\nsome notes")); note.Type != models.TypeNote {
+		t.Errorf("type = %s, want note", note.Type)
+	}
+}
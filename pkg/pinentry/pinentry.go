@@ -0,0 +1,231 @@
+package pinentry
+
+// Package pinentry asks for a passphrase through a GnuPG pinentry program.
+//
+// pinentry speaks a small subset of the Assuan protocol over stdin/stdout:
+// the client sends commands (SETDESC, SETPROMPT, GETPIN, ...) and the
+// program answers with data lines ("D ...") followed by "OK", or "ERR".
+// See https://www.gnupg.org/documentation/manuals/assuan/ for details.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+)
+
+// DefaultProgram is the pinentry executable looked up in PATH
+const DefaultProgram = "pinentry"
+
+// Assuan error code (GPG_ERR_CANCELED in the pinentry source) sent when the
+// user closes the dialog
+const errCodeCanceled = 99
+
+// ErrCanceled is returned when the user cancels the pinentry dialog
+var ErrCanceled = errors.New("pinentry: operation canceled")
+
+// Request describes the dialog shown to the user
+type Request struct {
+	Title       string
+	Description string
+	Prompt      string
+	Error       string // Shown when asking again after a wrong passphrase
+}
+
+// GetPIN runs the pinentry program and returns the entered passphrase.
+// An empty program name uses DefaultProgram.
+func GetPIN(program string, req Request) (*crypto.SecureString, error) {
+	if program == "" {
+		program = DefaultProgram
+	}
+
+	cmd := exec.Command(program)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", program, err)
+	}
+	defer func() {
+		stdin.Close()
+		_ = cmd.Wait()
+	}()
+
+	c := &conn{w: stdin, r: bufio.NewReader(stdout)}
+
+	// The server greets with "OK" before accepting commands
+	if _, err := c.response(); err != nil {
+		return nil, err
+	}
+
+	if err := c.setOptions(req); err != nil {
+		return nil, err
+	}
+
+	data, err := c.command("GETPIN")
+	defer crypto.WipeMemory(data)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = c.send("BYE")
+
+	return crypto.NewSecureString(string(data))
+}
+
+// conn is a client connection to an Assuan server
+type conn struct {
+	w io.Writer
+	r *bufio.Reader
+}
+
+func (c *conn) setOptions(req Request) error {
+	var commands []string
+
+	if tty := ttyName(); tty != "" {
+		commands = append(commands, "OPTION ttyname="+tty)
+	}
+	if term := os.Getenv("TERM"); term != "" {
+		commands = append(commands, "OPTION ttytype="+term)
+	}
+	if req.Title != "" {
+		commands = append(commands, "SETTITLE "+escape(req.Title))
+	}
+	if req.Description != "" {
+		commands = append(commands, "SETDESC "+escape(req.Description))
+	}
+	if req.Prompt != "" {
+		commands = append(commands, "SETPROMPT "+escape(req.Prompt))
+	}
+	if req.Error != "" {
+		commands = append(commands, "SETERROR "+escape(req.Error))
+	}
+
+	for _, command := range commands {
+		if _, err := c.command(command); err != nil {
+			// Older pinentries reject some options; only the dialog text matters
+			if strings.HasPrefix(command, "OPTION ") {
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// command sends a command and returns the data lines of the response
+func (c *conn) command(line string) ([]byte, error) {
+	if err := c.send(line); err != nil {
+		return nil, err
+	}
+	return c.response()
+}
+
+func (c *conn) send(line string) error {
+	_, err := io.WriteString(c.w, line+"\n")
+	return err
+}
+
+// response reads lines until OK or ERR, collecting the decoded data lines
+func (c *conn) response() ([]byte, error) {
+	var data []byte
+
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			crypto.WipeMemory(data)
+			return nil, fmt.Errorf("pinentry: unexpected end of response: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "OK" || strings.HasPrefix(line, "OK "):
+			return data, nil
+
+		case strings.HasPrefix(line, "ERR "):
+			crypto.WipeMemory(data)
+			return nil, parseError(strings.TrimPrefix(line, "ERR "))
+
+		case strings.HasPrefix(line, "D "):
+			data = append(data, unescape(line[2:])...)
+
+		case strings.HasPrefix(line, "INQUIRE "):
+			// Nothing is ever inquired for GETPIN; refuse so the server moves on
+			if err := c.send("CAN"); err != nil {
+				return nil, err
+			}
+
+		default:
+			// Status ("S ...") and comment ("# ...") lines carry nothing we need
+		}
+	}
+}
+
+// parseError turns "ERR <code> <description>" into an error
+func parseError(rest string) error {
+	codeStr, desc, _ := strings.Cut(rest, " ")
+
+	code, err := strconv.ParseUint(codeStr, 10, 32)
+	if err == nil && code&0xFFFF == errCodeCanceled {
+		return ErrCanceled
+	}
+
+	return fmt.Errorf("pinentry: %s", unescape(desc))
+}
+
+// escape percent-encodes the characters Assuan reserves in a line
+func escape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '%', '\r', '\n':
+			fmt.Fprintf(&sb, "%%%02X", ch)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+// unescape decodes %XX sequences in a data line
+func unescape(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				out = append(out, byte(b))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, s[i])
+	}
+	return out
+}
+
+// ttyName returns the terminal pinentry-curses should draw on
+func ttyName() string {
+	if tty := os.Getenv("GPG_TTY"); tty != "" {
+		return tty
+	}
+	if link, err := os.Readlink("/proc/self/fd/0"); err == nil &&
+		(strings.HasPrefix(link, "/dev/pts/") || strings.HasPrefix(link, "/dev/tty")) {
+		return link
+	}
+	return ""
+}
@@ -7,15 +7,19 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/openpgp"
 )
 
 // Importer handles imports from Proton Pass
-type Importer struct{}
+type Importer struct {
+	// Decrypter decrypts PGP-encrypted exports; nil uses openpgp.Default()
+	Decrypter openpgp.Decrypter
+}
 
 // Item represents an item from Proton Pass JSON export
 type Item struct {
@@ -92,30 +96,18 @@ func (p *Importer) importFromZip(filePath string, passphrase string) ([]*models.
 			// Parse JSON data
 			return p.parseJSON(data)
 		} else if strings.HasSuffix(f.Name, "data.pgp") {
-			if passphrase == "" {
-				return nil, fmt.Errorf("passphrase required for encrypted export")
-			}
-
-			// Extract PGP file to temp location
 			rc, err := f.Open()
 			if err != nil {
 				return nil, fmt.Errorf("failed to open PGP file in ZIP: %w", err)
 			}
 			defer rc.Close()
 
-			tmpFile, err := os.CreateTemp("", "protonpass-*.pgp")
+			data, err := io.ReadAll(rc)
 			if err != nil {
-				return nil, fmt.Errorf("failed to create temp file: %w", err)
+				return nil, fmt.Errorf("failed to read PGP file in ZIP: %w", err)
 			}
-			defer os.Remove(tmpFile.Name())
-			defer tmpFile.Close()
 
-			if _, err := io.Copy(tmpFile, rc); err != nil {
-				return nil, fmt.Errorf("failed to write temp file: %w", err)
-			}
-			tmpFile.Close()
-
-			return p.importFromPGP(tmpFile.Name(), passphrase)
+			return p.decryptPGP(data, passphrase)
 		}
 	}
 
@@ -297,25 +289,30 @@ func (p *Importer) importFromCSV(filePath string) ([]*models.Password, error) {
 }
 
 func (p *Importer) importFromPGP(filePath string, passphrase string) ([]*models.Password, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP file: %w", err)
+	}
+
+	return p.decryptPGP(data, passphrase)
+}
+
+// decryptPGP decrypts an encrypted export in memory and parses the JSON inside
+func (p *Importer) decryptPGP(data []byte, passphrase string) ([]*models.Password, error) {
 	if passphrase == "" {
 		return nil, fmt.Errorf("passphrase required for encrypted PGP file")
 	}
 
-	// Create temp file for decrypted output
-	tmpFile, err := os.CreateTemp("", "protonpass-decrypted-*.json")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	decrypter := p.Decrypter
+	if decrypter == nil {
+		decrypter = openpgp.Default()
 	}
-	defer os.Remove(tmpFile.Name())
-	tmpFile.Close()
 
-	// Try to decrypt using gpg
-	cmd := exec.Command("gpg", "--decrypt", "--batch", "--yes", "--passphrase", passphrase, "--output", tmpFile.Name(), filePath)
-	output, err := cmd.CombinedOutput()
+	plaintext, err := decrypter.Decrypt(data, passphrase)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt PGP file: %s: %w", string(output), err)
+		return nil, fmt.Errorf("failed to decrypt PGP file: %w", err)
 	}
+	defer crypto.WipeMemory(plaintext)
 
-	// Read and parse decrypted JSON
-	return p.importFromJSON(tmpFile.Name())
+	return p.parseJSON(plaintext)
 }
@@ -16,7 +16,7 @@ import (
 //   - JSON (unencrypted)
 //   - CSV (simple format)
 //   - ZIP (containing JSON or PGP-encrypted data)
-//   - PGP (encrypted, requires gpg installed)
+//   - PGP (encrypted, decrypted in memory)
 //
 // How to use:
 //  1. Export from Proton Pass: Settings → Export
@@ -0,0 +1,91 @@
+package secretref
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Mask replaces secrets in masked output
+const Mask = "<concealed by openpasswd>"
+
+// minMaskLength keeps very short values (a "1" or "yes" flag) from being
+// masked everywhere they appear in the output
+const minMaskLength = 4
+
+// Masker is a writer that replaces secrets before passing output on. Bytes
+// that could be the start of a secret are held back until the next write
+// or Flush, so secrets split across writes are caught too.
+type Masker struct {
+	w       io.Writer
+	secrets [][]byte
+
+	mu      sync.Mutex
+	pending []byte
+}
+
+// NewMasker masks secrets in everything written to w
+func NewMasker(w io.Writer, secrets []string) *Masker {
+	m := &Masker{w: w}
+	for _, s := range secrets {
+		if len(s) >= minMaskLength {
+			m.secrets = append(m.secrets, []byte(s))
+		}
+	}
+
+	// Longer secrets first, so one that contains another is masked whole
+	sort.Slice(m.secrets, func(i, j int) bool {
+		return len(m.secrets[i]) > len(m.secrets[j])
+	})
+	return m
+}
+
+// Write masks p and writes what can't be part of a secret any more
+func (m *Masker) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = append(m.pending, p...)
+	for _, s := range m.secrets {
+		m.pending = bytes.ReplaceAll(m.pending, s, []byte(Mask))
+	}
+
+	keep := m.partialSecret()
+	if err := m.emit(len(m.pending) - keep); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the held back bytes, at the end of the output
+func (m *Masker) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.emit(len(m.pending))
+}
+
+// emit writes the first n pending bytes
+func (m *Masker) emit(n int) error {
+	if n <= 0 {
+		return nil
+	}
+	_, err := m.w.Write(m.pending[:n])
+	m.pending = append(m.pending[:0], m.pending[n:]...)
+	return err
+}
+
+// partialSecret returns the length of the longest end of the pending bytes
+// that a secret starts with
+func (m *Masker) partialSecret() int {
+	longest := 0
+	for _, s := range m.secrets {
+		for n := min(len(s)-1, len(m.pending)); n > longest; n-- {
+			if bytes.HasSuffix(m.pending, s[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
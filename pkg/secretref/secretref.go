@@ -0,0 +1,167 @@
+// Package secretref resolves secret references of the form
+// openpasswd://<vault>/<item>/<field> against the vault, for 'openpass run'
+// and 'openpass inject'.
+//
+// There is a single vault, so <vault> names the folder an entry belongs to
+// (the 1Password vault, Bitwarden folder or KeePass group it was imported
+// from); "default" stands for the whole vault. <item> is looked up exactly
+// like 'openpass get' does and <field> takes the same names as --field. The
+// field can be left out for the password. Segments with "/" or spaces in
+// them are percent-encoded.
+package secretref
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+// Scheme starts every reference
+const Scheme = "openpasswd://"
+
+// DefaultVault stands for every entry, whatever its folder
+const DefaultVault = "default"
+
+// refPattern finds references in text: bare, or wrapped in {{ }} as in
+// templates. A bare reference ends at whitespace, a quote or a bracket.
+var refPattern = regexp.MustCompile(`\{\{\s*(openpasswd://[^\s{}]+)\s*\}\}|openpasswd://[^\s"'<>(){}\[\]` + "`" + `]+`)
+
+// Ref is a parsed secret reference
+type Ref struct {
+	Vault string
+	Item  string
+	Field string
+}
+
+// Parse parses a single reference
+func Parse(s string) (Ref, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), Scheme)
+	if !ok {
+		return Ref{}, fmt.Errorf("not a secret reference: %q", s)
+	}
+
+	parts := strings.Split(rest, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Ref{}, fmt.Errorf("invalid secret reference %q: expected %s<vault>/<item>/<field>", s, Scheme)
+	}
+
+	for i, part := range parts {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil {
+			return Ref{}, fmt.Errorf("invalid secret reference %q: %w", s, err)
+		}
+		parts[i] = unescaped
+	}
+
+	ref := Ref{Vault: parts[0], Item: parts[1]}
+	if len(parts) == 3 {
+		ref.Field = parts[2]
+	}
+	if ref.Vault == "" || ref.Item == "" {
+		return Ref{}, fmt.Errorf("invalid secret reference %q: vault and item can't be empty", s)
+	}
+	return ref, nil
+}
+
+// String returns the reference in its canonical form
+func (r Ref) String() string {
+	s := Scheme + url.PathEscape(r.Vault) + "/" + url.PathEscape(r.Item)
+	if r.Field != "" {
+		s += "/" + url.PathEscape(r.Field)
+	}
+	return s
+}
+
+// Contains reports whether text holds at least one reference
+func Contains(text string) bool {
+	return strings.Contains(text, Scheme)
+}
+
+// Resolver resolves references against a decrypted list of entries and
+// remembers every value it handed out, for masking
+type Resolver struct {
+	store  vault.Store
+	items  []*vault.Item
+	values map[string]string
+}
+
+// NewResolver resolves against items, as loaded from store. The store saves
+// HOTP entries, whose counter advances with every code handed out; it may be
+// nil when there are no items.
+func NewResolver(store vault.Store, items []*vault.Item) *Resolver {
+	return &Resolver{store: store, items: items, values: make(map[string]string)}
+}
+
+// Resolve returns the value a reference points at
+func (r *Resolver) Resolve(ref Ref) (string, error) {
+	key := ref.String()
+	if value, ok := r.values[key]; ok {
+		return value, nil
+	}
+
+	items := r.items
+	if !strings.EqualFold(ref.Vault, DefaultVault) {
+		items = nil
+		for _, item := range r.items {
+			if strings.EqualFold(item.Fields[models.FieldFolder], ref.Vault) {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			return "", fmt.Errorf("%s: no entries in vault %q", key, ref.Vault)
+		}
+	}
+
+	item, err := vault.Find(items, ref.Item, vault.MatchDefault)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+
+	value, err := vault.FieldValue(r.store, item, ref.Field)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+
+	r.values[key] = value
+	return value, nil
+}
+
+// Render replaces every reference in text with its value
+func (r *Resolver) Render(text string) (string, error) {
+	var errs []error
+	out := refPattern.ReplaceAllStringFunc(text, func(match string) string {
+		raw := match
+		if sub := refPattern.FindStringSubmatch(match); sub[1] != "" {
+			raw = sub[1]
+		}
+
+		ref, err := Parse(raw)
+		if err == nil {
+			var value string
+			if value, err = r.Resolve(ref); err == nil {
+				return value
+			}
+		}
+		errs = append(errs, err)
+		return match
+	})
+
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return out, nil
+}
+
+// Values returns every value resolved so far
+func (r *Resolver) Values() []string {
+	values := make([]string, 0, len(r.values))
+	for _, value := range r.values {
+		values = append(values, value)
+	}
+	return values
+}
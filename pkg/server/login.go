@@ -0,0 +1,248 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"unicode"
+
+	"github.com/r2unit/openpasswd/pkg/auth"
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/srp"
+)
+
+// loginTimeout is how long a started login waits for the client's proof
+const loginTimeout = 2 * time.Minute
+
+// maxUsernameLength bounds usernames
+const maxUsernameLength = 128
+
+// errLoginFailed is the answer for every failed login, so it doesn't tell
+// whether the username exists
+var errLoginFailed = errors.New("invalid username or passphrase")
+
+// pendingLogin is a login between its two round trips
+type pendingLogin struct {
+	username  string
+	srp       *srp.Server
+	decoy     bool
+	expiresAt time.Time
+}
+
+// validUsername accepts 1 to maxUsernameLength printable characters
+func validUsername(username string) bool {
+	if username == "" || len(username) > maxUsernameLength {
+		return false
+	}
+	for _, c := range username {
+		if !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// decoyAccount makes up stable parameters for unknown usernames, so login
+// answers look the same whether an account exists or not. It costs about as
+// much as loading a real account, so timing doesn't tell them apart either.
+func (s *Server) decoyAccount(username string) *Account {
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, s.decoyKey)
+		mac.Write([]byte(label))
+		mac.Write([]byte(username))
+		return mac.Sum(nil)
+	}
+
+	return &Account{
+		KDFVersion: crypto.KDFVersionArgon2id,
+		KDFSalt:    derive("kdf salt"),
+		SRPSalt:    derive("srp salt"),
+		Verifier:   srp.DecoyVerifier(derive("srp verifier")),
+	}
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Username        string `json:"username"`
+		RegistrationKey string `json:"registration_key"`
+		KDFVersion      int    `json:"kdf_version"`
+		KDFSalt         []byte `json:"kdf_salt"`
+		SRPSalt         []byte `json:"srp_salt"`
+		Verifier        []byte `json:"verifier"`
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBlobSize)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if s.registrationKey != "" && subtle.ConstantTimeCompare([]byte(req.RegistrationKey), []byte(s.registrationKey)) != 1 {
+		http.Error(w, "Invalid registration key", http.StatusUnauthorized)
+		return
+	}
+
+	if !validUsername(req.Username) {
+		http.Error(w, "Invalid username", http.StatusBadRequest)
+		return
+	}
+	if !crypto.ValidSyncKDFVersion(req.KDFVersion) {
+		http.Error(w, "Unsupported KDF version", http.StatusBadRequest)
+		return
+	}
+	if len(req.KDFSalt) < 16 || len(req.SRPSalt) < 16 || len(req.Verifier) == 0 {
+		http.Error(w, "Invalid key parameters", http.StatusBadRequest)
+		return
+	}
+
+	err := s.store.Register(req.Username, &Account{
+		KDFVersion: req.KDFVersion,
+		KDFSalt:    req.KDFSalt,
+		SRPSalt:    req.SRPSalt,
+		Verifier:   req.Verifier,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if errors.Is(err, ErrRegistered) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Info("account registered", "username", req.Username)
+	writeJSON(w, http.StatusCreated, map[string]string{"status": "registered"})
+}
+
+// handleLoginStart is the first SRP round trip: it takes the username and A
+// and answers with the KDF parameters, the SRP salt and B
+func (s *Server) handleLoginStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		A        []byte `json:"a"`
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBlobSize)).Decode(&req); err != nil || !validUsername(req.Username) {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	decoy := false
+	account, err := s.store.Account(req.Username)
+	if errors.Is(err, ErrNotRegistered) {
+		decoy = true
+		account, err = s.decoyAccount(req.Username), nil
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	exchange, err := srp.NewServer(req.Username, account.SRPSalt, account.Verifier, req.A)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	loginID := hex.EncodeToString(id)
+
+	now := time.Now()
+	s.mu.Lock()
+	for k, l := range s.logins {
+		if now.After(l.expiresAt) {
+			delete(s.logins, k)
+		}
+	}
+	s.logins[loginID] = &pendingLogin{
+		username:  req.Username,
+		srp:       exchange,
+		decoy:     decoy,
+		expiresAt: now.Add(loginTimeout),
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"login_id":    loginID,
+		"kdf_version": account.KDFVersion,
+		"kdf_salt":    account.KDFSalt,
+		"srp_salt":    account.SRPSalt,
+		"b":           exchange.PublicKey(),
+	})
+}
+
+// handleLoginFinish checks the client's proof M1 and answers with M2. The
+// session token is derived from the shared key on both sides, so it isn't
+// sent.
+func (s *Server) handleLoginFinish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		LoginID string `json:"login_id"`
+		M1      []byte `json:"m1"`
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBlobSize)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// A login gets one attempt at the proof
+	s.mu.Lock()
+	login, ok := s.logins[req.LoginID]
+	delete(s.logins, req.LoginID)
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(login.expiresAt) {
+		http.Error(w, "Unknown or expired login", http.StatusUnauthorized)
+		return
+	}
+
+	m2, err := login.srp.Verify(req.M1)
+	if err != nil || login.decoy {
+		s.logger.Warn("login failed", "username", login.username, "remote", r.RemoteAddr)
+		http.Error(w, errLoginFailed.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	token := auth.TokenFromKey(login.srp.Key())
+	expiresAt := time.Now().Add(s.sessionTTL)
+	tokenHash := auth.HashToken(token)
+
+	s.mu.Lock()
+	s.sessions[tokenHash] = &auth.Session{
+		Token:     token,
+		Username:  login.username,
+		ExpiresAt: expiresAt,
+	}
+	s.mu.Unlock()
+
+	s.logger.Info("session created", "username", login.username, "expires_at", expiresAt.Format(time.RFC3339))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"m2":         m2,
+		"expires_at": expiresAt,
+	})
+}
@@ -1,62 +1,204 @@
 package server
 
-// This package provides HTTP server functionality for syncing passwords across devices
+// This package provides the HTTP sync server for syncing passwords across
+// devices.
+//
+// The server is zero-knowledge: clients encrypt every item before upload
+// (see pkg/client) and the server only stores the resulting blobs with a
+// version each, for conflict detection. The passphrase never reaches it.
+// Accounts log in with SRP-6a (see pkg/srp) using an auth key the client
+// derives from the passphrase, so the server holds only a verifier, and the
+// session token is derived from the key both sides prove to each other.
 //
 // Planned features:
-// - End-to-end encryption with client-side keys
-// - RESTful API for password CRUD operations
-// - Session management with token-based authentication
-// - Conflict resolution for simultaneous edits
 // - WebSocket support for real-time sync
-//
-// Security considerations:
-// - Zero-knowledge architecture (server never sees plaintext)
-// - TLS/SSL required for all connections
 // - Rate limiting to prevent brute force attacks
 // - Audit logging for all operations
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/r2unit/openpasswd/pkg/auth"
-	"github.com/r2unit/openpasswd/pkg/crypto"
-	"github.com/r2unit/openpasswd/pkg/database"
-	"github.com/r2unit/openpasswd/pkg/models"
 )
 
+// DefaultSessionTTL is how long a login token stays valid by default
+const DefaultSessionTTL = 24 * time.Hour
+
+// maxBlobSize bounds request bodies; items are small
+const maxBlobSize = 1 << 20
+
+// HTTP timeouts; requests are small, so slow clients are cut off early
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+	shutdownTimeout   = 10 * time.Second
+)
+
+// Options configures a Server
+type Options struct {
+	// RegistrationKey has to be given to register an account when set;
+	// otherwise anyone can register
+	RegistrationKey string
+	// SessionTTL is how long login tokens stay valid, DefaultSessionTTL
+	// when zero
+	SessionTTL time.Duration
+	// Logger receives access and event logs, slog.Default() when nil
+	Logger *slog.Logger
+}
+
 type Server struct {
-	db        *database.DB
-	salt      []byte
-	sessions  map[string]*auth.Session
-	mu        sync.RWMutex
-	masterKey string
+	store           *Store
+	sessions        map[string]*auth.Session
+	logins          map[string]*pendingLogin
+	mu              sync.RWMutex
+	registrationKey string
+	sessionTTL      time.Duration
+	logger          *slog.Logger
+	decoyKey        []byte
 }
 
-func New(db *database.DB, salt []byte, masterKey string) *Server {
+// New creates a server for the store
+func New(store *Store, opts Options) *Server {
+	decoyKey := make([]byte, 32)
+	_, _ = rand.Read(decoyKey)
+
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = DefaultSessionTTL
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
 	return &Server{
-		db:        db,
-		salt:      salt,
-		sessions:  make(map[string]*auth.Session),
-		masterKey: masterKey,
+		store:           store,
+		sessions:        make(map[string]*auth.Session),
+		logins:          make(map[string]*pendingLogin),
+		registrationKey: opts.RegistrationKey,
+		sessionTTL:      opts.SessionTTL,
+		logger:          opts.Logger,
+		decoyKey:        decoyKey,
+	}
+}
+
+// Handler returns the API on its own ServeMux, wrapped in access logging
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/register", s.handleRegister)
+	mux.HandleFunc("/api/auth/login/start", s.handleLoginStart)
+	mux.HandleFunc("/api/auth/login/finish", s.handleLoginFinish)
+	mux.HandleFunc("/api/auth/logout", s.handleLogout)
+	mux.HandleFunc("/api/items", s.handleItems)
+	mux.HandleFunc("/api/items/", s.handleItem)
+	mux.HandleFunc("/api/health", s.handleHealth)
+	return s.accessLog(mux)
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled, then shuts
+// down gracefully, letting requests in flight finish. A nil tlsConfig
+// serves plain HTTP.
+func (s *Server) ListenAndServe(ctx context.Context, addr string, tlsConfig *tls.Config) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
+
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		ErrorLog:          slog.NewLogLogger(s.logger.Handler(), slog.LevelWarn),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			errs <- srv.ServeTLS(listener, "", "")
+		} else {
+			errs <- srv.Serve(listener)
+		}
+	}()
+	s.logger.Info("server started", "addr", listener.Addr().String(), "tls", tlsConfig != nil)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+	return nil
+}
+
+// statusRecorder remembers the status and size of a response for the
+// access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += n
+	return n, err
+}
+
+// accessLog logs one line per request. Only the path is logged; tokens and
+// bodies never are.
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		s.logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start).Round(time.Microsecond).String(),
+			"remote", r.RemoteAddr,
+		)
+	})
 }
 
-func (s *Server) Start(port string) error {
-	http.HandleFunc("/api/auth/login", s.handleLogin)
-	http.HandleFunc("/api/auth/logout", s.handleLogout)
-	http.HandleFunc("/api/passwords", s.handlePasswords)
-	http.HandleFunc("/api/passwords/", s.handlePassword)
-	http.HandleFunc("/api/passwords/search", s.handleSearch)
-	http.HandleFunc("/api/health", s.handleHealth)
-
-	addr := ":" + port
-	fmt.Printf("Server starting on %s\n", addr)
-	return http.ListenAndServe(addr, nil)
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
 }
 
 func (s *Server) authenticate(r *http.Request) (*auth.Session, error) {
@@ -91,73 +233,7 @@ func (s *Server) authenticate(r *http.Request) (*auth.Session, error) {
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-}
-
-func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var req struct {
-		Passphrase string `json:"passphrase"`
-		MasterKey  string `json:"master_key"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
-	}
-
-	if req.MasterKey != s.masterKey {
-		http.Error(w, "Invalid master key", http.StatusUnauthorized)
-		return
-	}
-
-	encryptor := crypto.NewEncryptor(req.Passphrase, s.salt)
-	testPassword := &models.Password{
-		ID:       -1,
-		Name:     "test",
-		Username: "test",
-		Password: "test",
-	}
-
-	encrypted, err := encryptor.Encrypt(testPassword.Password)
-	if err != nil {
-		http.Error(w, "Encryption test failed", http.StatusInternalServerError)
-		return
-	}
-
-	if _, err := encryptor.Decrypt(encrypted); err != nil {
-		http.Error(w, "Invalid passphrase", http.StatusUnauthorized)
-		return
-	}
-
-	token, err := auth.GenerateToken()
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		return
-	}
-
-	expiresAt := time.Now().Add(24 * time.Hour)
-	tokenHash := auth.HashToken(token)
-
-	s.mu.Lock()
-	s.sessions[tokenHash] = &auth.Session{
-		Token:      token,
-		Passphrase: req.Passphrase,
-		ExpiresAt:  expiresAt,
-	}
-	s.mu.Unlock()
-
-	log.Printf("New session created, expires at %s", expiresAt.Format(time.RFC3339))
-
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
-		"token":      token,
-		"expires_at": expiresAt,
-	})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
@@ -177,166 +253,107 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	delete(s.sessions, tokenHash)
 	s.mu.Unlock()
 
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "logged out"})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "logged out"})
 }
 
-func (s *Server) handlePasswords(w http.ResponseWriter, r *http.Request) {
+// handleItems returns the items changed since the revision in ?since=, all
+// of them by default
+func (s *Server) handleItems(w http.ResponseWriter, r *http.Request) {
 	session, err := s.authenticate(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	encryptor := crypto.NewEncryptor(session.Passphrase, s.salt)
-
-	switch r.Method {
-	case http.MethodGet:
-		passwords, err := s.db.ListPasswords()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		for _, p := range passwords {
-			decrypted, err := encryptor.Decrypt(p.Password)
-			if err != nil {
-				http.Error(w, "Failed to decrypt password", http.StatusInternalServerError)
-				return
-			}
-			p.Password = decrypted
-		}
-
-		_ = json.NewEncoder(w).Encode(passwords)
-
-	case http.MethodPost:
-		var p models.Password
-		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
-			return
-		}
-
-		encrypted, err := encryptor.Encrypt(p.Password)
-		if err != nil {
-			http.Error(w, "Failed to encrypt password", http.StatusInternalServerError)
-			return
-		}
-		p.Password = encrypted
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		if err := s.db.AddPassword(&p); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		if since, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
 			return
 		}
+	}
 
-		w.WriteHeader(http.StatusCreated)
-		_ = json.NewEncoder(w).Encode(p)
-
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	items, revision, err := s.store.Changes(session.Username, since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"revision": revision,
+		"items":    items,
+	})
 }
 
-func (s *Server) handlePassword(w http.ResponseWriter, r *http.Request) {
+// handleItem stores (PUT) or deletes (DELETE) one item. Both carry the
+// version the client's copy is based on; a stale one gets 409 Conflict and
+// the current blob.
+func (s *Server) handleItem(w http.ResponseWriter, r *http.Request) {
 	session, err := s.authenticate(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	encryptor := crypto.NewEncryptor(session.Passphrase, s.salt)
-
-	var id int64
-	if _, err := fmt.Sscanf(r.URL.Path, "/api/passwords/%d", &id); err != nil {
-		http.Error(w, "Invalid password ID", http.StatusBadRequest)
+	id := strings.TrimPrefix(r.URL.Path, "/api/items/")
+	if !validItemID(id) {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		p, err := s.db.GetPassword(id)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-
-		decrypted, err := encryptor.Decrypt(p.Password)
-		if err != nil {
-			http.Error(w, "Failed to decrypt password", http.StatusInternalServerError)
-			return
-		}
-		p.Password = decrypted
-
-		_ = json.NewEncoder(w).Encode(p)
+	var blob *Blob
 
+	switch r.Method {
 	case http.MethodPut:
-		var p models.Password
-		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
-			return
+		var req struct {
+			BaseVersion int64  `json:"base_version"`
+			Data        []byte `json:"data"`
 		}
-		p.ID = id
-
-		encrypted, err := encryptor.Encrypt(p.Password)
-		if err != nil {
-			http.Error(w, "Failed to encrypt password", http.StatusInternalServerError)
-			return
-		}
-		p.Password = encrypted
-
-		if err := s.db.UpdatePassword(&p); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBlobSize)).Decode(&req); err != nil || len(req.Data) == 0 {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
 			return
 		}
-
-		_ = json.NewEncoder(w).Encode(p)
+		blob, err = s.store.Put(session.Username, id, req.BaseVersion, req.Data)
 
 	case http.MethodDelete:
-		if err := s.db.DeletePassword(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		baseVersion, perr := strconv.ParseInt(r.URL.Query().Get("base_version"), 10, 64)
+		if perr != nil {
+			http.Error(w, "Invalid base_version parameter", http.StatusBadRequest)
 			return
 		}
-
-		w.WriteHeader(http.StatusNoContent)
+		blob, err = s.store.Delete(session.Username, id, baseVersion)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
-	session, err := s.authenticate(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "Missing query parameter", http.StatusBadRequest)
 		return
 	}
 
-	encryptor := crypto.NewEncryptor(session.Passphrase, s.salt)
-
-	passwords, err := s.db.SearchPasswords(query)
-	if err != nil {
+	switch {
+	case errors.Is(err, ErrConflict):
+		writeJSON(w, http.StatusConflict, blob)
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrNotRegistered):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	default:
+		writeJSON(w, http.StatusOK, blob)
 	}
+}
 
-	for _, p := range passwords {
-		decrypted, err := encryptor.Decrypt(p.Password)
-		if err != nil {
-			http.Error(w, "Failed to decrypt password", http.StatusInternalServerError)
-			return
+// validItemID accepts the random IDs clients generate: up to 64 characters
+// of letters, digits, '-' and '_'
+func validItemID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if c != '-' && c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
 		}
-		p.Password = decrypted
 	}
-
-	_ = json.NewEncoder(w).Encode(passwords)
+	return true
 }
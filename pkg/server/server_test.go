@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/r2unit/openpasswd/pkg/client"
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/srp"
+)
+
+const (
+	testUser       = "alice"
+	testPassphrase = "correct horse battery staple"
+)
+
+// newTestServer starts a server with one registered account
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+
+	store, err := OpenStore(filepath.Join(t.TempDir(), "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := New(store, Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}).Handler()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	if err := client.Register(ts.URL, testUser, testPassphrase, ""); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func post(t *testing.T, url string, body interface{}) (int, []byte) {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, out
+}
+
+// startLogin runs the first round trip and computes the proof M1 for the
+// passphrase, like client.Login does
+func startLogin(t *testing.T, url, username, passphrase string) (string, []byte) {
+	t.Helper()
+
+	exchange, err := srp.NewClient(username)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := post(t, url+"/api/auth/login/start", map[string]interface{}{
+		"username": username,
+		"a":        exchange.PublicKey(),
+	})
+	if status != http.StatusOK {
+		t.Fatalf("login/start: status %d: %s", status, body)
+	}
+
+	var start struct {
+		LoginID    string `json:"login_id"`
+		KDFVersion int    `json:"kdf_version"`
+		KDFSalt    []byte `json:"kdf_salt"`
+		SRPSalt    []byte `json:"srp_salt"`
+		B          []byte `json:"b"`
+	}
+	if err := json.Unmarshal(body, &start); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := client.DeriveKeys(passphrase, start.KDFSalt, start.KDFVersion)
+	defer keys.Wipe()
+	m1, err := exchange.Proof(keys.AuthKey(), start.SRPSalt, start.B)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return start.LoginID, m1
+}
+
+func finishLogin(t *testing.T, url, loginID string, m1 []byte) (int, string) {
+	t.Helper()
+	status, body := post(t, url+"/api/auth/login/finish", map[string]interface{}{
+		"login_id": loginID,
+		"m1":       m1,
+	})
+	return status, strings.TrimSpace(string(body))
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	if _, _, err := client.Login(ts.URL, testUser, testPassphrase); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+}
+
+// A wrong passphrase and an unknown username must look the same
+func TestLoginFailures(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	loginID, m1 := startLogin(t, ts.URL, testUser, "wrong passphrase")
+	wrongStatus, wrongBody := finishLogin(t, ts.URL, loginID, m1)
+	loginID, m1 = startLogin(t, ts.URL, "mallory", testPassphrase)
+	unknownStatus, unknownBody := finishLogin(t, ts.URL, loginID, m1)
+
+	if wrongStatus != http.StatusUnauthorized || unknownStatus != http.StatusUnauthorized {
+		t.Errorf("status = %d (wrong passphrase), %d (unknown user), want 401", wrongStatus, unknownStatus)
+	}
+	if wrongBody != unknownBody || wrongBody != errLoginFailed.Error() {
+		t.Errorf("body = %q (wrong passphrase), %q (unknown user), want %q", wrongBody, unknownBody, errLoginFailed)
+	}
+}
+
+func TestLoginReplay(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	loginID, m1 := startLogin(t, ts.URL, testUser, testPassphrase)
+	if status, body := finishLogin(t, ts.URL, loginID, m1); status != http.StatusOK {
+		t.Fatalf("first finish: status %d: %s", status, body)
+	}
+
+	// A login ID can be finished only once, even with the right proof
+	if status, _ := finishLogin(t, ts.URL, loginID, m1); status != http.StatusUnauthorized {
+		t.Errorf("replayed finish: status %d, want 401", status)
+	}
+}
+
+// The client must not trust a server that can't prove it holds the
+// verifier
+func TestLoginRejectsBadServerProof(t *testing.T) {
+	ts := newTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/auth/login/finish" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := httptest.NewRecorder()
+			next.ServeHTTP(rec, r)
+
+			var finish map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &finish); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			finish["m2"] = make([]byte, 32)
+			writeJSON(w, rec.Code, finish)
+		})
+	})
+
+	_, _, err := client.Login(ts.URL, testUser, testPassphrase)
+	if !errors.Is(err, srp.ErrBadProof) {
+		t.Errorf("Login: err = %v, want ErrBadProof", err)
+	}
+}
+
+// A fake server must not be able to downgrade the KDF to make M1 cheaper
+// to brute-force
+func TestLoginRejectsWeakKDF(t *testing.T) {
+	for _, version := range []int{crypto.KDFVersionPBKDF2_100k, crypto.KDFVersionPBKDF2_600k, 0, 99} {
+		ts := newTestServer(t, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/auth/login/start" {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				rec := httptest.NewRecorder()
+				next.ServeHTTP(rec, r)
+
+				var start map[string]interface{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &start); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				start["kdf_version"] = version
+				writeJSON(w, rec.Code, start)
+			})
+		})
+
+		_, _, err := client.Login(ts.URL, testUser, testPassphrase)
+		if !errors.Is(err, client.ErrUnsupportedKDF) {
+			t.Errorf("kdf_version %d: err = %v, want ErrUnsupportedKDF", version, err)
+		}
+	}
+}
+
+func TestRegisterRejectsKDFVersion(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	for _, version := range []int{crypto.KDFVersionPBKDF2_100k, 0, 99} {
+		status, body := post(t, ts.URL+"/api/auth/register", map[string]interface{}{
+			"username":    "bob",
+			"kdf_version": version,
+			"kdf_salt":    make([]byte, 16),
+			"srp_salt":    make([]byte, 16),
+			"verifier":    []byte{1},
+		})
+		if status != http.StatusBadRequest {
+			t.Errorf("kdf_version %d: status %d: %s", version, status, body)
+		}
+	}
+}
+
+func TestPutChanges(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	c, _, err := client.Login(ts.URL, testUser, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := client.NewItem(&models.Password{Type: models.TypeLogin, Name: "GitHub", Username: "alice", Password: "s3cret"})
+	if err := c.Put(item); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if item.Version == 0 {
+		t.Error("Put didn't set the item version")
+	}
+
+	// A second device logs in and pulls everything
+	other, _, err := client.Login(ts.URL, testUser, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, revision, err := other.Changes(0)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != item.ID || items[0].Password.Password != "s3cret" {
+		t.Fatalf("Changes = %+v, want the uploaded item", items)
+	}
+
+	// Changing a stale copy conflicts
+	items[0].Password.Password = "changed"
+	if err := other.Put(items[0]); err != nil {
+		t.Fatal(err)
+	}
+	item.Password.Password = "stale"
+	if err := c.Put(item); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("stale Put: err = %v, want ErrConflict", err)
+	}
+
+	items, next, err := c.Changes(revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Password.Password != "changed" || next <= revision {
+		t.Errorf("Changes(%d) = %+v, revision %d", revision, items, next)
+	}
+}
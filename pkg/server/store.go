@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRegistered is returned when registering a username that is taken
+	ErrRegistered = errors.New("username is already registered")
+	// ErrNotRegistered is returned for unknown usernames
+	ErrNotRegistered = errors.New("no such account")
+	// ErrConflict is returned when an item changed since the version the
+	// client based its change on
+	ErrConflict = errors.New("item was changed by another device")
+	// ErrNotFound is returned for unknown item IDs
+	ErrNotFound = errors.New("item not found")
+)
+
+// Account holds the key derivation parameters every device needs to derive
+// the same keys from the passphrase, and the SRP verifier of the auth key.
+// None of it can be turned back into the passphrase or the encryption key.
+type Account struct {
+	KDFVersion int       `json:"kdf_version"`
+	KDFSalt    []byte    `json:"kdf_salt"`
+	SRPSalt    []byte    `json:"srp_salt"`
+	Verifier   []byte    `json:"verifier"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Blob is one item as the client encrypted it. Version is the store revision
+// of its last change. Deleted items are kept as tombstones without data so
+// other devices learn about the deletion.
+type Blob struct {
+	ID        string    `json:"id"`
+	Version   int64     `json:"version"`
+	Data      []byte    `json:"data,omitempty"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store persists the accounts and their encrypted items in a JSON file
+type Store struct {
+	path string
+	mu   sync.RWMutex
+	data storeData
+}
+
+type storeData struct {
+	Accounts map[string]*accountData `json:"accounts"`
+}
+
+// accountData is one account with its items. Revisions count per account.
+type accountData struct {
+	Account  *Account         `json:"account"`
+	Revision int64            `json:"revision"`
+	Items    map[string]*Blob `json:"items"`
+}
+
+// OpenStore loads the store at path, starting an empty one if it doesn't
+// exist yet
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, data: storeData{Accounts: make(map[string]*accountData)}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.data); err != nil {
+		return nil, fmt.Errorf("failed to parse store: %w", err)
+	}
+	if s.data.Accounts == nil {
+		s.data.Accounts = make(map[string]*accountData)
+	}
+	for _, a := range s.data.Accounts {
+		if a.Items == nil {
+			a.Items = make(map[string]*Blob)
+		}
+	}
+	return s, nil
+}
+
+// save writes the store through a temporary file, so a crash never leaves
+// half of it behind
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".store-*")
+	if err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	return nil
+}
+
+// Account returns the account registered under username
+func (s *Store) Account(username string) (*Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.data.Accounts[username]
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+	return a.Account, nil
+}
+
+// Register stores a new account under username
+func (s *Store) Register(username string, account *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.Accounts[username]; ok {
+		return ErrRegistered
+	}
+	s.data.Accounts[username] = &accountData{Account: account, Items: make(map[string]*Blob)}
+	if err := s.save(); err != nil {
+		delete(s.data.Accounts, username)
+		return err
+	}
+	return nil
+}
+
+// Changes returns the account's items changed after revision since, oldest
+// change first, and the current revision to pass as since next time
+func (s *Store) Changes(username string, since int64) ([]*Blob, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.data.Accounts[username]
+	if !ok {
+		return nil, 0, ErrNotRegistered
+	}
+
+	blobs := []*Blob{}
+	for _, b := range a.Items {
+		if b.Version > since {
+			blobs = append(blobs, b)
+		}
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Version < blobs[j].Version })
+	return blobs, a.Revision, nil
+}
+
+// Put stores data for an item. baseVersion is the version the client's copy
+// is based on, 0 for a new item; if the item changed since, ErrConflict is
+// returned along with the current blob.
+func (s *Store) Put(username, id string, baseVersion int64, data []byte) (*Blob, error) {
+	return s.change(username, id, baseVersion, func(b *Blob) {
+		b.Data = data
+		b.Deleted = false
+	})
+}
+
+// Delete replaces an item with a tombstone, under the same version check as
+// Put
+func (s *Store) Delete(username, id string, baseVersion int64) (*Blob, error) {
+	return s.change(username, id, baseVersion, func(b *Blob) {
+		b.Data = nil
+		b.Deleted = true
+	})
+}
+
+func (s *Store) change(username, id string, baseVersion int64, apply func(*Blob)) (*Blob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.data.Accounts[username]
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+
+	current, ok := a.Items[id]
+	switch {
+	case !ok && baseVersion != 0:
+		return nil, ErrNotFound
+	case ok && current.Version != baseVersion:
+		return current, ErrConflict
+	}
+
+	next := &Blob{ID: id}
+	if ok {
+		*next = *current
+	}
+	apply(next)
+	next.Version = a.Revision + 1
+	next.UpdatedAt = time.Now().UTC()
+
+	a.Items[id] = next
+	a.Revision++
+	if err := s.save(); err != nil {
+		a.Revision--
+		if ok {
+			a.Items[id] = current
+		} else {
+			delete(a.Items, id)
+		}
+		return nil, err
+	}
+	return next, nil
+}
@@ -6,19 +6,19 @@ package sources
 // 1. Bitwarden - Has public API: https://bitwarden.com/help/public-api/
 //    - Requires OAuth authentication
 //    - Supports real-time sync
-//    - Export format: JSON
+//    - Export format: JSON (file imports are handled by pkg/bitwarden)
 //
 // 2. 1Password - Has CLI and Connect API: https://developer.1password.com/
 //    - Requires API token
 //    - Has official Go SDK
-//    - Export format: CSV, 1PIF
+//    - Export format: CSV, 1PIF, 1PUX (file imports are handled by pkg/onepassword)
 //
 // 3. LastPass - Limited API access
-//    - Export format: CSV
+//    - Export format: CSV (file imports are handled by pkg/browser)
 //    - Consider CLI scraping as alternative
 //
 // 4. KeePass - File-based, no API
-//    - Export format: CSV, XML
+//    - Export format: CSV, XML (KDBX and XML imports are handled by pkg/keepass)
 //    - Could sync via cloud storage (Dropbox, Google Drive)
 //
 // 5. Dashlane - Has API: https://www.dashlane.com/business/api
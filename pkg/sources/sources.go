@@ -1,7 +1,15 @@
 package sources
 
 import (
+	"path/filepath"
+	"strings"
+
+	"github.com/r2unit/openpasswd/pkg/bitwarden"
+	"github.com/r2unit/openpasswd/pkg/browser"
+	"github.com/r2unit/openpasswd/pkg/keepass"
 	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/onepassword"
+	"github.com/r2unit/openpasswd/pkg/passwordstore"
 	"github.com/r2unit/openpasswd/pkg/proton/pass"
 )
 
@@ -9,12 +17,12 @@ import (
 type Source string
 
 const (
-	SourceProtonPass Source = "protonpass"
-
-	// SourceBitwarden  Source = "bitwarden"  // Has public API with OAuth
-	// Source1Password  Source = "1password"  // Has CLI and Connect API
-	// SourceLastPass   Source = "lastpass"   // CSV export only
-	// SourceKeePass    Source = "keepass"    // File-based, no API
+	SourceProtonPass    Source = "protonpass"
+	SourceBitwarden     Source = "bitwarden"
+	SourceKeePass       Source = "keepass"
+	Source1Password     Source = "1password"
+	SourceBrowser       Source = "browser" // Chrome, Edge, Firefox, Safari and LastPass CSV exports
+	SourcePasswordStore Source = "pass"    // pass and gopass stores
 )
 
 // Importer interface for all password manager importers
@@ -43,20 +51,71 @@ type Importer interface {
 	SupportsFormat(format string) bool
 }
 
+// PassphraseDetector is implemented by importers that can tell from the file
+// contents whether it needs a passphrase, e.g. Bitwarden's password-protected
+// JSON exports which use the same extension as plain ones
+type PassphraseDetector interface {
+	NeedsPassphrase(filePath string) bool
+}
+
+// NeedsPassphrase reports whether importing the file requires a passphrase
+func NeedsPassphrase(importer Importer, filePath string) bool {
+	if d, ok := importer.(PassphraseDetector); ok {
+		return d.NeedsPassphrase(filePath)
+	}
+
+	ext := strings.ToLower(filepath.Ext(filePath))
+	return ext == ".zip" || ext == ".pgp"
+}
+
+// KeyFileImporter is implemented by importers whose files can be protected
+// by a key file in addition to (or instead of) a passphrase, e.g. KeePass
+type KeyFileImporter interface {
+	SetKeyFile(path string)
+}
+
+// ColumnMapper is implemented by importers of tabular files whose layout may
+// need to be mapped by hand, e.g. CSV exports from unknown sources
+type ColumnMapper interface {
+	// DetectColumns returns the columns of the file and the detected mapping
+	DetectColumns(filePath string) (*browser.Layout, error)
+
+	// SetColumnMapping overrides the detected mapping for the next Import
+	SetColumnMapping(mapping map[string]int)
+}
+
+// SkipReporter is implemented by importers that leave out parts of the
+// input they can't read instead of failing the whole import, e.g. password
+// store files encrypted to another GPG key
+type SkipReporter interface {
+	// Skipped describes what the last Import left out
+	Skipped() []string
+}
+
+// Skipped returns what the importer's last Import left out, if anything
+func Skipped(importer Importer) []string {
+	if r, ok := importer.(SkipReporter); ok {
+		return r.Skipped()
+	}
+	return nil
+}
+
 // GetImporter returns an importer for the given source
 //
 func GetImporter(source Source) Importer {
 	switch source {
 	case SourceProtonPass:
 		return &pass.Importer{}
-	// case SourceBitwarden:
-	//     return &BitwardenImporter{}
-	// case Source1Password:
-	//     return &OnePasswordImporter{}
-	// case SourceLastPass:
-	//     return &LastPassImporter{}
-	// case SourceKeePass:
-	//     return &KeePassImporter{}
+	case SourceBitwarden:
+		return &bitwarden.Importer{}
+	case SourceKeePass:
+		return &keepass.Importer{}
+	case Source1Password:
+		return &onepassword.Importer{}
+	case SourceBrowser:
+		return &browser.Importer{}
+	case SourcePasswordStore:
+		return &passwordstore.Importer{}
 	default:
 		return nil
 	}
@@ -67,7 +126,12 @@ func GetImporter(source Source) Importer {
 // This is used by the import TUI to display available password managers.
 func GetAvailableImporters() []Importer {
 	return []Importer{
-		&pass.Importer{}, // Proton Pass (via export files only)
+		&pass.Importer{},          // Proton Pass (via export files only)
+		&bitwarden.Importer{},     // Bitwarden (JSON exports)
+		&keepass.Importer{},       // KeePass (KDBX databases and XML exports)
+		&onepassword.Importer{},   // 1Password (1PUX archives and CSV exports)
+		&browser.Importer{},       // Browsers and LastPass (CSV exports)
+		&passwordstore.Importer{}, // pass and gopass (GPG-encrypted store directories)
 		// See other_importers.go for implementation notes
 	}
 }
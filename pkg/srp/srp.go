@@ -0,0 +1,267 @@
+// Package srp implements the SRP-6a password-authenticated key exchange
+// (RFC 2945, RFC 5054) with SHA-256 and the 3072-bit group of RFC 5054.
+//
+// The server only stores a verifier, g^x with x derived from the password
+// and a salt. A login proves knowledge of the password without sending it,
+// and both sides end up with the same session key, which each one proves to
+// the other (M1 from the client, M2 from the server).
+package srp
+
+import (
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+)
+
+// groupPrime is the 3072-bit safe prime of RFC 5054 appendix A
+var groupPrime, _ = new(big.Int).SetString(""+
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74"+
+	"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437"+
+	"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05"+
+	"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB"+
+	"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B"+
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718"+
+	"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33"+
+	"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7"+
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864"+
+	"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2"+
+	"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF", 16)
+
+// groupGenerator is the generator RFC 5054 pairs with the 3072-bit prime
+var groupGenerator = big.NewInt(5)
+
+// groupSize is the byte length values are padded to
+var groupSize = (groupPrime.BitLen() + 7) / 8
+
+// multiplier is k = H(N | PAD(g))
+var multiplier = new(big.Int).SetBytes(hash(groupPrime.Bytes(), pad(groupGenerator)))
+
+// secretSize is the size of the random exponents a and b
+const secretSize = 32
+
+var (
+	// ErrInvalidPublicKey is returned for an A or B outside 1..N-1
+	ErrInvalidPublicKey = errors.New("invalid SRP public value")
+	// ErrBadProof is returned when the other side's proof doesn't match,
+	// because the password was wrong or the peer isn't who it claims to be
+	ErrBadProof = errors.New("SRP proof mismatch")
+)
+
+func hash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// pad returns n as a big-endian number of groupSize bytes
+func pad(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, groupSize))
+}
+
+// privateKey computes x = H(salt | H(username ":" password))
+func privateKey(username string, password, salt []byte) *big.Int {
+	inner := hash([]byte(username), []byte(":"), password)
+	return new(big.Int).SetBytes(hash(salt, inner))
+}
+
+// scrambler computes u = H(PAD(A) | PAD(B))
+func scrambler(A, B *big.Int) *big.Int {
+	return new(big.Int).SetBytes(hash(pad(A), pad(B)))
+}
+
+// clientProof computes M1 = H(H(N) xor H(g) | H(I) | s | A | B | K)
+func clientProof(username string, salt []byte, A, B *big.Int, key []byte) []byte {
+	hn := hash(groupPrime.Bytes())
+	hg := hash(pad(groupGenerator))
+	for i := range hn {
+		hn[i] ^= hg[i]
+	}
+	return hash(hn, hash([]byte(username)), salt, pad(A), pad(B), key)
+}
+
+// serverProof computes M2 = H(A | M1 | K)
+func serverProof(A *big.Int, m1, key []byte) []byte {
+	return hash(pad(A), m1, key)
+}
+
+// validPublic rejects public values outside 1..N-1; 0 mod N would let a
+// peer force the session key without knowing the password
+func validPublic(n *big.Int) bool {
+	return n.Sign() > 0 && n.Cmp(groupPrime) < 0
+}
+
+func randomSecret() (*big.Int, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// NewSalt returns a random salt for NewVerifier
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// NewVerifier computes the verifier v = g^x the server stores at
+// registration
+func NewVerifier(username string, password, salt []byte) []byte {
+	x := privateKey(username, password, salt)
+	return pad(new(big.Int).Exp(groupGenerator, x, groupPrime))
+}
+
+// DecoyVerifier derives a verifier from seed that no known password matches,
+// for answering logins of unknown usernames. Unlike NewVerifier it needs no
+// modular exponentiation, so it can't be told apart by how long it takes.
+func DecoyVerifier(seed []byte) []byte {
+	// 8 bytes more than N keep the bias of the reduction negligible
+	expanded, err := hkdf.Expand(sha256.New, seed, "srp decoy verifier", groupSize+8)
+	if err != nil {
+		panic(err) // only for lengths HKDF can't produce
+	}
+	v := new(big.Int).SetBytes(expanded)
+	v.Mod(v, groupPrime)
+	if v.Sign() == 0 {
+		v.SetInt64(1)
+	}
+	return pad(v)
+}
+
+// Client is the client side of one login
+type Client struct {
+	username string
+	a, A     *big.Int
+	m1, key  []byte
+}
+
+// NewClient starts a login; send PublicKey to the server
+func NewClient(username string) (*Client, error) {
+	a, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		username: username,
+		a:        a,
+		A:        new(big.Int).Exp(groupGenerator, a, groupPrime),
+	}, nil
+}
+
+// PublicKey returns A, the client's public value
+func (c *Client) PublicKey() []byte {
+	return pad(c.A)
+}
+
+// Proof takes the salt and B from the server and returns M1, the proof to
+// send back. The password is only needed now, as the client may have to
+// derive it with parameters it received along with the salt.
+func (c *Client) Proof(password, salt, serverPublic []byte) ([]byte, error) {
+	B := new(big.Int).SetBytes(serverPublic)
+	if !validPublic(B) {
+		return nil, ErrInvalidPublicKey
+	}
+	u := scrambler(c.A, B)
+	if u.Sign() == 0 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	// S = (B - k*g^x) ^ (a + u*x)
+	x := privateKey(c.username, password, salt)
+	kgx := new(big.Int).Exp(groupGenerator, x, groupPrime)
+	kgx.Mul(kgx, multiplier)
+	base := new(big.Int).Sub(B, kgx)
+	base.Mod(base, groupPrime)
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, c.a)
+	S := new(big.Int).Exp(base, exp, groupPrime)
+
+	c.key = hash(pad(S))
+	c.m1 = clientProof(c.username, salt, c.A, B, c.key)
+	return c.m1, nil
+}
+
+// Verify checks the server's proof M2. Only after it succeeds is the
+// server known to hold the verifier, and the key safe to use.
+func (c *Client) Verify(m2 []byte) error {
+	if c.key == nil || subtle.ConstantTimeCompare(m2, serverProof(c.A, c.m1, c.key)) != 1 {
+		return ErrBadProof
+	}
+	return nil
+}
+
+// Key returns the shared session key, once Verify succeeded
+func (c *Client) Key() []byte {
+	return c.key
+}
+
+// Server is the server side of one login
+type Server struct {
+	username string
+	salt     []byte
+	A, B     *big.Int
+	key      []byte
+}
+
+// NewServer answers a client's A for the account with the given salt and
+// verifier; send PublicKey and the salt to the client
+func NewServer(username string, salt, verifier, clientPublic []byte) (*Server, error) {
+	A := new(big.Int).SetBytes(clientPublic)
+	if !validPublic(A) {
+		return nil, ErrInvalidPublicKey
+	}
+
+	b, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// B = k*v + g^b
+	v := new(big.Int).SetBytes(verifier)
+	B := new(big.Int).Mul(multiplier, v)
+	B.Add(B, new(big.Int).Exp(groupGenerator, b, groupPrime))
+	B.Mod(B, groupPrime)
+
+	// S = (A * v^u) ^ b
+	u := scrambler(A, B)
+	S := new(big.Int).Exp(v, u, groupPrime)
+	S.Mul(S, A)
+	S.Exp(S, b, groupPrime)
+
+	return &Server{
+		username: username,
+		salt:     salt,
+		A:        A,
+		B:        B,
+		key:      hash(pad(S)),
+	}, nil
+}
+
+// PublicKey returns B, the server's public value
+func (s *Server) PublicKey() []byte {
+	return pad(s.B)
+}
+
+// Verify checks the client's proof M1 and returns M2, the server's proof
+// for the client
+func (s *Server) Verify(m1 []byte) ([]byte, error) {
+	expected := clientProof(s.username, s.salt, s.A, s.B, s.key)
+	if subtle.ConstantTimeCompare(m1, expected) != 1 {
+		return nil, ErrBadProof
+	}
+	return serverProof(s.A, m1, s.key), nil
+}
+
+// Key returns the shared session key, once Verify succeeded
+func (s *Server) Key() []byte {
+	return s.key
+}
@@ -0,0 +1,347 @@
+// Package sshkey generates, encodes and parses the SSH keys stored in
+// "ssh_key" vault entries, and signs with them the way the SSH agent
+// protocol expects. Private keys are written in the OpenSSH format without
+// a cipher, since the vault already encrypts them.
+package sshkey
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Key types accepted by Generate
+const (
+	TypeEd25519 = "ed25519"
+	TypeRSA     = "rsa"
+)
+
+// RSA key sizes
+const (
+	DefaultRSABits = 3072
+	MinRSABits     = 2048
+)
+
+// Public key and signature algorithm names
+const (
+	AlgoEd25519    = "ssh-ed25519"
+	AlgoRSA        = "ssh-rsa"
+	AlgoRSASHA256  = "rsa-sha2-256"
+	AlgoRSASHA512  = "rsa-sha2-512"
+	opensshMagic   = "openssh-key-v1\x00"
+	opensshPEMType = "OPENSSH PRIVATE KEY"
+)
+
+// Signature flags of SSH2_AGENTC_SIGN_REQUEST
+const (
+	FlagRSASHA256 = 2
+	FlagRSASHA512 = 4
+)
+
+// ErrEncrypted is returned for OpenSSH keys protected with a passphrase,
+// whose bcrypt key derivation isn't supported
+var ErrEncrypted = errors.New("passphrase-protected OpenSSH keys are not supported; remove the passphrase with 'ssh-keygen -p' first (the vault encrypts the key)")
+
+// Generate creates a new Ed25519 or RSA private key. bits only applies to
+// RSA; zero picks DefaultRSABits.
+func Generate(keyType string, bits int) (crypto.Signer, error) {
+	switch strings.ToLower(keyType) {
+	case "", TypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		return key, nil
+	case TypeRSA:
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
+		if bits < MinRSABits {
+			return nil, fmt.Errorf("RSA keys need at least %d bits", MinRSABits)
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s (use ed25519 or rsa)", keyType)
+	}
+}
+
+// MarshalPublicKey returns the wire encoding of a public key, as used in
+// authorized_keys lines and by the agent protocol
+func MarshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	var b Builder
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		b.String([]byte(AlgoEd25519)).String(pub)
+	case *rsa.PublicKey:
+		b.String([]byte(AlgoRSA)).Mpint(big.NewInt(int64(pub.E))).Mpint(pub.N)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return b.Bytes(), nil
+}
+
+// AuthorizedKey returns the public key as an authorized_keys line
+func AuthorizedKey(pub crypto.PublicKey, comment string) (string, error) {
+	blob, err := MarshalPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	line := keyAlgo(blob) + " " + base64.StdEncoding.EncodeToString(blob)
+	if comment = strings.TrimSpace(comment); comment != "" {
+		line += " " + comment
+	}
+	return line, nil
+}
+
+// Fingerprint returns the SHA256 fingerprint of a wire-encoded public key in
+// the form ssh-keygen prints
+func Fingerprint(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// keyAlgo returns the algorithm name a wire-encoded public key starts with
+func keyAlgo(blob []byte) string {
+	return string(NewReader(blob).String())
+}
+
+// MarshalPrivateKey encodes a private key as an unencrypted OpenSSH PEM block
+func MarshalPrivateKey(key crypto.Signer, comment string) ([]byte, error) {
+	pubBlob, err := MarshalPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	check := make([]byte, 4)
+	if _, err := rand.Read(check); err != nil {
+		return nil, fmt.Errorf("failed to generate check bytes: %w", err)
+	}
+	checkInt := binary.BigEndian.Uint32(check)
+
+	var priv Builder
+	priv.Uint32(checkInt).Uint32(checkInt)
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		priv.String([]byte(AlgoEd25519)).String(k.Public().(ed25519.PublicKey)).String(k)
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("multi-prime RSA keys are not supported")
+		}
+		k.Precompute()
+		priv.String([]byte(AlgoRSA)).
+			Mpint(k.N).
+			Mpint(big.NewInt(int64(k.E))).
+			Mpint(k.D).
+			Mpint(k.Precomputed.Qinv).
+			Mpint(k.Primes[0]).
+			Mpint(k.Primes[1])
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	priv.String([]byte(comment))
+
+	// Pad the private section to the cipher block size ("none" uses 8)
+	for i := byte(1); len(priv.Bytes())%8 != 0; i++ {
+		priv.Byte(i)
+	}
+
+	var b Builder
+	b.buf = append(b.buf, opensshMagic...)
+	b.String([]byte("none")).String([]byte("none")).String(nil).Uint32(1)
+	b.String(pubBlob)
+	b.String(priv.Bytes())
+
+	return pem.EncodeToMemory(&pem.Block{Type: opensshPEMType, Bytes: b.Bytes()}), nil
+}
+
+// ParsePrivateKey decodes a PEM private key: the OpenSSH format, PKCS#1 RSA
+// or PKCS#8. The passphrase is used for legacy encrypted PEM blocks. The
+// comment is only known for OpenSSH keys.
+func ParsePrivateKey(data []byte, passphrase string) (crypto.Signer, string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, "", errors.New("no PEM private key found")
+	}
+
+	der := block.Bytes
+	// Legacy encrypted PEM keys ("Proc-Type: 4,ENCRYPTED") use the passphrase
+	if x509.IsEncryptedPEMBlock(block) {
+		if passphrase == "" {
+			return nil, "", errors.New("the private key is encrypted but no passphrase is set")
+		}
+		var err error
+		der, err = x509.DecryptPEMBlock(block, []byte(passphrase))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+	}
+
+	switch block.Type {
+	case opensshPEMType:
+		return parseOpenSSH(der)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+		return key, "", nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse private key: %w", err)
+		}
+		switch key := key.(type) {
+		case ed25519.PrivateKey:
+			return key, "", nil
+		case *rsa.PrivateKey:
+			return key, "", nil
+		}
+		return nil, "", fmt.Errorf("unsupported private key type %T", key)
+	default:
+		return nil, "", fmt.Errorf("unsupported PEM block: %s", block.Type)
+	}
+}
+
+// parseOpenSSH decodes the body of an "OPENSSH PRIVATE KEY" block
+func parseOpenSSH(data []byte) (crypto.Signer, string, error) {
+	if !bytes.HasPrefix(data, []byte(opensshMagic)) {
+		return nil, "", errors.New("not an OpenSSH private key")
+	}
+
+	r := NewReader(data[len(opensshMagic):])
+	cipher := string(r.String())
+	kdf := string(r.String())
+	r.String() // KDF options
+	count := r.Uint32()
+	r.String() // public key
+	priv := r.String()
+	if err := r.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to parse OpenSSH private key: %w", err)
+	}
+	if cipher != "none" || kdf != "none" {
+		return nil, "", ErrEncrypted
+	}
+	if count != 1 {
+		return nil, "", fmt.Errorf("expected one key in the file, found %d", count)
+	}
+
+	r = NewReader(priv)
+	if r.Uint32() != r.Uint32() {
+		return nil, "", errors.New("corrupt OpenSSH private key (check bytes differ)")
+	}
+
+	var key crypto.Signer
+	switch algo := string(r.String()); algo {
+	case AlgoEd25519:
+		r.String() // public key, repeated in the private key
+		seed := r.String()
+		if r.Err() == nil && len(seed) != ed25519.PrivateKeySize {
+			return nil, "", errors.New("invalid Ed25519 private key length")
+		}
+		key = ed25519.PrivateKey(bytes.Clone(seed))
+	case AlgoRSA:
+		n, e, d := r.Mpint(), r.Mpint(), r.Mpint()
+		r.Mpint() // iqmp, recomputed by Precompute
+		p, q := r.Mpint(), r.Mpint()
+		if r.Err() == nil && !e.IsInt64() {
+			return nil, "", errors.New("invalid RSA public exponent")
+		}
+		rsaKey := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if r.Err() == nil {
+			if err := rsaKey.Validate(); err != nil {
+				return nil, "", fmt.Errorf("invalid RSA private key: %w", err)
+			}
+			rsaKey.Precompute()
+		}
+		key = rsaKey
+	default:
+		return nil, "", fmt.Errorf("unsupported key type: %s", algo)
+	}
+
+	comment := string(r.String())
+	if err := r.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to parse OpenSSH private key: %w", err)
+	}
+	return key, comment, nil
+}
+
+// Sign signs data with key for the agent protocol and returns the
+// wire-encoded signature. flags select SHA-2 signatures for RSA keys.
+func Sign(key crypto.Signer, data []byte, flags uint32) ([]byte, error) {
+	var algo string
+	var sig []byte
+	var err error
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		algo = AlgoEd25519
+		sig = ed25519.Sign(k, data)
+	case *rsa.PrivateKey:
+		var hash crypto.Hash
+		var digest []byte
+		switch {
+		case flags&FlagRSASHA512 != 0:
+			algo, hash = AlgoRSASHA512, crypto.SHA512
+			sum := sha512.Sum512(data)
+			digest = sum[:]
+		case flags&FlagRSASHA256 != 0:
+			algo, hash = AlgoRSASHA256, crypto.SHA256
+			sum := sha256.Sum256(data)
+			digest = sum[:]
+		default:
+			algo, hash = AlgoRSA, crypto.SHA1
+			sum := sha1.Sum(data)
+			digest = sum[:]
+		}
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	var b Builder
+	b.String([]byte(algo)).String(sig)
+	return b.Bytes(), nil
+}
+
+// Wipe overwrites the secret parts of a private key in memory
+func Wipe(key crypto.Signer) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		clear(k)
+	case *rsa.PrivateKey:
+		clear(k.D.Bits())
+		for _, p := range k.Primes {
+			clear(p.Bits())
+		}
+		if k.Precomputed.Dp != nil {
+			clear(k.Precomputed.Dp.Bits())
+			clear(k.Precomputed.Dq.Bits())
+			clear(k.Precomputed.Qinv.Bits())
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package sshkey
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+// errShort is returned when a message ends before a value it announces
+var errShort = errors.New("truncated ssh message")
+
+// Builder appends values in the SSH wire encoding (RFC 4251 section 5)
+type Builder struct {
+	buf []byte
+}
+
+// Bytes returns the encoded message
+func (b *Builder) Bytes() []byte {
+	return b.buf
+}
+
+// Byte appends a single byte
+func (b *Builder) Byte(v byte) *Builder {
+	b.buf = append(b.buf, v)
+	return b
+}
+
+// Uint32 appends a big-endian uint32
+func (b *Builder) Uint32(v uint32) *Builder {
+	b.buf = binary.BigEndian.AppendUint32(b.buf, v)
+	return b
+}
+
+// String appends a length-prefixed byte string
+func (b *Builder) String(v []byte) *Builder {
+	b.Uint32(uint32(len(v)))
+	b.buf = append(b.buf, v...)
+	return b
+}
+
+// Mpint appends a non-negative multiple precision integer
+func (b *Builder) Mpint(v *big.Int) *Builder {
+	raw := v.Bytes()
+	if len(raw) > 0 && raw[0]&0x80 != 0 {
+		raw = append([]byte{0}, raw...)
+	}
+	return b.String(raw)
+}
+
+// Reader consumes values in the SSH wire encoding. The first error sticks,
+// so callers check Err once after reading everything.
+type Reader struct {
+	buf []byte
+	err error
+}
+
+// NewReader reads from buf
+func NewReader(buf []byte) *Reader {
+	return &Reader{buf: buf}
+}
+
+// Err returns the first error hit while reading
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Len returns the number of unread bytes
+func (r *Reader) Len() int {
+	return len(r.buf)
+}
+
+// Byte reads a single byte
+func (r *Reader) Byte() byte {
+	if r.err != nil || len(r.buf) < 1 {
+		r.fail()
+		return 0
+	}
+	v := r.buf[0]
+	r.buf = r.buf[1:]
+	return v
+}
+
+// Uint32 reads a big-endian uint32
+func (r *Reader) Uint32() uint32 {
+	if r.err != nil || len(r.buf) < 4 {
+		r.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+// String reads a length-prefixed byte string. The result aliases the input.
+func (r *Reader) String() []byte {
+	n := r.Uint32()
+	if r.err != nil || uint64(n) > uint64(len(r.buf)) {
+		r.fail()
+		return nil
+	}
+	v := r.buf[:n:n]
+	r.buf = r.buf[n:]
+	return v
+}
+
+// Mpint reads a multiple precision integer, rejecting negative values
+func (r *Reader) Mpint() *big.Int {
+	raw := r.String()
+	if len(raw) > 0 && raw[0]&0x80 != 0 {
+		r.err = errors.New("negative integer in ssh message")
+	}
+	if r.err != nil {
+		return new(big.Int)
+	}
+	return new(big.Int).SetBytes(raw)
+}
+
+func (r *Reader) fail() {
+	if r.err == nil {
+		r.err = errShort
+	}
+	r.buf = nil
+}
@@ -11,13 +11,13 @@ import (
 	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/database"
 	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
 )
 
 type addModel struct {
 	db              *database.DB
-	salt            []byte
 	encryptor       *crypto.Encryptor
-	passphrase      string
+	store           vault.Store
 	step            int
 	passwordType    string
 	cursor          int
@@ -35,6 +35,8 @@ type addModel struct {
 	showPassword    map[string]bool
 	successTimer    int
 	keybindings     config.Keybindings
+	editing         *models.Password  // Entry being edited, nil when adding
+	fieldKeys       map[string]string // Input name -> stored custom field name
 }
 
 var passwordTypes = []struct {
@@ -80,19 +82,12 @@ var (
 	spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 )
 
-func NewAddTUI(db *database.DB, salt []byte, passphrase string, passwordType string) *addModel {
-	var encryptor *crypto.Encryptor
-	if passphrase != "" {
-		encryptor = crypto.NewEncryptor(passphrase, salt)
-	}
-
+// NewAddTUI creates the add form; new entries are saved to the store
+func NewAddTUI(store vault.Store, passwordType string) *addModel {
 	keybindings, _ := config.LoadKeybindings()
 
 	m := &addModel{
-		db:           db,
-		salt:         salt,
-		encryptor:    encryptor,
-		passphrase:   passphrase,
+		store:        store,
 		step:         0,
 		inputs:       make(map[string]string),
 		showPassword: make(map[string]bool),
@@ -125,6 +120,8 @@ func (m *addModel) setupInputs() {
 		m.inputOrder = []string{"name", "password", "notes"}
 	case "other":
 		m.inputOrder = []string{"name", "value", "notes"}
+	case "ssh_key":
+		m.inputOrder = []string{"name", "comment", "notes"}
 	}
 
 	if len(m.inputOrder) > 0 {
@@ -161,6 +158,9 @@ func (m addModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.err != nil {
 			m.err = msg.err
 		} else if msg.success {
+			if m.editing != nil {
+				return m, m.finishEdit(true)
+			}
 			m.success = true
 			m.successTimer = 15
 			return m, tick()
@@ -227,11 +227,17 @@ func (m addModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Normal mode key handling
 		switch key {
 		case m.keybindings.QuitAlt:
+			if m.editing != nil {
+				return m, m.finishEdit(false)
+			}
 			if m.step == 0 || m.err != nil {
 				return m, tea.Quit
 			}
 
 		case m.keybindings.Back:
+			if m.editing != nil {
+				return m, m.finishEdit(false)
+			}
 			if m.step == 1 {
 				m.step = 0
 				m.passwordType = ""
@@ -313,131 +319,24 @@ func (m addModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m *addModel) savePassword() tea.Cmd {
 	return func() tea.Msg {
-		name := m.inputs["name"]
-		if name == "" {
+		item := m.item()
+		if item.Name == "" {
 			return saveResultMsg{err: fmt.Errorf("name is required")}
 		}
 
-		if m.encryptor == nil {
-			m.encryptor = crypto.NewEncryptor(m.passphrase, m.salt)
-		}
-
-		password := &models.Password{
-			Type:   models.PasswordType(m.passwordType),
-			Fields: make(map[string]string),
-		}
-
-		encryptedName, err := m.encryptor.Encrypt(name)
-		if err != nil {
-			return saveResultMsg{err: err}
-		}
-		password.Name = encryptedName
-
-		switch m.passwordType {
-		case "login":
-			if m.inputs["username"] != "" {
-				encryptedUsername, err := m.encryptor.Encrypt(m.inputs["username"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Username = encryptedUsername
-			}
-			if m.inputs["password"] != "" {
-				encryptedPassword, err := m.encryptor.Encrypt(m.inputs["password"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Password = encryptedPassword
-			}
-			if m.inputs["url"] != "" {
-				encryptedURL, err := m.encryptor.Encrypt(m.inputs["url"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.URL = encryptedURL
-			}
-			if m.inputs["notes"] != "" {
-				encryptedNotes, err := m.encryptor.Encrypt(m.inputs["notes"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Notes = encryptedNotes
-			}
-		case "card":
-			for _, field := range []string{"cardholder", "number", "expiry", "cvv"} {
-				if m.inputs[field] != "" {
-					encrypted, err := m.encryptor.Encrypt(m.inputs[field])
-					if err != nil {
-						return saveResultMsg{err: err}
-					}
-					password.Fields[field] = encrypted
-				}
-			}
-			if m.inputs["notes"] != "" {
-				encryptedNotes, err := m.encryptor.Encrypt(m.inputs["notes"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Notes = encryptedNotes
-			}
-		case "note":
-			if m.inputs["content"] != "" {
-				encryptedContent, err := m.encryptor.Encrypt(m.inputs["content"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Notes = encryptedContent
-			}
-		case "identity":
-			for _, field := range []string{"full_name", "email", "phone", "address"} {
-				if m.inputs[field] != "" {
-					encrypted, err := m.encryptor.Encrypt(m.inputs[field])
-					if err != nil {
-						return saveResultMsg{err: err}
-					}
-					password.Fields[field] = encrypted
-				}
+		if m.editing != nil {
+			password, err := m.editedPassword()
+			if err != nil {
+				return saveResultMsg{err: err}
 			}
-			if m.inputs["notes"] != "" {
-				encryptedNotes, err := m.encryptor.Encrypt(m.inputs["notes"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Notes = encryptedNotes
-			}
-		case "password":
-			if m.inputs["password"] != "" {
-				encryptedPassword, err := m.encryptor.Encrypt(m.inputs["password"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Password = encryptedPassword
-			}
-			if m.inputs["notes"] != "" {
-				encryptedNotes, err := m.encryptor.Encrypt(m.inputs["notes"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Notes = encryptedNotes
-			}
-		case "other":
-			if m.inputs["value"] != "" {
-				encrypted, err := m.encryptor.Encrypt(m.inputs["value"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Fields["value"] = encrypted
-			}
-			if m.inputs["notes"] != "" {
-				encryptedNotes, err := m.encryptor.Encrypt(m.inputs["notes"])
-				if err != nil {
-					return saveResultMsg{err: err}
-				}
-				password.Notes = encryptedNotes
+
+			if err := m.db.UpdatePassword(password); err != nil {
+				return saveResultMsg{err: err}
 			}
+			return saveResultMsg{success: true}
 		}
 
-		if err := m.db.AddPassword(password); err != nil {
+		if _, err := m.store.Add(item); err != nil {
 			return saveResultMsg{err: err}
 		}
 
@@ -445,12 +344,53 @@ func (m *addModel) savePassword() tea.Cmd {
 	}
 }
 
+// item builds the entry from the form inputs
+func (m *addModel) item() *vault.Item {
+	item := &vault.Item{
+		Type:   models.PasswordType(m.passwordType),
+		Name:   m.inputs["name"],
+		Notes:  m.inputs["notes"],
+		Fields: make(map[string]string),
+	}
+
+	var fields []string
+	switch m.passwordType {
+	case "login":
+		item.Username = m.inputs["username"]
+		item.Password = m.inputs["password"]
+		item.URL = m.inputs["url"]
+	case "card":
+		fields = []string{"cardholder", "number", "expiry", "cvv"}
+	case "note":
+		item.Notes = m.inputs["content"]
+	case "identity":
+		fields = []string{"full_name", "email", "phone", "address"}
+	case "password":
+		item.Password = m.inputs["password"]
+	case "other":
+		fields = []string{"value"}
+	case "ssh_key":
+		fields = []string{"comment"}
+	}
+
+	for _, field := range fields {
+		if m.inputs[field] != "" {
+			item.Fields[m.fieldKey(field)] = m.inputs[field]
+		}
+	}
+
+	return item
+}
+
 func (m addModel) View() string {
 	if m.success {
 		return addSuccessStyle.Render("✓ ") + "Password saved successfully!\n"
 	}
 
 	if m.err != nil {
+		if m.editing != nil {
+			return addErrorStyle.Render("✗ ") + fmt.Sprintf("Error: %v\n\nPress 'esc' to return to the list", m.err)
+		}
 		return addErrorStyle.Render("✗ ") + fmt.Sprintf("Error: %v\n\nPress ':q' or 'ctrl+c' to exit", m.err)
 	}
 
@@ -481,7 +421,11 @@ func (m addModel) View() string {
 			s.WriteString(addNormalStyle.Render("↑/↓ or k/j: navigate • enter: select • :q or ctrl+c: quit"))
 		}
 	} else if m.step == 1 {
-		s.WriteString(addTitleStyle.Render(fmt.Sprintf("Add %s", strings.Title(m.passwordType))))
+		action := "Add"
+		if m.editing != nil {
+			action = "Edit"
+		}
+		s.WriteString(addTitleStyle.Render(fmt.Sprintf("%s %s", action, strings.Title(m.passwordType))))
 		s.WriteString("\n\n")
 
 		for _, key := range m.inputOrder {
@@ -527,9 +471,9 @@ func (m addModel) View() string {
 	return s.String()
 }
 
-func RunAddTUI(db *database.DB, salt []byte, passphrase string, passwordType string) error {
+func RunAddTUI(store vault.Store, passwordType string) error {
 	p := tea.NewProgram(
-		NewAddTUI(db, salt, passphrase, passwordType),
+		NewAddTUI(store, passwordType),
 	)
 	_, err := p.Run()
 	return err
@@ -9,12 +9,12 @@ import (
 	"github.com/r2unit/openpasswd/pkg/auth"
 	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/database"
+	"github.com/r2unit/openpasswd/pkg/vault"
 )
 
 type authLoginModel struct {
 	db               *database.DB
-	salt             []byte
-	masterPass       string
+	encryptor        *crypto.Encryptor
 	providers        []auth.Provider
 	cursor           int
 	step             int // 0: select provider, 1: enter credentials, 2: syncing, 3: done
@@ -57,13 +57,14 @@ var (
 				Bold(true)
 )
 
-func NewAuthLoginTUI(db *database.DB, salt []byte, masterPass string) *authLoginModel {
+// NewAuthLoginTUI creates the provider login for an unlocked session
+func NewAuthLoginTUI(session *vault.Session) *authLoginModel {
 	providers := auth.GetAllProviders()
+	encryptor, _ := session.Encryptor()
 
 	return &authLoginModel{
-		db:               db,
-		salt:             salt,
-		masterPass:       masterPass,
+		db:               session.DB,
+		encryptor:        encryptor,
 		providers:        providers,
 		cursor:           0,
 		step:             0,
@@ -211,7 +212,7 @@ func (m *authLoginModel) performSync() tea.Cmd {
 		}
 
 		// Encrypt and save passwords
-		encryptor := crypto.NewEncryptor(m.masterPass, m.salt)
+		encryptor := m.encryptor
 		successCount := 0
 
 		for _, pwd := range passwords {
@@ -370,8 +371,12 @@ func (m authLoginModel) View() string {
 	return s.String()
 }
 
-func RunAuthLoginTUI(db *database.DB, salt []byte, masterPass string) error {
-	p := tea.NewProgram(NewAuthLoginTUI(db, salt, masterPass))
+func RunAuthLoginTUI(session *vault.Session) error {
+	if !session.Unlocked() {
+		return vault.ErrLocked
+	}
+
+	p := tea.NewProgram(NewAuthLoginTUI(session))
 	_, err := p.Run()
 	return err
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/database"
 	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
 )
 
 type view int
@@ -69,11 +70,12 @@ var (
 			Bold(true)
 )
 
-func NewBubbleTea(db *database.DB, salt []byte, passphrase string) *model {
-	encryptor := crypto.NewEncryptor(passphrase, salt)
+// NewBubbleTea creates the menu TUI for an unlocked session
+func NewBubbleTea(session *vault.Session) *model {
+	encryptor, _ := session.Encryptor()
 	keybindings, _ := config.LoadKeybindings()
 	return &model{
-		db:           db,
+		db:           session.DB,
 		encryptor:    encryptor,
 		currentView:  menuView,
 		keybindings:  keybindings,
@@ -348,8 +350,12 @@ func (m model) renderMessage() string {
 	return s.String()
 }
 
-func RunBubbleTea(db *database.DB, salt []byte, passphrase string) error {
-	p := tea.NewProgram(NewBubbleTea(db, salt, passphrase))
+func RunBubbleTea(session *vault.Session) error {
+	if !session.Unlocked() {
+		return vault.ErrLocked
+	}
+
+	p := tea.NewProgram(NewBubbleTea(session))
 	_, err := p.Run()
 	return err
 }
@@ -0,0 +1,166 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/r2unit/openpasswd/pkg/config"
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/database"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// editFinishedMsg is sent by the add form in edit mode when it closes
+type editFinishedMsg struct {
+	saved bool
+	id    int64
+}
+
+// fieldAliases lists the custom field names other sources (e.g. Proton Pass
+// imports) use for the inputs of the add form
+var fieldAliases = map[string][]string{
+	"cardholder": {"cardholder_name"},
+	"expiry":     {"expiration_date"},
+	"phone":      {"phone_number"},
+}
+
+// NewEditTUI opens the add form pre-filled with an existing entry. Saving
+// re-encrypts the entry with the given encryptor and updates it in place.
+func NewEditTUI(db *database.DB, encryptor *crypto.Encryptor, p *models.Password) *addModel {
+	keybindings, _ := config.LoadKeybindings()
+
+	m := &addModel{
+		db:           db,
+		encryptor:    encryptor,
+		editing:      p,
+		step:         1,
+		passwordType: editFormType(p),
+		inputs:       make(map[string]string),
+		fieldKeys:    make(map[string]string),
+		showPassword: make(map[string]bool),
+		width:        80,
+		height:       24,
+		keybindings:  keybindings,
+	}
+	m.setupInputs()
+	m.prefillInputs()
+
+	return m
+}
+
+// editFormType picks the form layout for an entry
+func editFormType(p *models.Password) string {
+	// SSH keys are created by 'openpass ssh-keygen', so the add form doesn't
+	// offer them, but their name, comment and notes can be edited
+	if p.Type == models.TypeSSHKey {
+		return string(models.TypeSSHKey)
+	}
+	for _, pt := range passwordTypes {
+		if string(p.Type) == pt.name {
+			return pt.name
+		}
+	}
+	if p.Username != "" || p.URL != "" {
+		return "login"
+	}
+	return "other"
+}
+
+// prefillInputs decrypts the entry being edited into the form inputs
+func (m *addModel) prefillInputs() {
+	decrypt := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		if plain, err := m.encryptor.Decrypt(value); err == nil {
+			return plain
+		}
+		return value
+	}
+
+	p := m.editing
+	for _, key := range m.inputOrder {
+		switch key {
+		case "name":
+			m.inputs[key] = decrypt(p.Name)
+		case "username":
+			m.inputs[key] = decrypt(p.Username)
+		case "password":
+			m.inputs[key] = decrypt(p.Password)
+		case "url":
+			m.inputs[key] = decrypt(p.URL)
+		case "notes", "content":
+			m.inputs[key] = decrypt(p.Notes)
+		default:
+			if value, ok := p.Fields[key]; ok {
+				m.inputs[key] = decrypt(value)
+				continue
+			}
+			for _, alias := range fieldAliases[key] {
+				if value, ok := p.Fields[alias]; ok {
+					m.inputs[key] = decrypt(value)
+					m.fieldKeys[key] = alias
+					break
+				}
+			}
+		}
+	}
+}
+
+// fieldKey returns the custom field name an input is stored under
+func (m *addModel) fieldKey(input string) string {
+	if key, ok := m.fieldKeys[input]; ok {
+		return key
+	}
+	return input
+}
+
+// editedPassword applies the form inputs to a copy of the entry being
+// edited. The type and every value the form doesn't show (e.g. the username
+// of an "other" entry, or imported TOTP secrets) are kept as they are.
+func (m *addModel) editedPassword() (*models.Password, error) {
+	p := *m.editing
+	p.Fields = make(map[string]string, len(m.editing.Fields))
+	for key, value := range m.editing.Fields {
+		p.Fields[key] = value
+	}
+
+	for _, input := range m.inputOrder {
+		var value string
+		if plain := m.inputs[input]; plain != "" {
+			var err error
+			if value, err = m.encryptor.Encrypt(plain); err != nil {
+				return nil, fmt.Errorf("failed to encrypt %s: %w", input, err)
+			}
+		}
+
+		switch input {
+		case "name":
+			p.Name = value
+		case "username":
+			p.Username = value
+		case "password":
+			p.Password = value
+		case "url":
+			p.URL = value
+		case "notes", "content":
+			p.Notes = value
+		default:
+			if value == "" {
+				delete(p.Fields, m.fieldKey(input))
+			} else {
+				p.Fields[m.fieldKey(input)] = value
+			}
+		}
+	}
+
+	return &p, nil
+}
+
+// finishEdit closes the form and returns control to the list
+func (m addModel) finishEdit(saved bool) tea.Cmd {
+	id := m.editing.ID
+	return func() tea.Msg {
+		return editFinishedMsg{saved: saved, id: id}
+	}
+}
@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+func testEncryptor(t *testing.T) *crypto.Encryptor {
+	t.Helper()
+	enc, err := crypto.NewEncryptorFromKey(bytes.Repeat([]byte{0x42}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+// editEntry opens the edit form on an entry, lets change update the inputs
+// and returns the decrypted result of saving it
+func editEntry(t *testing.T, item *vault.Item, change func(inputs map[string]string)) *vault.Item {
+	t.Helper()
+
+	enc := testEncryptor(t)
+	p, err := vault.Encrypt(enc, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewEditTUI(nil, enc, p)
+	change(m.inputs)
+
+	saved, err := m.editedPassword()
+	if err != nil {
+		t.Fatalf("editedPassword: %v", err)
+	}
+	edited, err := vault.Decrypt(enc, saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return edited
+}
+
+// 1Password server and database items are imported as "other" entries with
+// a username, password and URL, which the "other" form doesn't show
+func TestEditOtherKeepsLogin(t *testing.T) {
+	edited := editEntry(t, &vault.Item{
+		ID:       7,
+		Type:     models.TypeOther,
+		Name:     "db01",
+		Username: "postgres",
+		Password: "s3cret",
+		URL:      "postgres://db01:5432",
+		Fields:   map[string]string{"value": "old", "port": "5432"},
+	}, func(inputs map[string]string) {
+		inputs["name"] = "db01 (primary)"
+		inputs["value"] = "new"
+	})
+
+	if edited.ID != 7 || edited.Type != models.TypeOther || edited.Name != "db01 (primary)" {
+		t.Errorf("edited = %+v", edited)
+	}
+	if edited.Username != "postgres" || edited.Password != "s3cret" || edited.URL != "postgres://db01:5432" {
+		t.Errorf("login values lost: %+v", edited)
+	}
+	if edited.Fields["value"] != "new" || edited.Fields["port"] != "5432" {
+		t.Errorf("fields = %v", edited.Fields)
+	}
+}
+
+// Registry logins have no form of their own and must stay registry logins
+func TestEditRegistryKeepsType(t *testing.T) {
+	edited := editEntry(t, &vault.Item{
+		Type:     models.TypeRegistry,
+		Name:     "ghcr.io",
+		Username: "alice",
+		Password: "ghp_token",
+		URL:      "https://ghcr.io",
+	}, func(inputs map[string]string) {
+		inputs["password"] = "ghp_rotated"
+		inputs["notes"] = "rotated"
+	})
+
+	if edited.Type != models.TypeRegistry {
+		t.Errorf("type = %s, want registry", edited.Type)
+	}
+	if edited.Username != "alice" || edited.Password != "ghp_rotated" || edited.URL != "https://ghcr.io" || edited.Notes != "rotated" {
+		t.Errorf("edited = %+v", edited)
+	}
+}
+
+func TestEditClearsField(t *testing.T) {
+	edited := editEntry(t, &vault.Item{
+		Type:   models.TypeCard,
+		Name:   "Visa",
+		Fields: map[string]string{"cardholder_name": "Alice", "number": "4111111111111111"},
+	}, func(inputs map[string]string) {
+		inputs["cardholder"] = ""
+	})
+
+	if _, ok := edited.Fields["cardholder_name"]; ok || edited.Fields["number"] != "4111111111111111" {
+		t.Errorf("fields = %v", edited.Fields)
+	}
+}
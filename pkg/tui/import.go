@@ -7,29 +7,56 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/r2unit/openpasswd/pkg/crypto"
-	"github.com/r2unit/openpasswd/pkg/database"
+	"github.com/r2unit/openpasswd/pkg/browser"
+	"github.com/r2unit/openpasswd/pkg/config"
+	"github.com/r2unit/openpasswd/pkg/models"
 	"github.com/r2unit/openpasswd/pkg/sources"
+	"github.com/r2unit/openpasswd/pkg/vault"
 )
 
+// ImportOptions configures the import TUI
+type ImportOptions struct {
+	DryRun     bool   // Preview and write the report, but don't change the vault
+	ReportPath string // Where to write the import report; empty uses the imports directory
+}
+
 type importModel struct {
-	db             *database.DB
-	salt           []byte
-	masterPass     string
+	session        *vault.Session
+	opts           ImportOptions
 	importers      []sources.Importer
 	cursor         int
-	step           int // 0: select source, 1: enter file path, 2: enter passphrase (if needed), 3: importing, 4: done
+	step           int // 0: select source, 1: enter file path, 2: enter passphrase (if needed), 3: importing, 4: done, 5: map columns, 6: preview
 	selectedSource sources.Importer
 	filePath       string
 	filePassphrase string
 	filePassInput  string
+	keyFileInput   string
+	keyFileFocus   bool // Typing goes to the key file path instead of the passphrase
+	layout         *browser.Layout
+	mapping        map[string]int // Field to column index, edited in step 5
+	mapCursor      int
+	plan           *vault.ImportPlan
+	planCursor     int // Selected duplicate in step 6
+	reportPath     string
 	errorMsg       string
 	successMsg     string
-	importedCount  int
 	width          int
 	height         int
 }
 
+// importLoadedMsg carries the planned import once the file has been read
+type importLoadedMsg struct {
+	plan     *vault.ImportPlan
+	errorMsg string
+}
+
+// importAppliedMsg carries the report once the plan has been written
+type importAppliedMsg struct {
+	report *vault.ImportReport
+	path   string
+	err    error
+}
+
 var (
 	importTitleStyle = lipgloss.NewStyle().
 				Bold(true).
@@ -57,20 +84,22 @@ var (
 	importSuccessStyle = lipgloss.NewStyle().
 				Foreground(lipgloss.Color("#00FF00")).
 				Bold(true)
+
+	importWarningStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FFFF00"))
 )
 
-func NewImportTUI(db *database.DB, salt []byte, masterPass string) *importModel {
+func NewImportTUI(session *vault.Session, opts ImportOptions) *importModel {
 	importers := sources.GetAvailableImporters()
 
 	return &importModel{
-		db:         db,
-		salt:       salt,
-		masterPass: masterPass,
-		importers:  importers,
-		cursor:     0,
-		step:       0,
-		width:      80,
-		height:     24,
+		session:   session,
+		opts:      opts,
+		importers: importers,
+		cursor:    0,
+		step:      0,
+		width:     80,
+		height:    24,
 	}
 }
 
@@ -84,12 +113,42 @@ func (m importModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 
+	case importLoadedMsg:
+		if msg.errorMsg != "" {
+			m.errorMsg = msg.errorMsg
+			m.step = 1
+			return m, nil
+		}
+		m.plan = msg.plan
+		m.planCursor = 0
+		m.errorMsg = ""
+		m.step = 6
+
+	case importAppliedMsg:
+		m.reportPath = msg.path
+		m.errorMsg = ""
+		if msg.err != nil {
+			m.errorMsg = msg.err.Error()
+		}
+		added, updated, skipped, failed := msg.report.Summary()
+		if m.opts.DryRun {
+			m.successMsg = fmt.Sprintf("Dry run: would add %d, update %d and skip %d entries", added, updated, skipped)
+		} else {
+			m.successMsg = fmt.Sprintf("Imported %d new and updated %d existing entries (%d skipped, %d failed)", added, updated, skipped, failed)
+		}
+		m.step = 4
+
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "q":
 			if m.step == 0 || m.step == 4 {
 				return m, tea.Quit
 			}
+			if m.step == 5 || m.step == 6 {
+				m.step = 1
+				m.errorMsg = ""
+				return m, nil
+			}
 			// Go back on other steps
 			if m.step > 0 && m.step < 4 {
 				m.step--
@@ -98,31 +157,58 @@ func (m importModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.filePath = ""
 					m.filePassphrase = ""
 					m.filePassInput = ""
+					m.keyFileInput = ""
+					m.keyFileFocus = false
 				}
 				return m, nil
 			}
 
 		case "esc":
-			if m.step > 0 && m.step < 4 {
+			if m.step == 5 || m.step == 6 {
+				m.step = 1
+				m.errorMsg = ""
+			} else if m.step > 0 && m.step < 4 {
 				m.step--
 				m.errorMsg = ""
 				if m.step == 0 {
 					m.filePath = ""
 					m.filePassphrase = ""
 					m.filePassInput = ""
+					m.keyFileInput = ""
+					m.keyFileFocus = false
 				}
 			} else {
 				return m, tea.Quit
 			}
 
+		case "tab":
+			if m.step == 2 && m.keyFileImporter() != nil {
+				m.keyFileFocus = !m.keyFileFocus
+			}
+
 		case "up", "k":
 			if m.step == 0 && m.cursor > 0 {
 				m.cursor--
+			} else if m.step == 5 && m.mapCursor > 0 {
+				m.mapCursor--
+			} else if m.step == 6 && m.planCursor > 0 {
+				m.planCursor--
 			}
 
 		case "down", "j":
 			if m.step == 0 && m.cursor < len(m.importers)-1 {
 				m.cursor++
+			} else if m.step == 5 && m.mapCursor < len(browser.Fields)-1 {
+				m.mapCursor++
+			} else if m.step == 6 && m.planCursor < len(m.plan.Duplicates())-1 {
+				m.planCursor++
+			}
+
+		case "left", "right", " ":
+			if m.step == 5 && msg.String() != " " {
+				m.cycleColumn(msg.String() == "right")
+			} else if m.step == 6 {
+				m.cycleResolution(msg.String() != "left")
 			}
 
 		case "enter":
@@ -141,32 +227,82 @@ func (m importModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.errorMsg = "File does not exist: " + m.filePath
 					return m, nil
 				}
+				// Files in an unrecognised layout need their columns mapped first
+				if mapper, ok := m.selectedSource.(sources.ColumnMapper); ok {
+					layout, err := mapper.DetectColumns(m.filePath)
+					if err != nil {
+						m.errorMsg = err.Error()
+						return m, nil
+					}
+					mapper.SetColumnMapping(nil)
+					if !layout.Known() {
+						m.layout = layout
+						m.mapping = make(map[string]int, len(layout.Mapping))
+						for field, idx := range layout.Mapping {
+							m.mapping[field] = idx
+						}
+						m.mapCursor = 0
+						m.errorMsg = ""
+						m.step = 5
+						return m, nil
+					}
+				}
 				// Check if source needs passphrase (for encrypted files)
-				ext := strings.ToLower(m.filePath[strings.LastIndex(m.filePath, "."):])
-				if ext == ".zip" || ext == ".pgp" {
+				if sources.NeedsPassphrase(m.selectedSource, m.filePath) {
 					m.step = 2 // Ask for passphrase
 				} else {
 					m.step = 3 // Start import
-					return m, m.performImport()
+					return m, m.loadImport()
 				}
 			case 2: // Passphrase entered
 				m.filePassphrase = m.filePassInput
+				if kf := m.keyFileImporter(); kf != nil {
+					kf.SetKeyFile(m.keyFileInput)
+				}
 				m.step = 3
-				return m, m.performImport()
+				return m, m.loadImport()
 			case 4: // Done
 				return m, tea.Quit
+			case 5: // Columns mapped
+				if len(m.mapping) == 0 {
+					m.errorMsg = "Map at least one column"
+					return m, nil
+				}
+				m.selectedSource.(sources.ColumnMapper).SetColumnMapping(m.mapping)
+				m.errorMsg = ""
+				m.step = 3
+				return m, m.loadImport()
+			case 6: // Preview confirmed
+				m.step = 3
+				return m, m.applyImport()
 			}
 
 		case "backspace":
 			if m.step == 1 && len(m.filePath) > 0 {
 				m.filePath = m.filePath[:len(m.filePath)-1]
-			} else if m.step == 2 && len(m.filePassInput) > 0 {
+			} else if m.step == 2 && m.keyFileFocus && len(m.keyFileInput) > 0 {
+				m.keyFileInput = m.keyFileInput[:len(m.keyFileInput)-1]
+			} else if m.step == 2 && !m.keyFileFocus && len(m.filePassInput) > 0 {
 				m.filePassInput = m.filePassInput[:len(m.filePassInput)-1]
 			}
 
 		default:
-			if m.step == 1 && len(msg.String()) == 1 {
+			if m.step == 6 {
+				// Bulk choices for every duplicate
+				switch msg.String() {
+				case "s":
+					m.plan.ResolveAll(vault.ResolveSkip)
+				case "o":
+					m.plan.ResolveAll(vault.ResolveOverwrite)
+				case "b":
+					m.plan.ResolveAll(vault.ResolveKeepBoth)
+				case "m":
+					m.plan.ResolveAll(vault.ResolveMerge)
+				}
+			} else if m.step == 1 && len(msg.String()) == 1 {
 				m.filePath += msg.String()
+			} else if m.step == 2 && m.keyFileFocus && len(msg.String()) == 1 {
+				m.keyFileInput += msg.String()
 			} else if m.step == 2 && len(msg.String()) == 1 {
 				m.filePassInput += msg.String()
 			}
@@ -176,89 +312,153 @@ func (m importModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m *importModel) performImport() tea.Cmd {
+// cycleColumn moves the field under the cursor to the next or previous
+// column, passing through "not mapped"
+func (m *importModel) cycleColumn(forward bool) {
+	field := browser.Fields[m.mapCursor]
+	idx, ok := m.mapping[field]
+	if !ok {
+		idx = -1
+	}
+
+	n := len(m.layout.Columns)
+	if forward {
+		idx++
+	} else {
+		idx--
+	}
+	// -1 is "not mapped"; wrap around at both ends
+	if idx >= n {
+		idx = -1
+	} else if idx < -1 {
+		idx = n - 1
+	}
+
+	if idx == -1 {
+		delete(m.mapping, field)
+	} else {
+		m.mapping[field] = idx
+	}
+}
+
+// keyFileImporter returns the selected importer if it accepts a key file
+func (m importModel) keyFileImporter() sources.KeyFileImporter {
+	kf, _ := m.selectedSource.(sources.KeyFileImporter)
+	return kf
+}
+
+// cycleResolution changes the resolution of the selected duplicate
+func (m *importModel) cycleResolution(forward bool) {
+	dups := m.plan.Duplicates()
+	if m.planCursor >= len(dups) {
+		return
+	}
+	entry := dups[m.planCursor]
+
+	i := 0
+	for j, r := range vault.Resolutions {
+		if r == entry.Resolution {
+			i = j
+		}
+	}
+	n := len(vault.Resolutions)
+	if forward {
+		i = (i + 1) % n
+	} else {
+		i = (i + n - 1) % n
+	}
+	entry.Resolution = vault.Resolutions[i]
+}
+
+// loadImport reads the export file and plans the import against the vault
+func (m importModel) loadImport() tea.Cmd {
+	source, path, passphrase := m.selectedSource, m.filePath, m.filePassphrase
+	session := m.session
+
 	return func() tea.Msg {
-		passwords, err := m.selectedSource.Import(m.filePath, m.filePassphrase)
+		passwords, err := source.Import(path, passphrase)
 		if err != nil {
-			m.errorMsg = fmt.Sprintf("Import failed: %v", err)
-			m.step = 1
-			return nil
+			return importLoadedMsg{errorMsg: fmt.Sprintf("Import failed: %v", err)}
 		}
-
 		if len(passwords) == 0 {
-			m.errorMsg = "No passwords found in file"
-			m.step = 1
-			return nil
+			return importLoadedMsg{errorMsg: "No passwords found in file"}
 		}
 
-		// Encrypt and save passwords
-		encryptor := crypto.NewEncryptor(m.masterPass, m.salt)
-		successCount := 0
+		existing, err := session.Items()
+		if err != nil {
+			return importLoadedMsg{errorMsg: fmt.Sprintf("Failed to read the vault: %v", err)}
+		}
 
+		imported := make([]*vault.Item, 0, len(passwords))
 		for _, pwd := range passwords {
-			// Encrypt all fields
-			if pwd.Name != "" {
-				encrypted, err := encryptor.Encrypt(pwd.Name)
-				if err != nil {
-					continue
-				}
-				pwd.Name = encrypted
-			}
+			imported = append(imported, vault.FromPassword(pwd))
+		}
 
-			if pwd.Username != "" {
-				encrypted, err := encryptor.Encrypt(pwd.Username)
-				if err != nil {
-					continue
-				}
-				pwd.Username = encrypted
-			}
+		plan := vault.PlanImport(existing, imported)
+		plan.Skipped = sources.Skipped(source)
+		return importLoadedMsg{plan: plan}
+	}
+}
 
-			if pwd.Password != "" {
-				encrypted, err := encryptor.Encrypt(pwd.Password)
-				if err != nil {
-					continue
-				}
-				pwd.Password = encrypted
-			}
+// applyImport writes the previewed plan (unless this is a dry run) and
+// saves the import report
+func (m importModel) applyImport() tea.Cmd {
+	plan, session, opts := m.plan, m.session, m.opts
+	source, file := m.selectedSource.GetName(), m.filePath
 
-			if pwd.URL != "" {
-				encrypted, err := encryptor.Encrypt(pwd.URL)
-				if err != nil {
-					continue
-				}
-				pwd.URL = encrypted
-			}
+	return func() tea.Msg {
+		var report *vault.ImportReport
+		if opts.DryRun {
+			report = plan.DryRun()
+		} else {
+			report = plan.Apply(session)
+		}
+		report.Source, report.File = source, file
 
-			if pwd.Notes != "" {
-				encrypted, err := encryptor.Encrypt(pwd.Notes)
-				if err != nil {
-					continue
-				}
-				pwd.Notes = encrypted
-			}
-
-			// Encrypt custom fields
-			for key, val := range pwd.Fields {
-				encrypted, err := encryptor.Encrypt(val)
-				if err != nil {
-					continue
-				}
-				pwd.Fields[key] = encrypted
+		path := opts.ReportPath
+		if path == "" {
+			var err error
+			if path, err = config.ImportReportPath(report.Time); err != nil {
+				return importAppliedMsg{report: report, err: fmt.Errorf("failed to save import report: %w", err)}
 			}
+		}
+		if err := report.Save(path); err != nil {
+			return importAppliedMsg{report: report, err: err}
+		}
 
-			// Save to database
-			if err := m.db.AddPassword(pwd); err != nil {
-				continue
-			}
+		return importAppliedMsg{report: report, path: path}
+	}
+}
 
-			successCount++
+// typeCounts describes the number of items per type, e.g. "3 logins, 1 card"
+func typeCounts(counts map[models.PasswordType]int) string {
+	order := []models.PasswordType{models.TypeLogin, models.TypeCard, models.TypeNote, models.TypeIdentity, models.TypePassword, models.TypeSSHKey, models.TypeOther}
+	for t := range counts {
+		known := false
+		for _, o := range order {
+			known = known || o == t
+		}
+		if !known {
+			order = append(order, t)
 		}
+	}
 
-		m.importedCount = successCount
-		m.successMsg = fmt.Sprintf("Successfully imported %d passwords", successCount)
-		m.step = 4
-		return nil
+	var parts []string
+	for _, t := range order {
+		n := counts[t]
+		if n == 0 {
+			continue
+		}
+		name := string(t)
+		if name == "" {
+			name = "other"
+		}
+		if n != 1 {
+			name += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, name))
 	}
+	return strings.Join(parts, ", ")
 }
 
 func (m importModel) View() string {
@@ -307,18 +507,34 @@ func (m importModel) View() string {
 		s.WriteString(importLabelStyle.Render("File: "))
 		s.WriteString(importValueStyle.Render(m.filePath))
 		s.WriteString("\n\n")
+		passCursor, keyCursor := "▋", ""
+		if m.keyFileFocus {
+			passCursor, keyCursor = "", "▋"
+		}
 		s.WriteString(importLabelStyle.Render("Enter export passphrase: "))
-		s.WriteString(importValueStyle.Render(strings.Repeat("•", len(m.filePassInput)) + "▋"))
-		s.WriteString("\n\n")
+		s.WriteString(importValueStyle.Render(strings.Repeat("•", len(m.filePassInput)) + passCursor))
+		s.WriteString("\n")
+		if m.keyFileImporter() != nil {
+			s.WriteString(importLabelStyle.Render("Key file (optional):     "))
+			s.WriteString(importValueStyle.Render(m.keyFileInput + keyCursor))
+			s.WriteString("\n")
+		}
+		s.WriteString("\n")
 
 		if m.errorMsg != "" {
 			s.WriteString(importErrorStyle.Render("✗ " + m.errorMsg))
 			s.WriteString("\n\n")
 		}
 
-		s.WriteString(importNormalStyle.Render("Enter the passphrase you used when exporting"))
-		s.WriteString("\n")
-		s.WriteString(importNormalStyle.Render("enter: import • esc: back • q: quit"))
+		if m.keyFileImporter() != nil {
+			s.WriteString(importNormalStyle.Render("Enter the database passphrase and/or the path to its key file"))
+			s.WriteString("\n")
+			s.WriteString(importNormalStyle.Render("tab: switch field • enter: import • esc: back • q: quit"))
+		} else {
+			s.WriteString(importNormalStyle.Render("Enter the passphrase you used when exporting"))
+			s.WriteString("\n")
+			s.WriteString(importNormalStyle.Render("enter: import • esc: back • q: quit"))
+		}
 
 	case 3: // Importing
 		s.WriteString(importLabelStyle.Render("Importing passwords..."))
@@ -329,19 +545,177 @@ func (m importModel) View() string {
 		if m.successMsg != "" {
 			s.WriteString(importSuccessStyle.Render("✓ " + m.successMsg))
 			s.WriteString("\n\n")
-			s.WriteString(importNormalStyle.Render("Your passwords have been securely imported and encrypted."))
-		} else if m.errorMsg != "" {
+			if m.opts.DryRun {
+				s.WriteString(importNormalStyle.Render("Nothing was written to your vault."))
+			} else {
+				s.WriteString(importNormalStyle.Render("Your passwords have been securely imported and encrypted."))
+			}
+		}
+		if m.reportPath != "" {
+			s.WriteString("\n")
+			s.WriteString(importLabelStyle.Render("Report: "))
+			s.WriteString(importValueStyle.Render(m.reportPath))
+		}
+		if m.errorMsg != "" {
+			s.WriteString("\n\n")
 			s.WriteString(importErrorStyle.Render("✗ " + m.errorMsg))
 		}
 		s.WriteString("\n\n")
 		s.WriteString(importNormalStyle.Render("Press any key to exit"))
+
+	case 5: // Map columns
+		s.WriteString(importLabelStyle.Render(fmt.Sprintf("Import from: %s", m.selectedSource.GetName())))
+		s.WriteString("\n\n")
+		s.WriteString(importLabelStyle.Render("File: "))
+		s.WriteString(importValueStyle.Render(m.filePath))
+		s.WriteString("\n\n")
+		s.WriteString(importNormalStyle.Render("The CSV layout wasn't recognised. Choose the column for each field:"))
+		s.WriteString("\n\n")
+
+		for i, field := range browser.Fields {
+			column := "(not mapped)"
+			if idx, ok := m.mapping[field]; ok {
+				column = m.layout.Columns[idx]
+				if idx < len(m.layout.Sample) && m.layout.Sample[idx] != "" {
+					sample := m.layout.Sample[idx]
+					if field == browser.FieldPassword || field == browser.FieldTOTP {
+						sample = "••••••"
+					} else if r := []rune(sample); len(r) > 30 {
+						sample = string(r[:30]) + "…"
+					}
+					column += fmt.Sprintf("  (e.g. %q)", sample)
+				}
+			}
+
+			line := fmt.Sprintf("%-10s %s", field, column)
+			if i == m.mapCursor {
+				s.WriteString(importSelectedStyle.Render("→ " + line))
+			} else {
+				s.WriteString(importNormalStyle.Render("  " + line))
+			}
+			s.WriteString("\n")
+		}
+		s.WriteString("\n")
+
+		if m.errorMsg != "" {
+			s.WriteString(importErrorStyle.Render("✗ " + m.errorMsg))
+			s.WriteString("\n\n")
+		}
+
+		s.WriteString(importNormalStyle.Render("↑/↓: field • ←/→: column • enter: import • esc: back"))
+
+	case 6: // Preview
+		m.viewPreview(&s)
 	}
 
 	return s.String()
 }
 
-func RunImportTUI(db *database.DB, salt []byte, masterPass string) error {
-	p := tea.NewProgram(NewImportTUI(db, salt, masterPass))
+// maxPreviewRows is the number of duplicates or warnings listed at once
+const maxPreviewRows = 10
+
+func (m importModel) viewPreview(s *strings.Builder) {
+	s.WriteString(importLabelStyle.Render(fmt.Sprintf("Import from: %s", m.selectedSource.GetName())))
+	s.WriteString("\n\n")
+	s.WriteString(importLabelStyle.Render("File: "))
+	s.WriteString(importValueStyle.Render(m.filePath))
+	s.WriteString("\n")
+	if m.opts.DryRun {
+		s.WriteString(importWarningStyle.Render("Dry run: the vault won't be changed"))
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+
+	s.WriteString(importValueStyle.Render(fmt.Sprintf("Found %d items: %s", len(m.plan.Entries), typeCounts(m.plan.Counts()))))
+	s.WriteString("\n")
+
+	dups := m.plan.Duplicates()
+	if len(dups) == 0 {
+		s.WriteString(importNormalStyle.Render("No duplicates of existing entries"))
+		s.WriteString("\n")
+	} else {
+		s.WriteString(importValueStyle.Render(fmt.Sprintf("%d already in the vault:", len(dups))))
+		s.WriteString("\n\n")
+
+		// Scroll so the cursor stays visible
+		first := 0
+		if m.planCursor >= maxPreviewRows {
+			first = m.planCursor - maxPreviewRows + 1
+		}
+		for i := first; i < len(dups) && i < first+maxPreviewRows; i++ {
+			e := dups[i]
+			line := fmt.Sprintf("%-12s %s — %s (id %d)", "["+e.Resolution.String()+"]", e.Item.Name, e.Reason, e.Existing.ID)
+			if i == m.planCursor {
+				s.WriteString(importSelectedStyle.Render("→ " + line))
+			} else {
+				s.WriteString(importNormalStyle.Render("  " + line))
+			}
+			s.WriteString("\n")
+		}
+		if more := len(dups) - first - maxPreviewRows; more > 0 {
+			s.WriteString(importNormalStyle.Render(fmt.Sprintf("  … %d more", more)))
+			s.WriteString("\n")
+		}
+	}
+
+	if n := len(m.plan.Skipped); n > 0 {
+		s.WriteString("\n")
+		s.WriteString(importWarningStyle.Render(fmt.Sprintf("⚠ %d source items could not be read and will be left out:", n)))
+		s.WriteString("\n")
+		for i, skipped := range m.plan.Skipped {
+			if i == maxPreviewRows {
+				s.WriteString(importNormalStyle.Render(fmt.Sprintf("  … %d more in the report", n-i)))
+				s.WriteString("\n")
+				break
+			}
+			s.WriteString(importNormalStyle.Render("  " + skipped))
+			s.WriteString("\n")
+		}
+	}
+
+	if n := m.plan.Warnings(); n > 0 {
+		s.WriteString("\n")
+		s.WriteString(importWarningStyle.Render(fmt.Sprintf("⚠ %d items with warnings:", n)))
+		s.WriteString("\n")
+		shown := 0
+		for _, e := range m.plan.Entries {
+			if len(e.Warnings) == 0 {
+				continue
+			}
+			if shown == maxPreviewRows {
+				s.WriteString(importNormalStyle.Render(fmt.Sprintf("  … %d more in the report", n-shown)))
+				s.WriteString("\n")
+				break
+			}
+			name := e.Item.Name
+			if name == "" {
+				name = "(unnamed)"
+			}
+			s.WriteString(importNormalStyle.Render(fmt.Sprintf("  %s: %s", name, strings.Join(e.Warnings, ", "))))
+			s.WriteString("\n")
+			shown++
+		}
+	}
+	s.WriteString("\n")
+
+	if m.errorMsg != "" {
+		s.WriteString(importErrorStyle.Render("✗ " + m.errorMsg))
+		s.WriteString("\n\n")
+	}
+
+	action := "import"
+	if m.opts.DryRun {
+		action = "write report"
+	}
+	if len(dups) > 0 {
+		s.WriteString(importNormalStyle.Render("↑/↓: select • ←/→/space: change • all: s skip, o overwrite, b keep both, m merge"))
+		s.WriteString("\n")
+	}
+	s.WriteString(importNormalStyle.Render(fmt.Sprintf("enter: %s • esc: back", action)))
+}
+
+func RunImportTUI(session *vault.Session, opts ImportOptions) error {
+	p := tea.NewProgram(NewImportTUI(session, opts))
 	_, err := p.Run()
 	return err
 }
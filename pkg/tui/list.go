@@ -2,16 +2,18 @@ package tui
 
 import (
 	"fmt"
-	"os/exec"
-	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/r2unit/openpasswd/pkg/clipboard"
 	"github.com/r2unit/openpasswd/pkg/config"
 	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/database"
 	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
 )
 
 type listModel struct {
@@ -31,6 +33,10 @@ type listModel struct {
 	width             int
 	height            int
 	keybindings       config.Keybindings
+	clipboardClear    time.Duration
+	editor            *addModel        // Edit form shown over the list, nil when closed
+	deleteTarget      *models.Password // Entry awaiting delete confirmation
+	statusMessage     string
 }
 
 type detailField struct {
@@ -76,12 +82,20 @@ var (
 				Background(lipgloss.Color("#3A3A00")).
 				Bold(true).
 				Italic(true)
+
+	listDialogStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#FF5F5F")).
+			Padding(1, 2)
 )
 
-func NewListTUI(db *database.DB, salt []byte, passphrase string) *listModel {
-	encryptor := crypto.NewEncryptor(passphrase, salt)
-	passwords, _ := db.ListPasswords()
+// NewListTUI creates the password list for an unlocked session
+func NewListTUI(session *vault.Session) *listModel {
+	db := session.DB
+	encryptor, _ := session.Encryptor()
+	passwords := loadPasswords(db)
 	keybindings, _ := config.LoadKeybindings()
+	clipboardClear := loadClipboardSettings()
 
 	return &listModel{
 		db:                db,
@@ -94,6 +108,7 @@ func NewListTUI(db *database.DB, salt []byte, passphrase string) *listModel {
 		width:             80,
 		height:            24,
 		keybindings:       keybindings,
+		clipboardClear:    clipboardClear,
 	}
 }
 
@@ -102,6 +117,35 @@ func (m listModel) Init() tea.Cmd {
 }
 
 func (m listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if done, ok := msg.(editFinishedMsg); ok {
+		m.editor = nil
+		if done.saved {
+			m.reload()
+			if m.showDetails {
+				if p, err := m.db.GetPassword(done.id); err == nil {
+					m.selectedPass = p
+					m.buildDetailFields()
+				}
+				m.copiedMessage = "✓ Entry updated"
+			} else {
+				m.statusMessage = "✓ Entry updated"
+			}
+		}
+		return m, nil
+	}
+
+	// The edit form owns the screen while it is open
+	if m.editor != nil {
+		if size, ok := msg.(tea.WindowSizeMsg); ok {
+			m.width = size.Width
+			m.height = size.Height
+		}
+		updated, cmd := m.editor.Update(msg)
+		editor := updated.(addModel)
+		m.editor = &editor
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -110,8 +154,20 @@ func (m listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		key := msg.String()
 
+		if m.deleteTarget != nil {
+			switch key {
+			case "y", "Y":
+				m.deleteSelected()
+			case "n", "N", m.keybindings.Back, m.keybindings.QuitAlt:
+				m.deleteTarget = nil
+			}
+			return m, nil
+		}
+
+		m.statusMessage = ""
+
 		// Handle command mode (nvim-style)
-		if key == ":" && m.commandInput == "" && m.searchInput == "" && !m.showDetails {
+		if key == ":" && m.commandInput == "" && m.searchInput == "" {
 			m.commandInput = ":"
 			return m, nil
 		}
@@ -120,10 +176,23 @@ func (m listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.commandInput != "" {
 			if key == "enter" {
 				// Execute command
-				if m.commandInput == m.keybindings.Quit {
+				command := m.commandInput
+				m.commandInput = ""
+				switch command {
+				case m.keybindings.Quit:
+					if m.showDetails {
+						m.showDetails = false
+						m.showPassword = false
+						return m, nil
+					}
 					return m, tea.Quit
+				case ":edit", ":e":
+					return m, m.startEdit()
+				case ":delete", ":d":
+					m.startDelete()
+				default:
+					m.setStatus(fmt.Sprintf("✗ Unknown command: %s", command))
 				}
-				m.commandInput = ""
 				return m, nil
 			} else if key == "backspace" {
 				if len(m.commandInput) > 0 {
@@ -190,19 +259,36 @@ func (m listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "c":
 			if m.showDetails {
 				allText := m.buildAllFieldsText()
-				if err := copyToClipboard(allText); err == nil {
-					m.copiedMessage = "✓ Copied all fields to clipboard"
+				if err := clipboard.CopyWithTimeout(allText, m.clipboardClear); err == nil {
+					m.copiedMessage = "✓ Copied all fields to clipboard" + clearNotice(m.clipboardClear)
 				} else {
 					m.copiedMessage = fmt.Sprintf("✗ Failed to copy: %v", err)
 				}
+			} else {
+				m.appendSearch(key)
+			}
+
+		// Edit and delete act on the selected row of the list too, except
+		// while a search is being typed, where they are part of the query
+		case m.keybindings.Edit:
+			if m.showDetails || m.searchInput == "" {
+				return m, m.startEdit()
+			}
+			m.appendSearch(key)
+
+		case m.keybindings.Delete:
+			if m.showDetails || m.searchInput == "" {
+				m.startDelete()
+			} else {
+				m.appendSearch(key)
 			}
 
 		case m.keybindings.Select:
 			if m.showDetails {
 				if len(m.detailFields) > 0 && m.detailCursor < len(m.detailFields) {
 					field := m.detailFields[m.detailCursor]
-					if err := copyToClipboard(field.value); err == nil {
-						m.copiedMessage = fmt.Sprintf("✓ Copied %s to clipboard", field.label)
+					if err := clipboard.CopyWithTimeout(field.value, m.clipboardClear); err == nil {
+						m.copiedMessage = fmt.Sprintf("✓ Copied %s to clipboard", field.label) + clearNotice(m.clipboardClear)
 					} else {
 						m.copiedMessage = fmt.Sprintf("✗ Failed to copy: %v", err)
 					}
@@ -224,17 +310,117 @@ func (m listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		default:
-			if !m.showDetails && len(msg.String()) == 1 {
-				m.searchInput += msg.String()
-				m.filterPasswords()
-				m.cursor = 0
-			}
+			m.appendSearch(msg.String())
 		}
 	}
 
 	return m, nil
 }
 
+// appendSearch adds a typed character to the search query in the list view
+func (m *listModel) appendSearch(key string) {
+	if !m.showDetails && len(key) == 1 {
+		m.searchInput += key
+		m.filterPasswords()
+		m.cursor = 0
+	}
+}
+
+// target returns the entry that edit and delete act on
+func (m *listModel) target() *models.Password {
+	if m.showDetails {
+		return m.selectedPass
+	}
+	if m.cursor < len(m.filteredPasswords) {
+		return m.filteredPasswords[m.cursor]
+	}
+	return nil
+}
+
+// startEdit opens the add form pre-filled with the target entry
+func (m *listModel) startEdit() tea.Cmd {
+	p := m.target()
+	if p == nil {
+		return nil
+	}
+	m.editor = NewEditTUI(m.db, m.encryptor, p)
+	return m.editor.Init()
+}
+
+// startDelete asks for confirmation before deleting the target entry
+func (m *listModel) startDelete() {
+	m.deleteTarget = m.target()
+}
+
+// deleteSelected removes the entry awaiting confirmation
+func (m *listModel) deleteSelected() {
+	p := m.deleteTarget
+	m.deleteTarget = nil
+
+	name := m.decryptName(p)
+	if err := m.db.DeletePassword(p.ID); err != nil {
+		m.setStatus(fmt.Sprintf("✗ Failed to delete: %v", err))
+		return
+	}
+
+	m.showDetails = false
+	m.showPassword = false
+	m.selectedPass = nil
+	m.reload()
+	m.statusMessage = fmt.Sprintf("✓ Deleted %s", name)
+}
+
+// setStatus shows a message in whichever view is active
+func (m *listModel) setStatus(msg string) {
+	if m.showDetails {
+		m.copiedMessage = msg
+	} else {
+		m.statusMessage = msg
+	}
+}
+
+// reload refreshes the entries from the database, keeping the cursor on the
+// same entry when it still exists
+func (m *listModel) reload() {
+	var currentID int64
+	if m.cursor < len(m.filteredPasswords) {
+		currentID = m.filteredPasswords[m.cursor].ID
+	}
+
+	m.passwords = loadPasswords(m.db)
+	m.filterPasswords()
+
+	m.cursor = 0
+	for i, p := range m.filteredPasswords {
+		if p.ID == currentID {
+			m.cursor = i
+			break
+		}
+	}
+}
+
+func (m *listModel) decryptName(p *models.Password) string {
+	if name, err := m.encryptor.Decrypt(p.Name); err == nil {
+		return name
+	}
+	return p.Name
+}
+
+// loadPasswords lists the entries in a stable order, without hidden types
+func loadPasswords(db *database.DB) []*models.Password {
+	all, _ := db.ListPasswords()
+	passwords := all[:0]
+	for _, p := range all {
+		if !p.Type.Hidden() {
+			passwords = append(passwords, p)
+		}
+	}
+	sort.Slice(passwords, func(i, j int) bool {
+		return passwords[i].ID < passwords[j].ID
+	})
+	return passwords
+}
+
 func (m *listModel) filterPasswords() {
 	if m.searchInput == "" {
 		m.filteredPasswords = m.passwords
@@ -285,6 +471,14 @@ func (m *listModel) filterPasswords() {
 }
 
 func (m listModel) View() string {
+	if m.editor != nil {
+		return m.editor.View()
+	}
+
+	if m.deleteTarget != nil {
+		return m.renderDeleteDialog()
+	}
+
 	if m.showDetails {
 		return m.renderDetails()
 	}
@@ -412,15 +606,42 @@ func (m listModel) View() string {
 
 	s.WriteString("\n")
 
+	if m.statusMessage != "" {
+		if strings.HasPrefix(m.statusMessage, "✓") {
+			s.WriteString(addSuccessStyle.Render(m.statusMessage))
+		} else {
+			s.WriteString(addErrorStyle.Render(m.statusMessage))
+		}
+		s.WriteString("\n\n")
+	}
+
 	if m.commandInput != "" {
 		s.WriteString(listSelectedStyle.Render(m.commandInput + "▋"))
 	} else {
-		s.WriteString(listNormalStyle.Render("↑/↓ or k/j: navigate • enter: view details • type to search • esc: clear/back • :q or ctrl+c: quit"))
+		s.WriteString(listNormalStyle.Render("↑/↓ or k/j: navigate • enter: view details • type to search • e: edit • d: delete • esc: clear/back • :q or ctrl+c: quit"))
 	}
 
 	return s.String()
 }
 
+func (m listModel) renderDeleteDialog() string {
+	var s strings.Builder
+
+	s.WriteString(addErrorStyle.Render("Delete entry?"))
+	s.WriteString("\n\n")
+	s.WriteString(listLabelStyle.Render("Name: "))
+	s.WriteString(listValueStyle.Render(m.decryptName(m.deleteTarget)))
+	s.WriteString("\n")
+	s.WriteString(listLabelStyle.Render("Type: "))
+	s.WriteString(listValueStyle.Render(string(m.deleteTarget.Type)))
+	s.WriteString("\n\n")
+	s.WriteString(listNormalStyle.Render("This cannot be undone."))
+	s.WriteString("\n\n")
+	s.WriteString(listNormalStyle.Render("y: delete • n/esc: cancel"))
+
+	return listDialogStyle.Render(s.String())
+}
+
 func (m listModel) renderDetails() string {
 	if m.selectedPass == nil {
 		return "No password selected"
@@ -485,7 +706,7 @@ func (m listModel) renderDetails() string {
 	if m.commandInput != "" {
 		s.WriteString(listSelectedStyle.Render(m.commandInput + "▋"))
 	} else {
-		s.WriteString(listNormalStyle.Render("↑/↓ or k/j: select field • enter: copy • c: copy all • tab: toggle password • :q/esc/ctrl+c: go back"))
+		s.WriteString(listNormalStyle.Render("↑/↓ or k/j: select field • enter: copy • c: copy all • tab: toggle password • e: edit • d: delete • :q/esc/ctrl+c: go back"))
 	}
 
 	return s.String()
@@ -561,46 +782,23 @@ func (m *listModel) buildAllFieldsText() string {
 	return s.String()
 }
 
-func copyToClipboard(text string) error {
-	var cmd *exec.Cmd
-
-	switch runtime.GOOS {
-	case "linux":
-		if _, err := exec.LookPath("xclip"); err == nil {
-			cmd = exec.Command("xclip", "-selection", "clipboard")
-		} else if _, err := exec.LookPath("xsel"); err == nil {
-			cmd = exec.Command("xsel", "--clipboard", "--input")
-		} else if _, err := exec.LookPath("wl-copy"); err == nil {
-			cmd = exec.Command("wl-copy")
-		} else {
-			return fmt.Errorf("no clipboard utility found (install xclip, xsel, or wl-clipboard)")
-		}
-	case "darwin":
-		cmd = exec.Command("pbcopy")
-	case "windows":
-		cmd = exec.Command("clip")
-	default:
-		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
-	}
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
-	}
-
-	if err := cmd.Start(); err != nil {
-		return err
-	}
-
-	if _, err := stdin.Write([]byte(text)); err != nil {
-		return err
+// loadClipboardSettings applies the configured clipboard backend and returns
+// how long copied values stay on the clipboard
+func loadClipboardSettings() time.Duration {
+	cfg, _ := config.LoadClipboardConfig()
+	// An unusable backend name falls back to detection
+	if err := clipboard.UseBackend(cfg.Backend); err != nil {
+		_ = clipboard.UseBackend("auto")
 	}
+	return cfg.ClearAfterDuration()
+}
 
-	if err := stdin.Close(); err != nil {
-		return err
+// clearNotice describes when the clipboard will be cleared
+func clearNotice(after time.Duration) string {
+	if after <= 0 {
+		return ""
 	}
-
-	return cmd.Wait()
+	return fmt.Sprintf(" (clears in %s)", after)
 }
 
 func truncateString(s string, max int) string {
@@ -653,9 +851,13 @@ func highlightText(text, query string, normalStyle, highlightStyle lipgloss.Styl
 	return result.String()
 }
 
-func RunListTUI(db *database.DB, salt []byte, passphrase string) error {
+func RunListTUI(session *vault.Session) error {
+	if !session.Unlocked() {
+		return vault.ErrLocked
+	}
+
 	p := tea.NewProgram(
-		NewListTUI(db, salt, passphrase),
+		NewListTUI(session),
 	)
 	_, err := p.Run()
 	return err
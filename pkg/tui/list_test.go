@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/r2unit/openpasswd/pkg/config"
+	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
+)
+
+func testList(t *testing.T, names ...string) listModel {
+	t.Helper()
+
+	enc := testEncryptor(t)
+	var passwords []*models.Password
+	for i, name := range names {
+		p, err := vault.Encrypt(enc, &vault.Item{ID: int64(i + 1), Type: models.TypeLogin, Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		passwords = append(passwords, p)
+	}
+
+	return listModel{
+		encryptor:         enc,
+		passwords:         passwords,
+		filteredPasswords: passwords,
+		keybindings:       config.DefaultKeybindings(),
+	}
+}
+
+func press(m listModel, keys ...string) listModel {
+	for _, key := range keys {
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = updated.(listModel)
+	}
+	return m
+}
+
+func TestListEditDeleteKeys(t *testing.T) {
+	m := press(testList(t, "GitHub", "GitLab"), "j", "d")
+	if m.deleteTarget == nil || m.deleteTarget.ID != 2 {
+		t.Errorf("d in the list: delete target = %v, want entry 2", m.deleteTarget)
+	}
+
+	m = press(testList(t, "GitHub", "GitLab"), "j", "e")
+	if m.editor == nil || m.editor.editing.ID != 2 {
+		t.Error("e in the list didn't open the selected entry")
+	}
+
+	// While searching they are typed into the query
+	m = press(testList(t, "GitHub", "Reddit"), "r", "e", "d")
+	if m.searchInput != "red" || m.editor != nil || m.deleteTarget != nil {
+		t.Errorf("search = %q, editor open %v, delete target %v", m.searchInput, m.editor != nil, m.deleteTarget)
+	}
+}
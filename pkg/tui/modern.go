@@ -12,6 +12,7 @@ import (
 	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/database"
 	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
 )
 
 type modernModel struct {
@@ -72,8 +73,10 @@ var (
 			MarginTop(1)
 )
 
-func NewModernTUI(db *database.DB, salt []byte, passphrase string) *modernModel {
-	encryptor := crypto.NewEncryptor(passphrase, salt)
+// NewModernTUI creates the full-screen browser for an unlocked session
+func NewModernTUI(session *vault.Session) *modernModel {
+	db := session.DB
+	encryptor, _ := session.Encryptor()
 
 	passwords, _ := db.ListPasswords()
 
@@ -532,9 +535,13 @@ func formatTimeAgo(t time.Time) string {
 	}
 }
 
-func RunModernTUI(db *database.DB, salt []byte, passphrase string) error {
+func RunModernTUI(session *vault.Session) error {
+	if !session.Unlocked() {
+		return vault.ErrLocked
+	}
+
 	p := tea.NewProgram(
-		NewModernTUI(db, salt, passphrase),
+		NewModernTUI(session),
 		tea.WithAltScreen(),
 	)
 	_, err := p.Run()
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sshConfirmTimeout denies a sign request nobody answered
+const sshConfirmTimeout = 30 * time.Second
+
+type sshConfirmModel struct {
+	name        string
+	comment     string
+	fingerprint string
+	purpose     string
+	remaining   int
+	allowed     bool
+	done        bool
+}
+
+// NewSSHConfirmTUI asks whether the SSH agent may sign with a key
+func NewSSHConfirmTUI(name, comment, fingerprint, purpose string) *sshConfirmModel {
+	return &sshConfirmModel{
+		name:        name,
+		comment:     comment,
+		fingerprint: fingerprint,
+		purpose:     purpose,
+		remaining:   int(sshConfirmTimeout / time.Second),
+	}
+}
+
+type sshConfirmTickMsg time.Time
+
+func sshConfirmTick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return sshConfirmTickMsg(t)
+	})
+}
+
+func (m sshConfirmModel) Init() tea.Cmd {
+	return sshConfirmTick()
+}
+
+func (m sshConfirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case sshConfirmTickMsg:
+		m.remaining--
+		if m.remaining <= 0 {
+			m.done = true
+			return m, tea.Quit
+		}
+		return m, sshConfirmTick()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "y", "Y":
+			m.allowed = true
+			m.done = true
+			return m, tea.Quit
+		case "n", "N", "esc", "q", "ctrl+c":
+			m.done = true
+			return m, tea.Quit
+		}
+	}
+
+	return m, nil
+}
+
+func (m sshConfirmModel) View() string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("#5FAFFF"))
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFAF00")).
+		Bold(true)
+
+	valueStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFFFF"))
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#666666"))
+
+	if m.done {
+		if m.allowed {
+			return addSuccessStyle.Render("✓ ") + fmt.Sprintf("Signed with %s\n", m.name)
+		}
+		return addErrorStyle.Render("✗ ") + fmt.Sprintf("Refused to sign with %s\n", m.name)
+	}
+
+	var s strings.Builder
+	s.WriteString(titleStyle.Render("SSH signature requested"))
+	s.WriteString("\n\n")
+	s.WriteString(labelStyle.Render("Key:         "))
+	s.WriteString(valueStyle.Render(m.name))
+	if m.comment != "" && m.comment != m.name {
+		s.WriteString(hintStyle.Render(" (" + m.comment + ")"))
+	}
+	s.WriteString("\n")
+	s.WriteString(labelStyle.Render("Fingerprint: "))
+	s.WriteString(valueStyle.Render(m.fingerprint))
+	s.WriteString("\n")
+	s.WriteString(labelStyle.Render("Request:     "))
+	s.WriteString(valueStyle.Render(m.purpose))
+	s.WriteString("\n\n")
+	s.WriteString(hintStyle.Render(fmt.Sprintf("y: allow • n: deny (denied in %ds)", m.remaining)))
+	s.WriteString("\n")
+
+	return s.String()
+}
+
+// RunSSHConfirmTUI shows the prompt and reports whether signing was
+// allowed. Unanswered requests are denied after 30 seconds.
+func RunSSHConfirmTUI(name, comment, fingerprint, purpose string) (bool, error) {
+	p := tea.NewProgram(NewSSHConfirmTUI(name, comment, fingerprint, purpose))
+	result, err := p.Run()
+	if err != nil {
+		return false, err
+	}
+	m, ok := result.(sshConfirmModel)
+	return ok && m.allowed, nil
+}
@@ -2,12 +2,11 @@ package tui
 
 import (
 	"fmt"
-	"os/exec"
-	"runtime"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/r2unit/openpasswd/pkg/clipboard"
 	"github.com/r2unit/openpasswd/pkg/config"
 	"github.com/r2unit/openpasswd/pkg/mfa"
 	"github.com/r2unit/openpasswd/pkg/server"
@@ -96,8 +95,9 @@ func (m totpModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case "c":
 			if m.step == 1 && m.serverURL != "" {
-				if err := copyToClipboardTOTP(m.serverURL); err == nil {
-					m.copiedMessage = "✓ Copied URL to clipboard"
+				clearAfter := loadClipboardSettings()
+				if err := clipboard.CopyWithTimeout(m.serverURL, clearAfter); err == nil {
+					m.copiedMessage = "✓ Copied URL to clipboard" + clearNotice(clearAfter)
 				} else {
 					m.copiedMessage = fmt.Sprintf("✗ Failed to copy: %v", err)
 				}
@@ -137,7 +137,8 @@ func (m totpModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
-				if !mfa.ValidateTOTP(m.key.Secret, m.codeInput) {
+				step, ok := mfa.ValidateTOTPAfter(m.key.Secret, m.codeInput, 0)
+				if !ok {
 					m.err = fmt.Errorf("invalid TOTP code, please try again")
 					m.codeInput = ""
 					return m, nil
@@ -148,6 +149,12 @@ func (m totpModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
+				// The verification code counts as used
+				if err := config.SaveTOTPLastStep(step); err != nil {
+					m.err = err
+					return m, nil
+				}
+
 				m.success = true
 				return m, tea.Quit
 			}
@@ -272,48 +279,6 @@ func (m totpModel) View() string {
 	return s.String()
 }
 
-func copyToClipboardTOTP(text string) error {
-	var cmd *exec.Cmd
-
-	switch runtime.GOOS {
-	case "linux":
-		if _, err := exec.LookPath("xclip"); err == nil {
-			cmd = exec.Command("xclip", "-selection", "clipboard")
-		} else if _, err := exec.LookPath("xsel"); err == nil {
-			cmd = exec.Command("xsel", "--clipboard", "--input")
-		} else if _, err := exec.LookPath("wl-copy"); err == nil {
-			cmd = exec.Command("wl-copy")
-		} else {
-			return fmt.Errorf("no clipboard utility found (install xclip, xsel, or wl-clipboard)")
-		}
-	case "darwin":
-		cmd = exec.Command("pbcopy")
-	case "windows":
-		cmd = exec.Command("clip")
-	default:
-		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
-	}
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
-	}
-
-	if err := cmd.Start(); err != nil {
-		return err
-	}
-
-	if _, err := stdin.Write([]byte(text)); err != nil {
-		return err
-	}
-
-	if err := stdin.Close(); err != nil {
-		return err
-	}
-
-	return cmd.Wait()
-}
-
 func RunTOTPSetupTUI(accountName string) error {
 	p := tea.NewProgram(
 		NewTOTPSetupTUI(accountName),
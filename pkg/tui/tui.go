@@ -10,19 +10,22 @@ import (
 	"github.com/r2unit/openpasswd/pkg/crypto"
 	"github.com/r2unit/openpasswd/pkg/database"
 	"github.com/r2unit/openpasswd/pkg/models"
+	"github.com/r2unit/openpasswd/pkg/vault"
 )
 
 type App struct {
+	session   *vault.Session
 	db        *database.DB
-	salt      []byte
 	encryptor *crypto.Encryptor
 	scanner   *bufio.Scanner
 }
 
-func New(db *database.DB, salt []byte) *App {
+// New creates the line-based app; Run asks for the passphrase if the session
+// is still locked
+func New(session *vault.Session) *App {
 	return &App{
-		db:      db,
-		salt:    salt,
+		session: session,
+		db:      session.DB,
 		scanner: bufio.NewScanner(os.Stdin),
 	}
 }
@@ -32,12 +35,21 @@ func (a *App) Run() error {
 	fmt.Println("║              OpenPasswd - Password Manager                ║")
 	fmt.Println("╚═══════════════════════════════════════════════════════════╝")
 
-	passphrase, err := a.getPassphrase()
+	if !a.session.Unlocked() {
+		passphrase, err := a.getPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := a.session.Unlock(passphrase); err != nil {
+			return err
+		}
+	}
+
+	encryptor, err := a.session.Encryptor()
 	if err != nil {
 		return err
 	}
-
-	a.encryptor = crypto.NewEncryptor(passphrase, a.salt)
+	a.encryptor = encryptor
 
 	for {
 		a.showMenu()
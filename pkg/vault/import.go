@@ -0,0 +1,494 @@
+package vault
+
+// Import planning: imported items are matched against the decrypted vault
+// first, so the result can be previewed (or run as a dry run) and every
+// duplicate resolved before anything is written.
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/database"
+	"github.com/r2unit/openpasswd/pkg/mfa"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// fieldPasswordHistory is the custom field importers put previous passwords
+// in, newest first, one "YYYY-MM-DD HH:MM  password" line each
+const fieldPasswordHistory = "password_history"
+
+// Resolution decides what happens to an imported item
+type Resolution int
+
+const (
+	// ResolveAdd adds an item that has no duplicate in the vault
+	ResolveAdd Resolution = iota
+	// ResolveSkip leaves the existing entry alone and drops the imported item
+	ResolveSkip
+	// ResolveOverwrite replaces the existing entry with the imported item
+	ResolveOverwrite
+	// ResolveKeepBoth adds the imported item next to the existing entry
+	ResolveKeepBoth
+	// ResolveMerge fills in and updates the existing entry from the imported item
+	ResolveMerge
+)
+
+// Resolutions lists the choices for duplicates in the order they're cycled
+var Resolutions = []Resolution{ResolveSkip, ResolveOverwrite, ResolveKeepBoth, ResolveMerge}
+
+func (r Resolution) String() string {
+	switch r {
+	case ResolveAdd:
+		return "add"
+	case ResolveSkip:
+		return "skip"
+	case ResolveOverwrite:
+		return "overwrite"
+	case ResolveKeepBoth:
+		return "keep both"
+	case ResolveMerge:
+		return "merge"
+	default:
+		return fmt.Sprintf("resolution(%d)", int(r))
+	}
+}
+
+// ParseResolution parses a duplicate resolution as given on the command line
+func ParseResolution(s string) (Resolution, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "skip":
+		return ResolveSkip, nil
+	case "overwrite":
+		return ResolveOverwrite, nil
+	case "keep-both", "keep both", "both":
+		return ResolveKeepBoth, nil
+	case "merge":
+		return ResolveMerge, nil
+	default:
+		return 0, fmt.Errorf("unknown duplicate resolution %q (use skip, overwrite, keep-both or merge)", s)
+	}
+}
+
+// DuplicateReason tells why an imported item matches an existing entry
+type DuplicateReason int
+
+const (
+	// NotDuplicate means no existing entry matches
+	NotDuplicate DuplicateReason = iota
+	// SameURLAndUsername matches on the URL's host and the username
+	SameURLAndUsername
+	// SameName matches on the entry name
+	SameName
+)
+
+func (r DuplicateReason) String() string {
+	switch r {
+	case SameURLAndUsername:
+		return "same URL and username"
+	case SameName:
+		return "same name"
+	default:
+		return ""
+	}
+}
+
+// ImportEntry is one imported item and what the import will do with it
+type ImportEntry struct {
+	Item       *Item
+	Existing   *Item // Matching vault entry; nil when Reason is NotDuplicate
+	Reason     DuplicateReason
+	Resolution Resolution
+	Warnings   []string
+}
+
+// ImportPlan is the result of matching imported items against the vault
+type ImportPlan struct {
+	Entries []*ImportEntry
+	Skipped []string // Parts of the input the importer left out, and why
+}
+
+// FromPassword wraps a plaintext entry returned by an importer
+func FromPassword(p *models.Password) *Item {
+	return &Item{
+		ID:        p.ID,
+		Type:      p.Type,
+		Name:      p.Name,
+		Username:  p.Username,
+		Password:  p.Password,
+		URL:       p.URL,
+		Notes:     p.Notes,
+		Fields:    p.Fields,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+// PlanImport matches imported items against the existing entries. An item
+// is a duplicate if an entry has the same URL host and username, or failing
+// that the same name. Duplicates default to ResolveSkip.
+func PlanImport(existing, imported []*Item) *ImportPlan {
+	byLogin := make(map[string]*Item)
+	byName := make(map[string]*Item)
+	for _, item := range existing {
+		if key := loginKey(item); key != "" {
+			if _, ok := byLogin[key]; !ok {
+				byLogin[key] = item
+			}
+		}
+		if key := nameKey(item); key != "" {
+			if _, ok := byName[key]; !ok {
+				byName[key] = item
+			}
+		}
+	}
+
+	plan := &ImportPlan{Entries: make([]*ImportEntry, 0, len(imported))}
+	seenLogin := make(map[string]string)
+	seenName := make(map[string]string)
+
+	for _, item := range imported {
+		entry := &ImportEntry{Item: item, Resolution: ResolveAdd, Warnings: itemWarnings(item)}
+
+		login, name := loginKey(item), nameKey(item)
+		if match, ok := byLogin[login]; ok && login != "" {
+			entry.Existing, entry.Reason = match, SameURLAndUsername
+		} else if match, ok := byName[name]; ok && name != "" {
+			entry.Existing, entry.Reason = match, SameName
+		}
+		if entry.Existing != nil {
+			entry.Resolution = ResolveSkip
+		}
+
+		// Duplicates within the file itself are imported but flagged
+		if other, ok := seenLogin[login]; ok && login != "" {
+			entry.Warnings = append(entry.Warnings, fmt.Sprintf("same URL and username as %q in this file", other))
+		} else if other, ok := seenName[name]; ok && name != "" {
+			entry.Warnings = append(entry.Warnings, fmt.Sprintf("same name as %q in this file", other))
+		}
+		if login != "" {
+			seenLogin[login] = item.Name
+		}
+		if name != "" {
+			seenName[name] = item.Name
+		}
+
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	return plan
+}
+
+// loginKey is the URL host and username of an item, or "" if either is missing
+func loginKey(item *Item) string {
+	host := urlHost(item.URL)
+	user := strings.ToLower(strings.TrimSpace(item.Username))
+	if host == "" || user == "" {
+		return ""
+	}
+	return host + "\x00" + user
+}
+
+func nameKey(item *Item) string {
+	return strings.ToLower(strings.TrimSpace(item.Name))
+}
+
+// urlHost returns the lowercased host of a URL without "www." and the port.
+// URLs without a scheme (e.g. "github.com/login") are accepted.
+func urlHost(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// itemWarnings lists problems with an imported item worth a look before
+// importing it
+func itemWarnings(item *Item) []string {
+	var warnings []string
+
+	if strings.TrimSpace(item.Name) == "" {
+		warnings = append(warnings, "has no name")
+	}
+	if item.Type == models.TypeLogin && item.Password == "" {
+		warnings = append(warnings, "login has no password")
+	}
+	if item.URL != "" && urlHost(item.URL) == "" {
+		warnings = append(warnings, "URL can't be parsed")
+	}
+	if secret := strings.TrimSpace(item.Fields[models.FieldTOTP]); strings.Contains(secret, "://") {
+		if _, err := mfa.ParseOTPAuthURI(secret); err != nil {
+			warnings = append(warnings, "TOTP URI can't be parsed")
+		}
+	}
+
+	return warnings
+}
+
+// Counts returns the number of imported items per type
+func (p *ImportPlan) Counts() map[models.PasswordType]int {
+	counts := make(map[models.PasswordType]int)
+	for _, e := range p.Entries {
+		counts[e.Item.Type]++
+	}
+	return counts
+}
+
+// Duplicates returns the entries that match an existing entry
+func (p *ImportPlan) Duplicates() []*ImportEntry {
+	var dups []*ImportEntry
+	for _, e := range p.Entries {
+		if e.Existing != nil {
+			dups = append(dups, e)
+		}
+	}
+	return dups
+}
+
+// Warnings returns the number of entries with at least one warning
+func (p *ImportPlan) Warnings() int {
+	n := 0
+	for _, e := range p.Entries {
+		if len(e.Warnings) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// ResolveAll sets the resolution of every duplicate
+func (p *ImportPlan) ResolveAll(r Resolution) {
+	for _, e := range p.Duplicates() {
+		e.Resolution = r
+	}
+}
+
+// Merge combines an existing entry with an imported duplicate and returns
+// the result along with the names of the fields that changed. Empty values
+// are filled in from the import; for conflicting values the more recently
+// updated side wins (the import, if it has no date), a replaced password
+// moves to the password history and differing notes are appended.
+func Merge(existing, imported *Item) (*Item, []string) {
+	merged := *existing
+	merged.Fields = make(map[string]string, len(existing.Fields)+len(imported.Fields))
+	for k, v := range existing.Fields {
+		merged.Fields[k] = v
+	}
+
+	// Items without a modification date come from a fresh export
+	importedNewer := imported.UpdatedAt.IsZero() || imported.UpdatedAt.After(existing.UpdatedAt)
+	var changed []string
+
+	pick := func(name string, dst *string, val string) {
+		if val == "" || val == *dst {
+			return
+		}
+		if *dst == "" || importedNewer {
+			*dst = val
+			changed = append(changed, name)
+		}
+	}
+
+	pick("username", &merged.Username, imported.Username)
+	pick("url", &merged.URL, imported.URL)
+
+	if imported.Password != "" && imported.Password != existing.Password {
+		switch {
+		case existing.Password == "":
+			merged.Password = imported.Password
+			changed = append(changed, "password")
+		case importedNewer:
+			merged.Password = imported.Password
+			merged.Fields[fieldPasswordHistory] = prependHistory(merged.Fields[fieldPasswordHistory], existing.UpdatedAt, existing.Password)
+			changed = append(changed, "password")
+		default:
+			merged.Fields[fieldPasswordHistory] = prependHistory(merged.Fields[fieldPasswordHistory], imported.UpdatedAt, imported.Password)
+			changed = append(changed, fieldPasswordHistory)
+		}
+	}
+
+	if notes := strings.TrimSpace(imported.Notes); notes != "" && !strings.Contains(merged.Notes, notes) {
+		if merged.Notes == "" {
+			merged.Notes = imported.Notes
+		} else {
+			merged.Notes = strings.TrimRight(merged.Notes, "\n") + "\n\n" + imported.Notes
+		}
+		changed = append(changed, "notes")
+	}
+
+	for key, val := range imported.Fields {
+		if key == fieldPasswordHistory {
+			if history := mergeHistory(merged.Fields[key], val); history != merged.Fields[key] {
+				merged.Fields[key] = history
+				changed = append(changed, key)
+			}
+			continue
+		}
+		cur := merged.Fields[key]
+		pick(key, &cur, val)
+		if cur != "" {
+			merged.Fields[key] = cur
+		}
+	}
+
+	return &merged, uniqueStrings(changed)
+}
+
+// prependHistory adds a password to the front of a password history field
+func prependHistory(history string, changed time.Time, password string) string {
+	line := password
+	if !changed.IsZero() {
+		line = changed.Local().Format("2006-01-02 15:04") + "  " + password
+	}
+	if history == "" {
+		return line
+	}
+	return line + "\n" + history
+}
+
+// mergeHistory appends the lines of b that a doesn't have
+func mergeHistory(a, b string) string {
+	have := make(map[string]bool)
+	for _, line := range strings.Split(a, "\n") {
+		have[line] = true
+	}
+
+	out := a
+	for _, line := range strings.Split(b, "\n") {
+		if line == "" || have[line] {
+			continue
+		}
+		have[line] = true
+		if out != "" {
+			out += "\n"
+		}
+		out += line
+	}
+	return out
+}
+
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	out := list[:0]
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// resolved returns the entry an overwrite or merge writes, and the fields
+// a merge changed. Several imported items can match the same existing
+// entry, so current holds what earlier entries of the plan made of it.
+func (e *ImportEntry) resolved(current map[int64]*Item) (*Item, []string) {
+	existing := e.Existing
+	if c, ok := current[existing.ID]; ok {
+		existing = c
+	}
+
+	if e.Resolution == ResolveMerge {
+		return Merge(existing, e.Item)
+	}
+	replaced := *e.Item
+	replaced.ID, replaced.CreatedAt = existing.ID, existing.CreatedAt
+	return &replaced, nil
+}
+
+// DryRun returns the report of what Apply would do without writing anything
+func (p *ImportPlan) DryRun() *ImportReport {
+	report := &ImportReport{DryRun: true, Time: time.Now(), Skipped: p.Skipped}
+	current := make(map[int64]*Item)
+
+	for _, e := range p.Entries {
+		re := newReportEntry(e)
+		if e.Resolution == ResolveOverwrite || e.Resolution == ResolveMerge {
+			var result *Item
+			result, re.Changed = e.resolved(current)
+			current[result.ID] = result
+		}
+		report.Entries = append(report.Entries, re)
+	}
+	return report
+}
+
+// Apply encrypts and writes the plan to the session's vault. Failed entries
+// are recorded in the report and don't stop the import. The session can't be
+// locked until Apply returns.
+func (p *ImportPlan) Apply(session *Session) *ImportReport {
+	report := &ImportReport{Time: time.Now(), Skipped: p.Skipped}
+
+	err := session.withEncryptor(func(enc *crypto.Encryptor) error {
+		current := make(map[int64]*Item)
+		for _, e := range p.Entries {
+			report.Entries = append(report.Entries, applyEntry(session.DB, enc, e, current))
+		}
+		return nil
+	})
+	if err != nil {
+		for _, e := range p.Entries {
+			re := newReportEntry(e)
+			re.Error = err.Error()
+			report.Entries = append(report.Entries, re)
+		}
+	}
+
+	return report
+}
+
+// applyEntry writes one entry; current holds the entries this import changed
+func applyEntry(db *database.DB, enc *crypto.Encryptor, e *ImportEntry, current map[int64]*Item) ReportEntry {
+	re := newReportEntry(e)
+
+	var err error
+	switch e.Resolution {
+	case ResolveAdd, ResolveKeepBoth:
+		re.ID, err = addItem(db, enc, e.Item)
+	case ResolveOverwrite, ResolveMerge:
+		var result *Item
+		result, re.Changed = e.resolved(current)
+		if e.Resolution == ResolveMerge && len(re.Changed) == 0 {
+			re.ID = result.ID
+		} else if re.ID, err = updateItem(db, enc, result); err == nil {
+			current[result.ID] = result
+		}
+	}
+	if err != nil {
+		re.Error = err.Error()
+	}
+	return re
+}
+
+func addItem(db *database.DB, enc *crypto.Encryptor, item *Item) (int64, error) {
+	p, err := Encrypt(enc, item)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt %q: %w", item.Name, err)
+	}
+	if err := db.AddPassword(p); err != nil {
+		return 0, fmt.Errorf("failed to save %q: %w", item.Name, err)
+	}
+	return p.ID, nil
+}
+
+func updateItem(db *database.DB, enc *crypto.Encryptor, item *Item) (int64, error) {
+	p, err := Encrypt(enc, item)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt %q: %w", item.Name, err)
+	}
+	if err := db.UpdatePassword(p); err != nil {
+		return 0, fmt.Errorf("failed to update %q: %w", item.Name, err)
+	}
+	return p.ID, nil
+}
@@ -0,0 +1,202 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// importSession opens an unlocked session holding one GitHub login
+func importSession(t *testing.T) (*Session, *Item) {
+	t.Helper()
+
+	s := testSession(t)
+	if err := s.UnlockWithKey(testKey); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := &Item{
+		Type:     models.TypeLogin,
+		Name:     "GitHub",
+		Username: "alice",
+		Password: "old password",
+		URL:      "https://github.com",
+		Fields:   map[string]string{"pin": "1234"},
+	}
+	if _, err := s.Add(existing); err != nil {
+		t.Fatal(err)
+	}
+	return s, existing
+}
+
+func vaultItems(t *testing.T, s *Session) []*Item {
+	t.Helper()
+	items, err := s.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestPlanImport(t *testing.T) {
+	existing := []*Item{
+		{ID: 1, Name: "GitHub", Username: "alice", URL: "https://github.com"},
+		{ID: 2, Name: "Bank"},
+	}
+	imported := []*Item{
+		{Name: "GitHub (work)", Username: "Alice", URL: "www.github.com/login", Password: "x"},
+		{Name: "bank", Type: models.TypeNote},
+		{Name: "New", Password: "x"},
+		{Name: "new", Password: "y"},
+	}
+
+	plan := PlanImport(existing, imported)
+
+	tests := []struct {
+		existing   int64
+		reason     DuplicateReason
+		resolution Resolution
+		warnings   int
+	}{
+		{1, SameURLAndUsername, ResolveSkip, 0},
+		{2, SameName, ResolveSkip, 0},
+		{0, NotDuplicate, ResolveAdd, 0},
+		{0, NotDuplicate, ResolveAdd, 1}, // same name as the one before
+	}
+	for i, tt := range tests {
+		e := plan.Entries[i]
+		var id int64
+		if e.Existing != nil {
+			id = e.Existing.ID
+		}
+		if id != tt.existing || e.Reason != tt.reason || e.Resolution != tt.resolution || len(e.Warnings) != tt.warnings {
+			t.Errorf("entry %d: existing %d, reason %q, resolution %s, warnings %v", i, id, e.Reason, e.Resolution, e.Warnings)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		resolution Resolution
+		check      func(t *testing.T, items []*Item)
+	}{
+		{ResolveSkip, func(t *testing.T, items []*Item) {
+			if len(items) != 1 || items[0].Password != "old password" {
+				t.Errorf("items = %+v", items)
+			}
+		}},
+		{ResolveOverwrite, func(t *testing.T, items []*Item) {
+			if len(items) != 1 || items[0].Password != "new password" || items[0].Fields["pin"] != "" || items[0].Fields["team"] != "core" {
+				t.Errorf("items = %+v", items)
+			}
+		}},
+		{ResolveKeepBoth, func(t *testing.T, items []*Item) {
+			if len(items) != 2 {
+				t.Errorf("got %d items, want 2", len(items))
+			}
+		}},
+		{ResolveMerge, func(t *testing.T, items []*Item) {
+			if len(items) != 1 {
+				t.Fatalf("got %d items, want 1", len(items))
+			}
+			merged := items[0]
+			if merged.Password != "new password" || merged.Fields["pin"] != "1234" || merged.Fields["team"] != "core" ||
+				!strings.Contains(merged.Fields[fieldPasswordHistory], "old password") {
+				t.Errorf("merged = %+v", merged)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resolution.String(), func(t *testing.T) {
+			s, _ := importSession(t)
+
+			imported := &Item{
+				Type:     models.TypeLogin,
+				Name:     "GitHub",
+				Username: "alice",
+				Password: "new password",
+				URL:      "https://github.com/login",
+				Fields:   map[string]string{"team": "core"},
+			}
+			plan := PlanImport(vaultItems(t, s), []*Item{imported})
+			plan.ResolveAll(tt.resolution)
+
+			report := plan.Apply(s)
+			if len(report.Entries) != 1 || report.Entries[0].Error != "" {
+				t.Fatalf("report = %+v", report.Entries)
+			}
+			tt.check(t, vaultItems(t, s))
+		})
+	}
+}
+
+// Two imported items matching the same entry must both end up in it
+func TestApplySameEntryTwice(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		s, _ := importSession(t)
+
+		plan := PlanImport(vaultItems(t, s), []*Item{
+			{Name: "GitHub", Username: "alice", URL: "https://github.com", Fields: map[string]string{"team": "core"}},
+			{Name: "github", Notes: "Recovery codes are in the safe"},
+		})
+		plan.ResolveAll(ResolveMerge)
+
+		if dryRun {
+			report := plan.DryRun()
+			if got := report.Entries[1].Changed; len(got) != 1 || got[0] != "notes" {
+				t.Errorf("dry run: second merge changed %v, want [notes]", got)
+			}
+			continue
+		}
+
+		report := plan.Apply(s)
+		for _, e := range report.Entries {
+			if e.Error != "" {
+				t.Fatal(e.Error)
+			}
+		}
+
+		items := vaultItems(t, s)
+		if len(items) != 1 || items[0].Fields["team"] != "core" || items[0].Notes != "Recovery codes are in the safe" {
+			t.Errorf("items = %+v", items)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	s, existing := importSession(t)
+
+	plan := PlanImport(vaultItems(t, s), []*Item{
+		{Type: models.TypeLogin, Name: "GitHub", Username: "alice", URL: "https://github.com", Password: "new password"},
+		{Type: models.TypeNote, Name: "Wifi", Notes: "SSID: office"},
+	})
+	plan.ResolveAll(ResolveMerge)
+	plan.Skipped = []string{"failed to decrypt shared/team.gpg: no secret key"}
+
+	report := plan.DryRun()
+	if !report.DryRun || len(report.Entries) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if changed := report.Entries[0].Changed; len(changed) != 1 || changed[0] != "password" {
+		t.Errorf("merge would change %v, want [password]", changed)
+	}
+	if added, updated, skipped, failed := report.Summary(); added != 1 || updated != 1 || skipped != 0 || failed != 0 {
+		t.Errorf("summary = %d added, %d updated, %d skipped, %d failed", added, updated, skipped, failed)
+	}
+
+	var out strings.Builder
+	if err := report.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "warning: failed to decrypt shared/team.gpg") {
+		t.Errorf("report doesn't list the skipped file:\n%s", out.String())
+	}
+
+	// Nothing was written
+	items := vaultItems(t, s)
+	if len(items) != 1 || items[0].Password != existing.Password {
+		t.Errorf("dry run changed the vault: %+v", items)
+	}
+}
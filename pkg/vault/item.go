@@ -0,0 +1,245 @@
+package vault
+
+// Package vault holds the session that unlocks the vault, the decrypted view
+// of stored entries and the lookup logic shared by the scriptable CLI
+// commands (get, show, ls) and anything else that resolves entries by name.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/mfa"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// Item is a decrypted password entry
+type Item struct {
+	ID        int64               `json:"id"`
+	Type      models.PasswordType `json:"type"`
+	Name      string              `json:"name"`
+	Username  string              `json:"username,omitempty"`
+	Password  string              `json:"password,omitempty"`
+	URL       string              `json:"url,omitempty"`
+	Notes     string              `json:"notes,omitempty"`
+	Fields    map[string]string   `json:"fields,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// Decrypt decrypts every field of a stored entry
+func Decrypt(enc *crypto.Encryptor, p *models.Password) (*Item, error) {
+	item := &Item{
+		ID:        p.ID,
+		Type:      p.Type,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+
+	var err error
+	if item.Name, err = decryptField(enc, p.Name); err != nil {
+		return nil, fmt.Errorf("failed to decrypt entry %d: %w", p.ID, err)
+	}
+	if item.Username, err = decryptField(enc, p.Username); err != nil {
+		return nil, fmt.Errorf("failed to decrypt entry %d: %w", p.ID, err)
+	}
+	if item.Password, err = decryptField(enc, p.Password); err != nil {
+		return nil, fmt.Errorf("failed to decrypt entry %d: %w", p.ID, err)
+	}
+	if item.URL, err = decryptField(enc, p.URL); err != nil {
+		return nil, fmt.Errorf("failed to decrypt entry %d: %w", p.ID, err)
+	}
+	if item.Notes, err = decryptField(enc, p.Notes); err != nil {
+		return nil, fmt.Errorf("failed to decrypt entry %d: %w", p.ID, err)
+	}
+
+	if len(p.Fields) > 0 {
+		item.Fields = make(map[string]string, len(p.Fields))
+		for key, val := range p.Fields {
+			decrypted, err := decryptField(enc, val)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt field %q of entry %d: %w", key, p.ID, err)
+			}
+			item.Fields[key] = decrypted
+		}
+	}
+
+	return item, nil
+}
+
+// DecryptAll decrypts a list of entries, sorted by name then ID
+func DecryptAll(enc *crypto.Encryptor, passwords []*models.Password) ([]*Item, error) {
+	items := make([]*Item, 0, len(passwords))
+	for _, p := range passwords {
+		item, err := Decrypt(enc, p)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		a, b := strings.ToLower(items[i].Name), strings.ToLower(items[j].Name)
+		if a != b {
+			return a < b
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
+
+// Encrypt converts a decrypted item back into a stored entry. The ID and
+// timestamps are copied as-is; database.AddPassword assigns new ones.
+func Encrypt(enc *crypto.Encryptor, item *Item) (*models.Password, error) {
+	p := &models.Password{
+		ID:        item.ID,
+		Type:      item.Type,
+		Fields:    make(map[string]string, len(item.Fields)),
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	}
+
+	var err error
+	if p.Name, err = encryptField(enc, item.Name); err != nil {
+		return nil, err
+	}
+	if p.Username, err = encryptField(enc, item.Username); err != nil {
+		return nil, err
+	}
+	if p.Password, err = encryptField(enc, item.Password); err != nil {
+		return nil, err
+	}
+	if p.URL, err = encryptField(enc, item.URL); err != nil {
+		return nil, err
+	}
+	if p.Notes, err = encryptField(enc, item.Notes); err != nil {
+		return nil, err
+	}
+
+	for key, val := range item.Fields {
+		if p.Fields[key], err = encryptField(enc, val); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func encryptField(enc *crypto.Encryptor, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	return enc.Encrypt(value)
+}
+
+func decryptField(enc *crypto.Encryptor, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	return enc.Decrypt(value)
+}
+
+// Field returns a single value of the item. The built-in names are name,
+// username, password, url, notes and totp (the current code, not the secret,
+// see TOTPCode);
+// anything else is looked up in the custom fields. No name means the password,
+// or the public key of SSH key entries.
+func (i *Item) Field(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "":
+		// SSH keys have no password; their public key is what scripts want
+		if i.Type == models.TypeSSHKey {
+			return i.Fields[models.FieldPublicKey], nil
+		}
+		return i.Password, nil
+	case "password":
+		return i.Password, nil
+	case "name":
+		return i.Name, nil
+	case "username", "user":
+		return i.Username, nil
+	case "url":
+		return i.URL, nil
+	case "notes":
+		return i.Notes, nil
+	case "totp":
+		return i.TOTPCode(time.Now())
+	}
+
+	if val, ok := i.Fields[name]; ok {
+		return val, nil
+	}
+
+	// Custom field names are matched case-insensitively as a fallback
+	for key, val := range i.Fields {
+		if strings.EqualFold(key, name) {
+			return val, nil
+		}
+	}
+
+	return "", fmt.Errorf("entry %q has no field %q", i.Name, name)
+}
+
+// TOTPCode generates the current one-time code from the item's TOTP field.
+// For HOTP keys it's the code of the stored counter, which isn't advanced;
+// use OTPCode to hand a code out.
+func (i *Item) TOTPCode(t time.Time) (string, error) {
+	key, err := i.otpKey()
+	if err != nil {
+		return "", err
+	}
+	return key.Code(t)
+}
+
+// otpKey parses the TOTP field, an otpauth:// URI or a bare base32 secret
+func (i *Item) otpKey() (*mfa.OTPKey, error) {
+	secret := strings.TrimSpace(i.Fields[models.FieldTOTP])
+	if secret == "" {
+		return nil, fmt.Errorf("entry %q has no TOTP secret", i.Name)
+	}
+
+	if strings.Contains(secret, "://") {
+		return mfa.ParseOTPAuthURI(secret)
+	}
+
+	if _, err := mfa.DecodeSecret(secret); err != nil {
+		return nil, err
+	}
+	return &mfa.OTPKey{Type: mfa.OTPTypeTOTP, Secret: secret, Options: mfa.DefaultOTPOptions()}, nil
+}
+
+// OTPCode returns the item's one-time code at t. HOTP counters must never
+// be reused, so for HOTP keys the counter is advanced and the entry saved
+// through store before the code is returned.
+func OTPCode(store Store, item *Item, t time.Time) (string, error) {
+	key, err := item.otpKey()
+	if err != nil {
+		return "", err
+	}
+	if key.Type != mfa.OTPTypeHOTP {
+		return key.Code(t)
+	}
+
+	code, err := key.NextHOTP()
+	if err != nil {
+		return "", err
+	}
+
+	item.Fields[models.FieldTOTP] = key.URL()
+	if err := store.Update(item); err != nil {
+		return "", fmt.Errorf("failed to save HOTP counter: %w", err)
+	}
+	return code, nil
+}
+
+// FieldValue is Item.Field for callers that hand the value out: the totp
+// field goes through OTPCode, advancing HOTP counters
+func FieldValue(store Store, item *Item, name string) (string, error) {
+	if strings.EqualFold(name, "totp") {
+		return OTPCode(store, item, time.Now())
+	}
+	return item.Field(name)
+}
@@ -0,0 +1,68 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/mfa"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// updateStore records the entries saved through Update
+type updateStore struct {
+	Store
+	updated []*Item
+}
+
+func (s *updateStore) Update(item *Item) error {
+	s.updated = append(s.updated, item)
+	return nil
+}
+
+func TestOTPCodeAdvancesHOTP(t *testing.T) {
+	store := &updateStore{}
+	item := &Item{
+		Name: "hotp",
+		Fields: map[string]string{
+			models.FieldTOTP: "otpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0",
+		},
+	}
+
+	// RFC 4226 appendix D codes for counters 0 and 1
+	for _, want := range []string{"755224", "287082"} {
+		code, err := FieldValue(store, item, "totp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Errorf("code = %s, want %s", code, want)
+		}
+	}
+
+	if len(store.updated) != 2 {
+		t.Fatalf("saved %d times, want 2", len(store.updated))
+	}
+	key, err := mfa.ParseOTPAuthURI(item.Fields[models.FieldTOTP])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Counter != 2 {
+		t.Errorf("stored counter = %d, want 2", key.Counter)
+	}
+}
+
+func TestOTPCodeTOTPIsNotSaved(t *testing.T) {
+	store := &updateStore{}
+	item := &Item{Name: "totp", Fields: map[string]string{models.FieldTOTP: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}}
+
+	code, err := OTPCode(store, item, time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "287082" {
+		t.Errorf("code = %s, want 287082", code)
+	}
+	if len(store.updated) != 0 {
+		t.Errorf("TOTP entry was saved %d times", len(store.updated))
+	}
+}
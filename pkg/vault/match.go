@@ -0,0 +1,114 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MatchMode controls how a query is compared against entry names
+type MatchMode int
+
+const (
+	// MatchDefault prefers an exact name, then falls back to a substring match
+	MatchDefault MatchMode = iota
+	// MatchExact only accepts a case-insensitive exact name
+	MatchExact
+	// MatchFuzzy accepts names containing the query characters in order
+	MatchFuzzy
+)
+
+// ErrNoMatch is returned when no entry matches a query
+var ErrNoMatch = errors.New("no matching entry")
+
+// AmbiguousError is returned when a query matches more than one entry
+type AmbiguousError struct {
+	Query   string
+	Matches []*Item
+}
+
+func (e *AmbiguousError) Error() string {
+	names := make([]string, 0, len(e.Matches))
+	for _, m := range e.Matches {
+		names = append(names, fmt.Sprintf("%s (id %d)", m.Name, m.ID))
+	}
+	return fmt.Sprintf("%q matches %d entries: %s", e.Query, len(e.Matches), strings.Join(names, ", "))
+}
+
+// Find returns the single entry matching query. A numeric query is first
+// tried as an entry ID. Returns ErrNoMatch or an *AmbiguousError otherwise.
+func Find(items []*Item, query string, mode MatchMode) (*Item, error) {
+	if id, err := strconv.ParseInt(query, 10, 64); err == nil {
+		for _, item := range items {
+			if item.ID == id {
+				return item, nil
+			}
+		}
+	}
+
+	matches := Filter(items, query, mode)
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w for %q", ErrNoMatch, query)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, &AmbiguousError{Query: query, Matches: matches}
+	}
+}
+
+// Filter returns every entry matching query. In the default and fuzzy
+// modes an exact name match wins over looser matches.
+func Filter(items []*Item, query string, mode MatchMode) []*Item {
+	if query == "" {
+		return items
+	}
+
+	var exact []*Item
+	for _, item := range items {
+		if strings.EqualFold(item.Name, query) {
+			exact = append(exact, item)
+		}
+	}
+	if len(exact) > 0 || mode == MatchExact {
+		return exact
+	}
+
+	q := strings.ToLower(query)
+	var matches []*Item
+	for _, item := range items {
+		name := strings.ToLower(item.Name)
+		switch mode {
+		case MatchFuzzy:
+			if fuzzyContains(name, q) || fuzzyContains(strings.ToLower(item.URL), q) {
+				matches = append(matches, item)
+			}
+		default:
+			if strings.Contains(name, q) {
+				matches = append(matches, item)
+			}
+		}
+	}
+
+	return matches
+}
+
+// fuzzyContains reports whether all runes of query appear in s in order
+func fuzzyContains(s, query string) bool {
+	if query == "" {
+		return true
+	}
+
+	q := []rune(query)
+	i := 0
+	for _, r := range s {
+		if r == q[i] {
+			i++
+			if i == len(q) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+// ImportReport records what an import did, or would do for a dry run. It
+// holds names and decisions only, never secrets.
+type ImportReport struct {
+	Source  string
+	File    string
+	DryRun  bool
+	Time    time.Time
+	Entries []ReportEntry
+	Skipped []string // Parts of the input the importer left out, and why
+}
+
+// ReportEntry is the outcome of one imported item
+type ReportEntry struct {
+	Name       string
+	Type       models.PasswordType
+	Reason     DuplicateReason
+	ExistingID int64 // Matched vault entry, 0 if none
+	Resolution Resolution
+	ID         int64    // Entry written, 0 if skipped, failed or a dry run
+	Changed    []string // Fields changed by a merge
+	Warnings   []string
+	Error      string
+}
+
+func newReportEntry(e *ImportEntry) ReportEntry {
+	re := ReportEntry{
+		Name:       e.Item.Name,
+		Type:       e.Item.Type,
+		Reason:     e.Reason,
+		Resolution: e.Resolution,
+		Warnings:   e.Warnings,
+	}
+	if e.Existing != nil {
+		re.ExistingID = e.Existing.ID
+	}
+	return re
+}
+
+// Summary counts the entries by outcome; merges that changed nothing count
+// as skipped
+func (r *ImportReport) Summary() (added, updated, skipped, failed int) {
+	for _, e := range r.Entries {
+		switch {
+		case e.Error != "":
+			failed++
+		case e.Resolution == ResolveSkip, e.Resolution == ResolveMerge && len(e.Changed) == 0:
+			skipped++
+		case e.Resolution == ResolveOverwrite || e.Resolution == ResolveMerge:
+			updated++
+		default:
+			added++
+		}
+	}
+	return
+}
+
+// Write writes the report as plain text
+func (r *ImportReport) Write(w io.Writer) error {
+	added, updated, skipped, failed := r.Summary()
+
+	var b strings.Builder
+	b.WriteString("OpenPasswd import report\n\n")
+	fmt.Fprintf(&b, "Date:    %s\n", r.Time.Format("2006-01-02 15:04:05 MST"))
+	if r.Source != "" {
+		fmt.Fprintf(&b, "Source:  %s\n", r.Source)
+	}
+	if r.File != "" {
+		fmt.Fprintf(&b, "File:    %s\n", r.File)
+	}
+	if r.DryRun {
+		b.WriteString("Mode:    dry run (nothing was written)\n")
+	}
+	fmt.Fprintf(&b, "Result:  %d added, %d updated, %d skipped, %d failed\n\n", added, updated, skipped, failed)
+
+	if len(r.Skipped) > 0 {
+		b.WriteString("Not imported from the source:\n")
+		for _, s := range r.Skipped {
+			fmt.Fprintf(&b, "  warning: %s\n", s)
+		}
+		b.WriteString("\n")
+	}
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tDUPLICATE\tACTION\tID\tDETAILS")
+	for _, e := range r.Entries {
+		duplicate := "-"
+		if e.Reason != NotDuplicate {
+			duplicate = fmt.Sprintf("%s (id %d)", e.Reason, e.ExistingID)
+		}
+
+		id := "-"
+		if e.ID != 0 {
+			id = fmt.Sprintf("%d", e.ID)
+		}
+
+		var details []string
+		if e.Error != "" {
+			details = append(details, "error: "+e.Error)
+		}
+		if len(e.Changed) > 0 {
+			details = append(details, "changed: "+strings.Join(e.Changed, ", "))
+		}
+		for _, w := range e.Warnings {
+			details = append(details, "warning: "+w)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Name, e.Type, duplicate, e.Resolution, id, strings.Join(details, "; "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Save writes the report to a file readable only by the owner
+func (r *ImportReport) Save(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create import report: %w", err)
+	}
+
+	err = r.Write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write import report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package vault
+
+// A Session opens the configuration and the database once and derives the
+// vault key once, with the KDF version the vault was created with. The TUIs,
+// the CLI commands and the server all work through a session instead of
+// passing the master passphrase around and deriving keys of their own.
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/r2unit/openpasswd/pkg/config"
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/database"
+	"github.com/r2unit/openpasswd/pkg/mfa"
+)
+
+var (
+	// ErrWrongPassphrase is returned when a passphrase doesn't decrypt the vault
+	ErrWrongPassphrase = errors.New("incorrect passphrase")
+	// ErrLocked is returned when a locked session is asked for its key
+	ErrLocked = errors.New("vault is locked")
+)
+
+// Store reads and changes decrypted entries. An unlocked Session is one,
+// the agent client another.
+type Store interface {
+	Items() ([]*Item, error)
+	Find(query string, mode MatchMode) (*Item, error)
+	Add(item *Item) (int64, error)
+	Update(item *Item) error
+	Delete(id int64) error
+	Close() error
+}
+
+// Session is an open vault, locked until Unlock succeeds
+type Session struct {
+	Config *config.Config
+	DB     *database.DB
+
+	mu        sync.RWMutex
+	encryptor *crypto.Encryptor
+}
+
+// Open loads the configuration and opens the database
+func Open() (*Session, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.New(cfg.DatabasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &Session{Config: cfg, DB: db}, nil
+}
+
+// Unlock derives the vault key from the passphrase and checks it, see verify
+func (s *Session) Unlock(passphrase string) error {
+	encryptor := crypto.NewEncryptorWithVersion(passphrase, s.Config.Salt, s.Config.KDFVersion)
+
+	if err := s.verify(encryptor); err != nil {
+		encryptor.Wipe()
+		return err
+	}
+
+	s.setEncryptor(encryptor)
+	return nil
+}
+
+// UnlockWithKey unlocks the session with an already derived key, as handed
+// over by the agent or a key cache
+func (s *Session) UnlockWithKey(key []byte) error {
+	encryptor, err := crypto.NewEncryptorFromKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verify(encryptor); err != nil {
+		encryptor.Wipe()
+		return err
+	}
+
+	s.setEncryptor(encryptor)
+	return nil
+}
+
+func (s *Session) setEncryptor(encryptor *crypto.Encryptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.encryptor != nil {
+		s.encryptor.Wipe()
+	}
+	s.encryptor = encryptor
+}
+
+// keyCheckValue is encrypted with the vault key to check passphrases
+const keyCheckValue = "openpasswd key check"
+
+// SaveKeyCheck stores the value unlocking is checked against. Init writes it,
+// and anything that changes the vault key has to write it again.
+func SaveKeyCheck(encryptor *crypto.Encryptor) error {
+	encrypted, err := encryptor.Encrypt(keyCheckValue)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key check: %w", err)
+	}
+	if err := config.SaveKeyCheck(encrypted); err != nil {
+		return fmt.Errorf("failed to save key check: %w", err)
+	}
+	return nil
+}
+
+// verify checks the encryptor against the stored check value. Vaults from
+// before it existed are checked against the first entry name instead and get
+// a check value on the first unlock that passes, so an empty vault only
+// accepts the passphrase it was first unlocked with.
+func (s *Session) verify(encryptor *crypto.Encryptor) error {
+	check, err := config.LoadKeyCheck()
+	if err == nil {
+		if plain, err := encryptor.Decrypt(check); err != nil || plain != keyCheckValue {
+			return ErrWrongPassphrase
+		}
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to load key check: %w", err)
+	}
+
+	passwords, err := s.DB.ListPasswords()
+	if err != nil {
+		return fmt.Errorf("failed to list passwords: %w", err)
+	}
+	for _, p := range passwords {
+		if p.Name != "" {
+			if _, err := encryptor.Decrypt(p.Name); err != nil {
+				return ErrWrongPassphrase
+			}
+			break
+		}
+	}
+	return SaveKeyCheck(encryptor)
+}
+
+// CheckPassphrase reports whether the passphrase derives the key of the
+// unlocked session, for confirmations and logins
+func (s *Session) CheckPassphrase(passphrase string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.encryptor == nil {
+		return false
+	}
+
+	key := crypto.DeriveKey(passphrase, s.Config.Salt, crypto.GetKDFParams(s.Config.KDFVersion))
+	defer crypto.WipeMemory(key)
+	return subtle.ConstantTimeCompare(key, s.encryptor.GetKey()) == 1
+}
+
+// Unlocked reports whether the session holds the vault key
+func (s *Session) Unlocked() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.encryptor != nil
+}
+
+// Encryptor returns the encryptor of the unlocked session. Lock wipes it, so
+// code that shares the session with other goroutines, like the agent, uses
+// the Session methods instead.
+func (s *Session) Encryptor() (*crypto.Encryptor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.encryptor == nil {
+		return nil, ErrLocked
+	}
+	return s.encryptor, nil
+}
+
+// withEncryptor runs fn with the encryptor of the unlocked session. Lock
+// waits for fn to return, so the key isn't wiped while it's in use.
+func (s *Session) withEncryptor(fn func(encryptor *crypto.Encryptor) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.encryptor == nil {
+		return ErrLocked
+	}
+	return fn(s.encryptor)
+}
+
+// Key returns a copy of the vault key of the unlocked session
+func (s *Session) Key() ([]byte, error) {
+	var key []byte
+	err := s.withEncryptor(func(encryptor *crypto.Encryptor) error {
+		key = append([]byte(nil), encryptor.GetKey()...)
+		return nil
+	})
+	return key, err
+}
+
+// Reload picks up changes other processes made to the database since it
+// was opened. Sessions that live long, like the agent's, call it before
+// reading or changing entries, as a change to a stale database is refused.
+func (s *Session) Reload() error {
+	return s.DB.Reload()
+}
+
+// Items decrypts every entry in the vault
+func (s *Session) Items() ([]*Item, error) {
+	var items []*Item
+	err := s.withEncryptor(func(encryptor *crypto.Encryptor) error {
+		passwords, err := s.DB.ListPasswords()
+		if err != nil {
+			return fmt.Errorf("failed to list passwords: %w", err)
+		}
+		items, err = DecryptAll(encryptor, passwords)
+		return err
+	})
+	return items, err
+}
+
+// Find resolves a query to a single entry, see the package-level Find
+func (s *Session) Find(query string, mode MatchMode) (*Item, error) {
+	items, err := s.Items()
+	if err != nil {
+		return nil, err
+	}
+	return Find(items, query, mode)
+}
+
+// Add encrypts and stores a new entry, returning its ID
+func (s *Session) Add(item *Item) (int64, error) {
+	var id int64
+	err := s.withEncryptor(func(encryptor *crypto.Encryptor) error {
+		var err error
+		id, err = addItem(s.DB, encryptor, item)
+		return err
+	})
+	return id, err
+}
+
+// Update encrypts an existing entry and replaces the stored one with the
+// same ID
+func (s *Session) Update(item *Item) error {
+	return s.withEncryptor(func(encryptor *crypto.Encryptor) error {
+		_, err := updateItem(s.DB, encryptor, item)
+		return err
+	})
+}
+
+// Delete removes an entry. Like every change it needs the session unlocked.
+func (s *Session) Delete(id int64) error {
+	return s.withEncryptor(func(*crypto.Encryptor) error {
+		if err := s.DB.DeletePassword(id); err != nil {
+			return fmt.Errorf("failed to delete entry %d: %w", id, err)
+		}
+		return nil
+	})
+}
+
+// Lock wipes the vault key once the operations using it are done; the
+// session can be unlocked again
+func (s *Session) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.encryptor != nil {
+		s.encryptor.Wipe()
+		s.encryptor = nil
+	}
+}
+
+// Close locks the session and closes the database
+func (s *Session) Close() error {
+	s.Lock()
+	return s.DB.Close()
+}
+
+// VerifySecondFactor checks a TOTP or backup code against the stored secrets.
+// Accepted TOTP time steps are recorded so a code can't be replayed and
+// backup codes are removed once used. It returns the number of backup codes
+// left when one was used, -1 otherwise.
+func VerifySecondFactor(code string) (int, error) {
+	secret, err := config.LoadTOTPSecret()
+	if err != nil {
+		return -1, fmt.Errorf("failed to load TOTP secret: %w", err)
+	}
+
+	if mfa.LooksLikeBackupCode(code) {
+		return consumeBackupCode(code)
+	}
+
+	lastStep, err := config.LoadTOTPLastStep()
+	if err != nil {
+		return -1, err
+	}
+
+	step, ok := mfa.ValidateTOTPAfter(secret, code, lastStep)
+	if !ok {
+		return -1, fmt.Errorf("invalid or already used TOTP code")
+	}
+
+	return -1, config.SaveTOTPLastStep(step)
+}
+
+// BackupCodes returns the stored backup codes; none stored is an empty set
+func BackupCodes() (*mfa.BackupCodeSet, error) {
+	encoded, err := config.LoadBackupCodes()
+	if errors.Is(err, os.ErrNotExist) {
+		return &mfa.BackupCodeSet{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backup codes: %w", err)
+	}
+	return mfa.DecodeBackupCodes(encoded)
+}
+
+// consumeBackupCode checks a backup code and removes it from the stored set
+func consumeBackupCode(code string) (int, error) {
+	set, err := BackupCodes()
+	if err != nil {
+		return -1, err
+	}
+
+	if !set.Consume(code) {
+		return -1, fmt.Errorf("invalid backup code")
+	}
+
+	encoded, err := mfa.EncodeBackupCodes(set)
+	if err != nil {
+		return -1, err
+	}
+	if err := config.SaveBackupCodes(encoded); err != nil {
+		return -1, err
+	}
+
+	return set.Remaining(), nil
+}
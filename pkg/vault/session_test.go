@@ -0,0 +1,114 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/r2unit/openpasswd/pkg/config"
+	"github.com/r2unit/openpasswd/pkg/crypto"
+	"github.com/r2unit/openpasswd/pkg/database"
+	"github.com/r2unit/openpasswd/pkg/models"
+)
+
+var (
+	testKey  = bytes.Repeat([]byte{0x42}, 32)
+	wrongKey = bytes.Repeat([]byte{0x24}, 32)
+)
+
+// testSession opens a session on an empty database, with the configuration
+// directory in a temporary home
+func testSession(t *testing.T) *Session {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := database.New(filepath.Join(t.TempDir(), "passwords.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Session{Config: &config.Config{}, DB: db}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestUnlockEmptyVault(t *testing.T) {
+	s := testSession(t)
+
+	if err := s.UnlockWithKey(testKey); err != nil {
+		t.Fatalf("first unlock: %v", err)
+	}
+	s.Lock()
+
+	// The first unlock saved a check value, so a wrong key fails even
+	// without entries to check it against
+	if err := s.UnlockWithKey(wrongKey); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong key: err = %v, want ErrWrongPassphrase", err)
+	}
+	if err := s.UnlockWithKey(testKey); err != nil {
+		t.Errorf("right key: %v", err)
+	}
+}
+
+// Vaults from before the check value are checked against their entries
+func TestUnlockLegacyVault(t *testing.T) {
+	s := testSession(t)
+
+	enc, err := crypto.NewEncryptorFromKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addItem(s.DB, enc, &Item{Type: models.TypeLogin, Name: "GitHub"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UnlockWithKey(wrongKey); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong key: err = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := config.LoadKeyCheck(); err == nil {
+		t.Error("a wrong key saved a check value")
+	}
+
+	if err := s.UnlockWithKey(testKey); err != nil {
+		t.Fatalf("right key: %v", err)
+	}
+	if _, err := config.LoadKeyCheck(); err != nil {
+		t.Errorf("no check value after unlocking: %v", err)
+	}
+}
+
+// Locking must not wipe the key under an operation that is using it
+func TestLockWaitsForOperations(t *testing.T) {
+	s := testSession(t)
+	if err := s.UnlockWithKey(testKey); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan struct{})
+	err := s.withEncryptor(func(encryptor *crypto.Encryptor) error {
+		go func() {
+			s.Lock()
+			close(locked)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-locked:
+			t.Error("Lock returned while the key was in use")
+		default:
+		}
+		if !bytes.Equal(encryptor.GetKey(), testKey) {
+			t.Error("key was wiped while in use")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-locked
+	if _, err := s.Items(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Items after Lock: err = %v, want ErrLocked", err)
+	}
+}